@@ -0,0 +1,371 @@
+// /home/krylon/go/src/github.com/blicero/donkey/admin.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 06:48:23 krylon>
+//
+// Administrative commands that work on the database directly.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/blicero/donkey/common"
+	"github.com/blicero/donkey/database"
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/recordtype"
+	"github.com/blicero/krylib"
+)
+
+const defaultQueryLimit = 100
+
+// subcommand picks the subcommand named by the first argument from cmds.
+func subcommand(name string, args []string, cmds map[string]func([]string) error) error {
+	var (
+		run   func([]string) error
+		found bool
+	)
+
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s <subcommand>\n",
+			os.Args[0],
+			name)
+		return errUsage
+	} else if run, found = cmds[args[0]]; !found {
+		fmt.Fprintf(os.Stderr, "Unknown subcommand %s %s\n",
+			name,
+			args[0])
+		return errUsage
+	}
+
+	return run(args[1:])
+} // func subcommand(name string, args []string, cmds map[string]func([]string) error) error
+
+// openDB opens the database in the given base directory.
+func openDB(baseDir string) (*database.Database, error) {
+	if err := setBaseDir(baseDir); err != nil {
+		return nil, err
+	}
+
+	return database.Open(common.DbPath)
+} // func openDB(baseDir string) (*database.Database, error)
+
+// lookupHost finds a Host by its ID or name.
+func lookupHost(db *database.Database, s string) (*model.Host, error) {
+	var (
+		err  error
+		id   int64
+		host *model.Host
+	)
+
+	if id, err = strconv.ParseInt(s, 10, 64); err == nil {
+		host, err = db.HostGetByID(krylib.ID(id))
+	} else {
+		host, err = db.HostGetByName(s)
+	}
+
+	if err != nil {
+		return nil, err
+	} else if host == nil {
+		return nil, fmt.Errorf("Host %s was not found", s)
+	}
+
+	return host, nil
+} // func lookupHost(db *database.Database, s string) (*model.Host, error)
+
+// parseTimeArg parses a point in time given on the command line, either
+// as an RFC3339 timestamp or as a duration, meaning that long ago.
+func parseTimeArg(s string) (time.Time, error) {
+	var (
+		err error
+		t   time.Time
+		d   time.Duration
+	)
+
+	if t, err = time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	} else if d, err = time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	return t, fmt.Errorf("Invalid time %q, expected RFC3339 timestamp or a duration like 24h", s)
+} // func parseTimeArg(s string) (time.Time, error)
+
+func cmdHosts(args []string) error {
+	return subcommand("hosts", args, map[string]func([]string) error{
+		"list":   cmdHostsList,
+		"delete": cmdHostsDelete,
+		"revoke": cmdHostsRevoke,
+	})
+} // func cmdHosts(args []string) error
+
+func cmdHostsList(args []string) error {
+	var (
+		err         error
+		db          *database.Database
+		hosts       []model.Host
+		fs, baseDir = newFlagSet("hosts list")
+	)
+
+	if err = fs.Parse(args); err != nil {
+		return err
+	} else if db, err = openDB(*baseDir); err != nil {
+		return err
+	}
+
+	defer db.Close() // nolint: errcheck
+
+	if hosts, err = db.HostGetAll(); err != nil {
+		return err
+	}
+
+	var w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "ID\tName\tAddress\tOS\tState\tLast Contact")
+	for _, h := range hosts {
+		var contact = "never"
+
+		if !h.LastContact.IsZero() && h.LastContact.Unix() != 0 {
+			contact = h.LastContact.Format(common.TimestampFormat)
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n",
+			h.ID,
+			h.Name,
+			h.Addr,
+			h.OS,
+			h.State,
+			contact)
+	}
+
+	return w.Flush()
+} // func cmdHostsList(args []string) error
+
+func cmdHostsDelete(args []string) error {
+	var (
+		err         error
+		db          *database.Database
+		fs, baseDir = newFlagSet("hosts delete")
+	)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s hosts delete [options] <id|name>...\n",
+			os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	if err = fs.Parse(args); err != nil {
+		return err
+	} else if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	} else if db, err = openDB(*baseDir); err != nil {
+		return err
+	}
+
+	defer db.Close() // nolint: errcheck
+
+	for _, arg := range fs.Args() {
+		var host *model.Host
+
+		if host, err = lookupHost(db, arg); err != nil {
+			return err
+		} else if err = db.HostDelete(host.ID); err != nil {
+			return fmt.Errorf("Cannot delete Host %s (%d): %w",
+				host.Name,
+				host.ID,
+				err)
+		}
+
+		fmt.Printf("Deleted Host %s (%d)\n", host.Name, host.ID)
+	}
+
+	return nil
+} // func cmdHostsDelete(args []string) error
+
+// cmdHostsRevoke clears the tokens of the given Hosts. Their Agents are
+// refused until they register again, and the first Agent to register a
+// Host afterwards claims it, e.g. after the machine was reinstalled and lost
+// its token.
+func cmdHostsRevoke(args []string) error {
+	var (
+		err         error
+		db          *database.Database
+		fs, baseDir = newFlagSet("hosts revoke")
+	)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s hosts revoke [options] <id|name>...\n",
+			os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	if err = fs.Parse(args); err != nil {
+		return err
+	} else if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	} else if db, err = openDB(*baseDir); err != nil {
+		return err
+	}
+
+	defer db.Close() // nolint: errcheck
+
+	for _, arg := range fs.Args() {
+		var host *model.Host
+
+		if host, err = lookupHost(db, arg); err != nil {
+			return err
+		} else if err = db.HostUpdateToken(host, ""); err != nil {
+			return fmt.Errorf("Cannot revoke token of Host %s (%d): %w",
+				host.Name,
+				host.ID,
+				err)
+		}
+
+		fmt.Printf("Revoked token of Host %s (%d)\n", host.Name, host.ID)
+	}
+
+	return nil
+} // func cmdHostsRevoke(args []string) error
+
+func cmdRecords(args []string) error {
+	return subcommand("records", args, map[string]func([]string) error{
+		"query": cmdRecordsQuery,
+	})
+} // func cmdRecords(args []string) error
+
+func cmdRecordsQuery(args []string) error {
+	var (
+		err         error
+		db          *database.Database
+		host        *model.Host
+		rtype       recordtype.ID
+		recs        []model.Record
+		rng         database.RecordRange
+		fs, baseDir = newFlagSet("records query")
+		hostArg     = fs.String("host", "", "The ID or name of the Host")
+		typeArg     = fs.String("type", "", "The type of Records, by name or number")
+		beginArg    = fs.String("begin", "", "The earliest Record, as RFC3339 timestamp or a duration (e.g. 24h ago)")
+		endArg      = fs.String("end", "", "The latest Record, as RFC3339 timestamp or a duration (e.g. 1h ago)")
+		limit       = fs.Int64("limit", defaultQueryLimit, "The maximum number of Records to display")
+	)
+
+	if err = fs.Parse(args); err != nil {
+		return err
+	} else if fs.NArg() != 0 || (*hostArg == "" && *typeArg == "") {
+		fmt.Fprintln(os.Stderr, "At least one of -host and -type is required")
+		fs.Usage()
+		return errUsage
+	} else if *limit < 1 {
+		return fmt.Errorf("Invalid limit %d", *limit)
+	}
+
+	rng.Limit = *limit
+
+	if *beginArg != "" {
+		if rng.Begin, err = parseTimeArg(*beginArg); err != nil {
+			return err
+		}
+	}
+
+	if *endArg != "" {
+		if rng.End, err = parseTimeArg(*endArg); err != nil {
+			return err
+		}
+	}
+
+	if !rng.Begin.IsZero() && !rng.End.IsZero() && rng.Begin.After(rng.End) {
+		return errors.New("The beginning of the period is after its end")
+	}
+
+	if *typeArg != "" {
+		if rtype, err = recordtype.Parse(*typeArg); err != nil {
+			return err
+		}
+	}
+
+	if db, err = openDB(*baseDir); err != nil {
+		return err
+	}
+
+	defer db.Close() // nolint: errcheck
+
+	if *hostArg != "" {
+		if host, err = lookupHost(db, *hostArg); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case host != nil && *typeArg != "":
+		recs, err = db.RecordGetByHostTypeRange(host, rtype, rng)
+	case host != nil:
+		recs, err = db.RecordGetByHostRange(host, rng)
+	default:
+		recs, err = db.RecordGetByTypeRange(rtype, rng)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "ID\tHost\tTimestamp\tType\tPayload")
+	for _, r := range recs {
+		fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\n",
+			r.ID,
+			r.HostID,
+			r.Timestamp.Format(common.TimestampFormat),
+			r.Source,
+			r.Payload)
+	}
+
+	if err = w.Flush(); err != nil {
+		return err
+	} else if int64(len(recs)) == *limit {
+		fmt.Fprintf(os.Stderr, "Output was limited to %d Records, use -limit or -begin to see more.\n",
+			*limit)
+	}
+
+	return nil
+} // func cmdRecordsQuery(args []string) error
+
+func cmdDB(args []string) error {
+	return subcommand("db", args, map[string]func([]string) error{
+		"maintain": cmdDBMaintain,
+	})
+} // func cmdDB(args []string) error
+
+func cmdDBMaintain(args []string) error {
+	var (
+		err         error
+		db          *database.Database
+		fs, baseDir = newFlagSet("db maintain")
+	)
+
+	if err = fs.Parse(args); err != nil {
+		return err
+	} else if db, err = openDB(*baseDir); err != nil {
+		return err
+	}
+
+	defer db.Close() // nolint: errcheck
+
+	var begin = time.Now()
+
+	if err = db.PerformMaintenance(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Database maintenance finished after %s\n",
+		time.Since(begin).Round(time.Millisecond))
+	return nil
+} // func cmdDBMaintain(args []string) error
@@ -7,7 +7,13 @@
 package agent
 
 import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/blicero/donkey/model"
 )
@@ -16,13 +22,10 @@ func TestLoadProbe(t *testing.T) {
 	var (
 		lp  Probe
 		err error
-		q   chan model.Record
 		rec *model.Record
 	)
 
-	q = make(chan model.Record, 2)
-
-	if lp, err = CreateLoadProbe(q); err != nil {
+	if lp, err = CreateLoadProbe(); err != nil {
 		t.Fatalf("Failed to create LoadProbe: %s",
 			err.Error())
 	} else if rec, err = lp.Collect(); err != nil {
@@ -34,15 +37,12 @@ func TestLoadProbe(t *testing.T) {
 
 func TestSensorsProbe(t *testing.T) {
 	var (
-		q   chan model.Record
 		sp  Probe
 		err error
 		rec *model.Record
 	)
 
-	q = make(chan model.Record, 2)
-
-	if sp, err = CreateSensorsProbe(q); err != nil {
+	if sp, err = CreateSensorsProbe(); err != nil {
 		t.Fatalf("Failed to create SensorsProbe: %s",
 			err.Error())
 	} else if rec, err = sp.Collect(); err != nil {
@@ -51,3 +51,420 @@ func TestSensorsProbe(t *testing.T) {
 		t.Error("Collect() did not return an error, but the record was nil")
 	}
 } // func TestSensorsProbe(t *testing.T)
+
+func TestCPUFreqProbe(t *testing.T) {
+	var (
+		err  error
+		cp   *CPUFreqProbe
+		rec  *model.Record
+		val  any
+		freq *model.CPUFreq
+		ok   bool
+	)
+
+	if cp, err = CreateCPUFreqProbe(); err != nil {
+		t.Fatalf("Failed to create CPUFreqProbe: %s",
+			err.Error())
+	}
+
+	cp.root = "testdata/fakeroot"
+
+	if rec, err = cp.Collect(); err != nil {
+		t.Fatalf("Failed to collect CPU frequencies: %s", err.Error())
+	} else if val, err = rec.Decode(); err != nil {
+		t.Fatalf("Failed to decode Record: %s\n%s", err.Error(), rec.Payload)
+	} else if freq, ok = val.(*model.CPUFreq); !ok {
+		t.Fatalf("Decoding Record yielded %T, expected *model.CPUFreq", val)
+	} else if len(freq.Cores) != 3 {
+		t.Fatalf("Expected 3 CPU cores, found %d", len(freq.Cores))
+	}
+
+	for i, c := range freq.Cores {
+		var expect = int64(1400000 + i*100000)
+
+		if c.CPU != i {
+			t.Errorf("Core #%d has CPU number %d", i, c.CPU)
+		} else if c.Cur != expect {
+			t.Errorf("Core #%d has frequency %d, expected %d",
+				i,
+				c.Cur,
+				expect)
+		} else if c.Min != 800000 || c.Max != 3600000 {
+			t.Errorf("Core #%d has unexpected range %d - %d",
+				i,
+				c.Min,
+				c.Max)
+		}
+	}
+} // func TestCPUFreqProbe(t *testing.T)
+
+func TestRAMProbe(t *testing.T) {
+	var (
+		err error
+		rp  *RAMProbe
+		rec *model.Record
+		val any
+		ram *model.RAM
+		ok  bool
+	)
+
+	if rp, err = CreateRAMProbe(); err != nil {
+		t.Fatalf("Failed to create RAMProbe: %s",
+			err.Error())
+	}
+
+	rp.root = "testdata/fakeroot"
+
+	if rec, err = rp.Collect(); err != nil {
+		t.Fatalf("Failed to collect memory usage: %s", err.Error())
+	} else if val, err = rec.Decode(); err != nil {
+		t.Fatalf("Failed to decode Record: %s\n%s", err.Error(), rec.Payload)
+	} else if ram, ok = val.(*model.RAM); !ok {
+		t.Fatalf("Decoding Record yielded %T, expected *model.RAM", val)
+	} else if ram.Total != 16318480*1024 {
+		t.Errorf("Unexpected total memory: %d", ram.Total)
+	} else if ram.Available != 10127668*1024 {
+		t.Errorf("Unexpected available memory: %d", ram.Available)
+	} else if ram.SwapUsed() != (8388604-8382204)*1024 {
+		t.Errorf("Unexpected swap usage: %d", ram.SwapUsed())
+	}
+} // func TestRAMProbe(t *testing.T)
+
+func TestFilesystemFilter(t *testing.T) {
+	type testCase struct {
+		name   string
+		filter *fsFilter
+		expect []string
+	}
+
+	var tests = []testCase{
+		{
+			name:   "default",
+			expect: []string{"/run", "/", "/boot/efi", "/home", "/media/USB Stick", "/var/lib/docker/overlay2"},
+		},
+		{
+			name: "exclude",
+			filter: &fsFilter{
+				ExcludeFS:     []string{"tmpfs", "vfat"},
+				ExcludeMounts: []string{"/var/lib/docker/*"},
+			},
+			expect: []string{"/", "/home"},
+		},
+		{
+			name: "include",
+			filter: &fsFilter{
+				IncludeFS:     []string{"ext4", "squashfs"},
+				IncludeMounts: []string{"/", "/snap/*/*"},
+			},
+			expect: []string{"/", "/snap/core22/1380"},
+		},
+	}
+
+	for _, c := range tests {
+		var (
+			err    error
+			fp     *FilesystemProbe
+			mounts []model.MountUsage
+		)
+
+		if fp, err = CreateFilesystemProbe(c.filter); err != nil {
+			t.Fatalf("Failed to create FilesystemProbe: %s",
+				err.Error())
+		}
+
+		fp.root = "testdata/fakeroot"
+
+		if mounts, err = fp.mounts(); err != nil {
+			t.Errorf("[%s] Failed to read mounts: %s", c.name, err.Error())
+			continue
+		} else if len(mounts) != len(c.expect) {
+			t.Errorf("[%s] Expected %d mounts, got %d: %v",
+				c.name,
+				len(c.expect),
+				len(mounts),
+				mounts)
+			continue
+		}
+
+		for i, m := range mounts {
+			if m.Mountpoint != c.expect[i] {
+				t.Errorf("[%s] Mount #%d should be %q, but is %q",
+					c.name,
+					i,
+					c.expect[i],
+					m.Mountpoint)
+			}
+		}
+	}
+} // func TestFilesystemFilter(t *testing.T)
+
+func TestFilesystemProbe(t *testing.T) {
+	var (
+		err error
+		fp  *FilesystemProbe
+		rec *model.Record
+	)
+
+	if fp, err = CreateFilesystemProbe(nil); err != nil {
+		t.Fatalf("Failed to create FilesystemProbe: %s",
+			err.Error())
+	} else if rec, err = fp.Collect(); err != nil {
+		t.Errorf("Failed to collect file system usage: %s", err.Error())
+	} else if err = rec.Validate(); err != nil {
+		t.Errorf("Record is not valid: %s\n%s", err.Error(), rec.Payload)
+	}
+} // func TestFilesystemProbe(t *testing.T)
+
+// writeNetDev writes a fake /proc/net/dev below root. Each interface is
+// given as its name followed by rx bytes and tx bytes.
+func writeNetDev(t *testing.T, root string, ifaces ...any) {
+	var sb strings.Builder
+
+	sb.WriteString("Inter-|   Receive                                                |  Transmit\n")
+	sb.WriteString(" face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed\n")
+
+	for i := 0; i+2 < len(ifaces); i += 3 {
+		fmt.Fprintf(&sb, "%6s: %d 10 0 1 0 0 0 0 %d 20 0 0 0 0 0 0\n",
+			ifaces[i],
+			ifaces[i+1],
+			ifaces[i+2])
+	}
+
+	if err := os.WriteFile(filepath.Join(root, netdevPath), []byte(sb.String()), 0644); err != nil {
+		t.Fatalf("Cannot write fake %s: %s", netdevPath, err.Error())
+	}
+} // func writeNetDev(t *testing.T, root string, ifaces ...any)
+
+func TestNetDevProbe(t *testing.T) {
+	var (
+		err   error
+		np    *NetDevProbe
+		rec   *model.Record
+		val   any
+		net   *model.Network
+		ok    bool
+		root  = t.TempDir()
+		clock = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	)
+
+	if err = os.MkdirAll(filepath.Join(root, "proc", "net"), 0755); err != nil {
+		t.Fatalf("Cannot create fake procfs: %s", err.Error())
+	} else if np, err = CreateNetDevProbe(); err != nil {
+		t.Fatalf("Failed to create NetDevProbe: %s", err.Error())
+	}
+
+	np.root = root
+	np.now = func() time.Time { return clock }
+
+	// The wlan0 counters are about to wrap around at 32 bits. The
+	// counters of eth0, which are 64 bits wide, and those of eth1, which
+	// are nowhere near 2^32, are about to be reset.
+	writeNetDev(t, root,
+		"lo", 1000, 1000,
+		"eth0", uint64(math.MaxUint64-999), 5000,
+		"eth1", 500000, 500000,
+		"wlan0", uint64(math.MaxUint32-99), 0)
+
+	if _, err = np.Collect(); err != errNoBaseline {
+		t.Fatalf("First call to Collect should return errNoBaseline, not %v", err)
+	}
+
+	clock = clock.Add(time.Second * 10)
+	writeNetDev(t, root,
+		"lo", 11000, 21000,
+		"eth0", 9000, 5000,
+		"eth1", 100, 500100,
+		"wlan0", 400, 100,
+		"tun0", 0, 0)
+
+	if rec, err = np.Collect(); err != nil {
+		t.Fatalf("Failed to collect network throughput: %s", err.Error())
+	} else if val, err = rec.Decode(); err != nil {
+		t.Fatalf("Failed to decode Record: %s\n%s", err.Error(), rec.Payload)
+	} else if net, ok = val.(*model.Network); !ok {
+		t.Fatalf("Decoding Record yielded %T, expected *model.Network", val)
+	} else if net.Interval != 10 {
+		t.Errorf("Unexpected interval: %f", net.Interval)
+	}
+
+	// eth0 and eth1 are skipped until they have a new baseline.
+	var expect = []model.IfaceRates{
+		{Name: "lo", RxBytes: 1000, TxBytes: 2000},
+		{Name: "wlan0", RxBytes: 50, TxBytes: 10},
+	}
+
+	if len(net.Interfaces) != len(expect) {
+		t.Fatalf("Expected %d interfaces, got %d: %v",
+			len(expect),
+			len(net.Interfaces),
+			net.Interfaces)
+	}
+
+	for i, e := range expect {
+		var iface = net.Interfaces[i]
+
+		if iface.Name != e.Name || iface.RxBytes != e.RxBytes || iface.TxBytes != e.TxBytes {
+			t.Errorf("Interface #%d should be %s (rx %.0f, tx %.0f), but is %s (rx %.0f, tx %.0f)",
+				i,
+				e.Name,
+				e.RxBytes,
+				e.TxBytes,
+				iface.Name,
+				iface.RxBytes,
+				iface.TxBytes)
+		} else if iface.RxPackets != 0 || iface.RxDrops != 0 {
+			t.Errorf("Interface %s should have no packet rate, got %f packets, %f drops",
+				iface.Name,
+				iface.RxPackets,
+				iface.RxDrops)
+		}
+	}
+
+	// wlan0 is gone, eth0 and tun0 now have a baseline.
+	clock = clock.Add(time.Second * 5)
+	writeNetDev(t, root,
+		"lo", 11000, 21000,
+		"eth0", 9000, 5000,
+		"tun0", 500, 0)
+
+	if rec, err = np.Collect(); err != nil {
+		t.Fatalf("Failed to collect network throughput: %s", err.Error())
+	} else if val, err = rec.Decode(); err != nil {
+		t.Fatalf("Failed to decode Record: %s\n%s", err.Error(), rec.Payload)
+	} else if net = val.(*model.Network); len(net.Interfaces) != 3 {
+		t.Fatalf("Expected 3 interfaces, got %d: %v",
+			len(net.Interfaces),
+			net.Interfaces)
+	} else if net.Interfaces[2].Name != "tun0" || net.Interfaces[2].RxBytes != 100 {
+		t.Errorf("Unexpected rates for tun0: %v", net.Interfaces[2])
+	}
+} // func TestNetDevProbe(t *testing.T)
+
+func TestCounterDelta(t *testing.T) {
+	type testCase struct {
+		prev, cur uint64
+		delta     uint64
+		ok        bool
+	}
+
+	var cases = []testCase{
+		{prev: 100, cur: 300, delta: 200, ok: true},
+		{prev: 100, cur: 100, delta: 0, ok: true},
+		{prev: math.MaxUint32 - 9, cur: 10, delta: 20, ok: true},
+		{prev: math.MaxUint32 / 2, cur: 10, ok: false},
+		{prev: math.MaxUint64 - 9, cur: 10, ok: false},
+		{prev: math.MaxUint32 + 1000, cur: 0, ok: false},
+	}
+
+	for i, c := range cases {
+		if delta, ok := counterDelta(c.prev, c.cur); ok != c.ok || delta != c.delta {
+			t.Errorf("Test case #%d: counterDelta(%d, %d) = (%d, %t), expected (%d, %t)",
+				i,
+				c.prev,
+				c.cur,
+				delta,
+				ok,
+				c.delta,
+				c.ok)
+		}
+	}
+} // func TestCounterDelta(t *testing.T)
+
+func TestParseSensors(t *testing.T) {
+	type testCase struct {
+		host  string
+		count int
+		kind  string
+		label string
+		value float64
+		crit  float64
+	}
+
+	var tests = []testCase{
+		{host: "dixie", count: 1, kind: model.SensorTemp, label: "temp1", value: 48.692},
+		{host: "julie", count: 69, kind: model.SensorFan, label: "Exhaust", value: 3207},
+		{host: "mondauge", count: 40, kind: model.SensorTemp, label: "Package id 0", value: 52, crit: 100},
+		{host: "riviera", count: 4, kind: model.SensorTemp, label: "temp1", value: 53, crit: 85},
+		{host: "schwarzgeraet", count: 40, kind: model.SensorTemp, label: "Tctl", value: 61.75},
+	}
+
+	for _, c := range tests {
+		var (
+			err     error
+			buf     []byte
+			sensors *model.Sensors
+			r       *model.SensorReading
+			path    = filepath.Join("testdata", "sensors."+c.host)
+		)
+
+		if buf, err = os.ReadFile(path); err != nil {
+			t.Fatalf("Cannot read %s: %s", path, err.Error())
+		} else if sensors, err = model.ParseSensorsJSON(buf); err != nil {
+			t.Errorf("[%s] Cannot parse sensors output: %s", c.host, err.Error())
+			continue
+		} else if len(sensors.Readings) != c.count {
+			t.Errorf("[%s] Expected %d readings, got %d",
+				c.host,
+				c.count,
+				len(sensors.Readings))
+		}
+
+		if r = sensors.Find(c.kind, c.label); r == nil {
+			t.Errorf("[%s] Did not find %s reading %q", c.host, c.kind, c.label)
+		} else if r.Value != c.value {
+			t.Errorf("[%s] Reading %q should be %f, but is %f",
+				c.host,
+				c.label,
+				c.value,
+				r.Value)
+		} else if c.crit != 0 && (r.Crit == nil || *r.Crit != c.crit) {
+			t.Errorf("[%s] Reading %q should have a critical limit of %f, got %v",
+				c.host,
+				c.label,
+				c.crit,
+				r.Crit)
+		}
+	}
+} // func TestParseSensors(t *testing.T)
+
+func TestSensorsHwmon(t *testing.T) {
+	var (
+		err     error
+		sp      *SensorsProbe
+		sensors *model.Sensors
+		r       *model.SensorReading
+	)
+
+	if sp, err = CreateSensorsProbe(); err != nil {
+		t.Fatalf("Failed to create SensorsProbe: %s",
+			err.Error())
+	}
+
+	sp.root = "testdata/fakeroot"
+
+	if sensors, err = sp.readHwmon(); err != nil {
+		t.Fatalf("Failed to read hwmon: %s", err.Error())
+	} else if len(sensors.Readings) != 4 {
+		t.Fatalf("Expected 4 readings, got %d: %v",
+			len(sensors.Readings),
+			sensors.Readings)
+	}
+
+	if r = sensors.Find(model.SensorTemp, "Package id 0"); r == nil {
+		t.Error("Did not find CPU package temperature")
+	} else if r.Value != 52 || r.High == nil || *r.High != 100 || r.Adapter != "platform" {
+		t.Errorf("Unexpected CPU package temperature: %+v", r)
+	}
+
+	if r = sensors.Find(model.SensorVoltage, "in0"); r == nil {
+		t.Error("Did not find voltage in0")
+	} else if r.Value != 0.464 || r.High == nil || *r.High != 1.744 {
+		t.Errorf("Unexpected voltage: %+v", r)
+	}
+
+	if r = sensors.Find(model.SensorFan, "fan1"); r == nil {
+		t.Error("Did not find fan1")
+	} else if r.Value != 1180 {
+		t.Errorf("Unexpected fan speed: %+v", r)
+	}
+} // func TestSensorsHwmon(t *testing.T)
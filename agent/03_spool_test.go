@@ -0,0 +1,182 @@
+// /home/krylon/go/src/github.com/blicero/donkey/agent/03_spool_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 14:31:40 krylon>
+
+package agent
+
+import (
+	"fmt"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/blicero/donkey/common"
+	"github.com/blicero/donkey/logdomain"
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/recordtype"
+)
+
+func makeTestRecord(i int) *model.Record {
+	return &model.Record{
+		HostID:    1,
+		Timestamp: time.Unix(1700000000+int64(i), 0),
+		Source:    recordtype.LoadAvg,
+		Payload:   fmt.Sprintf("[1.%02d, 0.5, 0.25]", i),
+	}
+} // func makeTestRecord(i int) *model.Record
+
+func TestSpoolOrder(t *testing.T) {
+	const cnt = 10
+	var (
+		err error
+		sp  *spool
+		lg  *log.Logger
+		dir = t.TempDir()
+	)
+
+	if lg, err = common.GetLogger(logdomain.Agent); err != nil {
+		t.Fatalf("Cannot get Logger: %s", err.Error())
+	} else if sp, err = openSpool(dir, 0, lg); err != nil {
+		t.Fatalf("Cannot open spool in %s: %s", dir, err.Error())
+	}
+
+	for i := 0; i < cnt; i++ {
+		if err = sp.Append(makeTestRecord(i)); err != nil {
+			t.Fatalf("Cannot append Record #%d: %s", i, err.Error())
+		}
+	}
+
+	// Re-opening the spool must yield the same Records in the same order.
+	if sp, err = openSpool(dir, 0, lg); err != nil {
+		t.Fatalf("Cannot re-open spool in %s: %s", dir, err.Error())
+	} else if sp.Len() != cnt {
+		t.Fatalf("Unexpected number of spooled Records: %d (expected %d)",
+			sp.Len(),
+			cnt)
+	}
+
+	for i := 0; i < cnt; i += 3 {
+		var recs, names = sp.Peek(3)
+
+		if len(recs) == 0 {
+			t.Fatalf("Spool is empty after %d Records", i)
+		}
+
+		for j, rec := range recs {
+			if expect := makeTestRecord(i + j); rec.Payload != expect.Payload {
+				t.Errorf("Record #%d has unexpected payload %q (expected %q)",
+					i+j,
+					rec.Payload,
+					expect.Payload)
+			}
+		}
+
+		sp.Pop(names)
+	}
+
+	if sp.Len() != 0 {
+		t.Errorf("Spool should be empty, but has %d Records", sp.Len())
+	}
+} // func TestSpoolOrder(t *testing.T)
+
+func TestSpoolEviction(t *testing.T) {
+	const (
+		cnt  = 20
+		keep = 5
+	)
+	var (
+		err  error
+		sp   *spool
+		lg   *log.Logger
+		size int64
+		dir  = t.TempDir()
+	)
+
+	if lg, err = common.GetLogger(logdomain.Agent); err != nil {
+		t.Fatalf("Cannot get Logger: %s", err.Error())
+	}
+
+	// All test Records serialize to the same number of bytes, so we can
+	// size the spool to hold exactly <keep> of them.
+	if sp, err = openSpool(t.TempDir(), 0, lg); err != nil {
+		t.Fatalf("Cannot open spool: %s", err.Error())
+	} else if err = sp.Append(makeTestRecord(0)); err != nil {
+		t.Fatalf("Cannot append Record: %s", err.Error())
+	}
+
+	size = sp.size * keep
+
+	if sp, err = openSpool(dir, size, lg); err != nil {
+		t.Fatalf("Cannot open spool in %s: %s", dir, err.Error())
+	}
+
+	for i := 0; i < cnt; i++ {
+		if err = sp.Append(makeTestRecord(i)); err != nil {
+			t.Fatalf("Cannot append Record #%d: %s", i, err.Error())
+		}
+	}
+
+	if sp.Len() != keep {
+		t.Fatalf("Spool should hold %d Records, but has %d",
+			keep,
+			sp.Len())
+	} else if recs, _ := sp.Peek(1); recs[0].Payload != makeTestRecord(cnt-keep).Payload {
+		t.Errorf("Oldest Record in spool has unexpected payload %q",
+			recs[0].Payload)
+	}
+} // func TestSpoolEviction(t *testing.T)
+
+func TestSpoolPopEvicted(t *testing.T) {
+	const keep = 3
+	var (
+		err   error
+		sp    *spool
+		lg    *log.Logger
+		size  int64
+		recs  []model.Record
+		names []string
+	)
+
+	if lg, err = common.GetLogger(logdomain.Agent); err != nil {
+		t.Fatalf("Cannot get Logger: %s", err.Error())
+	} else if sp, err = openSpool(t.TempDir(), 0, lg); err != nil {
+		t.Fatalf("Cannot open spool: %s", err.Error())
+	} else if err = sp.Append(makeTestRecord(0)); err != nil {
+		t.Fatalf("Cannot append Record: %s", err.Error())
+	}
+
+	size = sp.size * keep
+
+	if sp, err = openSpool(t.TempDir(), size, lg); err != nil {
+		t.Fatalf("Cannot open spool: %s", err.Error())
+	}
+
+	for i := 0; i < keep; i++ {
+		if err = sp.Append(makeTestRecord(i)); err != nil {
+			t.Fatalf("Cannot append Record #%d: %s", i, err.Error())
+		}
+	}
+
+	recs, names = sp.Peek(2)
+
+	// While the first two Records are being delivered, two more are
+	// spooled, evicting them.
+	for i := keep; i < keep+2; i++ {
+		if err = sp.Append(makeTestRecord(i)); err != nil {
+			t.Fatalf("Cannot append Record #%d: %s", i, err.Error())
+		}
+	}
+
+	sp.Pop(names)
+
+	if len(recs) != 2 {
+		t.Fatalf("Peek returned %d Records, expected 2", len(recs))
+	} else if sp.Len() != keep {
+		t.Fatalf("Pop removed Records that were not delivered, %d left", sp.Len())
+	} else if recs, _ = sp.Peek(keep); recs[0].Payload != makeTestRecord(2).Payload {
+		t.Errorf("Oldest Record in spool has unexpected payload %q",
+			recs[0].Payload)
+	}
+} // func TestSpoolPopEvicted(t *testing.T)
@@ -0,0 +1,113 @@
+// /home/krylon/go/src/github.com/blicero/donkey/agent/04_agent_tls_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 06:15:50 krylon>
+
+package agent
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/blicero/donkey/common/testcert"
+)
+
+func TestAgentTLS(t *testing.T) {
+	var (
+		err  error
+		b    *testcert.Bundle
+		pem  []byte
+		cert tls.Certificate
+		ts   *httptest.Server
+	)
+
+	if b, err = testcert.Generate(t.TempDir(), "abobo"); err != nil {
+		t.Fatalf("Cannot generate certificates: %s", err.Error())
+	} else if pem, err = os.ReadFile(b.CA); err != nil {
+		t.Fatalf("Cannot read CA certificate: %s", err.Error())
+	} else if cert, err = tls.LoadX509KeyPair(b.Cert, b.Key); err != nil {
+		t.Fatalf("Cannot load server certificate: %s", err.Error())
+	}
+
+	// The Server echoes the name the client certificate was issued to.
+	ts = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.TLS.PeerCertificates[0].Subject.CommonName) // nolint: errcheck
+	}))
+	ts.TLS = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    x509.NewCertPool(),
+	}
+	ts.TLS.ClientCAs.AppendCertsFromPEM(pem)
+	ts.StartTLS()
+	defer ts.Close()
+
+	type testCase struct {
+		cfg         *tlsConfig
+		expectError bool
+		expectFail  bool
+	}
+
+	var (
+		paths = b.Clients["abobo"]
+		cases = []testCase{
+			{cfg: &tlsConfig{CACert: b.CA, Cert: paths[0], Key: paths[1]}},
+			{cfg: &tlsConfig{CACert: b.CA}, expectFail: true},
+			{cfg: &tlsConfig{Cert: paths[0], Key: paths[1]}, expectFail: true},
+			{cfg: &tlsConfig{CACert: b.CA, Cert: paths[0]}, expectError: true},
+			{cfg: &tlsConfig{CACert: paths[1]}, expectError: true},
+		}
+	)
+
+	for i, c := range cases {
+		var (
+			res  *http.Response
+			body []byte
+			ag   = &Agent{
+				server: strings.TrimPrefix(ts.URL, "https://"),
+				cfg:    config{TLS: c.cfg},
+			}
+		)
+
+		if err = ag.setupClient(); err != nil {
+			if !c.expectError {
+				t.Errorf("Test case #%d: Cannot set up client: %s", i, err.Error())
+			}
+			continue
+		} else if c.expectError {
+			t.Errorf("Test case #%d: Expected an error setting up the client", i)
+			continue
+		} else if !strings.HasPrefix(ag.url("/ws/report"), "https://") {
+			t.Errorf("Test case #%d: URL does not use HTTPS: %s",
+				i,
+				ag.url("/ws/report"))
+		}
+
+		if res, err = ag.client.Get(ag.url("/")); err != nil {
+			if !c.expectFail {
+				t.Errorf("Test case #%d: Request failed: %s", i, err.Error())
+			}
+			continue
+		}
+
+		body, err = io.ReadAll(res.Body)
+		res.Body.Close() // nolint: errcheck
+
+		if c.expectFail {
+			t.Errorf("Test case #%d: Request should have failed", i)
+		} else if err != nil {
+			t.Errorf("Test case #%d: Cannot read response: %s", i, err.Error())
+		} else if string(body) != "abobo" {
+			t.Errorf("Test case #%d: Server saw client certificate for %q",
+				i,
+				body)
+		}
+	}
+} // func TestAgentTLS(t *testing.T)
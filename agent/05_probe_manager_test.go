@@ -0,0 +1,269 @@
+// /home/krylon/go/src/github.com/blicero/donkey/agent/05_probe_manager_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 09:31:55 krylon>
+
+package agent
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/recordtype"
+)
+
+// testProbe is a Probe whose Collect calls a function supplied by the test.
+type testProbe struct {
+	name    string
+	collect func() error
+}
+
+func (p *testProbe) Collect() (*model.Record, error) {
+	if err := p.collect(); err != nil {
+		return nil, err
+	}
+
+	return &model.Record{
+		Timestamp: time.Now(),
+		Source:    recordtype.LoadAvg,
+		Payload:   p.name,
+	}, nil
+} // func (p *testProbe) Collect() (*model.Record, error)
+
+func TestProbeManager(t *testing.T) {
+	var (
+		err     error
+		m       *probeManager
+		created atomic.Int64
+		panicky atomic.Bool
+		factory = func(name string, collect func() error) probeFactory {
+			return func(_ *config) (Probe, error) {
+				return &testProbe{name: name, collect: collect}, nil
+			}
+		}
+		q   = make(chan model.Record, 16)
+		cfg = &config{
+			Probes: map[string]int{
+				"test_ok":      1,
+				"test_panic":   1,
+				"test_failing": 1,
+				"test_broken":  1,
+				"no_such_type": 1,
+			},
+		}
+	)
+
+	probeTypes["test_ok"] = factory("test_ok", func() error { return nil })
+	// Panics the first time it is called, works after being restarted.
+	probeTypes["test_panic"] = factory("test_panic", func() error {
+		if panicky.CompareAndSwap(false, true) {
+			panic("test_panic")
+		}
+		return nil
+	})
+	probeTypes["test_failing"] = factory("test_failing", func() error {
+		return errors.New("test_failing")
+	})
+	// Cannot be created the first time.
+	probeTypes["test_broken"] = func(c *config) (Probe, error) {
+		if created.Add(1) == 1 {
+			return nil, errors.New("test_broken")
+		}
+		return factory("test_broken", func() error { return nil })(c)
+	}
+
+	defer func() {
+		delete(probeTypes, "test_ok")
+		delete(probeTypes, "test_panic")
+		delete(probeTypes, "test_failing")
+		delete(probeTypes, "test_broken")
+	}()
+
+	if m, err = newProbeManager(q, cfg); err != nil {
+		t.Fatalf("Cannot create probe manager: %s", err.Error())
+	} else if len(m.probes) != 4 {
+		t.Fatalf("Expected 4 Probes, got %d", len(m.probes))
+	} else if m.probes[0].interval != time.Second {
+		t.Errorf("Unexpected interval for Probe %s: %s",
+			m.probes[0].name,
+			m.probes[0].interval)
+	}
+
+	for _, h := range m.probes {
+		h.interval = time.Millisecond * 10
+	}
+
+	m.backoffMin = time.Millisecond * 10
+	m.backoffMax = time.Millisecond * 40
+	m.healthIntv = time.Millisecond * 100
+
+	m.start()
+
+	var (
+		seen    = make(map[string]int)
+		health  *model.ProbeHealth
+		timeout = time.After(time.Second * 5)
+	)
+
+	for health == nil || seen["test_ok"] == 0 || seen["test_panic"] == 0 || seen["test_broken"] == 0 {
+		select {
+		case <-timeout:
+			m.stop()
+			t.Fatalf("Did not receive Records from all Probes: %v", seen)
+		case rec := <-q:
+			if rec.Source != recordtype.ProbeHealth {
+				seen[rec.Payload]++
+				continue
+			}
+
+			var v any
+			if v, err = rec.Decode(); err != nil {
+				t.Fatalf("Cannot decode ProbeHealth Record: %s", err.Error())
+			}
+			health = v.(*model.ProbeHealth)
+		}
+	}
+
+	m.stop()
+
+	if seen["test_failing"] != 0 {
+		t.Errorf("Failing Probe delivered %d Records", seen["test_failing"])
+	}
+
+	var status = make(map[string]model.ProbeStatus)
+	for _, s := range m.health().Probes {
+		status[s.Name] = s
+	}
+
+	if s := status["test_ok"]; s.Restarts != 0 || s.Errors != 0 {
+		t.Errorf("Healthy Probe was restarted %d times, with %d errors",
+			s.Restarts,
+			s.Errors)
+	}
+
+	for _, name := range []string{"test_panic", "test_failing", "test_broken"} {
+		if s := status[name]; s.Restarts == 0 || s.LastError == "" {
+			t.Errorf("Probe %s was not restarted: %#v", name, s)
+		}
+	}
+
+	if s := status["test_failing"]; s.Errors < probeMaxErrors {
+		t.Errorf("Failing Probe reported only %d errors", s.Errors)
+	}
+
+	for name, s := range status {
+		if s.Running {
+			t.Errorf("Probe %s is still running after stop", name)
+		}
+	}
+
+	var samples = health.Samples()
+	if len(samples) != len(m.probes)*3 {
+		t.Errorf("Expected %d Samples from ProbeHealth, got %d",
+			len(m.probes)*3,
+			len(samples))
+	}
+} // func TestProbeManager(t *testing.T)
+
+func TestProbeManagerUpdate(t *testing.T) {
+	var (
+		err     error
+		m       *probeManager
+		created = make(map[string]int)
+		lock    sync.Mutex
+		factory = func(name string) probeFactory {
+			return func(_ *config) (Probe, error) {
+				lock.Lock()
+				created[name]++
+				lock.Unlock()
+				return &testProbe{name: name, collect: func() error { return nil }}, nil
+			}
+		}
+		q   = make(chan model.Record, 16)
+		cfg = &config{Probes: map[string]int{"test_a": 1, "test_b": 1}}
+	)
+
+	for _, name := range []string{"test_a", "test_b", "test_c"} {
+		probeTypes[name] = factory(name)
+	}
+
+	defer func() {
+		delete(probeTypes, "test_a")
+		delete(probeTypes, "test_b")
+		delete(probeTypes, "test_c")
+	}()
+
+	if m, err = newProbeManager(q, cfg); err != nil {
+		t.Fatalf("Cannot create probe manager: %s", err.Error())
+	}
+
+	// Drain the queue, so the Probes never block.
+	var drained = make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-drained:
+				return
+			case <-q:
+			}
+		}
+	}()
+	defer close(drained)
+
+	m.start()
+	defer m.stop()
+
+	var waitRunning = func(names ...string) {
+		var deadline = time.Now().Add(time.Second * 5)
+
+	CHECK:
+		for time.Now().Before(deadline) {
+			var running = make(map[string]bool)
+
+			for _, s := range m.health().Probes {
+				running[s.Name] = s.Running
+			}
+
+			if len(running) != len(names) {
+				t.Fatalf("Expected Probes %v, got %v", names, running)
+			}
+
+			for _, name := range names {
+				if !running[name] {
+					time.Sleep(time.Millisecond * 10)
+					continue CHECK
+				}
+			}
+
+			return
+		}
+
+		t.Fatalf("Probes %v did not start in time", names)
+	}
+
+	waitRunning("test_a", "test_b")
+
+	// test_a keeps running, test_b gets a new interval, test_c is added.
+	m.update(&config{Probes: map[string]int{"test_a": 1, "test_b": 2, "test_c": 1}})
+	waitRunning("test_a", "test_b", "test_c")
+
+	// test_b is dropped.
+	m.update(&config{Probes: map[string]int{"test_a": 1, "test_c": 1}})
+	waitRunning("test_a", "test_c")
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if created["test_a"] != 1 {
+		t.Errorf("Probe test_a was created %d times, expected 1", created["test_a"])
+	} else if created["test_b"] != 2 {
+		t.Errorf("Probe test_b was created %d times, expected 2", created["test_b"])
+	} else if created["test_c"] != 1 {
+		t.Errorf("Probe test_c was created %d times, expected 1", created["test_c"])
+	}
+} // func TestProbeManagerUpdate(t *testing.T)
@@ -0,0 +1,503 @@
+// /home/krylon/go/src/github.com/blicero/donkey/agent/06_agent_config_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 10:58:20 krylon>
+
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/blicero/donkey/common"
+	"github.com/blicero/donkey/logdomain"
+	"github.com/blicero/donkey/model"
+)
+
+func TestConfigWithManaged(t *testing.T) {
+	var local = config{
+		Server:        "donkey:5102",
+		Probes:        map[string]int{"load": 10},
+		BatchSize:     5,
+		BatchInterval: 30,
+	}
+
+	type testCase struct {
+		managed  *model.AgentConfig
+		expected config
+	}
+
+	var cases = []testCase{
+		{
+			managed:  nil,
+			expected: local,
+		},
+		{
+			managed: &model.AgentConfig{Probes: map[string]int{"ram": 60}},
+			expected: config{
+				Server:        "donkey:5102",
+				Probes:        map[string]int{"ram": 60},
+				BatchSize:     5,
+				BatchInterval: 30,
+			},
+		},
+		{
+			managed: &model.AgentConfig{BatchSize: 20},
+			expected: config{
+				Server:        "donkey:5102",
+				Probes:        map[string]int{"load": 10},
+				BatchSize:     20,
+				BatchInterval: 30,
+			},
+		},
+		{
+			managed: &model.AgentConfig{BatchSize: model.MaxBatchSize * 4},
+			expected: config{
+				Server:        "donkey:5102",
+				Probes:        map[string]int{"load": 10},
+				BatchSize:     model.MaxBatchSize,
+				BatchInterval: 30,
+			},
+		},
+	}
+
+	for i, c := range cases {
+		if cfg := local.withManaged(c.managed); !reflect.DeepEqual(cfg, c.expected) {
+			t.Errorf("Test case #%d: Expected %#v, got %#v",
+				i,
+				c.expected,
+				cfg)
+		}
+	}
+
+	if local.Probes["load"] != 10 || len(local.Probes) != 1 {
+		t.Errorf("withManaged modified the local configuration: %v", local.Probes)
+	}
+} // func TestConfigWithManaged(t *testing.T)
+
+// testAgent creates an Agent for the given Server and configuration that has
+// registered as Host 42, without reading a configuration file.
+func testAgent(t *testing.T, srv string, cfg config) *Agent {
+	var (
+		err error
+		ag  = &Agent{
+			server:  srv,
+			hostID:  42,
+			recordq: make(chan model.Record, 16),
+			local:   cfg,
+			cfg:     cfg,
+		}
+	)
+
+	if ag.log, err = common.GetLogger(logdomain.Agent); err != nil {
+		t.Fatalf("Cannot create Logger: %s", err.Error())
+	} else if ag.probes, err = newProbeManager(ag.recordq, &ag.cfg); err != nil {
+		t.Fatalf("Cannot create probe manager: %s", err.Error())
+	}
+
+	return ag
+} // func testAgent(t *testing.T, srv string, cfg config) *Agent
+
+func TestAgentFetchConfig(t *testing.T) {
+	var (
+		err      error
+		ag       *Agent
+		ts       *httptest.Server
+		reply    model.ConfigResponse
+		interval string
+		local    = config{
+			Token:  "secret",
+			Probes: map[string]int{"load": 10},
+		}
+	)
+
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/ws/config/42" {
+			http.Error(w, "Wrong method or path", http.StatusNotFound)
+			return
+		} else if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "No token", http.StatusUnauthorized)
+			return
+		}
+
+		interval = r.URL.Query().Get("interval")
+		json.NewEncoder(w).Encode(&reply) // nolint: errcheck
+	}))
+	defer ts.Close()
+
+	ag = testAgent(t, strings.TrimPrefix(ts.URL, "http://"), local)
+
+	reply.Status = true
+	reply.Config = &model.AgentConfig{
+		Probes:    map[string]int{"ram": 60, "netdev": 30},
+		BatchSize: 10,
+	}
+
+	if err = ag.fetchConfig(); err != nil {
+		t.Fatalf("Cannot fetch configuration: %s", err.Error())
+	} else if !reflect.DeepEqual(ag.cfg.Probes, reply.Config.Probes) {
+		t.Errorf("Probes were not applied: %v", ag.cfg.Probes)
+	} else if ag.cfg.BatchSize != 10 {
+		t.Errorf("Batch size was not applied: %d", ag.cfg.BatchSize)
+	} else if ag.cfg.Token != "secret" {
+		t.Errorf("Token was lost: %q", ag.cfg.Token)
+	} else if len(ag.probes.probes) != 2 {
+		t.Errorf("Probe manager has %d Probes, expected 2", len(ag.probes.probes))
+	} else if expect := time.Second*10 + heartbeat; interval != fmt.Sprint(math.Ceil(expect.Seconds())) {
+		t.Errorf("Agent reported contact interval %q, expected %s", interval, expect)
+	} else if expect = time.Second*(30+defaultBatchInterval) + heartbeat; ag.contactInterval() != expect {
+		t.Errorf("Contact interval should be %s with the new configuration, not %s",
+			expect,
+			ag.contactInterval())
+	}
+
+	// The Server stops managing our configuration.
+	reply.Config = nil
+
+	if err = ag.fetchConfig(); err != nil {
+		t.Fatalf("Cannot fetch configuration: %s", err.Error())
+	} else if !reflect.DeepEqual(ag.cfg, local) {
+		t.Errorf("Agent did not return to its own configuration: %#v", ag.cfg)
+	}
+
+	reply.Status = false
+	reply.Message = "Go away"
+
+	if err = ag.fetchConfig(); err == nil {
+		t.Error("Fetching configuration should have failed")
+	}
+} // func TestAgentFetchConfig(t *testing.T)
+
+func TestAgentReloadConfig(t *testing.T) {
+	var (
+		err     error
+		ag      *Agent
+		buf     []byte
+		oldPath = common.AgentConfPath
+		local   = config{
+			Server: "donkey:5102",
+			HostID: 42,
+			Token:  "secret",
+			Probes: map[string]int{"load": 10},
+		}
+		edited = config{
+			Server:    "elsewhere:5102",
+			Probes:    map[string]int{"load": 10, "ram": 60},
+			BatchSize: 5,
+		}
+	)
+
+	common.AgentConfPath = filepath.Join(t.TempDir(), "agent.json")
+	defer func() { common.AgentConfPath = oldPath }()
+
+	ag = testAgent(t, local.Server, local)
+	ag.managed = &model.AgentConfig{BatchSize: 20}
+
+	if buf, err = json.Marshal(&edited); err != nil {
+		t.Fatalf("Cannot serialize configuration: %s", err.Error())
+	} else if err = os.WriteFile(common.AgentConfPath, buf, 0600); err != nil {
+		t.Fatalf("Cannot write configuration: %s", err.Error())
+	} else if err = ag.reloadConfig(); err != nil {
+		t.Fatalf("Cannot reload configuration: %s", err.Error())
+	}
+
+	if ag.cfg.Server != local.Server || ag.cfg.Token != local.Token || ag.cfg.HostID != local.HostID {
+		t.Errorf("Reloading changed the Agent's identity: %#v", ag.cfg)
+	} else if !reflect.DeepEqual(ag.cfg.Probes, edited.Probes) {
+		t.Errorf("Probes were not reloaded: %v", ag.cfg.Probes)
+	} else if ag.cfg.BatchSize != 20 {
+		t.Errorf("Batch size from the Server should take precedence, got %d",
+			ag.cfg.BatchSize)
+	} else if len(ag.probes.probes) != 2 {
+		t.Errorf("Probe manager has %d Probes, expected 2", len(ag.probes.probes))
+	}
+} // func TestAgentReloadConfig(t *testing.T)
+
+func TestAgentBatchRejected(t *testing.T) {
+	const maxBatch = 2
+	var (
+		err      error
+		ag       *Agent
+		ts       *httptest.Server
+		lock     sync.Mutex
+		received []string
+	)
+
+	// The test Server refuses batches larger than maxBatch as a whole,
+	// and the Record with the payload "bad" on its own.
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var recs []model.Record
+
+		switch r.URL.Path {
+		case "/ws/report":
+			var (
+				rec   model.Record
+				reply model.Response
+			)
+
+			json.NewDecoder(r.Body).Decode(&rec) // nolint: errcheck
+			if reply.Status = rec.Payload != "bad"; reply.Status {
+				recs = append(recs, rec)
+			}
+			json.NewEncoder(w).Encode(&reply) // nolint: errcheck
+		case "/ws/report/batch":
+			var reply model.BatchResponse
+
+			json.NewDecoder(r.Body).Decode(&recs) // nolint: errcheck
+			if reply.Status = len(recs) <= maxBatch; !reply.Status {
+				recs = nil
+			}
+			for range recs {
+				reply.Results = append(reply.Results, model.RecordStatus{Status: true})
+			}
+			json.NewEncoder(w).Encode(&reply) // nolint: errcheck
+		}
+
+		lock.Lock()
+		for _, rec := range recs {
+			received = append(received, rec.Payload)
+		}
+		lock.Unlock()
+	}))
+	defer ts.Close()
+
+	ag = testAgent(t, strings.TrimPrefix(ts.URL, "http://"), config{BatchSize: 5})
+
+	if ag.spool, err = openSpool(t.TempDir(), 0, ag.log); err != nil {
+		t.Fatalf("Cannot open spool: %s", err.Error())
+	}
+
+	for _, p := range []string{"1", "2", "bad", "4", "5"} {
+		ag.batch = append(ag.batch, model.Record{Payload: p})
+	}
+
+	if err = ag.flushBatch(); err != nil {
+		t.Fatalf("Cannot flush batch: %s", err.Error())
+	} else if ag.spool.Len() != 0 {
+		t.Errorf("Rejected batch was spooled, %d Records in spool", ag.spool.Len())
+	} else if !reflect.DeepEqual(received, []string{"1", "2", "4", "5"}) {
+		t.Errorf("Unexpected Records delivered: %v", received)
+	}
+} // func TestAgentBatchRejected(t *testing.T)
+
+// TestAgentBatchPartial checks that when the Server fails while a refused
+// batch is sent in halves, only the Records that were not delivered are
+// spooled and sent again.
+func TestAgentBatchPartial(t *testing.T) {
+	const maxBatch = 2
+	var (
+		err      error
+		ag       *Agent
+		ts       *httptest.Server
+		lock     sync.Mutex
+		down     = true
+		received []string
+	)
+
+	// The test Server refuses batches larger than maxBatch as a whole.
+	// While down is set, it fails on smaller batches containing the
+	// Record with the payload "4".
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var (
+			recs  []model.Record
+			reply model.BatchResponse
+		)
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		json.NewDecoder(r.Body).Decode(&recs) // nolint: errcheck
+
+		if reply.Status = len(recs) <= maxBatch; !reply.Status {
+			recs = nil
+		}
+
+		for _, rec := range recs {
+			if down && rec.Payload == "4" {
+				http.Error(w, "Database is locked", http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		for _, rec := range recs {
+			received = append(received, rec.Payload)
+			reply.Results = append(reply.Results, model.RecordStatus{Status: true})
+		}
+
+		json.NewEncoder(w).Encode(&reply) // nolint: errcheck
+	}))
+	defer ts.Close()
+
+	ag = testAgent(t, strings.TrimPrefix(ts.URL, "http://"), config{BatchSize: 4})
+
+	if ag.spool, err = openSpool(t.TempDir(), 0, ag.log); err != nil {
+		t.Fatalf("Cannot open spool: %s", err.Error())
+	}
+
+	for _, p := range []string{"1", "2", "3", "4"} {
+		ag.batch = append(ag.batch, model.Record{Payload: p})
+	}
+
+	if err = ag.flushBatch(); err == nil {
+		t.Fatalf("Flushing the batch should have failed")
+	} else if ag.spool.Len() != 2 {
+		t.Errorf("Expected the 2 undelivered Records to be spooled, %d Records in spool",
+			ag.spool.Len())
+	}
+
+	lock.Lock()
+	down = false
+	lock.Unlock()
+	ag.retryAt = time.Time{}
+
+	if err = ag.drainSpool(); err != nil {
+		t.Fatalf("Cannot drain spool: %s", err.Error())
+	} else if ag.spool.Len() != 0 {
+		t.Errorf("%d Records remain spooled", ag.spool.Len())
+	} else if !reflect.DeepEqual(received, []string{"1", "2", "3", "4"}) {
+		t.Errorf("Unexpected Records delivered: %v", received)
+	}
+} // func TestAgentBatchPartial(t *testing.T)
+
+func TestAgentRevokedToken(t *testing.T) {
+	var (
+		oldPath = common.AgentConfPath
+		// If claimed is set, another Agent registered our Host, so
+		// we cannot register again until the token is revoked.
+		cases = []struct{ claimed bool }{
+			{claimed: false},
+			{claimed: true},
+		}
+	)
+
+	common.AgentConfPath = filepath.Join(t.TempDir(), "agent.json")
+	defer func() { common.AgentConfPath = oldPath }()
+
+	for i, c := range cases {
+		var (
+			err      error
+			ag       *Agent
+			ts       *httptest.Server
+			lock     sync.Mutex
+			received []string
+			stored   string
+			done     = make(chan struct{})
+		)
+
+		if c.claimed {
+			stored = "other"
+		}
+
+		// Like the real Server, the test Server lets an Agent
+		// register a known Host again if it presents the current
+		// token, or if the token was revoked.
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			switch r.URL.Path {
+			case "/ws/register":
+				if stored != "" && token != stored {
+					http.Error(w, "Invalid token", http.StatusForbidden)
+					return
+				}
+				var reply = model.Registration{HostID: 42, Token: "new"}
+
+				reply.Status = true
+				stored = reply.Token
+				json.NewEncoder(w).Encode(&reply) // nolint: errcheck
+				return
+			}
+
+			if stored == "" || token != stored {
+				http.Error(w, "Invalid token", http.StatusForbidden)
+				return
+			} else if r.URL.Path == "/ws/report" {
+				var rec model.Record
+
+				json.NewDecoder(r.Body).Decode(&rec) // nolint: errcheck
+				received = append(received, rec.Payload)
+			}
+
+			json.NewEncoder(w).Encode(&model.Response{Status: true}) // nolint: errcheck
+		}))
+
+		ag = testAgent(t, strings.TrimPrefix(ts.URL, "http://"), config{Token: "old"})
+		ag.sigq = make(chan os.Signal, 1)
+
+		if ag.spool, err = openSpool(t.TempDir(), 0, ag.log); err != nil {
+			t.Fatalf("Test case #%d: Cannot open spool: %s", i, err.Error())
+		}
+
+		go func() {
+			ag.Run()
+			close(done)
+		}()
+
+		var delivered = func(timeout time.Duration) bool {
+			for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(time.Millisecond * 50) {
+				lock.Lock()
+				var cnt = len(received)
+				lock.Unlock()
+
+				if cnt > 0 {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		ag.recordq <- model.Record{Payload: "1"}
+
+		if c.claimed {
+			// The Agent keeps its Record and keeps running,
+			// until an administrator revokes the token.
+			if delivered(heartbeat + time.Second) {
+				t.Errorf("Test case #%d: Server accepted Record with a foreign token", i)
+			}
+
+			select {
+			case <-done:
+				t.Fatalf("Test case #%d: Agent quit when it could not register again", i)
+			default:
+			}
+
+			lock.Lock()
+			stored = ""
+			lock.Unlock()
+		}
+
+		// The spooled Record is delivered on the next tick after
+		// the Agent registered again.
+		delivered(heartbeat * 3)
+
+		ag.sigq <- syscall.SIGTERM
+		<-done
+		ts.Close()
+
+		lock.Lock()
+		if !reflect.DeepEqual(received, []string{"1"}) {
+			t.Errorf("Test case #%d: Unexpected Records delivered: %v", i, received)
+		} else if ag.cfg.Token != "new" {
+			t.Errorf("Test case #%d: Agent did not pick up its new token: %q", i, ag.cfg.Token)
+		} else if ag.spool.Len() != 0 {
+			t.Errorf("Test case #%d: %d Records remain spooled", i, ag.spool.Len())
+		}
+		lock.Unlock()
+	}
+} // func TestAgentRevokedToken(t *testing.T)
@@ -0,0 +1,322 @@
+// /home/krylon/go/src/github.com/blicero/donkey/agent/07_probe_exec_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 12:31:14 krylon>
+
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/blicero/donkey/model"
+)
+
+func TestParseCheckOutput(t *testing.T) {
+	var (
+		f = func(x float64) *float64 { return &x }
+	)
+
+	type testCase struct {
+		output string
+		text   string
+		long   string
+		perf   []model.PerfData
+	}
+
+	var cases = []testCase{
+		{
+			output: "DISK OK\n",
+			text:   "DISK OK",
+			perf:   []model.PerfData{},
+		},
+		{
+			output: "DISK OK - free space: / 3326 MB (56%); | /=2643MB;5948;5958;0;5968\n",
+			text:   "DISK OK - free space: / 3326 MB (56%);",
+			perf: []model.PerfData{
+				{Label: "/", Value: 2643, UOM: "MB", Warn: "5948", Crit: "5958", Min: f(0), Max: f(5968)},
+			},
+		},
+		{
+			output: "LOAD WARNING | 'load 1'=2.5;2;4 'it''s'=1e3c;;;; broken time=U;1;2 pct=99.5%\n" +
+				"first line of long output\n" +
+				"second line | 'more data'=-3.25s;@1:2;~:5\n" +
+				"last=7\n",
+			text: "LOAD WARNING",
+			long: "first line of long output\nsecond line",
+			perf: []model.PerfData{
+				{Label: "load 1", Value: 2.5, Warn: "2", Crit: "4"},
+				{Label: "it's", Value: 1000, UOM: "c"},
+				{Label: "pct", Value: 99.5, UOM: "%"},
+				{Label: "more data", Value: -3.25, UOM: "s", Warn: "@1:2", Crit: "~:5"},
+				{Label: "last", Value: 7},
+			},
+		},
+	}
+
+	for i, c := range cases {
+		var text, long, perf = model.ParseCheckOutput([]byte(c.output))
+
+		if text != c.text {
+			t.Errorf("Test case #%d: Expected output %q, got %q", i, c.text, text)
+		} else if long != c.long {
+			t.Errorf("Test case #%d: Expected long output %q, got %q", i, c.long, long)
+		} else if !reflect.DeepEqual(perf, c.perf) {
+			t.Errorf("Test case #%d: Expected performance data\n%#v\ngot\n%#v",
+				i,
+				c.perf,
+				perf)
+		}
+	}
+} // func TestParseCheckOutput(t *testing.T)
+
+// writeCheck writes a shell script with the given body to dir and returns
+// its path.
+func writeCheck(t *testing.T, dir, name, body string) string {
+	var path = filepath.Join(dir, name)
+
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatalf("Cannot write check script %s: %s", path, err.Error())
+	}
+
+	return path
+} // func writeCheck(t *testing.T, dir, name, body string) string
+
+func TestExecProbe(t *testing.T) {
+	var dir = t.TempDir()
+
+	type testCase struct {
+		check   checkConfig
+		status  int
+		output  string
+		perf    int
+		samples int
+	}
+
+	var cases = []testCase{
+		{
+			check: checkConfig{
+				Name: "ok",
+				Command: writeCheck(t, dir, "ok.sh",
+					"echo \"PING OK - Packet loss = 0%, RTA = 0.80 ms | 'round trip'=0.80ms;100;500;0 loss=0%;20;60;0;100\"\n"),
+			},
+			status:  model.CheckOK,
+			output:  "PING OK - Packet loss = 0%, RTA = 0.80 ms",
+			perf:    2,
+			samples: 3,
+		},
+		{
+			check: checkConfig{
+				Name:    "warning",
+				Command: writeCheck(t, dir, "warning.sh", "echo \"$1 WARNING\"\nexit 1\n"),
+				Args:    []string{"SWAP"},
+			},
+			status:  model.CheckWarning,
+			output:  "SWAP WARNING",
+			samples: 1,
+		},
+		{
+			check: checkConfig{
+				Name:    "critical",
+				Command: writeCheck(t, dir, "critical.sh", "echo 'HTTP CRITICAL'\nexit 2\n"),
+			},
+			status:  model.CheckCritical,
+			output:  "HTTP CRITICAL",
+			samples: 1,
+		},
+		{
+			check: checkConfig{
+				Name:    "weird",
+				Command: writeCheck(t, dir, "weird.sh", "echo 'Something went wrong' >&2\nexit 7\n"),
+			},
+			status:  model.CheckUnknown,
+			output:  "Something went wrong",
+			samples: 1,
+		},
+		{
+			check: checkConfig{
+				Name:    "slow",
+				Command: writeCheck(t, dir, "slow.sh", "exec sleep 10\n"),
+				Timeout: 1,
+			},
+			status:  model.CheckUnknown,
+			output:  "Check timed out after 1s",
+			samples: 1,
+		},
+	}
+
+	for i, c := range cases {
+		var (
+			err     error
+			p       *ExecProbe
+			rec     *model.Record
+			v       any
+			check   *model.Check
+			samples []model.Sample
+			ok      bool
+		)
+
+		if p, err = CreateExecProbe(&c.check); err != nil {
+			t.Fatalf("Test case #%d: Cannot create ExecProbe: %s", i, err.Error())
+		} else if rec, err = p.Collect(); err != nil {
+			t.Errorf("Test case #%d: Error running check: %s", i, err.Error())
+			continue
+		} else if err = rec.Validate(); err != nil {
+			t.Errorf("Test case #%d: Invalid Record: %s", i, err.Error())
+			continue
+		} else if v, err = rec.Decode(); err != nil {
+			t.Errorf("Test case #%d: Cannot decode Record: %s", i, err.Error())
+			continue
+		} else if check, ok = v.(*model.Check); !ok {
+			t.Errorf("Test case #%d: Expected *model.Check, got %T", i, v)
+			continue
+		} else if samples, err = rec.Samples(); err != nil {
+			t.Errorf("Test case #%d: Cannot get Samples: %s", i, err.Error())
+			continue
+		}
+
+		if check.Name != c.check.Name {
+			t.Errorf("Test case #%d: Expected name %q, got %q", i, c.check.Name, check.Name)
+		} else if check.Status != c.status {
+			t.Errorf("Test case #%d: Expected status %s, got %s",
+				i,
+				model.CheckStatusName(c.status),
+				model.CheckStatusName(check.Status))
+		} else if check.Output != c.output {
+			t.Errorf("Test case #%d: Expected output %q, got %q", i, c.output, check.Output)
+		} else if len(check.Perf) != c.perf {
+			t.Errorf("Test case #%d: Expected %d items of performance data, got %d",
+				i,
+				c.perf,
+				len(check.Perf))
+		} else if len(samples) != c.samples {
+			t.Errorf("Test case #%d: Expected %d Samples, got %d",
+				i,
+				c.samples,
+				len(samples))
+		}
+	}
+
+	var missing = checkConfig{Name: "missing", Command: filepath.Join(dir, "no_such_check")}
+
+	if p, err := CreateExecProbe(&missing); err != nil {
+		t.Fatalf("Cannot create ExecProbe: %s", err.Error())
+	} else if _, err = p.Collect(); err == nil {
+		t.Error("Running a missing command should have failed")
+	}
+
+	if _, err := CreateExecProbe(&checkConfig{Name: "nothing"}); err == nil {
+		t.Error("Check without command should have been rejected")
+	}
+} // func TestExecProbe(t *testing.T)
+
+func TestCheckSamples(t *testing.T) {
+	var (
+		check = model.Check{
+			Name:   "disk",
+			Status: model.CheckWarning,
+			Perf: []model.PerfData{
+				{Label: "/", Value: 80, Warn: "75", Crit: "0:90"},
+				{Label: "/home", Value: 10, Warn: "10:", Crit: "@0:5"},
+			},
+		}
+		samples = check.Samples()
+	)
+
+	if len(samples) != 3 {
+		t.Fatalf("Expected 3 Samples, got %d", len(samples))
+	} else if s := samples[0]; s.Metric != "check.status" || s.Instance != "disk" || s.Value != model.CheckWarning {
+		t.Errorf("Unexpected status Sample: %#v", s)
+	} else if s = samples[1]; s.Instance != "disk//" || s.High == nil || *s.High != 75 || s.Crit == nil || *s.Crit != 90 {
+		t.Errorf("Unexpected Sample for /: %#v", s)
+	} else if s = samples[2]; s.Instance != "disk//home" || s.High != nil || s.Crit != nil {
+		t.Errorf("Thresholds that are not upper limits should be ignored: %#v", s)
+	}
+} // func TestCheckSamples(t *testing.T)
+
+func TestProbeManagerChecks(t *testing.T) {
+	var (
+		err   error
+		m     *probeManager
+		names []string
+		cfg   = &config{
+			Checks: []*checkConfig{
+				{Name: "ping", Command: "/bin/true", Interval: 30},
+				{Name: "disk", Command: "/bin/true"},
+				{Name: "ping", Command: "/bin/false"},
+				{Name: "broken"},
+			},
+		}
+	)
+
+	if m, err = newProbeManager(make(chan model.Record), cfg); err != nil {
+		t.Fatalf("Cannot create probe manager: %s", err.Error())
+	}
+
+	for _, h := range m.probes {
+		names = append(names, h.name)
+	}
+
+	if !reflect.DeepEqual(names, []string{"check/disk", "check/ping"}) {
+		t.Errorf("Unexpected Probes: %v", names)
+	} else if m.probes[0].interval != defaultCheckInterval {
+		t.Errorf("Check disk should run every %s, not %s",
+			defaultCheckInterval,
+			m.probes[0].interval)
+	} else if m.probes[1].check.Command != "/bin/true" {
+		t.Errorf("Duplicate check replaced the first one: %#v", m.probes[1].check)
+	}
+} // func TestProbeManagerChecks(t *testing.T)
+
+func TestProbeManagerStopCheck(t *testing.T) {
+	var (
+		err   error
+		m     *probeManager
+		h     *probeHandle
+		begin time.Time
+		q     = make(chan model.Record, 4)
+		cfg   = &config{
+			Checks: []*checkConfig{
+				{
+					Name:     "slow",
+					Command:  writeCheck(t, t.TempDir(), "slow.sh", "exec sleep 30\n"),
+					Interval: 1,
+					Timeout:  60,
+				},
+			},
+		}
+	)
+
+	if m, err = newProbeManager(q, cfg); err != nil {
+		t.Fatalf("Cannot create probe manager: %s", err.Error())
+	}
+
+	m.start()
+	defer m.stop()
+
+	h = m.probes[0]
+
+	// Give the check time to start.
+	time.Sleep(time.Millisecond * 1500)
+
+	begin = time.Now()
+	m.update(&config{})
+
+	if d := time.Since(begin); d > time.Millisecond*500 {
+		t.Errorf("Update took %s, it should not wait for the check", d)
+	}
+
+	select {
+	case <-h.stopped:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Stopping the Probe did not abort the check")
+	}
+
+	if len(q) != 0 {
+		t.Errorf("Aborted check delivered %d Records", len(q))
+	}
+} // func TestProbeManagerStopCheck(t *testing.T)
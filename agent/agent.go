@@ -15,9 +15,11 @@ import (
 	"io"
 	"io/fs"
 	"log"
+	"math"
 	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
 	"strconv"
 	"sync/atomic"
 	"syscall"
@@ -29,14 +31,55 @@ import (
 	"github.com/blicero/krylib"
 )
 
+// The Agent's main loop wakes up every heartbeat to send batches and spooled
+// Records that are due. Unless configured otherwise, it holds on to a batch
+// for defaultBatchInterval seconds at most. It asks the Server for its
+// configuration every configInterval.
 const (
-	heartbeat = time.Millisecond * 2500
+	heartbeat            = time.Millisecond * 2500
+	defaultBatchInterval = 10
+	configInterval       = time.Minute * 5
 )
 
+// errRejected indicates that the Server received a Record but refused to
+// accept it. Sending the same Record again will not help.
+var errRejected = errors.New("Server rejected Record")
+
+// errBatchRejected indicates that the Server refused a batch of Records as a
+// whole. Sending the same batch again will not help, but sending its
+// Records in smaller batches might.
+var errBatchRejected = errors.New("Server rejected batch")
+
+// errUnauthorized indicates that the Server refused the token we presented,
+// e.g. because it was revoked. Nothing we send gets through until we
+// register again.
+var errUnauthorized = errors.New("Server refused our token")
+
+// Probes maps the types of Probes to run to the interval between two
+// samples in seconds, an interval of 0 means the default of 5 seconds.
+// If BatchSize is greater than 1, the Agent collects Records and sends them
+// to the Server in batches of up to BatchSize Records, or after
+// BatchInterval seconds have passed, whichever comes first.
+// Filesystem selects the file systems the filesystem Probe reports on.
+// Token is the secret the Server issued when the Agent registered, it has
+// to be presented with every report.
+// If TLS is set, the Agent talks to the Server via HTTPS.
+// Checks lists external commands following the conventions of Nagios
+// plugins, each of them is run by an ExecProbe. They can only be configured
+// locally, the Server cannot make the Agent run commands.
+// Probes, BatchSize and BatchInterval can be overridden by the Server, see
+// model.AgentConfig.
 type config struct {
-	Server string
-	HostID int64
-	Probes map[string]int
+	Server        string
+	HostID        int64
+	Token         string     `json:",omitempty"`
+	TLS           *tlsConfig `json:",omitempty"`
+	Probes        map[string]int
+	SpoolSize     int64          `json:",omitempty"`
+	BatchSize     int            `json:",omitempty"`
+	BatchInterval int            `json:",omitempty"`
+	Filesystem    *fsFilter      `json:",omitempty"`
+	Checks        []*checkConfig `json:",omitempty"`
 }
 
 // Agent wraps the state of the client.
@@ -46,10 +89,25 @@ type Agent struct {
 	name    string
 	active  atomic.Bool
 	log     *log.Logger
-	client  http.Client // nolint: unused,deadcode
+	client  http.Client
 	os      string
 	recordq chan model.Record
 	sigq    chan os.Signal
+	cfg     config
+	spool   *spool
+	backoff time.Duration
+	retryAt time.Time
+	batch   []model.Record
+	batchAt time.Time
+	probes  *probeManager
+	// local is the configuration as read from the configuration file,
+	// managed is the configuration the Server sent us. cfg is the result
+	// of combining them.
+	local    config
+	managed  *model.AgentConfig
+	configAt time.Time
+	// reported is the contact interval we last told the Server about.
+	reported time.Duration
 }
 
 // Create creates a new Agent.
@@ -73,16 +131,38 @@ func Create(srv string) (*Agent, error) {
 		ag.log.Printf("[ERROR] Failed to ask OS for hostname: %s\n",
 			err.Error())
 		return nil, err
-	} else if err = ag.readConfig(); err != nil {
+	}
+
+	ag.recordq = make(chan model.Record, 5)
+
+	if err = ag.readConfig(common.AgentConfPath); err != nil {
 		ag.log.Printf("[ERROR] Could not process configuration file: %s\n",
 			err.Error())
 		return nil, err
+	} else if ag.server == "" {
+		err = fmt.Errorf("No Server address was given, and %s does not contain one",
+			common.AgentConfPath)
+		ag.log.Printf("[ERROR] %s\n", err.Error())
+		return nil, err
+	} else if err = ag.setupClient(); err != nil {
+		ag.log.Printf("[ERROR] Invalid TLS settings: %s\n",
+			err.Error())
+		return nil, err
+	} else if ag.spool, err = openSpool(common.SpoolPath, ag.cfg.SpoolSize, ag.log); err != nil {
+		ag.log.Printf("[ERROR] Could not open spool at %s: %s\n",
+			common.SpoolPath,
+			err.Error())
+		return nil, err
+	} else if ag.probes, err = newProbeManager(ag.recordq, &ag.cfg); err != nil {
+		ag.log.Printf("[ERROR] Cannot create probe manager: %s\n",
+			err.Error())
+		return nil, err
 	}
 
-	ag.recordq = make(chan model.Record, 5)
+	ag.backoff = spoolBackoffMin
 	ag.sigq = make(chan os.Signal, 2)
 
-	signal.Notify(ag.sigq, os.Interrupt, syscall.SIGPIPE, syscall.SIGTERM)
+	signal.Notify(ag.sigq, os.Interrupt, syscall.SIGPIPE, syscall.SIGTERM, syscall.SIGHUP)
 
 	ag.log.Printf("[DEBUG] Agent coming up on %s, running %s %s\n",
 		ag.name,
@@ -92,7 +172,9 @@ func Create(srv string) (*Agent, error) {
 	return ag, nil
 } // func Create(srv string) (*Agent, error)
 
-func (ag *Agent) readConfig(path string) error {
+// loadConfig reads the configuration file at the given path. If the file
+// does not exist, an empty configuration is returned.
+func (ag *Agent) loadConfig(path string) (config, error) {
 	var (
 		fh  *os.File
 		cfg config
@@ -103,47 +185,134 @@ func (ag *Agent) readConfig(path string) error {
 	if fh, err = os.Open(path); err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
 			ag.log.Printf("[INFO] Agent configuration file %s does not exist.\n",
-				common.AgentConfPath)
-			return nil
+				path)
+			return cfg, nil
 		}
 		ag.log.Printf("[ERROR] Cannot open agent config %s: %s\n",
-			common.AgentConfPath,
+			path,
 			err.Error())
-		return err
+		return cfg, err
 	}
 
 	defer fh.Close()
 
 	if _, err = io.Copy(&buf, fh); err != nil {
 		ag.log.Printf("[ERROR] Failed to read from %s: %s\n",
-			common.AgentConfPath,
+			path,
 			err.Error())
-		return err
+		return cfg, err
 	} else if err = json.Unmarshal(buf.Bytes(), &cfg); err != nil {
 		ag.log.Printf("[ERROR] Error decoding config: %s\n",
 			err.Error())
+		return cfg, err
+	} else if cfg.BatchSize > model.MaxBatchSize {
+		ag.log.Printf("[WARN] Batch size %d exceeds the maximum the Server accepts, using %d\n",
+			cfg.BatchSize,
+			model.MaxBatchSize)
+		cfg.BatchSize = model.MaxBatchSize
+	}
+
+	return cfg, nil
+} // func (ag *Agent) loadConfig(path string) (config, error)
+
+func (ag *Agent) readConfig(path string) error {
+	var (
+		err error
+		cfg config
+	)
+
+	if cfg, err = ag.loadConfig(path); err != nil {
 		return err
 	} else if cfg.HostID != 0 {
 		ag.hostID = krylib.ID(cfg.HostID)
 	}
 
-	ag.server = cfg.Server
+	// A Server address passed to Create takes precedence over the one
+	// in the configuration file.
+	if ag.server == "" {
+		ag.server = cfg.Server
+	}
+	ag.local = cfg
+	ag.cfg = cfg
 
-	if cfg.Probes != nil {
-		for key, interval := range cfg.Probes {
-			switch key {
-			case "load":
-				var p Probe = &LoadProbe{}
-				go ag.runProbe(p, interval)
-			default:
-				ag.log.Printf("[ERROR] Don't know anything about probe type %q\n",
-					key)
-			}
-		}
+	return nil
+} // func (ag *Agent) readConfig(path string) error
+
+// reloadConfig reads the configuration file again and applies the settings
+// that can be changed while the Agent is running. The settings that
+// identify the Agent to the Server or control how it talks to the Server
+// are kept.
+func (ag *Agent) reloadConfig() error {
+	var (
+		err error
+		cfg config
+	)
+
+	if cfg, err = ag.loadConfig(common.AgentConfPath); err != nil {
+		ag.log.Printf("[ERROR] Cannot reload configuration, keeping the current one: %s\n",
+			err.Error())
+		return err
 	}
 
+	if cfg.Server != ag.local.Server ||
+		cfg.SpoolSize != ag.local.SpoolSize ||
+		!reflect.DeepEqual(cfg.TLS, ag.local.TLS) {
+		ag.log.Printf("[WARN] Changes to Server, SpoolSize and TLS in %s take effect after a restart\n",
+			common.AgentConfPath)
+	}
+
+	cfg.Server = ag.local.Server
+	cfg.HostID = ag.local.HostID
+	cfg.Token = ag.local.Token
+	cfg.SpoolSize = ag.local.SpoolSize
+	cfg.TLS = ag.local.TLS
+
+	ag.local = cfg
+	ag.applyConfig()
+
+	ag.log.Printf("[INFO] Reloaded configuration from %s\n",
+		common.AgentConfPath)
 	return nil
-} // func (ag *Agent) readConfig() error
+} // func (ag *Agent) reloadConfig() error
+
+// withManaged returns the configuration with the settings the Server
+// manages applied on top of it. The batch size is capped at the maximum the
+// Server accepts.
+func (c config) withManaged(m *model.AgentConfig) config {
+	if m == nil {
+		return c
+	}
+
+	if m.Probes != nil {
+		c.Probes = m.Probes
+	}
+	if m.BatchSize != 0 {
+		c.BatchSize = m.BatchSize
+	}
+	if m.BatchInterval != 0 {
+		c.BatchInterval = m.BatchInterval
+	}
+	if c.BatchSize > model.MaxBatchSize {
+		c.BatchSize = model.MaxBatchSize
+	}
+
+	return c
+} // func (c config) withManaged(m *model.AgentConfig) config
+
+// applyConfig combines the configuration file and the settings the Server
+// manages into the Agent's configuration and starts or stops Probes as
+// necessary.
+func (ag *Agent) applyConfig() {
+	ag.cfg = ag.local.withManaged(ag.managed)
+
+	if !ag.batching() && len(ag.batch) > 0 {
+		// If this fails, the Records are spooled, and the main loop
+		// deals with the error when it tries to deliver them again.
+		ag.flushBatch() // nolint: errcheck
+	}
+
+	ag.probes.update(&ag.cfg)
+} // func (ag *Agent) applyConfig()
 
 func (ag *Agent) writeConfig() error {
 	var (
@@ -153,16 +322,18 @@ func (ag *Agent) writeConfig() error {
 		fh  *os.File
 	)
 
-	cfg = config{
-		Server: ag.server,
-		HostID: int64(ag.hostID),
-	}
+	// We only write back what we read from the file, not the settings
+	// the Server manages.
+	cfg = ag.local
+	cfg.Server = ag.server
+	cfg.HostID = int64(ag.hostID)
+	cfg.Token = ag.cfg.Token
 
 	if buf, err = json.Marshal(&cfg); err != nil {
 		ag.log.Printf("[ERROR] Failed to serialize config: %s\n",
 			err.Error())
 		return err
-	} else if fh, err = os.Create(common.AgentConfPath); err != nil {
+	} else if fh, err = os.OpenFile(common.AgentConfPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600); err != nil {
 		ag.log.Printf("[ERROR] Failed to open agent config file at %s: %s\n",
 			common.AgentConfPath,
 			err.Error())
@@ -171,6 +342,15 @@ func (ag *Agent) writeConfig() error {
 
 	defer fh.Close()
 
+	// The config contains our token, so nobody else should be able to
+	// read it, even if the file existed before with laxer permissions.
+	if err = fh.Chmod(0600); err != nil {
+		ag.log.Printf("[ERROR] Cannot restrict permissions of agent config at %s: %s\n",
+			common.AgentConfPath,
+			err.Error())
+		return err
+	}
+
 	if _, err = fh.Write(buf); err != nil {
 		ag.log.Printf("[ERROR] Cannot open agent config at %s for writing: %s\n",
 			common.AgentConfPath,
@@ -178,6 +358,7 @@ func (ag *Agent) writeConfig() error {
 		return err
 	}
 
+	ag.local = cfg
 	return nil
 } // func (ag *Agent) writeConfig() error
 
@@ -194,7 +375,9 @@ func (ag *Agent) Run() {
 		ticker *time.Ticker
 	)
 
-	if ag.hostID == 0 {
+	// Agents that registered before the Server issued tokens have to
+	// register again to get one.
+	if ag.hostID == 0 || ag.cfg.Token == "" {
 		if err = ag.register(); err != nil {
 			ag.log.Printf("[ERROR] Failed to register with server %s: %s\n",
 				ag.server,
@@ -203,27 +386,100 @@ func (ag *Agent) Run() {
 		}
 	}
 
+	// If the Server cannot tell us our configuration right now, we
+	// start with our own and try again later.
+	ag.fetchConfig() // nolint: errcheck
+
+	ag.probes.start()
+	defer ag.probes.stop()
+
 	ticker = time.NewTicker(heartbeat)
 	defer ticker.Stop()
 
 	for ag.active.Load() {
+		err = nil
+
 		select {
 		case <-ticker.C:
-			continue
+			if len(ag.batch) > 0 && time.Since(ag.batchAt) >= ag.batchInterval() {
+				err = ag.flushBatch()
+			}
+			if err == nil {
+				err = ag.drainSpool()
+			}
+			if err == nil && (time.Since(ag.configAt) >= configInterval || ag.contactInterval() != ag.reported) {
+				err = ag.fetchConfig()
+			}
 		case rec = <-ag.recordq:
-			// Do something
-			if err = ag.reportRecord(&rec); err != nil {
-				ag.log.Printf("[ERROR] Failed to report Record to server: %s\n",
+			if ag.batching() {
+				if len(ag.batch) == 0 {
+					ag.batchAt = time.Now()
+				}
+				ag.batch = append(ag.batch, rec)
+				if len(ag.batch) >= ag.cfg.BatchSize {
+					err = ag.flushBatch()
+				}
+			} else if ag.spool.Len() > 0 {
+				// If there are Records waiting in the spool, we
+				// append new ones to it, so the Server receives
+				// them in order.
+				ag.spoolRecord(&rec)
+				err = ag.drainSpool()
+			} else if err = ag.reportRecord(&rec); errors.Is(err, errRejected) {
+				ag.log.Printf("[ERROR] Discarding Record: %s\n",
 					err.Error())
+			} else if err != nil {
+				ag.log.Printf("[ERROR] Failed to report Record to server, spooling it: %s\n",
+					err.Error())
+				ag.spoolRecord(&rec)
+				ag.retryAt = time.Now().Add(ag.backoff)
 			}
 		case sig = <-ag.sigq:
+			if sig == syscall.SIGHUP {
+				ag.reloadConfig() // nolint: errcheck
+				continue
+			}
 			ag.log.Printf("[INFO] Received Signal %s, quitting Agent loop.\n",
 				sig)
+			// Keep whatever we have not sent yet for the next run.
+			for i := range ag.batch {
+				ag.spoolRecord(&ag.batch[i])
+			}
 			return
 		}
+
+		// Whatever we failed to deliver has been spooled. If we
+		// cannot register again, we keep spooling and try again when
+		// the spool is due for its next attempt.
+		if errors.Is(err, errUnauthorized) {
+			if err = ag.reregister(); err != nil {
+				ag.log.Printf("[ERROR] Server refused our token, and we cannot register again: %s\n",
+					err.Error())
+			}
+		}
 	}
 } // func (ag *Agent) Run()
 
+// reregister registers with the Server again to get a new token. We still
+// present the old one: The Server only lets us claim our Host without it
+// if an administrator revoked it, see "donkey hosts revoke".
+func (ag *Agent) reregister() error {
+	var err error
+
+	ag.log.Printf("[WARN] Server refused our token, registering again\n")
+
+	if err = ag.register(); err != nil {
+		return err
+	}
+
+	ag.log.Printf("[INFO] Registered again as Host %d\n", ag.hostID)
+	// Deliver the spool and fetch our configuration on the next tick.
+	ag.retryAt = time.Time{}
+	ag.backoff = spoolBackoffMin
+	ag.configAt = time.Time{}
+	return nil
+} // func (ag *Agent) reregister() error
+
 func (ag *Agent) register() error {
 	const endpoint = "/ws/register"
 
@@ -231,18 +487,15 @@ func (ag *Agent) register() error {
 		err        error
 		msg        string
 		serialized []byte
-		addr       = fmt.Sprintf("http://%s%s",
-			ag.server,
-			endpoint)
-		host = model.Host{
+		addr       = ag.url(endpoint)
+		host       = model.Host{
 			Name: ag.name,
 			OS:   ag.os,
 		}
 		req   *http.Request
 		res   *http.Response
-		reply model.Response
+		reply model.Registration
 		buf   bytes.Buffer
-		id    int64
 	)
 
 	if serialized, err = json.Marshal(&host); err != nil {
@@ -254,7 +507,14 @@ func (ag *Agent) register() error {
 			addr,
 			err.Error())
 		return err
-	} else if res, err = ag.client.Do(req); err != nil {
+	}
+
+	// If we have been issued a token before, we present it, so the
+	// Server issues a new one instead of refusing to register a Host it
+	// already knows.
+	ag.authorize(req)
+
+	if res, err = ag.client.Do(req); err != nil {
 		ag.log.Printf("[ERROR] Failed to perform HTTP request for %s: %s\n",
 			addr,
 			err.Error())
@@ -281,14 +541,15 @@ func (ag *Agent) register() error {
 		ag.log.Printf("[ERROR] Response status says no: %s\n",
 			reply.Message)
 		return errors.New(reply.Message)
-	} else if id, err = strconv.ParseInt(reply.Message, 10, 64); err != nil {
-		ag.log.Printf("[ERROR] Failed to decode Host ID assigned to us by the server: %s\n ==> %s\n",
-			err.Error(),
+	} else if reply.HostID == 0 || reply.Token == "" {
+		msg = fmt.Sprintf("Server did not assign us a Host ID and token: %s",
 			reply.Message)
-		return err
+		ag.log.Printf("[ERROR] %s\n", msg)
+		return errors.New(msg)
 	}
 
-	ag.hostID = krylib.ID(id)
+	ag.hostID = krylib.ID(reply.HostID)
+	ag.cfg.Token = reply.Token
 
 	// I should write the config file at this point.
 	if err = ag.writeConfig(); err != nil {
@@ -301,19 +562,98 @@ func (ag *Agent) register() error {
 	return nil
 } // func (ag *Agent) register() error
 
+// fetchConfig asks the Server for the configuration it manages for our Host
+// and applies it, if it changed.
+func (ag *Agent) fetchConfig() error {
+	const endpoint = "/ws/config/"
+
+	var (
+		err      error
+		msg      string
+		addr     string
+		req      *http.Request
+		res      *http.Response
+		reply    model.ConfigResponse
+		buf      bytes.Buffer
+		interval = ag.contactInterval()
+	)
+
+	// We tell the Server how long we may go without contacting it, so it
+	// does not consider us stale too soon.
+	addr = fmt.Sprintf("%s?interval=%d",
+		ag.url(endpoint+strconv.FormatInt(int64(ag.hostID), 10)),
+		int64(math.Ceil(interval.Seconds())))
+	ag.configAt = time.Now()
+	ag.reported = interval
+
+	if req, err = http.NewRequest("GET", addr, nil); err != nil {
+		ag.log.Printf("[ERROR] Failed to create HTTP request to for %s: %s\n",
+			addr,
+			err.Error())
+		return err
+	}
+
+	ag.authorize(req)
+
+	if res, err = ag.client.Do(req); err != nil {
+		ag.log.Printf("[ERROR] Failed to perform HTTP request for %s: %s\n",
+			addr,
+			err.Error())
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+		ag.log.Printf("[ERROR] Server refused to tell us our configuration: %s\n",
+			res.Status)
+		return fmt.Errorf("%w: %s", errUnauthorized, res.Status)
+	} else if res.StatusCode != 200 {
+		msg = fmt.Sprintf("Server responded with Status %s",
+			res.Status)
+		ag.log.Printf("[ERROR] Cannot get configuration from Server: %s\n", msg)
+		return errors.New(msg)
+	} else if _, err = io.Copy(&buf, res.Body); err != nil {
+		ag.log.Printf("[ERROR] Failed to read Response Body: %s\n",
+			err.Error())
+		return err
+	} else if err = json.Unmarshal(buf.Bytes(), &reply); err != nil {
+		ag.log.Printf("[ERROR] Cannot decode response body: %s\n\n%s\n",
+			err.Error(),
+			buf.Bytes())
+		return err
+	} else if !reply.Status {
+		ag.log.Printf("[ERROR] Response status says no: %s\n",
+			reply.Message)
+		return errors.New(reply.Message)
+	} else if reflect.DeepEqual(reply.Config, ag.managed) {
+		return nil
+	}
+
+	if reply.Config == nil {
+		ag.log.Println("[INFO] Server no longer manages our configuration")
+	} else {
+		ag.log.Printf("[INFO] Received new configuration from Server: %d Probes, batch size %d\n",
+			len(reply.Config.Probes),
+			reply.Config.BatchSize)
+	}
+
+	ag.managed = reply.Config
+	ag.applyConfig()
+	return nil
+} // func (ag *Agent) fetchConfig() error
+
 func (ag *Agent) reportRecord(rec *model.Record) error {
 	const endpoint = "/ws/report"
 	var (
 		err        error
 		msg        string
 		serialized []byte
-		addr       = fmt.Sprintf("http://%s%s",
-			ag.server,
-			endpoint)
-		req   *http.Request
-		res   *http.Response
-		reply model.Response
-		buf   *bytes.Buffer
+		addr       = ag.url(endpoint)
+		req        *http.Request
+		res        *http.Response
+		reply      model.Response
+		buf        *bytes.Buffer
 	)
 
 	if rec == nil {
@@ -336,7 +676,11 @@ func (ag *Agent) reportRecord(rec *model.Record) error {
 			addr,
 			err.Error())
 		return err
-	} else if res, err = ag.client.Do(req); err != nil {
+	}
+
+	ag.authorize(req)
+
+	if res, err = ag.client.Do(req); err != nil {
 		ag.log.Printf("[ERROR] Failed to perform HTTP request for %s: %s\n",
 			addr,
 			err.Error())
@@ -346,7 +690,11 @@ func (ag *Agent) reportRecord(rec *model.Record) error {
 	defer res.Body.Close()
 	buf.Reset()
 
-	if res.StatusCode != 200 {
+	if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+		ag.log.Printf("[ERROR] Server refused our token: %s\n",
+			res.Status)
+		return fmt.Errorf("%w: %s", errUnauthorized, res.Status)
+	} else if res.StatusCode != 200 {
 		msg = fmt.Sprintf("Server responded with Status %s",
 			res.Status)
 		ag.log.Printf("[ERROR] %s\n", msg)
@@ -363,27 +711,255 @@ func (ag *Agent) reportRecord(rec *model.Record) error {
 	} else if !reply.Status {
 		ag.log.Printf("[ERROR] Response status says no: %s\n",
 			reply.Message)
-		return errors.New(reply.Message)
+		return fmt.Errorf("%w: %s", errRejected, reply.Message)
 	}
 
 	return nil
 } // func (ag *Agent) reportRecord(rec *model.Record) error
 
-func (ag *Agent) runProbe(p Probe, interval int) {
-	var ticker = time.NewTicker(time.Second * time.Duration(interval))
-	defer ticker.Stop()
+// authorize adds the token the Server issued to us to a request.
+func (ag *Agent) authorize(req *http.Request) {
+	if ag.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+ag.cfg.Token)
+	}
+} // func (ag *Agent) authorize(req *http.Request)
+
+// reportBatch sends several Records to the Server in a single request.
+// It only returns an error if the batch as a whole could not be delivered;
+// Records the Server refused individually are logged and dropped. If the
+// Server refused the whole batch, the error wraps errBatchRejected.
+func (ag *Agent) reportBatch(recs []model.Record) error {
+	const endpoint = "/ws/report/batch"
+	var (
+		err        error
+		msg        string
+		serialized []byte
+		addr       = ag.url(endpoint)
+		req        *http.Request
+		res        *http.Response
+		reply      model.BatchResponse
+		buf        *bytes.Buffer
+	)
 
+	for i := range recs {
+		recs[i].HostID = int64(ag.hostID)
+	}
+
+	if serialized, err = json.Marshal(recs); err != nil {
+		ag.log.Printf("[ERROR] Failed to serialize %d records: %s\n",
+			len(recs),
+			err.Error())
+		return err
+	}
+
+	buf = bytes.NewBuffer(serialized)
+
+	if req, err = http.NewRequest("POST", addr, buf); err != nil {
+		ag.log.Printf("[ERROR] Failed to create HTTP request to for %s: %s\n",
+			addr,
+			err.Error())
+		return err
+	}
+
+	ag.authorize(req)
+
+	if res, err = ag.client.Do(req); err != nil {
+		ag.log.Printf("[ERROR] Failed to perform HTTP request for %s: %s\n",
+			addr,
+			err.Error())
+		return err
+	}
+
+	defer res.Body.Close()
+	buf.Reset()
+
+	if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+		ag.log.Printf("[ERROR] Server refused our token: %s\n",
+			res.Status)
+		return fmt.Errorf("%w: %s", errUnauthorized, res.Status)
+	} else if res.StatusCode != 200 {
+		msg = fmt.Sprintf("Server responded with Status %s",
+			res.Status)
+		ag.log.Printf("[ERROR] %s\n", msg)
+		return errors.New(msg)
+	} else if _, err = io.Copy(buf, res.Body); err != nil {
+		ag.log.Printf("[ERROR] Failed to read Response Body: %s\n",
+			err.Error())
+		return err
+	} else if err = json.Unmarshal(buf.Bytes(), &reply); err != nil {
+		ag.log.Printf("[ERROR] Cannot decode response body: %s\n\n%s\n",
+			err.Error(),
+			buf.Bytes())
+		return err
+	} else if !reply.Status {
+		ag.log.Printf("[ERROR] Response status says no: %s\n",
+			reply.Message)
+		return fmt.Errorf("%w: %s", errBatchRejected, reply.Message)
+	}
+
+	for i, r := range reply.Results {
+		if !r.Status {
+			ag.log.Printf("[ERROR] Server rejected Record #%d of batch, discarding it: %s\n",
+				i,
+				r.Message)
+		}
+	}
+
+	return nil
+} // func (ag *Agent) reportBatch(recs []model.Record) error
+
+// spoolRecord appends a Record to the spool. If that fails, the Record is lost.
+func (ag *Agent) spoolRecord(rec *model.Record) {
+	if err := ag.spool.Append(rec); err != nil {
+		ag.log.Printf("[ERROR] Failed to spool Record, it is lost: %s\n",
+			err.Error())
+	}
+} // func (ag *Agent) spoolRecord(rec *model.Record)
+
+// drainSpool attempts to deliver spooled Records to the Server, oldest first.
+// When delivery fails, the delay before the next attempt is doubled, up to
+// spoolBackoffMax, and the error is returned.
+func (ag *Agent) drainSpool() error {
 	var (
-		err error
-		rec *model.Record
+		err   error
+		cnt   int
+		n     = 1
+		recs  []model.Record
+		left  []model.Record
+		names []string
 	)
 
-	for ag.active.Load() {
-		<-ticker.C
-		if rec, err = p.Collect(); err != nil {
-			ag.log.Printf("[ERROR] Failed to get Record from Probe: %s\n",
+	if ag.spool.Len() == 0 || time.Now().Before(ag.retryAt) {
+		return nil
+	} else if ag.batching() {
+		n = ag.cfg.BatchSize
+	}
+
+	for recs, names = ag.spool.Peek(n); len(recs) > 0; recs, names = ag.spool.Peek(n) {
+		left, err = ag.sendBatch(recs)
+		ag.spool.Pop(names[:len(recs)-len(left)])
+		cnt += len(recs) - len(left)
+
+		if err != nil {
+			ag.retryAt = time.Now().Add(ag.backoff)
+			ag.log.Printf("[INFO] Server still unavailable, %d Records remain spooled, next attempt at %s\n",
+				ag.spool.Len(),
+				ag.retryAt.Format(common.TimestampFormat))
+			if ag.backoff *= 2; ag.backoff > spoolBackoffMax {
+				ag.backoff = spoolBackoffMax
+			}
+			return err
+		}
+	}
+
+	ag.backoff = spoolBackoffMin
+	if cnt > 0 {
+		ag.log.Printf("[INFO] Delivered %d spooled Records to Server\n", cnt)
+	}
+
+	return nil
+} // func (ag *Agent) drainSpool() error
+
+// sendBatch delivers Records to the Server. If the Server refuses the batch
+// as a whole, it is split in half and each half is sent on its own, down to
+// single Records, which are discarded if the Server refuses them. If sending
+// fails, it returns the error along with the Records that should be kept for
+// another attempt. These are always the tail of recs, the Records before them
+// have been delivered or discarded.
+func (ag *Agent) sendBatch(recs []model.Record) ([]model.Record, error) {
+	var (
+		err  error
+		left []model.Record
+		half = len(recs) / 2
+	)
+
+	if len(recs) == 0 {
+		return nil, nil
+	} else if len(recs) == 1 {
+		if err = ag.reportRecord(&recs[0]); errors.Is(err, errRejected) {
+			ag.log.Printf("[ERROR] Discarding Record: %s\n",
 				err.Error())
+			return nil, nil
+		} else if err != nil {
+			return recs, err
 		}
-		ag.recordq <- *rec
+		return nil, nil
+	} else if err = ag.reportBatch(recs); err == nil {
+		return nil, nil
+	} else if !errors.Is(err, errBatchRejected) {
+		return recs, err
+	}
+
+	ag.log.Printf("[WARN] Server refused batch of %d Records, sending it in two halves\n",
+		len(recs))
+
+	if left, err = ag.sendBatch(recs[:half]); err != nil {
+		return recs[half-len(left):], err
 	}
-} // func (ag *Agent) runProbe(p Probe)
+
+	return ag.sendBatch(recs[half:])
+} // func (ag *Agent) sendBatch(recs []model.Record) ([]model.Record, error)
+
+// contactInterval returns the longest time the Agent goes without contacting
+// the Server with its current configuration: Unless it sends Records in
+// batches, it sends them as soon as a Probe delivers them, and it asks for
+// its configuration every configInterval in any case.
+func (ag *Agent) contactInterval() time.Duration {
+	var interval = configInterval
+
+	if silence := ag.probes.maxSilence(); silence > 0 && silence < interval {
+		interval = silence
+	}
+
+	if ag.batching() {
+		interval += ag.batchInterval()
+	}
+
+	// Batches and spooled Records are sent when the ticker fires next.
+	return interval + heartbeat
+} // func (ag *Agent) contactInterval() time.Duration
+
+// batching returns true if the Agent is configured to send Records in batches.
+func (ag *Agent) batching() bool {
+	return ag.cfg.BatchSize > 1
+} // func (ag *Agent) batching() bool
+
+// batchInterval returns the maximum amount of time the Agent holds on to
+// Records before sending them to the Server.
+func (ag *Agent) batchInterval() time.Duration {
+	if ag.cfg.BatchInterval <= 0 {
+		return time.Second * defaultBatchInterval
+	}
+
+	return time.Second * time.Duration(ag.cfg.BatchInterval)
+} // func (ag *Agent) batchInterval() time.Duration
+
+// flushBatch sends the Records collected so far to the Server. If that fails,
+// they are spooled, and the error is returned.
+func (ag *Agent) flushBatch() error {
+	var (
+		err  error
+		left []model.Record
+		recs = ag.batch
+	)
+
+	ag.batch = make([]model.Record, 0, ag.cfg.BatchSize)
+
+	if ag.spool.Len() > 0 {
+		for i := range recs {
+			ag.spoolRecord(&recs[i])
+		}
+		return ag.drainSpool()
+	} else if left, err = ag.sendBatch(recs); err != nil {
+		ag.log.Printf("[ERROR] Failed to report %d of %d Records to server, spooling them: %s\n",
+			len(left),
+			len(recs),
+			err.Error())
+		for i := range left {
+			ag.spoolRecord(&left[i])
+		}
+		ag.retryAt = time.Now().Add(ag.backoff)
+	}
+
+	return err
+} // func (ag *Agent) flushBatch() error
@@ -7,18 +7,47 @@
 package agent
 
 import (
+	"context"
+	"errors"
 	"time"
 
 	"github.com/blicero/donkey/model"
 )
 
+// ckInterval is the interval between two samples of a Probe, unless the
+// configuration says otherwise.
 const ckInterval = time.Millisecond * 5000
 
 // Probe defines an interface for components that gather data from the node and
-// return them as Records that can be sent back to the Server.
+// return them as Records that can be sent back to the Server. When to call
+// Collect is up to the probeManager.
 type Probe interface {
 	Collect() (*model.Record, error)
-	Run()
-	Running() bool
-	Stop()
+}
+
+// contextCollector is implemented by Probes that may take a while to collect
+// a sample, e.g. because they run an external command. The probeManager
+// cancels the context when it stops the Probe, so it does not have to wait
+// for the sample.
+type contextCollector interface {
+	CollectContext(ctx context.Context) (*model.Record, error)
+}
+
+// Some Probes are stateful: they compute their values from the difference
+// between two consecutive samples, e.g. to turn ever-increasing counters into
+// rates. Such a Probe keeps the previous sample itself, guarded by a mutex,
+// and returns errNoBaseline from Collect as long as it has nothing to
+// compare against. Callers should skip that error silently rather than
+// report it.
+var errNoBaseline = errors.New("no baseline sample, yet")
+
+// probeTypes maps the names used in the Probes section of the configuration
+// file to the constructors of the corresponding Probes.
+var probeTypes = map[string]probeFactory{
+	"load":       func(_ *config) (Probe, error) { return CreateLoadProbe() },
+	"sensors":    func(_ *config) (Probe, error) { return CreateSensorsProbe() },
+	"cpufreq":    func(_ *config) (Probe, error) { return CreateCPUFreqProbe() },
+	"ram":        func(_ *config) (Probe, error) { return CreateRAMProbe() },
+	"filesystem": func(c *config) (Probe, error) { return CreateFilesystemProbe(c.Filesystem) },
+	"netdev":     func(_ *config) (Probe, error) { return CreateNetDevProbe() },
 }
@@ -0,0 +1,123 @@
+// /home/krylon/go/src/github.com/blicero/donkey/agent/probe_cpufreq.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 17:32:15 krylon>
+
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blicero/donkey/common"
+	"github.com/blicero/donkey/logdomain"
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/recordtype"
+)
+
+const cpuDir = "sys/devices/system/cpu"
+
+var cpuDirPat = regexp.MustCompile(`^cpu(\d+)$`)
+
+// CPUFreqProbe gathers the clock frequencies of the CPU cores from sysfs.
+// It only works on Linux.
+type CPUFreqProbe struct {
+	log  *log.Logger
+	root string
+}
+
+// CreateCPUFreqProbe creates a Probe that collects the CPU frequencies periodically.
+func CreateCPUFreqProbe() (*CPUFreqProbe, error) {
+	var err error
+	p := &CPUFreqProbe{
+		root: "/",
+	}
+
+	if p.log, err = common.GetLogger(logdomain.Probe); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+} // func CreateCPUFreqProbe() (*CPUFreqProbe, error)
+
+// Collect reads the current frequency of each CPU core and wraps them in a Record.
+func (p *CPUFreqProbe) Collect() (*model.Record, error) {
+	var (
+		err     error
+		entries []os.DirEntry
+		buf     []byte
+		freq    model.CPUFreq
+		dir     = filepath.Join(p.root, cpuDir)
+	)
+
+	if entries, err = os.ReadDir(dir); err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		var (
+			match []string
+			core  model.CoreFreq
+			fdir  = filepath.Join(dir, e.Name(), "cpufreq")
+		)
+
+		if match = cpuDirPat.FindStringSubmatch(e.Name()); match == nil {
+			continue
+		} else if core.CPU, err = strconv.Atoi(match[1]); err != nil {
+			continue
+		} else if core.Cur, err = readSysInt(filepath.Join(fdir, "scaling_cur_freq")); err != nil {
+			// Some drivers only provide cpuinfo_cur_freq, and offline
+			// cores have no cpufreq folder at all.
+			if core.Cur, err = readSysInt(filepath.Join(fdir, "cpuinfo_cur_freq")); err != nil {
+				continue
+			}
+		}
+
+		core.Min, _ = readSysInt(filepath.Join(fdir, "cpuinfo_min_freq"))
+		core.Max, _ = readSysInt(filepath.Join(fdir, "cpuinfo_max_freq"))
+
+		freq.Cores = append(freq.Cores, core)
+	}
+
+	if len(freq.Cores) == 0 {
+		return nil, errors.New("No CPU frequency information found in " + dir)
+	}
+
+	sort.Slice(freq.Cores, func(i, j int) bool { return freq.Cores[i].CPU < freq.Cores[j].CPU })
+
+	if buf, err = json.Marshal(&freq); err != nil {
+		return nil, err
+	}
+
+	var rec = &model.Record{
+		Timestamp: time.Now(),
+		Source:    recordtype.CPUFreq,
+		Payload:   string(buf),
+	}
+
+	return rec, nil
+} // func (p *CPUFreqProbe) Collect() (*model.Record, error)
+
+// readSysInt reads a file that contains a single integer, as is common in
+// sysfs and procfs.
+func readSysInt(path string) (int64, error) {
+	var (
+		err error
+		buf []byte
+	)
+
+	if buf, err = os.ReadFile(path); err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(strings.TrimSpace(string(buf)), 10, 64)
+} // func readSysInt(path string) (int64, error)
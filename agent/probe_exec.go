@@ -0,0 +1,195 @@
+// /home/krylon/go/src/github.com/blicero/donkey/agent/probe_exec.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 12:07:36 krylon>
+
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/blicero/donkey/common"
+	"github.com/blicero/donkey/logdomain"
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/recordtype"
+)
+
+// Unless configured otherwise, checks run every defaultCheckInterval and are
+// killed after defaultCheckTimeout. Output beyond maxCheckOutput is
+// discarded.
+const (
+	defaultCheckInterval = time.Minute
+	defaultCheckTimeout  = time.Second * 30
+	maxCheckOutput       = 64 * 1024
+)
+
+// checkProbePrefix is prepended to the name of a check to form the name of
+// its Probe.
+const checkProbePrefix = "check/"
+
+// checkConfig describes an external check command that follows the Nagios
+// plugin conventions. Interval and Timeout are given in seconds.
+type checkConfig struct {
+	Name     string
+	Command  string
+	Args     []string `json:",omitempty"`
+	Interval int      `json:",omitempty"`
+	Timeout  int      `json:",omitempty"`
+}
+
+// validate checks the configuration of a check for nonsensical values.
+func (c *checkConfig) validate() error {
+	if c.Name == "" {
+		return errors.New("Check has no name")
+	} else if c.Command == "" {
+		return fmt.Errorf("Check %s has no command", c.Name)
+	} else if c.Interval < 0 || c.Timeout < 0 {
+		return fmt.Errorf("Check %s: interval and timeout must not be negative", c.Name)
+	}
+
+	return nil
+} // func (c *checkConfig) validate() error
+
+func (c *checkConfig) interval() time.Duration {
+	if c.Interval == 0 {
+		return defaultCheckInterval
+	}
+
+	return time.Second * time.Duration(c.Interval)
+} // func (c *checkConfig) interval() time.Duration
+
+func (c *checkConfig) timeout() time.Duration {
+	if c.Timeout == 0 {
+		return defaultCheckTimeout
+	}
+
+	return time.Second * time.Duration(c.Timeout)
+} // func (c *checkConfig) timeout() time.Duration
+
+// limitedBuffer is a bytes.Buffer that silently drops anything written to it
+// beyond its limit, so a runaway check cannot eat up the Agent's memory.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	var n = len(p)
+
+	if room := b.limit - b.Len(); room < len(p) {
+		p = p[:max(room, 0)]
+	}
+
+	b.Buffer.Write(p) // nolint: errcheck
+	return n, nil
+} // func (b *limitedBuffer) Write(p []byte) (int, error)
+
+// ExecProbe runs an external check command, e.g. a Nagios or Icinga plugin,
+// and reports its status, output and performance data.
+type ExecProbe struct {
+	log   *log.Logger
+	check *checkConfig
+}
+
+// CreateExecProbe creates a Probe that runs the given check command.
+func CreateExecProbe(check *checkConfig) (*ExecProbe, error) {
+	var err error
+
+	if err = check.validate(); err != nil {
+		return nil, err
+	}
+
+	p := &ExecProbe{
+		check: check,
+	}
+
+	if p.log, err = common.GetLogger(logdomain.Probe); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+} // func CreateExecProbe(check *checkConfig) (*ExecProbe, error)
+
+// Collect runs the check and wraps its result in a Record.
+func (p *ExecProbe) Collect() (*model.Record, error) {
+	return p.CollectContext(context.Background())
+} // func (p *ExecProbe) Collect() (*model.Record, error)
+
+// CollectContext runs the check and wraps its result in a Record. A check
+// that exits with a status other than OK is not an error, neither is a
+// check that times out, it is reported as UNKNOWN. Only failing to run the
+// command at all is, or ctx being cancelled before the check finished.
+func (p *ExecProbe) CollectContext(parent context.Context) (*model.Record, error) {
+	var (
+		err            error
+		buf            []byte
+		cmd            *exec.Cmd
+		ctx            context.Context
+		cancel         context.CancelFunc
+		bufOut, bufErr = limitedBuffer{limit: maxCheckOutput}, limitedBuffer{limit: maxCheckOutput}
+		exitErr        *exec.ExitError
+		begin          = time.Now()
+		timeout        = p.check.timeout()
+		check          = &model.Check{Name: p.check.Name}
+	)
+
+	ctx, cancel = context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	cmd = exec.CommandContext(ctx, p.check.Command, p.check.Args...)
+	cmd.Stdout = &bufOut
+	cmd.Stderr = &bufErr
+	// Plugins that spawn children of their own could otherwise keep the
+	// pipes open and make us wait for them after the timeout.
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	check.Duration = time.Since(begin).Seconds()
+
+	switch {
+	case parent.Err() != nil:
+		return nil, fmt.Errorf("Check %s was aborted: %w",
+			p.check.Name,
+			parent.Err())
+	case ctx.Err() == context.DeadlineExceeded:
+		check.Status = model.CheckUnknown
+		check.Output = fmt.Sprintf("Check timed out after %s", timeout)
+	case errors.As(err, &exitErr):
+		if check.Status = exitErr.ExitCode(); check.Status < model.CheckOK || check.Status > model.CheckUnknown {
+			check.Status = model.CheckUnknown
+		}
+		check.Output, check.LongOutput, check.Perf = model.ParseCheckOutput(bufOut.Bytes())
+	case err != nil:
+		return nil, fmt.Errorf("Failed to run check %s: %s - %s",
+			p.check.Name,
+			err.Error(),
+			strings.TrimSpace(bufErr.String()))
+	default:
+		check.Status = model.CheckOK
+		check.Output, check.LongOutput, check.Perf = model.ParseCheckOutput(bufOut.Bytes())
+	}
+
+	if check.Output == "" {
+		// Some plugins report problems on stderr only.
+		check.Output, _, _ = model.ParseCheckOutput(bufErr.Bytes())
+	}
+
+	if buf, err = json.Marshal(check); err != nil {
+		return nil, err
+	}
+
+	return &model.Record{
+		Timestamp: time.Now(),
+		Source:    recordtype.Check,
+		Payload:   string(buf),
+	}, nil
+} // func (p *ExecProbe) CollectContext(parent context.Context) (*model.Record, error)
@@ -0,0 +1,244 @@
+// /home/krylon/go/src/github.com/blicero/donkey/agent/probe_filesystem.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 19:02:37 krylon>
+
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blicero/donkey/common"
+	"github.com/blicero/donkey/logdomain"
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/recordtype"
+)
+
+const mountsPath = "proc/self/mounts"
+
+// pseudoFS lists file system types that do not store any data we care about.
+// They are ignored unless they are listed in fsFilter.IncludeFS explicitly.
+var pseudoFS = map[string]bool{
+	"autofs":      true,
+	"binfmt_misc": true,
+	"bpf":         true,
+	"cgroup":      true,
+	"cgroup2":     true,
+	"configfs":    true,
+	"debugfs":     true,
+	"devpts":      true,
+	"devtmpfs":    true,
+	"efivarfs":    true,
+	"fusectl":     true,
+	"hugetlbfs":   true,
+	"mqueue":      true,
+	"nsfs":        true,
+	"proc":        true,
+	"pstore":      true,
+	"ramfs":       true,
+	"rpc_pipefs":  true,
+	"securityfs":  true,
+	"selinuxfs":   true,
+	"squashfs":    true,
+	"sysfs":       true,
+	"tracefs":     true,
+}
+
+// fsFilter decides which file systems the FilesystemProbe reports on.
+// File system types are matched exactly, mount points are matched as
+// patterns as understood by filepath.Match. An empty Include list means
+// everything that is not excluded is included.
+type fsFilter struct {
+	IncludeFS     []string `json:",omitempty"`
+	ExcludeFS     []string `json:",omitempty"`
+	IncludeMounts []string `json:",omitempty"`
+	ExcludeMounts []string `json:",omitempty"`
+}
+
+// match returns true if the file system type and mount point pass the filter.
+func (f *fsFilter) match(fstype, mountpoint string) bool {
+	if f == nil {
+		return !pseudoFS[fstype]
+	}
+
+	if len(f.IncludeFS) > 0 {
+		if !matchAny(f.IncludeFS, fstype, false) {
+			return false
+		}
+	} else if pseudoFS[fstype] {
+		return false
+	}
+
+	if matchAny(f.ExcludeFS, fstype, false) {
+		return false
+	} else if len(f.IncludeMounts) > 0 && !matchAny(f.IncludeMounts, mountpoint, true) {
+		return false
+	}
+
+	return !matchAny(f.ExcludeMounts, mountpoint, true)
+} // func (f *fsFilter) match(fstype, mountpoint string) bool
+
+func matchAny(patterns []string, s string, glob bool) bool {
+	for _, p := range patterns {
+		if !glob {
+			if p == s {
+				return true
+			}
+		} else if ok, _ := filepath.Match(p, s); ok {
+			return true
+		}
+	}
+
+	return false
+} // func matchAny(patterns []string, s string, glob bool) bool
+
+// FilesystemProbe gathers the space and inode usage of mounted file systems.
+type FilesystemProbe struct {
+	log    *log.Logger
+	root   string
+	filter *fsFilter
+}
+
+// CreateFilesystemProbe creates a Probe that collects file system usage
+// periodically. If filter is nil, all file systems except pseudo file systems
+// like proc or sysfs are reported.
+func CreateFilesystemProbe(filter *fsFilter) (*FilesystemProbe, error) {
+	var err error
+	p := &FilesystemProbe{
+		root:   "/",
+		filter: filter,
+	}
+
+	if p.log, err = common.GetLogger(logdomain.Probe); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+} // func CreateFilesystemProbe(filter *fsFilter) (*FilesystemProbe, error)
+
+// Collect gathers the usage of all mounted file systems that pass the filter
+// and wraps them in a Record.
+func (p *FilesystemProbe) Collect() (*model.Record, error) {
+	var (
+		err    error
+		mounts []model.MountUsage
+		buf    []byte
+		data   model.Filesystem
+	)
+
+	if mounts, err = p.mounts(); err != nil {
+		return nil, err
+	}
+
+	data.Mounts = make([]model.MountUsage, 0, len(mounts))
+
+	for _, m := range mounts {
+		if err = statfs(&m); err != nil {
+			p.log.Printf("[DEBUG] Cannot stat file system at %s: %s\n",
+				m.Mountpoint,
+				err.Error())
+			continue
+		} else if m.Total == 0 {
+			continue
+		}
+
+		data.Mounts = append(data.Mounts, m)
+	}
+
+	if len(data.Mounts) == 0 {
+		return nil, errors.New("No file systems to report on")
+	} else if buf, err = json.Marshal(&data); err != nil {
+		return nil, err
+	}
+
+	var rec = &model.Record{
+		Timestamp: time.Now(),
+		Source:    recordtype.Filesystem,
+		Payload:   string(buf),
+	}
+
+	return rec, nil
+} // func (p *FilesystemProbe) Collect() (*model.Record, error)
+
+// mounts reads the list of mounted file systems and returns those that pass
+// the filter. If a mount point appears more than once, the last entry wins,
+// because it hides the ones before it.
+func (p *FilesystemProbe) mounts() ([]model.MountUsage, error) {
+	var (
+		err    error
+		fh     *os.File
+		seen   = make(map[string]int)
+		mounts = make([]model.MountUsage, 0, 16)
+		path   = filepath.Join(p.root, mountsPath)
+	)
+
+	if fh, err = os.Open(path); err != nil {
+		return nil, err
+	}
+
+	defer fh.Close() // nolint: errcheck
+
+	var scanner = bufio.NewScanner(fh)
+
+	for scanner.Scan() {
+		var (
+			idx    int
+			found  bool
+			fields = strings.Fields(scanner.Text())
+		)
+
+		if len(fields) < 3 {
+			continue
+		}
+
+		var m = model.MountUsage{
+			Device:     unescapeMount(fields[0]),
+			Mountpoint: unescapeMount(fields[1]),
+			FSType:     fields[2],
+		}
+
+		if !p.filter.match(m.FSType, m.Mountpoint) {
+			continue
+		} else if idx, found = seen[m.Mountpoint]; found {
+			mounts[idx] = m
+			continue
+		}
+
+		seen[m.Mountpoint] = len(mounts)
+		mounts = append(mounts, m)
+	}
+
+	return mounts, scanner.Err()
+} // func (p *FilesystemProbe) mounts() ([]model.MountUsage, error)
+
+// unescapeMount decodes the octal escape sequences the kernel uses for
+// whitespace and backslashes in /proc/self/mounts, e.g. "\040" for a space.
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var bld strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				bld.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		bld.WriteByte(s[i])
+	}
+
+	return bld.String()
+} // func unescapeMount(s string) string
@@ -9,7 +9,6 @@ package agent
 import (
 	"encoding/json"
 	"log"
-	"sync/atomic"
 	"time"
 
 	"github.com/blicero/donkey/common"
@@ -25,24 +24,20 @@ import "C"
 
 // LoadProbe gathers the system load average.
 type LoadProbe struct {
-	active  atomic.Bool
-	recordQ chan<- model.Record
-	log     *log.Logger
+	log *log.Logger
 }
 
 // CreateLoadProbe creates a Probe that collects the system load average periodically.
-func CreateLoadProbe(q chan<- model.Record) (*LoadProbe, error) {
+func CreateLoadProbe() (*LoadProbe, error) {
 	var err error
-	p := &LoadProbe{
-		recordQ: q,
-	}
+	p := &LoadProbe{}
 
 	if p.log, err = common.GetLogger(logdomain.Probe); err != nil {
 		return nil, err
 	}
 
 	return p, nil
-} // func CreateLoadProbe(q chan<- model.Record) (*LoadProbe, error)
+} // func CreateLoadProbe() (*LoadProbe, error)
 
 // Collect gathers the system load averages and wraps them in a Record.
 func (p *LoadProbe) Collect() (*model.Record, error) {
@@ -72,37 +67,3 @@ func (p *LoadProbe) Collect() (*model.Record, error) {
 
 	return record, nil
 } // func (p *LoadProbe) Collect() (*model.Record, error)
-
-// Running returns true if the Probe is active.
-func (p *LoadProbe) Running() bool {
-	return p.active.Load()
-} // func (p *LoadProbe) Running() bool
-
-// Stop tells the Probe to stop.
-func (p *LoadProbe) Stop() {
-	p.active.Store(false)
-} // func (p *LoadProbe) Stop()
-
-// Run executes the Probe's collect loop, this is usually executed in a separate goroutine.
-func (p *LoadProbe) Run() {
-	p.active.Store(true)
-	defer p.active.Store(false)
-
-	var ticker = time.NewTicker(ckInterval)
-	defer ticker.Stop()
-
-	for p.active.Load() {
-		var (
-			err error
-			rec *model.Record
-		)
-
-		<-ticker.C
-		if rec, err = p.Collect(); err != nil {
-			p.log.Printf("[ERROR] Failed to collect Load Average: %s\n",
-				err.Error())
-		} else {
-			p.recordQ <- *rec
-		}
-	}
-} // func (p *LoadProbe) Run()
@@ -0,0 +1,254 @@
+// /home/krylon/go/src/github.com/blicero/donkey/agent/probe_netdev.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 19:52:37 krylon>
+
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blicero/donkey/common"
+	"github.com/blicero/donkey/logdomain"
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/recordtype"
+)
+
+const netdevPath = "proc/net/dev"
+
+// Indices of the counters we care about in the lines of /proc/net/dev,
+// after the interface name has been removed.
+const (
+	ndRxBytes   = 0
+	ndRxPackets = 1
+	ndRxErrors  = 2
+	ndRxDrops   = 3
+	ndTxBytes   = 8
+	ndTxPackets = 9
+	ndTxErrors  = 10
+	ndTxDrops   = 11
+	ndFieldCnt  = 16
+)
+
+// netCounters holds the raw counters of a network interface.
+type netCounters [ndFieldCnt]uint64
+
+// NetDevProbe computes the throughput of the network interfaces from the
+// counters in /proc/net/dev. It only works on Linux.
+//
+// The rates are computed from the difference between two consecutive
+// samples, so the first call to Collect only records a baseline and returns
+// errNoBaseline.
+type NetDevProbe struct {
+	log    *log.Logger
+	root   string
+	now    func() time.Time
+	lock   sync.Mutex
+	prev   map[string]netCounters
+	prevAt time.Time
+}
+
+// CreateNetDevProbe creates a Probe that collects network throughput
+// periodically.
+func CreateNetDevProbe() (*NetDevProbe, error) {
+	var err error
+	p := &NetDevProbe{
+		root: "/",
+		now:  time.Now,
+	}
+
+	if p.log, err = common.GetLogger(logdomain.Probe); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+} // func CreateNetDevProbe() (*NetDevProbe, error)
+
+// Collect reads the interface counters and computes the rates since the
+// previous call.
+func (p *NetDevProbe) Collect() (*model.Record, error) {
+	var (
+		err      error
+		buf      []byte
+		now      time.Time
+		counters map[string]netCounters
+		net      model.Network
+	)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now = p.now()
+
+	if counters, err = p.read(); err != nil {
+		return nil, err
+	}
+
+	// Whatever happens below, the current sample becomes the baseline for
+	// the next call. Interfaces that have disappeared are dropped that way,
+	// too.
+	var prev, prevAt = p.prev, p.prevAt
+	p.prev, p.prevAt = counters, now
+
+	if prev == nil {
+		return nil, errNoBaseline
+	}
+
+	net.Interval = now.Sub(prevAt).Seconds()
+	if net.Interval <= 0 {
+		return nil, errNoBaseline
+	}
+
+	var names = make([]string, 0, len(counters))
+	for name := range counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	net.Interfaces = make([]model.IfaceRates, 0, len(counters))
+
+	for _, name := range names {
+		var (
+			old   netCounters
+			found bool
+			cur   = counters[name]
+		)
+
+		// An interface that has just appeared needs a baseline first.
+		if old, found = prev[name]; !found {
+			continue
+		}
+
+		var (
+			reset bool
+			rate  = func(idx int) float64 {
+				var delta, ok = counterDelta(old[idx], cur[idx])
+				reset = reset || !ok
+				return float64(delta) / net.Interval
+			}
+			rates = model.IfaceRates{
+				Name:      name,
+				RxBytes:   rate(ndRxBytes),
+				TxBytes:   rate(ndTxBytes),
+				RxPackets: rate(ndRxPackets),
+				TxPackets: rate(ndTxPackets),
+				RxErrors:  rate(ndRxErrors),
+				TxErrors:  rate(ndTxErrors),
+				RxDrops:   rate(ndRxDrops),
+				TxDrops:   rate(ndTxDrops),
+			}
+		)
+
+		// Like one that has just appeared, an interface whose counters
+		// were reset needs a new baseline.
+		if reset {
+			p.log.Printf("[INFO] Counters of interface %s were reset, skipping it\n",
+				name)
+			continue
+		}
+
+		net.Interfaces = append(net.Interfaces, rates)
+	}
+
+	if buf, err = json.Marshal(&net); err != nil {
+		return nil, err
+	}
+
+	var rec = &model.Record{
+		Timestamp: now,
+		Source:    recordtype.Network,
+		Payload:   string(buf),
+	}
+
+	return rec, nil
+} // func (p *NetDevProbe) Collect() (*model.Record, error)
+
+// read parses /proc/net/dev, which looks like this:
+//
+//	Inter-|   Receive                            ...|  Transmit
+//	 face |bytes    packets errs drop fifo frame ...|bytes    packets ...
+//	    lo:  123456     789    0    0    0     0 ...
+func (p *NetDevProbe) read() (map[string]netCounters, error) {
+	var (
+		err      error
+		fh       *os.File
+		path     = filepath.Join(p.root, netdevPath)
+		counters = make(map[string]netCounters)
+	)
+
+	if fh, err = os.Open(path); err != nil {
+		return nil, err
+	}
+
+	defer fh.Close() // nolint: errcheck
+
+	var scanner = bufio.NewScanner(fh)
+
+	for scanner.Scan() {
+		var (
+			name, rest string
+			found      bool
+			fields     []string
+			c          netCounters
+		)
+
+		if name, rest, found = strings.Cut(scanner.Text(), ":"); !found {
+			// The two header lines contain no colon.
+			continue
+		} else if fields = strings.Fields(rest); len(fields) < ndFieldCnt {
+			return nil, fmt.Errorf("Cannot parse line in %s: %q",
+				path,
+				scanner.Text())
+		}
+
+		for i := range c {
+			if c[i], err = strconv.ParseUint(fields[i], 10, 64); err != nil {
+				return nil, fmt.Errorf("Cannot parse line in %s: %q - %s",
+					path,
+					scanner.Text(),
+					err.Error())
+			}
+		}
+
+		counters[strings.TrimSpace(name)] = c
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return counters, nil
+} // func (p *NetDevProbe) read() (map[string]netCounters, error)
+
+// counterWrapMargin is how close to 2^32 the previous value of a counter has
+// to be for us to take a decrease for a 32 bit counter wrapping around.
+const counterWrapMargin = 1 << 30
+
+// counterDelta returns the difference between two samples of a counter.
+// If the counter went backwards, it either wrapped around, or it was reset,
+// e.g. because the interface was re-created or its driver reloaded.
+// Depending on the kernel and the driver, counters may be 32 or 64 bits
+// wide. A 64 bit counter does not wrap around in practice, so unless the
+// previous value was close to the limit of a 32 bit counter, we consider
+// the counter reset and return false.
+func counterDelta(prev, cur uint64) (uint64, bool) {
+	if cur >= prev {
+		return cur - prev, true
+	} else if prev <= math.MaxUint32 && math.MaxUint32-prev < counterWrapMargin {
+		return (math.MaxUint32 - prev) + cur + 1, true
+	}
+
+	return 0, false
+} // func counterDelta(prev, cur uint64) (uint64, bool)
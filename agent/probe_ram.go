@@ -0,0 +1,120 @@
+// /home/krylon/go/src/github.com/blicero/donkey/agent/probe_ram.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 17:48:02 krylon>
+
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blicero/donkey/common"
+	"github.com/blicero/donkey/logdomain"
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/recordtype"
+)
+
+const meminfoPath = "proc/meminfo"
+
+// RAMProbe gathers memory and swap usage from /proc/meminfo.
+// It only works on Linux.
+type RAMProbe struct {
+	log  *log.Logger
+	root string
+}
+
+// CreateRAMProbe creates a Probe that collects memory usage periodically.
+func CreateRAMProbe() (*RAMProbe, error) {
+	var err error
+	p := &RAMProbe{
+		root: "/",
+	}
+
+	if p.log, err = common.GetLogger(logdomain.Probe); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+} // func CreateRAMProbe() (*RAMProbe, error)
+
+// Collect reads the memory statistics and wraps them in a Record.
+func (p *RAMProbe) Collect() (*model.Record, error) {
+	var (
+		err  error
+		fh   *os.File
+		buf  []byte
+		ram  model.RAM
+		path = filepath.Join(p.root, meminfoPath)
+	)
+
+	if fh, err = os.Open(path); err != nil {
+		return nil, err
+	}
+
+	defer fh.Close() // nolint: errcheck
+
+	// The lines in /proc/meminfo look like this:
+	// MemTotal:       16318480 kB
+	var fields = map[string]*uint64{
+		"MemTotal":     &ram.Total,
+		"MemFree":      &ram.Free,
+		"MemAvailable": &ram.Available,
+		"Buffers":      &ram.Buffers,
+		"Cached":       &ram.Cached,
+		"SwapTotal":    &ram.SwapTotal,
+		"SwapFree":     &ram.SwapFree,
+	}
+
+	var scanner = bufio.NewScanner(fh)
+
+	for scanner.Scan() {
+		var (
+			val    uint64
+			dst    *uint64
+			found  bool
+			pieces = strings.Fields(scanner.Text())
+		)
+
+		if len(pieces) < 2 {
+			continue
+		} else if dst, found = fields[strings.TrimSuffix(pieces[0], ":")]; !found {
+			continue
+		} else if val, err = strconv.ParseUint(pieces[1], 10, 64); err != nil {
+			return nil, fmt.Errorf("Cannot parse line in %s: %q - %s",
+				path,
+				scanner.Text(),
+				err.Error())
+		}
+
+		if len(pieces) > 2 && pieces[2] == "kB" {
+			val *= 1024
+		}
+
+		*dst = val
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	} else if ram.Total == 0 {
+		return nil, fmt.Errorf("Did not find MemTotal in %s", path)
+	} else if buf, err = json.Marshal(&ram); err != nil {
+		return nil, err
+	}
+
+	var rec = &model.Record{
+		Timestamp: time.Now(),
+		Source:    recordtype.RAM,
+		Payload:   string(buf),
+	}
+
+	return rec, nil
+} // func (p *RAMProbe) Collect() (*model.Record, error)
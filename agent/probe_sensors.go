@@ -8,9 +8,16 @@ package agent
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"os"
 	"os/exec"
-	"sync/atomic"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/blicero/donkey/common"
@@ -19,20 +26,26 @@ import (
 	"github.com/blicero/donkey/model/recordtype"
 )
 
-const prog = "sensors"
+const (
+	prog      = "sensors"
+	hwmonPath = "sys/class/hwmon"
+)
+
+var hwmonInputPat = regexp.MustCompile(`^((temp|fan|in)\d+)_input$`)
 
 // SensorsProbe gathers data from sensors, most importantly temperature.
+// It uses lm-sensors if it is installed and falls back to reading the hwmon
+// subsystem in sysfs directly otherwise.
 type SensorsProbe struct {
-	active  atomic.Bool
-	recordQ chan<- model.Record
-	log     *log.Logger
+	log  *log.Logger
+	root string
 }
 
 // CreateSensorsProbe creates a Probe that queries the sensors attached to the system periodically.
-func CreateSensorsProbe(recQ chan<- model.Record) (*SensorsProbe, error) {
+func CreateSensorsProbe() (*SensorsProbe, error) {
 	var err error
 	p := &SensorsProbe{
-		recordQ: recQ,
+		root: "/",
 	}
 
 	if p.log, err = common.GetLogger(logdomain.Probe); err != nil {
@@ -44,11 +57,41 @@ func CreateSensorsProbe(recQ chan<- model.Record) (*SensorsProbe, error) {
 
 // Collect data from the sensors
 func (p *SensorsProbe) Collect() (*model.Record, error) {
+	var (
+		err     error
+		buf     []byte
+		sensors *model.Sensors
+		rec     = &model.Record{Source: recordtype.Sensors}
+	)
+
+	if _, err = exec.LookPath(prog); err != nil {
+		p.log.Printf("[TRACE] %s is not available, reading hwmon instead\n",
+			prog)
+		sensors, err = p.readHwmon()
+	} else {
+		sensors, err = p.readSensors()
+	}
+
+	if err != nil {
+		return nil, err
+	} else if len(sensors.Readings) == 0 {
+		return nil, errors.New("No sensor readings found")
+	} else if buf, err = json.Marshal(sensors); err != nil {
+		return nil, err
+	}
+
+	rec.Timestamp = time.Now()
+	rec.Payload = string(buf)
+
+	return rec, nil
+} // func (p *SensorsProbe) Collect() (*model.Record, error)
+
+// readSensors runs `sensors -j` and parses its output.
+func (p *SensorsProbe) readSensors() (*model.Sensors, error) {
 	var (
 		err            error
 		bufOut, bufErr bytes.Buffer
 		cmd            *exec.Cmd
-		rec            = &model.Record{Source: recordtype.Sensors}
 	)
 
 	cmd = exec.Command(prog, "-j")
@@ -56,46 +99,121 @@ func (p *SensorsProbe) Collect() (*model.Record, error) {
 	cmd.Stderr = &bufErr
 
 	if err = cmd.Run(); err != nil {
+		return nil, fmt.Errorf("Failed to run %s: %s - %s",
+			prog,
+			err.Error(),
+			strings.TrimSpace(bufErr.String()))
+	}
+
+	return model.ParseSensorsJSON(bufOut.Bytes())
+} // func (p *SensorsProbe) readSensors() (*model.Sensors, error)
+
+// readHwmon reads the sensors from /sys/class/hwmon. The kernel reports
+// temperatures in millidegrees Celsius and voltages in millivolts.
+func (p *SensorsProbe) readHwmon() (*model.Sensors, error) {
+	var (
+		err     error
+		devices []os.DirEntry
+		base    = filepath.Join(p.root, hwmonPath)
+		sensors = &model.Sensors{Readings: make([]model.SensorReading, 0)}
+	)
+
+	if devices, err = os.ReadDir(base); err != nil {
 		return nil, err
 	}
 
-	rec.Timestamp = time.Now()
-	rec.Payload = bufOut.String()
+	for _, d := range devices {
+		var (
+			chip, adapter string
+			link          string
+			files         []os.DirEntry
+			dir           = filepath.Join(base, d.Name())
+		)
 
-	return rec, nil
-} // func (p *SensorsProbe) Collect() (*model.Record, error)
+		if chip = readSysString(filepath.Join(dir, "name")); chip == "" {
+			continue
+		} else if files, err = os.ReadDir(dir); err != nil {
+			p.log.Printf("[ERROR] Cannot read %s: %s\n",
+				dir,
+				err.Error())
+			continue
+		}
 
-// Running returns the Probe's active flag
-func (p *SensorsProbe) Running() bool {
-	return p.active.Load()
-}
+		if link, err = os.Readlink(filepath.Join(dir, "device", "subsystem")); err == nil {
+			adapter = filepath.Base(link)
+		}
 
-// Stop clears the Probe's active flag
-func (p *SensorsProbe) Stop() {
-	p.active.Store(false)
-}
+		for _, f := range files {
+			var (
+				val, scale float64
+				r          model.SensorReading
+				m          = hwmonInputPat.FindStringSubmatch(f.Name())
+			)
+
+			if m == nil {
+				continue
+			} else if val, err = readSysFloat(filepath.Join(dir, f.Name())); err != nil {
+				continue
+			}
+
+			switch m[2] {
+			case "temp":
+				r.Kind, scale = model.SensorTemp, 1000
+			case "fan":
+				r.Kind, scale = model.SensorFan, 1
+			case "in":
+				r.Kind, scale = model.SensorVoltage, 1000
+			}
+
+			r.Chip = chip
+			r.Adapter = adapter
+			r.Value = val / scale
+
+			if r.Label = readSysString(filepath.Join(dir, m[1]+"_label")); r.Label == "" {
+				r.Label = m[1]
+			}
+
+			if high, err := readSysFloat(filepath.Join(dir, m[1]+"_max")); err == nil {
+				high /= scale
+				r.High = &high
+			}
+
+			if crit, err := readSysFloat(filepath.Join(dir, m[1]+"_crit")); err == nil {
+				crit /= scale
+				r.Crit = &crit
+			}
+
+			sensors.Readings = append(sensors.Readings, r)
+		}
+	}
 
-// Run executes the Probe's collect loop, this is usually executed in a separate goroutine.
-func (p *SensorsProbe) Run() {
-	p.active.Store(true)
-	defer p.active.Store(false)
+	sensors.Sort()
 
-	var ticker = time.NewTicker(ckInterval)
-	defer ticker.Stop()
+	return sensors, nil
+} // func (p *SensorsProbe) readHwmon() (*model.Sensors, error)
 
-	for p.active.Load() {
-		var (
-			err error
-			rec *model.Record
-		)
+// readSysString returns the content of a sysfs attribute with surrounding
+// whitespace removed, or an empty string if it cannot be read.
+func readSysString(path string) string {
+	var buf, err = os.ReadFile(path)
 
-		<-ticker.C
+	if err != nil {
+		return ""
+	}
 
-		if rec, err = p.Collect(); err != nil {
-			p.log.Printf("[ERROR] Failed to collect record: %s\n",
-				err.Error())
-		} else {
-			p.recordQ <- *rec
-		}
+	return strings.TrimSpace(string(buf))
+} // func readSysString(path string) string
+
+// readSysFloat reads a numeric sysfs attribute.
+func readSysFloat(path string) (float64, error) {
+	var (
+		err error
+		buf []byte
+	)
+
+	if buf, err = os.ReadFile(path); err != nil {
+		return 0, err
 	}
-} // func (p *LoadProbe) Run()
+
+	return strconv.ParseFloat(strings.TrimSpace(string(buf)), 64)
+} // func readSysFloat(path string) (float64, error)
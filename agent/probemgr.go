@@ -0,0 +1,518 @@
+// /home/krylon/go/src/github.com/blicero/donkey/agent/probemgr.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 09:02:37 krylon>
+
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blicero/donkey/common"
+	"github.com/blicero/donkey/logdomain"
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/recordtype"
+)
+
+// A Probe that panics, that cannot be created, or whose Collect fails
+// probeMaxErrors times in a row is considered crashed. The probeManager
+// creates it anew after a delay that starts at probeBackoffMin and doubles
+// with every crash, up to probeBackoffMax. Once a Probe has run for
+// probeBackoffMax without crashing, the delay is reset.
+const (
+	probeBackoffMin     = time.Second * 5
+	probeBackoffMax     = time.Minute * 5
+	probeMaxErrors      = 5
+	probeHealthInterval = time.Minute
+)
+
+// probeFactory creates a Probe from the Agent's configuration.
+type probeFactory func(cfg *config) (Probe, error)
+
+// probeHandle holds a Probe along with what the probeManager knows about its
+// health.
+type probeHandle struct {
+	name     string
+	create   probeFactory
+	interval time.Duration
+	cfg      *config
+	check    *checkConfig
+	done     chan struct{}
+	stopped  chan struct{}
+	lock     sync.Mutex
+	probe    Probe
+	running  bool
+	errors   uint64
+	failures int
+	restarts uint64
+	lastErr  string
+}
+
+// status returns the Probe's health.
+func (h *probeHandle) status() model.ProbeStatus {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return model.ProbeStatus{
+		Name:      h.name,
+		Running:   h.running,
+		Interval:  h.interval.Seconds(),
+		Errors:    h.errors,
+		Restarts:  h.restarts,
+		LastError: h.lastErr,
+	}
+} // func (h *probeHandle) status() model.ProbeStatus
+
+func (h *probeHandle) setRunning(running bool) {
+	h.lock.Lock()
+	h.running = running
+	h.lock.Unlock()
+} // func (h *probeHandle) setRunning(running bool)
+
+// failed records an error returned by the Probe and returns the number of
+// errors in a row.
+func (h *probeHandle) failed(err error) int {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.errors++
+	h.failures++
+	h.lastErr = err.Error()
+	return h.failures
+} // func (h *probeHandle) failed(err error) int
+
+func (h *probeHandle) succeeded() {
+	h.lock.Lock()
+	h.failures = 0
+	h.lock.Unlock()
+} // func (h *probeHandle) succeeded()
+
+// crashed records that the Probe crashed. The next attempt to run it
+// creates a new one.
+func (h *probeHandle) crashed(err error) {
+	h.probe = nil
+	h.lock.Lock()
+	h.running = false
+	h.failures = 0
+	h.restarts++
+	h.lastErr = err.Error()
+	h.lock.Unlock()
+} // func (h *probeHandle) crashed(err error)
+
+// probeManager runs the Probes listed in the Agent's configuration, each in
+// its own goroutine and at its own interval, restarts them when they crash,
+// and periodically sends a ProbeHealth Record about them.
+type probeManager struct {
+	log        *log.Logger
+	recordq    chan<- model.Record
+	lock       sync.Mutex
+	probes     []*probeHandle
+	active     bool
+	done       chan struct{}
+	wg         sync.WaitGroup
+	backoffMin time.Duration
+	backoffMax time.Duration
+	healthIntv time.Duration
+}
+
+// newProbeManager creates a probeManager for the Probes in the given
+// configuration. The Probes themselves are created when the probeManager
+// is started. Probes of unknown types are skipped.
+func newProbeManager(q chan<- model.Record, cfg *config) (*probeManager, error) {
+	var (
+		err error
+		m   = &probeManager{
+			recordq:    q,
+			backoffMin: probeBackoffMin,
+			backoffMax: probeBackoffMax,
+			healthIntv: probeHealthInterval,
+		}
+	)
+
+	if m.log, err = common.GetLogger(logdomain.Probe); err != nil {
+		return nil, err
+	}
+
+	m.probes = m.handles(cfg)
+
+	return m, nil
+} // func newProbeManager(q chan<- model.Record, cfg *config) (*probeManager, error)
+
+// handles creates a probeHandle for each Probe and each check in the
+// configuration, sorted by name. Each handle keeps its own copy of the
+// configuration.
+func (m *probeManager) handles(cfg *config) []*probeHandle {
+	var (
+		names   = make([]string, 0, len(cfg.Probes))
+		handles = make([]*probeHandle, 0, len(cfg.Probes))
+		snap    = *cfg
+	)
+
+	for name := range cfg.Probes {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		var (
+			create   probeFactory
+			found    bool
+			interval = time.Second * time.Duration(cfg.Probes[name])
+		)
+
+		if create, found = probeTypes[name]; !found {
+			m.log.Printf("[ERROR] Don't know anything about probe type %q\n",
+				name)
+			continue
+		} else if interval <= 0 {
+			interval = ckInterval
+		}
+
+		handles = append(handles, &probeHandle{
+			name:     name,
+			create:   create,
+			interval: interval,
+			cfg:      &snap,
+		})
+	}
+
+	var seen = make(map[string]bool, len(cfg.Checks))
+
+	for _, c := range cfg.Checks {
+		if c == nil {
+			continue
+		} else if err := c.validate(); err != nil {
+			m.log.Printf("[ERROR] Invalid check: %s\n", err.Error())
+			continue
+		} else if seen[c.Name] {
+			m.log.Printf("[ERROR] Duplicate check %q\n", c.Name)
+			continue
+		}
+
+		var check = *c
+
+		seen[c.Name] = true
+		handles = append(handles, &probeHandle{
+			name: checkProbePrefix + check.Name,
+			create: func(_ *config) (Probe, error) {
+				return CreateExecProbe(&check)
+			},
+			interval: check.interval(),
+			cfg:      &snap,
+			check:    &check,
+		})
+	}
+
+	sort.Slice(handles, func(i, j int) bool { return handles[i].name < handles[j].name })
+
+	return handles
+} // func (m *probeManager) handles(cfg *config) []*probeHandle
+
+// launch starts the goroutine running a Probe. The caller must hold the
+// lock.
+func (m *probeManager) launch(h *probeHandle) {
+	m.log.Printf("[INFO] Starting Probe %s, interval %s\n",
+		h.name,
+		h.interval)
+	h.done = make(chan struct{})
+	h.stopped = make(chan struct{})
+	m.wg.Add(1)
+	go m.supervise(h)
+} // func (m *probeManager) launch(h *probeHandle)
+
+// start runs all Probes.
+func (m *probeManager) start() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.active {
+		return
+	}
+
+	m.active = true
+	m.done = make(chan struct{})
+
+	for _, h := range m.probes {
+		m.launch(h)
+	}
+
+	m.wg.Add(1)
+	go m.reportHealth(m.done)
+} // func (m *probeManager) start()
+
+// stop stops all Probes and waits for them to finish.
+func (m *probeManager) stop() {
+	m.lock.Lock()
+
+	if !m.active {
+		m.lock.Unlock()
+		return
+	}
+
+	m.active = false
+	close(m.done)
+	for _, h := range m.probes {
+		close(h.done)
+	}
+
+	m.lock.Unlock()
+	m.wg.Wait()
+} // func (m *probeManager) stop()
+
+// update applies a new configuration: Probes that are no longer listed are
+// stopped, new ones are started. Probes whose interval, filesystem filter or
+// check command changed are restarted, the others keep running undisturbed.
+func (m *probeManager) update(cfg *config) {
+	var (
+		stopped []*probeHandle
+		current = make(map[string]*probeHandle)
+	)
+
+	m.lock.Lock()
+
+	for _, h := range m.probes {
+		current[h.name] = h
+	}
+
+	var handles = m.handles(cfg)
+
+	for i, h := range handles {
+		var old, found = current[h.name]
+
+		if found &&
+			old.interval == h.interval &&
+			reflect.DeepEqual(old.cfg.Filesystem, h.cfg.Filesystem) &&
+			reflect.DeepEqual(old.check, h.check) {
+			handles[i] = old
+			delete(current, h.name)
+		} else if m.active {
+			m.launch(h)
+		}
+	}
+
+	for _, h := range current {
+		m.log.Printf("[INFO] Stopping Probe %s\n", h.name)
+		if m.active {
+			close(h.done)
+			stopped = append(stopped, h)
+		}
+	}
+
+	m.probes = handles
+	m.lock.Unlock()
+
+	// A Probe might be in the middle of collecting a sample, we do not
+	// hold up the Agent until it is done.
+	for _, h := range stopped {
+		go func(h *probeHandle) {
+			<-h.stopped
+			m.log.Printf("[DEBUG] Probe %s has stopped\n", h.name)
+		}(h)
+	}
+} // func (m *probeManager) update(cfg *config)
+
+// health returns the state of all Probes.
+func (m *probeManager) health() *model.ProbeHealth {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var h = &model.ProbeHealth{
+		Probes: make([]model.ProbeStatus, len(m.probes)),
+	}
+
+	for i, p := range m.probes {
+		h.Probes[i] = p.status()
+	}
+
+	return h
+} // func (m *probeManager) health() *model.ProbeHealth
+
+// maxSilence returns the longest time that passes between two Records the
+// Probes deliver, counting the health reports, or 0 if there are no Probes.
+func (m *probeManager) maxSilence() time.Duration {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if len(m.probes) == 0 {
+		return 0
+	}
+
+	var silence = m.healthIntv
+
+	for _, h := range m.probes {
+		if h.interval < silence {
+			silence = h.interval
+		}
+	}
+
+	return silence
+} // func (m *probeManager) maxSilence() time.Duration
+
+// supervise runs a Probe until it is stopped, restarting it whenever it
+// crashes.
+func (m *probeManager) supervise(h *probeHandle) {
+	defer m.wg.Done()
+	defer close(h.stopped)
+
+	var backoff = m.backoffMin
+
+	for {
+		var (
+			err   error
+			begin = time.Now()
+		)
+
+		if err = m.runProbe(h); err == nil {
+			return
+		} else if time.Since(begin) >= m.backoffMax {
+			backoff = m.backoffMin
+		}
+
+		h.crashed(err)
+		m.log.Printf("[ERROR] Probe %s crashed, restarting it in %s: %s\n",
+			h.name,
+			backoff,
+			err.Error())
+
+		select {
+		case <-h.done:
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > m.backoffMax {
+			backoff = m.backoffMax
+		}
+	}
+} // func (m *probeManager) supervise(h *probeHandle)
+
+// runProbe creates the Probe, if necessary, and collects a sample at the
+// Probe's interval. It returns nil when the Probe is stopped, or an error
+// when the Probe crashed.
+func (m *probeManager) runProbe(h *probeHandle) (err error) {
+	defer func() {
+		if x := recover(); x != nil {
+			err = fmt.Errorf("panic: %v", x)
+		}
+	}()
+
+	var (
+		p           Probe
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+
+	defer cancel()
+
+	// Stopping the Probe aborts a sample that is being collected.
+	go func() {
+		select {
+		case <-h.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// A Probe that crashed is created anew, so it does not carry over
+	// whatever state made it crash. Only the goroutine supervising the
+	// Probe touches h.probe.
+	if p = h.probe; p == nil {
+		if p, err = h.create(h.cfg); err != nil {
+			return fmt.Errorf("cannot create Probe: %w", err)
+		}
+		h.probe = p
+	}
+
+	h.setRunning(true)
+	defer h.setRunning(false)
+
+	var ticker = time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		var rec *model.Record
+
+		select {
+		case <-h.done:
+			return nil
+		case <-ticker.C:
+		}
+
+		if cc, ok := p.(contextCollector); ok {
+			rec, err = cc.CollectContext(ctx)
+		} else {
+			rec, err = p.Collect()
+		}
+
+		if errors.Is(err, errNoBaseline) {
+			continue
+		} else if ctx.Err() != nil {
+			return nil
+		} else if err != nil {
+			m.log.Printf("[ERROR] Failed to get Record from Probe %s: %s\n",
+				h.name,
+				err.Error())
+			if h.failed(err) >= probeMaxErrors {
+				return fmt.Errorf("%d errors in a row, last one: %w",
+					probeMaxErrors,
+					err)
+			}
+			continue
+		}
+
+		h.succeeded()
+
+		select {
+		case <-h.done:
+			return nil
+		case m.recordq <- *rec:
+		}
+	}
+} // func (m *probeManager) runProbe(h *probeHandle) (err error)
+
+// reportHealth periodically sends the state of the Probes to the Server.
+func (m *probeManager) reportHealth(done <-chan struct{}) {
+	defer m.wg.Done()
+
+	var ticker = time.NewTicker(m.healthIntv)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		var (
+			err    error
+			health = m.health()
+			rec    = model.Record{
+				Timestamp: time.Now(),
+				Source:    recordtype.ProbeHealth,
+			}
+		)
+
+		if len(health.Probes) == 0 {
+			continue
+		} else if rec.Payload, err = model.EncodePayload(recordtype.ProbeHealth, health); err != nil {
+			m.log.Printf("[ERROR] Cannot serialize health of Probes: %s\n",
+				err.Error())
+			continue
+		}
+
+		select {
+		case <-done:
+			return
+		case m.recordq <- rec:
+		}
+	}
+} // func (m *probeManager) reportHealth(done <-chan struct{})
@@ -0,0 +1,256 @@
+// /home/krylon/go/src/github.com/blicero/donkey/agent/spool.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 14:02:11 krylon>
+
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blicero/donkey/model"
+)
+
+// defaultSpoolSize is the maximum number of bytes the spool may occupy on
+// disk unless the configuration says otherwise.
+const defaultSpoolSize int64 = 64 * 1024 * 1024
+
+// When the Server cannot be reached, we retry delivering spooled Records
+// after an increasing delay, starting at spoolBackoffMin and doubling up to
+// spoolBackoffMax.
+const (
+	spoolBackoffMin = heartbeat
+	spoolBackoffMax = time.Minute * 5
+)
+
+var spoolFilePat = regexp.MustCompile(`^[0-9a-f]{16}-[0-9a-f]{8}[.]json$`)
+
+type spoolEntry struct {
+	name string
+	size int64
+}
+
+// spool is a durable, on-disk queue of Records the Agent failed to deliver
+// to the Server. Each Record is stored in a file of its own, the file names
+// are chosen so that sorting them lexically yields the order in which they
+// were added.
+//
+// If adding a Record would push the spool beyond its size limit, the
+// oldest Records are discarded.
+type spool struct {
+	dir     string
+	limit   int64
+	size    int64
+	seq     uint32
+	log     *log.Logger
+	lock    sync.Mutex
+	entries []spoolEntry
+}
+
+// openSpool opens the spool in the given directory, creating the directory
+// if it does not exist, yet. Records spooled by a previous run of the Agent
+// are picked up.
+func openSpool(dir string, limit int64, l *log.Logger) (*spool, error) {
+	var (
+		err   error
+		files []os.DirEntry
+		sp    = &spool{
+			dir:   dir,
+			limit: limit,
+			log:   l,
+		}
+	)
+
+	if sp.limit <= 0 {
+		sp.limit = defaultSpoolSize
+	}
+
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		sp.log.Printf("[ERROR] Cannot create spool directory %s: %s\n",
+			dir,
+			err.Error())
+		return nil, err
+	} else if files, err = os.ReadDir(dir); err != nil {
+		sp.log.Printf("[ERROR] Cannot read spool directory %s: %s\n",
+			dir,
+			err.Error())
+		return nil, err
+	}
+
+	sp.entries = make([]spoolEntry, 0, len(files))
+
+	for _, f := range files {
+		var info os.FileInfo
+
+		if !f.Type().IsRegular() || !spoolFilePat.MatchString(f.Name()) {
+			continue
+		} else if info, err = f.Info(); err != nil {
+			sp.log.Printf("[ERROR] Cannot stat spool file %s: %s\n",
+				f.Name(),
+				err.Error())
+			continue
+		}
+
+		sp.entries = append(sp.entries, spoolEntry{name: f.Name(), size: info.Size()})
+		sp.size += info.Size()
+	}
+
+	sort.Slice(sp.entries, func(i, j int) bool { return sp.entries[i].name < sp.entries[j].name })
+
+	if len(sp.entries) > 0 {
+		sp.log.Printf("[INFO] Found %d spooled Records (%d bytes) in %s\n",
+			len(sp.entries),
+			sp.size,
+			dir)
+	}
+
+	return sp, nil
+} // func openSpool(dir string, limit int64, l *log.Logger) (*spool, error)
+
+// Len returns the number of Records currently in the spool.
+func (sp *spool) Len() int {
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	return len(sp.entries)
+} // func (sp *spool) Len() int
+
+// Append adds a Record to the end of the spool, evicting the oldest Records
+// if the spool grows beyond its size limit.
+func (sp *spool) Append(rec *model.Record) error {
+	var (
+		err       error
+		buf       []byte
+		name, tmp string
+	)
+
+	if buf, err = json.Marshal(rec); err != nil {
+		sp.log.Printf("[ERROR] Cannot serialize Record for spooling: %s\n",
+			err.Error())
+		return err
+	}
+
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+
+	sp.seq++
+	name = fmt.Sprintf("%016x-%08x.json", time.Now().UnixNano(), sp.seq)
+	tmp = filepath.Join(sp.dir, "."+name)
+
+	// Write to a temporary file first and rename it afterwards, so a
+	// crash cannot leave a truncated Record in the spool.
+	if err = os.WriteFile(tmp, buf, 0600); err != nil {
+		sp.log.Printf("[ERROR] Cannot write spool file %s: %s\n",
+			tmp,
+			err.Error())
+		return err
+	} else if err = os.Rename(tmp, filepath.Join(sp.dir, name)); err != nil {
+		sp.log.Printf("[ERROR] Cannot rename spool file %s: %s\n",
+			tmp,
+			err.Error())
+		os.Remove(tmp) // nolint: errcheck
+		return err
+	}
+
+	sp.entries = append(sp.entries, spoolEntry{name: name, size: int64(len(buf))})
+	sp.size += int64(len(buf))
+
+	for sp.size > sp.limit && len(sp.entries) > 1 {
+		sp.log.Printf("[WARN] Spool exceeds %d bytes, discarding oldest Record %s\n",
+			sp.limit,
+			sp.entries[0].name)
+		sp.remove(0)
+	}
+
+	return nil
+} // func (sp *spool) Append(rec *model.Record) error
+
+// Peek returns up to n of the oldest Records in the spool without removing
+// them, along with the names of their files, to be passed to Pop once the
+// Records have been delivered. If the spool is empty, it returns empty
+// slices.
+// Files that cannot be read or decoded are dropped from the spool.
+func (sp *spool) Peek(n int) ([]model.Record, []string) {
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+
+	var (
+		idx   int
+		recs  = make([]model.Record, 0, n)
+		names = make([]string, 0, n)
+	)
+
+	for idx < len(sp.entries) && len(recs) < n {
+		var (
+			err  error
+			buf  []byte
+			rec  model.Record
+			path = filepath.Join(sp.dir, sp.entries[idx].name)
+		)
+
+		if buf, err = os.ReadFile(path); err != nil {
+			sp.log.Printf("[ERROR] Cannot read spool file %s, discarding it: %s\n",
+				path,
+				err.Error())
+		} else if err = json.Unmarshal(buf, &rec); err != nil {
+			sp.log.Printf("[ERROR] Cannot decode spool file %s, discarding it: %s\n",
+				path,
+				err.Error())
+		} else {
+			recs = append(recs, rec)
+			names = append(names, sp.entries[idx].name)
+			idx++
+			continue
+		}
+
+		sp.remove(idx)
+	}
+
+	return recs, names
+} // func (sp *spool) Peek(n int) ([]model.Record, []string)
+
+// Pop removes the Records returned by Peek from the spool. Records that have
+// been evicted in the meantime are skipped, so Pop never removes a Record
+// that has not been delivered.
+func (sp *spool) Pop(names []string) {
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+
+	var done = make(map[string]bool, len(names))
+
+	for _, name := range names {
+		done[name] = true
+	}
+
+	for idx := len(sp.entries) - 1; idx >= 0; idx-- {
+		if done[sp.entries[idx].name] {
+			sp.remove(idx)
+		}
+	}
+} // func (sp *spool) Pop(names []string)
+
+// remove deletes the spool file at the given index. The caller must hold the
+// lock.
+func (sp *spool) remove(idx int) {
+	var (
+		err   error
+		entry = sp.entries[idx]
+	)
+
+	if err = os.Remove(filepath.Join(sp.dir, entry.name)); err != nil && !os.IsNotExist(err) {
+		sp.log.Printf("[ERROR] Cannot remove spool file %s: %s\n",
+			entry.name,
+			err.Error())
+	}
+
+	sp.entries = append(sp.entries[:idx], sp.entries[idx+1:]...)
+	sp.size -= entry.size
+} // func (sp *spool) remove(idx int)
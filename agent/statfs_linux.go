@@ -0,0 +1,40 @@
+// /home/krylon/go/src/github.com/blicero/donkey/agent/statfs_linux.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 18:41:30 krylon>
+
+//go:build linux
+
+package agent
+
+import (
+	"syscall"
+
+	"github.com/blicero/donkey/model"
+)
+
+// statfs fills in the size and inode counts of the file system mounted at
+// m.Mountpoint.
+func statfs(m *model.MountUsage) error {
+	var (
+		err error
+		st  syscall.Statfs_t
+	)
+
+	if err = syscall.Statfs(m.Mountpoint, &st); err != nil {
+		return err
+	}
+
+	var bsize = uint64(st.Bsize)
+
+	m.Total = st.Blocks * bsize
+	m.Free = st.Bfree * bsize
+	m.Avail = st.Bavail * bsize
+	m.Used = m.Total - m.Free
+	m.Inodes = st.Files
+	m.InodesFree = st.Ffree
+	m.InodesUsed = st.Files - st.Ffree
+
+	return nil
+} // func statfs(m *model.MountUsage) error
@@ -0,0 +1,20 @@
+// /home/krylon/go/src/github.com/blicero/donkey/agent/statfs_other.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 18:42:12 krylon>
+
+//go:build !linux
+
+package agent
+
+import (
+	"errors"
+
+	"github.com/blicero/donkey/model"
+)
+
+// statfs is not implemented on this platform, yet.
+func statfs(m *model.MountUsage) error {
+	return errors.New("statfs is not supported on this platform")
+} // func statfs(m *model.MountUsage) error
@@ -0,0 +1,101 @@
+// /home/krylon/go/src/github.com/blicero/donkey/agent/tls.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 05:41:08 krylon>
+
+package agent
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// tlsConfig tells the Agent to talk to the Server via HTTPS.
+// CACert is a PEM bundle of the CAs to verify the Server's certificate
+// with, if it is empty, the system's CAs are used.
+// Cert and Key are the client certificate and key the Agent presents to the
+// Server, if they are set.
+type tlsConfig struct {
+	CACert string `json:",omitempty"`
+	Cert   string `json:",omitempty"`
+	Key    string `json:",omitempty"`
+}
+
+// config turns the tlsConfig into a tls.Config, loading the certificates
+// along the way.
+func (c *tlsConfig) config() (*tls.Config, error) {
+	var (
+		err  error
+		pem  []byte
+		cert tls.Certificate
+		cfg  = &tls.Config{MinVersion: tls.VersionTLS12}
+	)
+
+	if (c.Cert == "") != (c.Key == "") {
+		return nil, errors.New("A client certificate needs both a certificate and a key")
+	} else if c.Cert != "" {
+		if cert, err = tls.LoadX509KeyPair(c.Cert, c.Key); err != nil {
+			return nil, fmt.Errorf("Cannot load client certificate %s and key %s: %w",
+				c.Cert,
+				c.Key,
+				err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if c.CACert == "" {
+		return cfg, nil
+	} else if pem, err = os.ReadFile(c.CACert); err != nil {
+		return nil, fmt.Errorf("Cannot read CA bundle %s: %w",
+			c.CACert,
+			err)
+	}
+
+	cfg.RootCAs = x509.NewCertPool()
+	if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s does not contain any certificates",
+			c.CACert)
+	}
+
+	return cfg, nil
+} // func (c *tlsConfig) config() (*tls.Config, error)
+
+// setupClient prepares the HTTP client the Agent talks to the Server with.
+func (ag *Agent) setupClient() error {
+	var (
+		err error
+		cfg *tls.Config
+	)
+
+	if ag.cfg.TLS == nil {
+		ag.client.Transport = nil
+		return nil
+	} else if cfg, err = ag.cfg.TLS.config(); err != nil {
+		return err
+	}
+
+	var transport = http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg
+	ag.client.Transport = transport
+
+	return nil
+} // func (ag *Agent) setupClient() error
+
+// url returns the URL of the given endpoint on the Server.
+func (ag *Agent) url(endpoint string) string {
+	var scheme = "http"
+
+	if ag.cfg.TLS != nil {
+		scheme = "https"
+	}
+
+	return fmt.Sprintf("%s://%s%s",
+		scheme,
+		ag.server,
+		endpoint)
+} // func (ag *Agent) url(endpoint string) string
@@ -63,6 +63,9 @@ var candidates = map[string][]string{
 		"common",
 		"logdomain",
 		"model/recordtype",
+		"model/hoststate",
+		"model/alertstate",
+		"model/resolution",
 		"database/query",
 		"agent/platform",
 	},
@@ -72,6 +75,7 @@ var candidates = map[string][]string{
 	},
 	"vet": {
 		"common",
+		"common/testcert",
 		"logdomain",
 		"agent",
 		"agent/platform",
@@ -79,10 +83,14 @@ var candidates = map[string][]string{
 		"database/query",
 		"model",
 		"model/recordtype",
+		"model/hoststate",
+		"model/alertstate",
+		"model/resolution",
 		"server",
 	},
 	"lint": {
 		"common",
+		"common/testcert",
 		"logdomain",
 		"agent",
 		"agent/platform",
@@ -90,6 +98,9 @@ var candidates = map[string][]string{
 		"database/query",
 		"model",
 		"model/recordtype",
+		"model/hoststate",
+		"model/alertstate",
+		"model/resolution",
 		"server",
 	},
 }
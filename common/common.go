@@ -24,19 +24,19 @@ import (
 	"github.com/odeke-em/go-uuid"
 )
 
-//var Debug bool = true
-
 //go:generate ./build_time_stamp.pl
 
 // Debug indicates whether to emit additional log messages and perform
-// additional sanity checks.
+// additional sanity checks. It may only be changed during startup, before
+// any other goroutines are running.
+var Debug = true
+
 // Version is the version number to display.
 // AppName is the name of the application.
 // TimestampFormat is the format string used to render datetime values.
 // HeartBeat is the interval for worker goroutines to wake up and check
 // their status.
 const (
-	Debug                    = true
 	Version                  = "0.0.1"
 	AppName                  = "Donkey"
 	TimestampFormat          = "2006-01-02 15:04:05"
@@ -82,11 +82,21 @@ func init() {
 // HostCachePath is the path to the IP cache.
 // XfrDbgPath is the path of the folder where data on DNS zone transfers
 // are stored.
+// SpoolPath is the folder where the Agent keeps Records it could not
+// deliver to the Server.
+// AlertConfPath is the file the Server reads its alerting rules from.
+// ServerConfPath is the Server's configuration file.
+// FleetConfPath is the file the Server reads the configuration it hands out
+// to Agents from.
 var (
-	BaseDir       = filepath.Join(os.Getenv("HOME"), fmt.Sprintf("%s.d", strings.ToLower(AppName)))
-	LogPath       = filepath.Join(BaseDir, fmt.Sprintf("%s.log", strings.ToLower(AppName)))
-	DbPath        = filepath.Join(BaseDir, fmt.Sprintf("%s.db", strings.ToLower(AppName)))
-	AgentConfPath = filepath.Join(BaseDir, "agent.json")
+	BaseDir        = filepath.Join(os.Getenv("HOME"), fmt.Sprintf("%s.d", strings.ToLower(AppName)))
+	LogPath        = filepath.Join(BaseDir, fmt.Sprintf("%s.log", strings.ToLower(AppName)))
+	DbPath         = filepath.Join(BaseDir, fmt.Sprintf("%s.db", strings.ToLower(AppName)))
+	AgentConfPath  = filepath.Join(BaseDir, "agent.json")
+	SpoolPath      = filepath.Join(BaseDir, "spool")
+	AlertConfPath  = filepath.Join(BaseDir, "alerts.json")
+	ServerConfPath = filepath.Join(BaseDir, "server.json")
+	FleetConfPath  = filepath.Join(BaseDir, "fleet.json")
 )
 
 // SetBaseDir sets the BaseDir and related variables.
@@ -97,6 +107,10 @@ func SetBaseDir(path string) error {
 	LogPath = filepath.Join(BaseDir, fmt.Sprintf("%s.log", strings.ToLower(AppName)))
 	DbPath = filepath.Join(BaseDir, fmt.Sprintf("%s.db", strings.ToLower(AppName)))
 	AgentConfPath = filepath.Join(BaseDir, "agent.json")
+	SpoolPath = filepath.Join(BaseDir, "spool")
+	AlertConfPath = filepath.Join(BaseDir, "alerts.json")
+	ServerConfPath = filepath.Join(BaseDir, "server.json")
+	FleetConfPath = filepath.Join(BaseDir, "fleet.json")
 
 	if err := InitApp(); err != nil {
 		fmt.Printf("Error initializing application environment: %s\n", err.Error())
@@ -127,7 +141,10 @@ func GetLogger(dom logdomain.ID) (*log.Logger, error) {
 		return nil, errors.New(msg)
 	}
 
-	writer := io.MultiWriter(os.Stdout, logfile)
+	writer := &levelFilter{
+		dom: dom,
+		out: io.MultiWriter(os.Stdout, logfile),
+	}
 
 	logger := log.New(writer, logName, log.Ldate|log.Ltime|log.Lshortfile)
 	return logger, nil
@@ -0,0 +1,98 @@
+// /home/krylon/go/src/github.com/blicero/donkey/common/loglevel.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 07:20:16 krylon>
+
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/blicero/donkey/logdomain"
+	"github.com/hashicorp/logutils"
+)
+
+// minLevels holds the index into LogLevels of the minimum level of each
+// log domain. The map itself is never modified after init, so the loggers
+// can consult it without locking.
+var (
+	minLevels  = make(map[logdomain.ID]*atomic.Int32)
+	levelsLock sync.Mutex
+)
+
+func init() {
+	for _, id := range logdomain.AllDomains() {
+		var lvl = new(atomic.Int32)
+
+		lvl.Store(int32(slices.Index(LogLevels, PackageLevels[id])))
+		minLevels[id] = lvl
+	}
+} // func init()
+
+// ParseLogLevel checks if s is the name of a log level, ignoring case.
+func ParseLogLevel(s string) (logutils.LogLevel, error) {
+	var lvl = logutils.LogLevel(strings.ToUpper(s))
+
+	if !slices.Contains(LogLevels, lvl) {
+		return "", fmt.Errorf("Invalid log level %q", s)
+	}
+
+	return lvl, nil
+} // func ParseLogLevel(s string) (logutils.LogLevel, error)
+
+// SetLogLevel sets the minimum level of the messages logged for the given
+// domain. It affects Loggers that have been created already, too.
+func SetLogLevel(dom logdomain.ID, lvl logutils.LogLevel) error {
+	var (
+		idx   = slices.Index(LogLevels, lvl)
+		level *atomic.Int32
+		found bool
+	)
+
+	if idx < 0 {
+		return fmt.Errorf("Invalid log level %q", lvl)
+	} else if level, found = minLevels[dom]; !found {
+		return fmt.Errorf("Invalid log domain %d", dom)
+	}
+
+	levelsLock.Lock()
+	PackageLevels[dom] = lvl
+	levelsLock.Unlock()
+
+	level.Store(int32(idx))
+	return nil
+} // func SetLogLevel(dom logdomain.ID, lvl logutils.LogLevel) error
+
+// levelFilter drops messages below the minimum level of its log domain.
+// Like logutils.LevelFilter, it takes the level from the first word in
+// square brackets, messages without a level are always written.
+type levelFilter struct {
+	dom logdomain.ID
+	out io.Writer
+}
+
+func (f *levelFilter) Write(p []byte) (int, error) {
+	var begin, end int
+
+	if begin = bytes.IndexByte(p, '['); begin >= 0 {
+		if end = bytes.IndexByte(p[begin:], ']'); end > 0 {
+			var (
+				lvl = logutils.LogLevel(p[begin+1 : begin+end])
+				idx = slices.Index(LogLevels, lvl)
+			)
+
+			if idx >= 0 && int32(idx) < minLevels[f.dom].Load() {
+				return len(p), nil
+			}
+		}
+	}
+
+	return f.out.Write(p)
+} // func (f *levelFilter) Write(p []byte) (int, error)
@@ -0,0 +1,169 @@
+// /home/krylon/go/src/github.com/blicero/donkey/common/testcert/testcert.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 05:10:44 krylon>
+
+// Package testcert creates a throwaway CA along with server and client
+// certificates, so the tests can exercise TLS without shipping key material.
+package testcert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const validity = time.Hour * 24
+
+// Bundle holds the paths of the files created by Generate.
+// Clients maps the name of each client to the paths of its certificate and
+// key.
+type Bundle struct {
+	CA      string
+	Cert    string
+	Key     string
+	Clients map[string][2]string
+}
+
+type issuer struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// Generate creates a CA in dir, a server certificate for localhost, and a
+// client certificate for each of the given names, the name being the
+// certificate's CommonName.
+func Generate(dir string, clients ...string) (*Bundle, error) {
+	var (
+		err error
+		ca  *issuer
+		b   = &Bundle{
+			CA:      filepath.Join(dir, "ca.pem"),
+			Cert:    filepath.Join(dir, "server.pem"),
+			Key:     filepath.Join(dir, "server.key"),
+			Clients: make(map[string][2]string, len(clients)),
+		}
+	)
+
+	if ca, err = newCA(b.CA); err != nil {
+		return nil, err
+	}
+
+	var server = &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.IPv6loopback, net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	if err = ca.issue(server, b.Cert, b.Key); err != nil {
+		return nil, err
+	}
+
+	for _, name := range clients {
+		var (
+			paths = [2]string{
+				filepath.Join(dir, "client-"+name+".pem"),
+				filepath.Join(dir, "client-"+name+".key"),
+			}
+			client = &x509.Certificate{
+				Subject:     pkix.Name{CommonName: name},
+				ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			}
+		)
+
+		if err = ca.issue(client, paths[0], paths[1]); err != nil {
+			return nil, err
+		}
+
+		b.Clients[name] = paths
+	}
+
+	return b, nil
+} // func Generate(dir string, clients ...string) (*Bundle, error)
+
+func newCA(path string) (*issuer, error) {
+	var (
+		err  error
+		der  []byte
+		ca   = new(issuer)
+		tmpl = &x509.Certificate{
+			Subject:               pkix.Name{CommonName: "Donkey Test CA"},
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		}
+	)
+
+	if ca.key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		return nil, err
+	} else if err = setValidity(tmpl); err != nil {
+		return nil, err
+	} else if der, err = x509.CreateCertificate(rand.Reader, tmpl, tmpl, &ca.key.PublicKey, ca.key); err != nil {
+		return nil, err
+	} else if ca.cert, err = x509.ParseCertificate(der); err != nil {
+		return nil, err
+	} else if err = writePEM(path, "CERTIFICATE", der); err != nil {
+		return nil, err
+	}
+
+	return ca, nil
+} // func newCA(path string) (*issuer, error)
+
+func (ca *issuer) issue(tmpl *x509.Certificate, certPath, keyPath string) error {
+	var (
+		err    error
+		der    []byte
+		keyDER []byte
+		key    *ecdsa.PrivateKey
+	)
+
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+
+	if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		return err
+	} else if err = setValidity(tmpl); err != nil {
+		return err
+	} else if der, err = x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key); err != nil {
+		return err
+	} else if keyDER, err = x509.MarshalECPrivateKey(key); err != nil {
+		return err
+	} else if err = writePEM(certPath, "CERTIFICATE", der); err != nil {
+		return err
+	}
+
+	return writePEM(keyPath, "EC PRIVATE KEY", keyDER)
+} // func (ca *issuer) issue(tmpl *x509.Certificate, certPath, keyPath string) error
+
+func setValidity(tmpl *x509.Certificate) error {
+	var (
+		err    error
+		serial *big.Int
+		now    = time.Now()
+	)
+
+	if serial, err = rand.Int(rand.Reader, big.NewInt(1<<62)); err != nil {
+		return err
+	}
+
+	tmpl.SerialNumber = serial
+	tmpl.NotBefore = now.Add(-time.Minute)
+	tmpl.NotAfter = now.Add(validity)
+
+	return nil
+} // func setValidity(tmpl *x509.Certificate) error
+
+func writePEM(path, kind string, der []byte) error {
+	var block = &pem.Block{Type: kind, Bytes: der}
+
+	return os.WriteFile(path, pem.EncodeToMemory(block), 0600)
+} // func writePEM(path, kind string, der []byte) error
@@ -84,6 +84,75 @@ func TestHostAdd(t *testing.T) {
 
 } // func TestHostAdd(t *testing.T)
 
+func TestHostToken(t *testing.T) {
+	if tdb == nil {
+		t.SkipNow()
+	}
+
+	const hash = "0123456789abcdef"
+
+	var (
+		err   error
+		token string
+		host  *model.Host
+	)
+
+	if host, err = tdb.HostGetByName("abobo"); err != nil {
+		t.Fatalf("Cannot look up Host abobo: %s", err.Error())
+	} else if host == nil {
+		t.Fatal("Host abobo was not found")
+	} else if token, err = tdb.HostGetToken(host.ID); err != nil {
+		t.Fatalf("Cannot get token of Host %s: %s", host.Name, err.Error())
+	} else if token != "" {
+		t.Errorf("Host %s should not have a token, yet: %q", host.Name, token)
+	} else if err = tdb.HostUpdateToken(host, hash); err != nil {
+		t.Fatalf("Cannot set token of Host %s: %s", host.Name, err.Error())
+	} else if token, err = tdb.HostGetToken(host.ID); err != nil {
+		t.Fatalf("Cannot get token of Host %s: %s", host.Name, err.Error())
+	} else if token != hash {
+		t.Errorf("Unexpected token for Host %s: %q (expected %q)",
+			host.Name,
+			token,
+			hash)
+	}
+
+	if err = tdb.HostUpdateToken(&model.Host{ID: 4711}, hash); err == nil {
+		t.Error("Setting the token of a non-existent Host should fail")
+	}
+} // func TestHostToken(t *testing.T)
+
+func TestHostInterval(t *testing.T) {
+	if tdb == nil {
+		t.SkipNow()
+	}
+
+	const interval = time.Minute * 5
+
+	var (
+		err  error
+		host *model.Host
+	)
+
+	if host, err = tdb.HostGetByName("bbobo"); err != nil {
+		t.Fatalf("Cannot look up Host bbobo: %s", err.Error())
+	} else if host == nil {
+		t.Fatal("Host bbobo was not found")
+	} else if host.Interval != 0 {
+		t.Errorf("Host %s should not have a contact interval, yet: %s",
+			host.Name,
+			host.Interval)
+	} else if err = tdb.HostUpdateInterval(host, interval); err != nil {
+		t.Fatalf("Cannot set contact interval of Host %s: %s", host.Name, err.Error())
+	} else if host, err = tdb.HostGetByID(host.ID); err != nil {
+		t.Fatalf("Cannot look up Host bbobo: %s", err.Error())
+	} else if host.Interval != interval {
+		t.Errorf("Unexpected contact interval for Host %s: %s (expected %s)",
+			host.Name,
+			host.Interval,
+			interval)
+	}
+} // func TestHostInterval(t *testing.T)
+
 func TestRecordAdd(t *testing.T) {
 	if tdb == nil {
 		t.SkipNow()
@@ -146,3 +215,204 @@ func TestRecordAdd(t *testing.T) {
 
 	status = true
 } // func TestRecordAdd(t *testing.T)
+
+func TestRecordGetLatest(t *testing.T) {
+	if tdb == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err   error
+		hosts []model.Host
+		last  = time.Date(2024, 4, 1, 8, 39, 0, 0, time.Local)
+	)
+
+	if hosts, err = tdb.HostGetAll(); err != nil {
+		t.Fatalf("Error fetching all hosts: %s", err.Error())
+	}
+
+	for i := range hosts {
+		var (
+			recs []model.Record
+			h    = &hosts[i]
+		)
+
+		if recs, err = tdb.RecordGetLatestByHost(h); err != nil {
+			t.Errorf("Error fetching latest Records for Host %s: %s",
+				h.Name,
+				err.Error())
+		} else if len(recs) != 1 {
+			t.Errorf("Expected 1 Record for Host %s, got %d",
+				h.Name,
+				len(recs))
+		} else if recs[0].Source != recordtype.LoadAvg {
+			t.Errorf("Latest Record for Host %s should be %s, got %s",
+				h.Name,
+				recordtype.LoadAvg,
+				recs[0].Source)
+		} else if !recs[0].Timestamp.Equal(last) {
+			t.Errorf("Latest Record for Host %s should be from %s, got %s",
+				h.Name,
+				last,
+				recs[0].Timestamp)
+		}
+	}
+} // func TestRecordGetLatest(t *testing.T)
+
+func TestRecordGetByHostType(t *testing.T) {
+	if tdb == nil {
+		t.SkipNow()
+	}
+
+	const recCnt = 25
+	var (
+		err   error
+		hosts []model.Host
+	)
+
+	if hosts, err = tdb.HostGetAll(); err != nil {
+		t.Fatalf("Error fetching all hosts: %s", err.Error())
+	}
+
+	for i := range hosts {
+		var (
+			recs  []model.Record
+			loads []model.Load
+			h     = &hosts[i]
+		)
+
+		if recs, err = tdb.RecordGetByHostType(h, recordtype.LoadAvg); err != nil {
+			t.Errorf("Error fetching Records for Host %s: %s",
+				h.Name,
+				err.Error())
+		} else if len(recs) != recCnt {
+			t.Errorf("Expected %d Records for Host %s, got %d",
+				recCnt,
+				h.Name,
+				len(recs))
+		} else if loads, err = tdb.LoadGetByHost(h.ID, 5); err != nil {
+			t.Errorf("Error fetching load averages for Host %s: %s",
+				h.Name,
+				err.Error())
+		} else if len(loads) != 5 {
+			t.Errorf("Expected 5 load averages for Host %s, got %d",
+				h.Name,
+				len(loads))
+		} else if !loads[4].Timestamp.Equal(recs[recCnt-1].Timestamp) {
+			t.Errorf("Most recent load average of Host %s should be from %s, not %s",
+				h.Name,
+				recs[recCnt-1].Timestamp,
+				loads[4].Timestamp)
+		} else if loads, err = tdb.LoadGetByHost(h.ID, -1); err != nil {
+			t.Errorf("Error fetching all load averages for Host %s: %s",
+				h.Name,
+				err.Error())
+		} else if len(loads) != recCnt {
+			t.Errorf("Expected %d load averages for Host %s, got %d",
+				recCnt,
+				h.Name,
+				len(loads))
+		}
+	}
+} // func TestRecordGetByHostType(t *testing.T)
+
+func TestRecordGetRange(t *testing.T) {
+	if tdb == nil {
+		t.SkipNow()
+	}
+
+	const pageSize = 4
+	var (
+		err   error
+		hosts []model.Host
+		loads []model.Load
+		begin = time.Date(2024, 4, 1, 8, 20, 0, 0, time.Local)
+		end   = time.Date(2024, 4, 1, 8, 29, 0, 0, time.Local)
+	)
+
+	if hosts, err = tdb.HostGetAll(); err != nil {
+		t.Fatalf("Error fetching all hosts: %s", err.Error())
+	}
+
+	var queries = []struct {
+		name  string
+		fetch func(r RecordRange) ([]model.Record, error)
+		cnt   int
+	}{
+		{
+			name: "host",
+			fetch: func(r RecordRange) ([]model.Record, error) {
+				return tdb.RecordGetByHostRange(&hosts[0], r)
+			},
+			cnt: 10,
+		},
+		{
+			name: "type",
+			fetch: func(r RecordRange) ([]model.Record, error) {
+				return tdb.RecordGetByTypeRange(recordtype.LoadAvg, r)
+			},
+			cnt: 10 * len(hosts),
+		},
+		{
+			name: "host/type",
+			fetch: func(r RecordRange) ([]model.Record, error) {
+				return tdb.RecordGetByHostTypeRange(&hosts[1], recordtype.LoadAvg, r)
+			},
+			cnt: 10,
+		},
+	}
+
+	for _, q := range queries {
+		var (
+			recs  []model.Record
+			total int
+			pages int
+			more  = true
+			prev  Cursor
+			rng   = RecordRange{Begin: begin, End: end, Limit: pageSize}
+		)
+
+		for more {
+			if recs, err = q.fetch(rng); err != nil {
+				t.Fatalf("Error fetching page %d by %s: %s",
+					pages,
+					q.name,
+					err.Error())
+			}
+
+			for _, rec := range recs {
+				if rec.Timestamp.Before(begin) || rec.Timestamp.After(end) {
+					t.Errorf("Record %d from %s is outside of the range",
+						rec.ID,
+						rec.Timestamp)
+				} else if rec.Timestamp.Before(prev.Timestamp) ||
+					(rec.Timestamp.Equal(prev.Timestamp) && rec.ID <= prev.ID) {
+					t.Errorf("Record %d from %s is out of order",
+						rec.ID,
+						rec.Timestamp)
+				}
+
+				prev = Cursor{Timestamp: rec.Timestamp, ID: rec.ID}
+			}
+
+			total += len(recs)
+			pages++
+			rng, more = rng.Next(recs)
+		}
+
+		if total != q.cnt {
+			t.Errorf("Expected %d Records by %s, got %d",
+				q.cnt,
+				q.name,
+				total)
+		}
+	}
+
+	if loads, err = tdb.LoadGetByPeriod(begin, end); err != nil {
+		t.Errorf("Error fetching load averages by period: %s", err.Error())
+	} else if len(loads) != 10*len(hosts) {
+		t.Errorf("Expected %d load averages, got %d",
+			10*len(hosts),
+			len(loads))
+	}
+} // func TestRecordGetRange(t *testing.T)
@@ -0,0 +1,281 @@
+// /home/krylon/go/src/github.com/blicero/donkey/database/03_database_migrate_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 15:58:27 krylon>
+
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blicero/donkey/common"
+	"github.com/blicero/donkey/logdomain"
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/recordtype"
+)
+
+func TestMigrationsApplied(t *testing.T) {
+	if tdb == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err     error
+		version int
+		list    []Migration
+	)
+
+	if version, err = tdb.SchemaVersion(); err != nil {
+		t.Fatalf("Cannot query schema version: %s", err.Error())
+	} else if version != len(qMigrate) {
+		t.Errorf("Unexpected schema version %d (expected %d)",
+			version,
+			len(qMigrate))
+	} else if list, err = tdb.Migrations(); err != nil {
+		t.Fatalf("Cannot list migrations: %s", err.Error())
+	} else if len(list) != len(qMigrate) {
+		t.Fatalf("Migrations returned %d items, expected %d",
+			len(list),
+			len(qMigrate))
+	}
+
+	for _, m := range list {
+		if !m.Applied {
+			t.Errorf("Migration %d (%s) is pending",
+				m.Version,
+				m.Description)
+		}
+	}
+} // func TestMigrationsApplied(t *testing.T)
+
+// createLegacy creates a database with only the baseline schema at path.
+func createLegacy(t *testing.T, path string) {
+	var (
+		err error
+		raw *sql.DB
+	)
+
+	if raw, err = sql.Open("sqlite3", path); err != nil {
+		t.Fatalf("Cannot open %s: %s", path, err.Error())
+	}
+
+	defer raw.Close() // nolint: errcheck
+
+	for _, q := range qInit {
+		if _, err = raw.Exec(q); err != nil {
+			t.Fatalf("Cannot execute init query: %s\n%s",
+				err.Error(),
+				q)
+		}
+	}
+} // func createLegacy(t *testing.T, path string)
+
+// TestMigrateLegacy creates a database with only the baseline schema, like
+// one created before migrations existed, and checks that Open brings it up
+// to date.
+func TestMigrateLegacy(t *testing.T) {
+	var (
+		err     error
+		db      *Database
+		version int
+		path    = filepath.Join(common.BaseDir, "legacy.db")
+	)
+
+	createLegacy(t, path)
+
+	if db, err = Open(path); err != nil {
+		t.Fatalf("Cannot open legacy database: %s", err.Error())
+	}
+
+	defer db.Close() // nolint: errcheck
+
+	if version, err = db.SchemaVersion(); err != nil {
+		t.Fatalf("Cannot query schema version: %s", err.Error())
+	} else if version != len(qMigrate) {
+		t.Errorf("Legacy database was not migrated: version is %d, expected %d",
+			version,
+			len(qMigrate))
+	}
+} // func TestMigrateLegacy(t *testing.T)
+
+// TestMigrateConcurrent has several connections migrate the same legacy
+// database at the same time, like several processes starting at once would.
+// Open serializes callers within one process, so we call migrate directly.
+func TestMigrateConcurrent(t *testing.T) {
+	const cnt = 4
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, cnt)
+		path = filepath.Join(t.TempDir(), "concurrent.db")
+	)
+
+	createLegacy(t, path)
+
+	for i := 0; i < cnt; i++ {
+		var (
+			err error
+			db  = &Database{path: path}
+		)
+
+		if db.log, err = common.GetLogger(logdomain.Database); err != nil {
+			t.Fatalf("Cannot create Logger: %s", err.Error())
+		} else if db.db, err = sql.Open("sqlite3", path+"?_journal=WAL&_fk=1"); err != nil {
+			t.Fatalf("Cannot open %s: %s", path, err.Error())
+		}
+
+		defer db.db.Close() // nolint: errcheck
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = db.migrate()
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Migration #%d failed: %s", i, err.Error())
+		}
+	}
+} // func TestMigrateConcurrent(t *testing.T)
+
+// TestMigrateRecordInstance checks that the Records of a legacy database
+// survive the migration that adds the instance to the record table, and that
+// check Records are named after their check.
+func TestMigrateRecordInstance(t *testing.T) {
+	var (
+		err   error
+		raw   *sql.DB
+		db    *Database
+		rows  *sql.Rows
+		names []string
+		path  = filepath.Join(t.TempDir(), "instance.db")
+		check = `{"Name":"ping","Status":0,"Output":"PING OK","Duration":0.1}`
+	)
+
+	createLegacy(t, path)
+
+	if raw, err = sql.Open("sqlite3", path); err != nil {
+		t.Fatalf("Cannot open %s: %s", path, err.Error())
+	} else if _, err = raw.Exec("INSERT INTO host (id, name, addr) VALUES (1, 'abobo', '10.0.0.1')"); err != nil {
+		t.Fatalf("Cannot add Host: %s", err.Error())
+	} else if _, err = raw.Exec("INSERT INTO record (host_id, timestamp, recordtype, payload) VALUES (1, 100, ?, '[1, 2, 3]'), (1, 100, ?, ?)",
+		recordtype.LoadAvg,
+		recordtype.Check,
+		check); err != nil {
+		t.Fatalf("Cannot add Records: %s", err.Error())
+	}
+
+	raw.Close() // nolint: errcheck
+
+	if db, err = Open(path); err != nil {
+		t.Fatalf("Cannot open legacy database: %s", err.Error())
+	}
+
+	defer db.Close() // nolint: errcheck
+
+	if rows, err = db.db.Query("SELECT instance FROM record ORDER BY id"); err != nil {
+		t.Fatalf("Cannot query Records: %s", err.Error())
+	}
+
+	defer rows.Close() // nolint: errcheck
+
+	for rows.Next() {
+		var name string
+
+		if err = rows.Scan(&name); err != nil {
+			t.Fatalf("Cannot scan instance: %s", err.Error())
+		}
+
+		names = append(names, name)
+	}
+
+	if !reflect.DeepEqual(names, []string{"", "ping"}) {
+		t.Errorf("Unexpected instances after migration: %q", names)
+	}
+
+	// A second check that finished at the same time.
+	var rec = &model.Record{
+		HostID:    1,
+		Timestamp: time.Unix(100, 0),
+		Source:    recordtype.Check,
+		Payload:   strings.Replace(check, "ping", "disk", 1),
+	}
+
+	if err = db.RecordAdd(rec); err != nil {
+		t.Errorf("Cannot add second check Record: %s", err.Error())
+	}
+} // func TestMigrateRecordInstance(t *testing.T)
+
+// TestMigrateRecordIDs checks that after the record table has been rebuilt,
+// new Records get IDs above the rollup watermark, even if the newest Records
+// had been deleted before the migration.
+func TestMigrateRecordIDs(t *testing.T) {
+	const watermark = 10
+	var (
+		err  error
+		raw  *sql.DB
+		db   *Database
+		path = filepath.Join(t.TempDir(), "ids.db")
+		rec  = &model.Record{
+			HostID:    1,
+			Timestamp: time.Unix(200, 0),
+			Source:    recordtype.LoadAvg,
+			Payload:   "[1, 2, 3]",
+		}
+	)
+
+	createLegacy(t, path)
+
+	if raw, err = sql.Open("sqlite3", path); err != nil {
+		t.Fatalf("Cannot open %s: %s", path, err.Error())
+	}
+
+	// Bring the database up to the version before the record table was
+	// rebuilt.
+	for _, m := range qMigrate[:7] {
+		for _, q := range m.queries {
+			if _, err = raw.Exec(q); err != nil {
+				t.Fatalf("Cannot apply migration %q: %s", m.desc, err.Error())
+			}
+		}
+	}
+
+	for _, q := range []string{
+		"PRAGMA user_version = 7",
+		"INSERT INTO host (id, name, addr) VALUES (1, 'abobo', '10.0.0.1')",
+		fmt.Sprintf("INSERT INTO record (id, host_id, timestamp, recordtype, payload) VALUES (1, 1, 100, %d, '[1, 2, 3]')",
+			recordtype.LoadAvg),
+		fmt.Sprintf("UPDATE rollup_watermark SET last_record = %d WHERE id = 1", watermark),
+	} {
+		if _, err = raw.Exec(q); err != nil {
+			t.Fatalf("Cannot execute %q: %s", q, err.Error())
+		}
+	}
+
+	raw.Close() // nolint: errcheck
+
+	if db, err = Open(path); err != nil {
+		t.Fatalf("Cannot open database: %s", err.Error())
+	}
+
+	defer db.Close() // nolint: errcheck
+
+	if err = db.RecordAdd(rec); err != nil {
+		t.Fatalf("Cannot add Record: %s", err.Error())
+	} else if rec.ID <= watermark {
+		t.Errorf("New Record got ID %d, which is not above the rollup watermark %d",
+			rec.ID,
+			watermark)
+	}
+} // func TestMigrateRecordIDs(t *testing.T)
@@ -0,0 +1,139 @@
+// /home/krylon/go/src/github.com/blicero/donkey/database/04_database_rollup_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 00:44:09 krylon>
+
+package database
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/recordtype"
+	"github.com/blicero/donkey/model/resolution"
+)
+
+// TestRollup relies on TestRecordAdd having added 25 load averages for each
+// Host, one per minute.
+func TestRollup(t *testing.T) {
+	if tdb == nil {
+		t.SkipNow()
+	}
+
+	const (
+		recCnt    = 25
+		batchSize = 10
+	)
+
+	var (
+		err      error
+		cnt, sum int
+		hosts    []model.Host
+		recs     []model.Record
+		rollups  []model.Rollup
+		begin    = time.Date(2024, 4, 1, 8, 15, 0, 0, time.Local)
+		end      = begin.Add(time.Hour)
+	)
+
+	if hosts, err = tdb.HostGetAll(); err != nil {
+		t.Fatalf("Error fetching all hosts: %s", err.Error())
+	}
+
+	for {
+		if cnt, err = tdb.RollupProcess(batchSize); err != nil {
+			t.Fatalf("Error processing rollups: %s", err.Error())
+		}
+
+		sum += cnt
+
+		if cnt < batchSize {
+			break
+		}
+	}
+
+	if sum != recCnt*len(hosts) {
+		t.Fatalf("Expected %d Records to be aggregated, got %d",
+			recCnt*len(hosts),
+			sum)
+	} else if recs, err = tdb.RecordGetByHostType(&hosts[0], recordtype.LoadAvg); err != nil {
+		t.Fatalf("Error fetching Records: %s", err.Error())
+	}
+
+	// Compute the expected hourly rollup of load1 from the raw data.
+	var expect = make(map[time.Time]*model.Rollup)
+
+	for i := range recs {
+		var (
+			samples []model.Sample
+			bucket  = resolution.Hour.Bucket(recs[i].Timestamp)
+		)
+
+		if samples, err = recs[i].Samples(); err != nil {
+			t.Fatalf("Cannot get Samples from Record %d: %s",
+				recs[i].ID,
+				err.Error())
+		} else if expect[bucket] == nil {
+			expect[bucket] = &model.Rollup{}
+		}
+
+		expect[bucket].Add(samples[0].Value)
+	}
+
+	if rollups, err = tdb.RollupGetByHostType(&hosts[0], recordtype.LoadAvg, resolution.Minute, begin, end); err != nil {
+		t.Fatalf("Error fetching minute rollups: %s", err.Error())
+	} else if len(rollups) != recCnt*3 {
+		t.Errorf("Expected %d minute rollups, got %d",
+			recCnt*3,
+			len(rollups))
+	} else if rollups, err = tdb.RollupGetByHostType(&hosts[0], recordtype.LoadAvg, resolution.Hour, begin, end); err != nil {
+		t.Fatalf("Error fetching hour rollups: %s", err.Error())
+	}
+
+	for _, r := range rollups {
+		var x = expect[r.Bucket]
+
+		if r.Metric != "load1" {
+			continue
+		} else if x == nil {
+			t.Errorf("Unexpected hourly rollup for %s", r.Bucket)
+		} else if r.Count != x.Count {
+			t.Errorf("Hourly rollup for %s should have %d values, not %d",
+				r.Bucket,
+				x.Count,
+				r.Count)
+		} else if math.Abs(r.Avg-x.Avg) > 1e-9 || r.Min != x.Min || r.Max != x.Max {
+			t.Errorf("Hourly rollup for %s is %.3f/%.3f/%.3f, expected %.3f/%.3f/%.3f",
+				r.Bucket,
+				r.Min, r.Avg, r.Max,
+				x.Min, x.Avg, x.Max)
+		}
+	}
+
+	// A Record that arrives late has to end up in the bucket it belongs
+	// to.
+	var late = model.Record{
+		HostID:    int64(hosts[0].ID),
+		Timestamp: begin.Add(time.Second * 30),
+		Source:    recordtype.LoadAvg,
+		Payload:   "[100, 100, 100]",
+	}
+
+	if err = tdb.RecordAdd(&late); err != nil {
+		t.Fatalf("Cannot add late Record: %s", err.Error())
+	} else if cnt, err = tdb.RollupProcess(batchSize); err != nil {
+		t.Fatalf("Error processing rollups: %s", err.Error())
+	} else if cnt != 1 {
+		t.Fatalf("Expected 1 Record to be aggregated, got %d", cnt)
+	} else if rollups, err = tdb.RollupGetByHostType(&hosts[0], recordtype.LoadAvg, resolution.Minute, begin, begin); err != nil {
+		t.Fatalf("Error fetching minute rollups: %s", err.Error())
+	} else if len(rollups) != 3 {
+		t.Fatalf("Expected 3 minute rollups, got %d", len(rollups))
+	} else if rollups[0].Count != 2 {
+		t.Errorf("Minute rollup should have 2 values, not %d", rollups[0].Count)
+	} else if rollups[0].Max != 100 {
+		t.Errorf("Maximum of minute rollup should be 100, not %.2f", rollups[0].Max)
+	}
+} // func TestRollup(t *testing.T)
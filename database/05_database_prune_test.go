@@ -0,0 +1,128 @@
+// /home/krylon/go/src/github.com/blicero/donkey/database/05_database_prune_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 01:25:51 krylon>
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/recordtype"
+	"github.com/blicero/donkey/model/resolution"
+)
+
+// TestPrune relies on TestRollup having added all Records to the rollups.
+func TestPrune(t *testing.T) {
+	if tdb == nil {
+		t.SkipNow()
+	}
+
+	const chunkSize = 5
+	var (
+		err        error
+		cnt, total int64
+		hosts      []model.Host
+		recs       []model.Record
+		before     = time.Date(2024, 4, 1, 8, 20, 0, 0, time.Local)
+	)
+
+	if hosts, err = tdb.HostGetAll(); err != nil {
+		t.Fatalf("Error fetching all hosts: %s", err.Error())
+	}
+
+	// A Record that has not been added to the rollups must not be
+	// deleted, no matter how old it is.
+	var pending = model.Record{
+		HostID:    int64(hosts[1].ID),
+		Timestamp: before.Add(-time.Hour),
+		Source:    recordtype.LoadAvg,
+		Payload:   "[0.5, 0.5, 0.5]",
+	}
+
+	if err = tdb.RecordAdd(&pending); err != nil {
+		t.Fatalf("Cannot add Record: %s", err.Error())
+	}
+
+	for {
+		if cnt, err = tdb.RecordPrune(recordtype.LoadAvg, before, chunkSize); err != nil {
+			t.Fatalf("Error pruning Records: %s", err.Error())
+		} else if cnt > chunkSize {
+			t.Fatalf("Deleted %d Records, more than chunk size %d", cnt, chunkSize)
+		}
+
+		total += cnt
+
+		if cnt < chunkSize {
+			break
+		}
+	}
+
+	// Five Records per Host, plus the late Record added by TestRollup.
+	if total != int64(5*len(hosts)+1) {
+		t.Errorf("Expected %d Records to be pruned, got %d",
+			5*len(hosts)+1,
+			total)
+	} else if recs, err = tdb.RecordGetByHostType(&hosts[1], recordtype.LoadAvg); err != nil {
+		t.Fatalf("Error fetching Records: %s", err.Error())
+	} else if len(recs) != 21 {
+		t.Errorf("Expected 21 Records to remain, got %d", len(recs))
+	} else if recs[0].ID != pending.ID {
+		t.Errorf("Record %d should not have been pruned before being added to the rollups",
+			pending.ID)
+	}
+
+	if cnt, err = tdb.RollupPrune(resolution.Minute, before, 1000); err != nil {
+		t.Fatalf("Error pruning rollups: %s", err.Error())
+	} else if cnt != int64(5*3*len(hosts)) {
+		t.Errorf("Expected %d rollups to be pruned, got %d",
+			5*3*len(hosts),
+			cnt)
+	} else if cnt, err = tdb.RollupPrune(resolution.Raw, before, 1000); err == nil {
+		t.Errorf("Pruning rollups at resolution %s should have failed",
+			resolution.Raw)
+	}
+} // func TestPrune(t *testing.T)
+
+// TestPruneIDs checks that the ID of the newest Record is not handed out
+// again after the Record has been deleted, lest the new Record be taken for
+// one that has been added to the rollups already.
+func TestPruneIDs(t *testing.T) {
+	if tdb == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err         error
+		hosts       []model.Host
+		first, next model.Record
+	)
+
+	if hosts, err = tdb.HostGetAll(); err != nil {
+		t.Fatalf("Error fetching all hosts: %s", err.Error())
+	}
+
+	first = model.Record{
+		HostID:    int64(hosts[0].ID),
+		Timestamp: time.Now(),
+		Source:    recordtype.LoadAvg,
+		Payload:   "[0.5, 0.5, 0.5]",
+	}
+	next = first
+	next.Timestamp = first.Timestamp.Add(time.Minute)
+
+	if err = tdb.RecordAdd(&first); err != nil {
+		t.Fatalf("Cannot add Record: %s", err.Error())
+	} else if _, err = tdb.db.Exec("DELETE FROM record WHERE id = ?", first.ID); err != nil {
+		t.Fatalf("Cannot delete Record %d: %s", first.ID, err.Error())
+	} else if err = tdb.RecordAdd(&next); err != nil {
+		t.Fatalf("Cannot add Record: %s", err.Error())
+	} else if next.ID <= first.ID {
+		t.Errorf("ID %d of a deleted Record was handed out again as %d",
+			first.ID,
+			next.ID)
+	}
+} // func TestPruneIDs(t *testing.T)
@@ -0,0 +1,59 @@
+// /home/krylon/go/src/github.com/blicero/donkey/database/06_database_pool_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 03:20:31 krylon>
+
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPoolStats(t *testing.T) {
+	const delay = time.Millisecond * 50
+
+	var (
+		err   error
+		pool  *Pool
+		db    *Database
+		stats PoolStats
+		done  = make(chan *Database)
+	)
+
+	if pool, err = NewPool(1); err != nil {
+		t.Fatalf("Cannot create Pool: %s", err.Error())
+	}
+
+	defer pool.Close() // nolint: errcheck
+
+	db = pool.Get()
+
+	go func() {
+		done <- pool.Get()
+	}()
+
+	time.Sleep(delay)
+	pool.Put(db)
+	db = <-done
+	pool.Put(db)
+
+	stats = pool.Stats()
+
+	if stats.Idle != 1 {
+		t.Errorf("Expected 1 idle connection, got %d", stats.Idle)
+	}
+
+	if stats.Gets != 2 {
+		t.Errorf("Expected 2 Gets, got %d", stats.Gets)
+	}
+
+	if stats.Waits != 1 {
+		t.Errorf("Expected 1 Wait, got %d", stats.Waits)
+	}
+
+	if stats.WaitTime < delay/2 {
+		t.Errorf("Wait time is too short: %s", stats.WaitTime)
+	}
+} // func TestPoolStats(t *testing.T)
@@ -8,11 +8,13 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"os"
 	"regexp"
+	"slices"
 	"sync"
 	"time"
 
@@ -20,7 +22,9 @@ import (
 	"github.com/blicero/donkey/database/query"
 	"github.com/blicero/donkey/logdomain"
 	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/hoststate"
 	"github.com/blicero/donkey/model/recordtype"
+	"github.com/blicero/donkey/model/resolution"
 	"github.com/blicero/krylib"
 	_ "github.com/mattn/go-sqlite3" // Import the database driver
 )
@@ -87,7 +91,8 @@ type Database struct {
 }
 
 // Open opens a Database. If the database specified by the path does not exist,
-// yet, it is created and initialized.
+// yet, it is created and initialized. Pending schema migrations are applied
+// automatically.
 func Open(path string) (*Database, error) {
 	var (
 		err      error
@@ -144,6 +149,14 @@ func Open(path string) (*Database, error) {
 			path)
 	}
 
+	if err = db.migrate(); err != nil {
+		db.log.Printf("[ERROR] Failed to migrate database schema at %s: %s\n",
+			path,
+			err.Error())
+		db.db.Close() // nolint: errcheck
+		return nil, err
+	}
+
 	return db, nil
 } // func Open(path string) (*Database, error)
 
@@ -675,11 +688,11 @@ EXEC_QUERY:
 
 	if rows.Next() {
 		var (
-			timestamp int64
-			host      = &model.Host{ID: id}
+			timestamp, interval int64
+			host                = &model.Host{ID: id}
 		)
 
-		if err = rows.Scan(&host.Name, &host.Addr, &host.OS, &timestamp); err != nil {
+		if err = rows.Scan(&host.Name, &host.Addr, &host.OS, &timestamp, &host.State, &interval); err != nil {
 			msg = fmt.Sprintf("Error scanning row for Host %d: %s",
 				id,
 				err.Error())
@@ -688,6 +701,7 @@ EXEC_QUERY:
 		}
 
 		host.LastContact = time.Unix(timestamp, 0)
+		host.Interval = time.Second * time.Duration(interval)
 
 		return host, nil
 	}
@@ -731,11 +745,11 @@ EXEC_QUERY:
 
 	if rows.Next() {
 		var (
-			stamp int64
-			host  = &model.Host{Name: name}
+			stamp, interval int64
+			host            = &model.Host{Name: name}
 		)
 
-		if err = rows.Scan(&host.ID, &host.Addr, &host.OS, &stamp); err != nil {
+		if err = rows.Scan(&host.ID, &host.Addr, &host.OS, &stamp, &host.State, &interval); err != nil {
 			msg = fmt.Sprintf("Error scanning row for Host %s: %s",
 				name,
 				err.Error())
@@ -744,6 +758,7 @@ EXEC_QUERY:
 		}
 
 		host.LastContact = time.Unix(stamp, 0)
+		host.Interval = time.Second * time.Duration(interval)
 
 		return host, nil
 	}
@@ -787,11 +802,11 @@ EXEC_QUERY:
 
 	if rows.Next() {
 		var (
-			stamp int64
-			host  = &model.Host{Addr: addr}
+			stamp, interval int64
+			host            = &model.Host{Addr: addr}
 		)
 
-		if err = rows.Scan(&host.ID, &host.Name, &host.OS, &stamp); err != nil {
+		if err = rows.Scan(&host.ID, &host.Name, &host.OS, &stamp, &host.State, &interval); err != nil {
 			msg = fmt.Sprintf("Error scanning row for Host %s: %s",
 				addr,
 				err.Error())
@@ -800,6 +815,7 @@ EXEC_QUERY:
 		}
 
 		host.LastContact = time.Unix(stamp, 0)
+		host.Interval = time.Second * time.Duration(interval)
 
 		return host, nil
 	}
@@ -844,11 +860,11 @@ EXEC_QUERY:
 
 	for rows.Next() {
 		var (
-			stamp int64
-			host  model.Host
+			stamp, interval int64
+			host            model.Host
 		)
 
-		if err = rows.Scan(&host.ID, &host.Name, &host.Addr, &host.OS, &stamp); err != nil {
+		if err = rows.Scan(&host.ID, &host.Name, &host.Addr, &host.OS, &stamp, &host.State, &interval); err != nil {
 			msg = fmt.Sprintf("Error scanning row: %s",
 				err.Error())
 			db.log.Printf("[ERROR] %s\n", msg)
@@ -856,6 +872,7 @@ EXEC_QUERY:
 		}
 
 		host.LastContact = time.Unix(stamp, 0)
+		host.Interval = time.Second * time.Duration(interval)
 		hosts = append(hosts, host)
 	}
 
@@ -1280,9 +1297,10 @@ EXEC_QUERY:
 	return nil
 } // func (db *Database) HostUpdateLastContact(h *model.Host, stamp time.Time) error
 
-// LoadAdd adds a system load measurement to the database.
-func (db *Database) LoadAdd(l *model.Load) error {
-	const qid query.ID = query.LoadAdd
+// HostUpdateInterval sets the longest time the Host's Agent goes without
+// contacting the Server, as reported by the Agent.
+func (db *Database) HostUpdateInterval(h *model.Host, interval time.Duration) error {
+	const qid query.ID = query.HostUpdateInterval
 	var (
 		err    error
 		msg    string
@@ -1328,106 +1346,215 @@ func (db *Database) LoadAdd(l *model.Load) error {
 	}
 
 	stmt = tx.Stmt(stmt)
-	var rows *sql.Rows
+	var (
+		res         sql.Result
+		numAffected int64
+	)
 
 EXEC_QUERY:
-	if rows, err = stmt.Query(l.HostID, l.Timestamp.Unix(), recordtype.LoadAvg, l.Payload()); err != nil {
+	if res, err = stmt.Exec(int64(interval.Seconds()), h.ID); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
 		} else {
-			err = fmt.Errorf("Cannot add Load to database: %s",
+			err = fmt.Errorf("Cannot change contact interval of Host %d from %s to %s: %s",
+				h.ID,
+				h.Interval,
+				interval,
 				err.Error())
 			db.log.Printf("[ERROR] %s\n", err.Error())
 			return err
 		}
+	} else if numAffected, err = res.RowsAffected(); err != nil {
+		msg = fmt.Sprintf("Failed to query query result for number of affected rows: %s",
+			err.Error())
+		db.log.Printf("[ERROR] %s\n", msg)
+		return err
+	} else if numAffected != 1 {
+		db.log.Printf("[DEBUG] Failed to change contact interval of Host %d from %s to %s, no matching record found in database.\n",
+			h.ID,
+			h.Interval,
+			interval)
 	} else {
-		var id int64
+		h.Interval = interval
+	}
 
-		defer rows.Close()
+	status = true
+	return nil
+} // func (db *Database) HostUpdateInterval(h *model.Host, interval time.Duration) error
 
-		if !rows.Next() {
-			// CANTHAPPEN
-			db.log.Printf("[ERROR] Query %s did not return a value\n",
-				qid)
-			return fmt.Errorf("Query %s did not return a value", qid)
-		} else if err = rows.Scan(&id); err != nil {
-			msg = fmt.Sprintf("Failed to get ID for newly added Load data: %s",
-				err.Error())
-			db.log.Printf("[ERROR] %s\n", msg)
-			return errors.New(msg)
+// HostUpdateState sets the liveness state of a Host and records the change
+// as a HostEvent. If the Host is already in the given state, nothing
+// happens.
+func (db *Database) HostUpdateState(h *model.Host, state hoststate.State, stamp time.Time) error {
+	const qid query.ID = query.HostUpdateState
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if h.State == state {
+		return nil
+	} else if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
 		}
+	}
 
-		l.ID = krylib.ID(id)
+	stmt = tx.Stmt(stmt)
+	var (
+		res         sql.Result
+		numAffected int64
+		evStmt      *sql.Stmt
+		evID        int64
+	)
+
+EXEC_QUERY:
+	if res, err = stmt.Exec(state, h.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot change state of Host %d from %s to %s: %s",
+				h.ID,
+				h.State,
+				state,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	} else if numAffected, err = res.RowsAffected(); err != nil {
+		msg = fmt.Sprintf("Failed to query query result for number of affected rows: %s",
+			err.Error())
+		db.log.Printf("[ERROR] %s\n", msg)
+		return err
+	} else if numAffected != 1 {
+		db.log.Printf("[DEBUG] Failed to change state of Host %d from %s to %s, no matching record found in database.\n",
+			h.ID,
+			h.State,
+			state)
 		status = true
 		return nil
+	} else if evStmt, err = db.getQuery(query.HostEventAdd); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			query.HostEventAdd,
+			err.Error())
+		return err
 	}
-} // func (db *Database) LoadAdd(l *Load) error
 
-// LoadGetByHost fetches the up to <n> most recent Load values for the given Host.
-func (db *Database) LoadGetByHost(id krylib.ID, n int64) ([]model.Load, error) {
-	const qid query.ID = query.LoadGetByHost
+	evStmt = tx.Stmt(evStmt)
+
+EXEC_EVENT:
+	if err = evStmt.QueryRow(h.ID, stamp.Unix(), h.State, state).Scan(&evID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_EVENT
+		}
+
+		err = fmt.Errorf("Cannot record state change of Host %d from %s to %s: %s",
+			h.ID,
+			h.State,
+			state,
+			err.Error())
+		db.log.Printf("[ERROR] %s\n", err.Error())
+		return err
+	}
+
+	h.State = state
+	status = true
+	return nil
+} // func (db *Database) HostUpdateState(h *model.Host, state hoststate.State, stamp time.Time) error
+
+// HostGetToken returns the hash of the token issued to the given Host.
+// If the Host has not been issued a token, or if it does not exist, it
+// returns an empty string.
+func (db *Database) HostGetToken(id krylib.ID) (string, error) {
+	const qid query.ID = query.HostGetToken
 	var (
 		err  error
 		msg  string
 		stmt *sql.Stmt
-		cnt  int64
 	)
 
 	if stmt, err = db.getQuery(qid); err != nil {
 		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
 			qid,
 			err.Error())
-		return nil, err
+		return "", err
 	} else if db.tx != nil {
 		stmt = db.tx.Stmt(stmt)
 	}
 
 	var rows *sql.Rows
 
-	if n < 0 {
-		cnt = 16
-	} else {
-		cnt = n
-	}
-
 EXEC_QUERY:
-	if rows, err = stmt.Query(id, n); err != nil {
+	if rows, err = stmt.Query(id); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
 		}
 
-		return nil, err
+		return "", err
 	}
 
 	defer rows.Close() // nolint: errcheck,gosec
-	var data = make([]model.Load, 0, cnt)
 
-	for rows.Next() {
-		var (
-			load  = model.Load{HostID: id}
-			stamp int64
-		)
+	if rows.Next() {
+		var token string
 
-		if err = rows.Scan(&load.ID, &stamp, &load.Load[0], &load.Load[1], &load.Load[2]); err != nil {
-			msg = fmt.Sprintf("Error scanning row: %s",
+		if err = rows.Scan(&token); err != nil {
+			msg = fmt.Sprintf("Error scanning token of Host %d: %s",
+				id,
 				err.Error())
 			db.log.Printf("[ERROR] %s\n", msg)
-			return nil, errors.New(msg)
+			return "", errors.New(msg)
 		}
 
-		load.Timestamp = time.Unix(stamp, 0)
-
-		data = append(data, load)
+		return token, nil
 	}
 
-	return data, nil
-} // func (db *Database) LoadGetByHost(id krylib.ID) ([]model.Load, error)
+	db.log.Printf("[DEBUG] Host %d was not found in database.\n", id)
 
-// RecordAdd adds a Record to the database. Like, for real.
-func (db *Database) RecordAdd(rec *model.Record) error {
-	const qid query.ID = query.RecordAdd
+	return "", nil
+} // func (db *Database) HostGetToken(id krylib.ID) (string, error)
+
+// HostUpdateToken stores the hash of the token issued to a Host, replacing
+// the previous one.
+func (db *Database) HostUpdateToken(h *model.Host, hash string) error {
+	const qid query.ID = query.HostUpdateToken
 	var (
 		err    error
 		msg    string
@@ -1444,8 +1571,6 @@ func (db *Database) RecordAdd(rec *model.Record) error {
 	} else if db.tx != nil {
 		tx = db.tx
 	} else {
-		db.log.Printf("[INFO] Start ad-hoc transaction for adding Record for Host #%d\n",
-			rec.HostID)
 	BEGIN_AD_HOC:
 		if tx, err = db.db.Begin(); err != nil {
 			if worthARetry(err) {
@@ -1475,56 +1600,49 @@ func (db *Database) RecordAdd(rec *model.Record) error {
 	}
 
 	stmt = tx.Stmt(stmt)
-	var rows *sql.Rows
+	var (
+		res         sql.Result
+		numAffected int64
+	)
 
 EXEC_QUERY:
-	if rows, err = stmt.Query(rec.HostID, rec.Timestamp.Unix(), recordtype.LoadAvg, rec.Payload); err != nil {
+	if res, err = stmt.Exec(hash, h.ID); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
 		} else {
-			err = fmt.Errorf("Cannot add Load to database: %s",
+			err = fmt.Errorf("Cannot update token of Host %d: %s",
+				h.ID,
 				err.Error())
 			db.log.Printf("[ERROR] %s\n", err.Error())
 			return err
 		}
-	}
-
-	var id int64
-
-	defer rows.Close()
-
-	if !rows.Next() {
-		// CANTHAPPEN
-		db.log.Printf("[ERROR] Query %s did not return a value\n",
-			qid)
-		return fmt.Errorf("Query %s did not return a value", qid)
-	} else if err = rows.Scan(&id); err != nil {
-		msg = fmt.Sprintf("Failed to get ID for newly added Load data: %s",
+	} else if numAffected, err = res.RowsAffected(); err != nil {
+		msg = fmt.Sprintf("Failed to query query result for number of affected rows: %s",
 			err.Error())
 		db.log.Printf("[ERROR] %s\n", msg)
-		return errors.New(msg)
+		return err
+	} else if numAffected != 1 {
+		err = fmt.Errorf("Cannot update token of Host %d: Host was not found in database",
+			h.ID)
+		db.log.Printf("[ERROR] %s\n", err.Error())
+		return err
 	}
 
-	rec.ID = id
 	status = true
 	return nil
-} // func (db *Database) RecordAdd(rec *model.Record) error
+} // func (db *Database) HostUpdateToken(h *model.Host, hash string) error
 
-// RecordGetByHost retrieves all Records for a given Host, of all types.
-// Probably a bit of a blunt instrument.
-func (db *Database) RecordGetByHost(h *model.Host) ([]model.Record, error) {
-	const qid query.ID = query.RecordGetByHost
+// HostEventGetByHost fetches the up to <n> most recent state changes of the
+// given Host, newest first.
+func (db *Database) HostEventGetByHost(id krylib.ID, n int64) ([]model.HostEvent, error) {
+	const qid query.ID = query.HostEventGetByHost
 	var (
 		err  error
-		msg  string
 		stmt *sql.Stmt
+		rows *sql.Rows
 	)
 
-	if h == nil {
-		return nil, krylib.ErrInvalidValue
-	}
-
 	if stmt, err = db.getQuery(qid); err != nil {
 		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
 			qid,
@@ -1534,10 +1652,8 @@ func (db *Database) RecordGetByHost(h *model.Host) ([]model.Record, error) {
 		stmt = db.tx.Stmt(stmt)
 	}
 
-	var rows *sql.Rows
-
 EXEC_QUERY:
-	if rows, err = stmt.Query(h.ID); err != nil {
+	if rows, err = stmt.Query(id, n); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
@@ -1547,37 +1663,36 @@ EXEC_QUERY:
 	}
 
 	defer rows.Close() // nolint: errcheck,gosec
-	var data = make([]model.Record, 0)
+	var events = make([]model.HostEvent, 0)
 
 	for rows.Next() {
 		var (
-			rec        = model.Record{HostID: int64(h.ID)}
-			stamp, src int64
+			stamp int64
+			ev    = model.HostEvent{HostID: id}
 		)
 
-		if err = rows.Scan(&rec.ID, &stamp, &src, &rec.Payload); err != nil {
-			msg = fmt.Sprintf("Error scanning row: %s",
+		if err = rows.Scan(&ev.ID, &stamp, &ev.Previous, &ev.State); err != nil {
+			var msg = fmt.Sprintf("Error scanning row: %s",
 				err.Error())
 			db.log.Printf("[ERROR] %s\n", msg)
 			return nil, errors.New(msg)
 		}
 
-		rec.Source = recordtype.ID(src)
-		rec.Timestamp = time.Unix(stamp, 0)
-
-		data = append(data, rec)
+		ev.Timestamp = time.Unix(stamp, 0)
+		events = append(events, ev)
 	}
 
-	return data, nil
-} // func (db *Database) RecordGetByHost(h *model.Host) ([]model.Record, error)
+	return events, nil
+} // func (db *Database) HostEventGetByHost(id krylib.ID, n int64) ([]model.HostEvent, error)
 
-// RecordGetByType fetches all Records of a given type.
-func (db *Database) RecordGetByType(id recordtype.ID) ([]model.Record, error) {
-	const qid query.ID = query.RecordGetByType
+// HostEventGetRecent fetches the up to <n> most recent state changes of all
+// Hosts, newest first.
+func (db *Database) HostEventGetRecent(n int64) ([]model.HostEvent, error) {
+	const qid query.ID = query.HostEventGetRecent
 	var (
 		err  error
-		msg  string
 		stmt *sql.Stmt
+		rows *sql.Rows
 	)
 
 	if stmt, err = db.getQuery(qid); err != nil {
@@ -1589,10 +1704,8 @@ func (db *Database) RecordGetByType(id recordtype.ID) ([]model.Record, error) {
 		stmt = db.tx.Stmt(stmt)
 	}
 
-	var rows *sql.Rows
-
 EXEC_QUERY:
-	if rows, err = stmt.Query(id); err != nil {
+	if rows, err = stmt.Query(n); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
@@ -1602,82 +1715,1525 @@ EXEC_QUERY:
 	}
 
 	defer rows.Close() // nolint: errcheck,gosec
-	var data = make([]model.Record, 0)
+	var events = make([]model.HostEvent, 0)
 
 	for rows.Next() {
 		var (
-			rec   = model.Record{Source: id}
 			stamp int64
+			ev    model.HostEvent
 		)
 
-		if err = rows.Scan(&rec.ID, &rec.HostID, &stamp, &rec.Payload); err != nil {
-			msg = fmt.Sprintf("Error scanning row: %s",
+		if err = rows.Scan(&ev.ID, &ev.HostID, &stamp, &ev.Previous, &ev.State); err != nil {
+			var msg = fmt.Sprintf("Error scanning row: %s",
 				err.Error())
 			db.log.Printf("[ERROR] %s\n", msg)
 			return nil, errors.New(msg)
 		}
 
-		rec.Timestamp = time.Unix(stamp, 0)
-
-		data = append(data, rec)
+		ev.Timestamp = time.Unix(stamp, 0)
+		events = append(events, ev)
 	}
 
-	return data, nil
-} // func (db *Database) RecordGetByType(id recordtype.ID) ([]model.Record, error)
+	return events, nil
+} // func (db *Database) HostEventGetRecent(n int64) ([]model.HostEvent, error)
 
-// RecordGetByHostType fetches all records for a given Host of a given type.
-func (db *Database) RecordGetByHostType(h *model.Host, t recordtype.ID) ([]model.Record, error) {
-	const qid query.ID = query.RecordGetByHostType
+// LoadAdd adds a system load measurement to the database.
+func (db *Database) LoadAdd(l *model.Load) error {
+	const qid query.ID = query.LoadAdd
 	var (
-		err  error
-		msg  string
-		stmt *sql.Stmt
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
 	)
 
 	if stmt, err = db.getQuery(qid); err != nil {
 		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
-			qid,
+			qid.String(),
 			err.Error())
-		return nil, err
+		return err
 	} else if db.tx != nil {
-		stmt = db.tx.Stmt(stmt)
-	}
-
-	var rows *sql.Rows
-
-EXEC_QUERY:
-	if rows, err = stmt.Query(h.ID, t); err != nil {
-		if worthARetry(err) {
-			waitForRetry()
-			goto EXEC_QUERY
-		}
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(l.HostID, l.Timestamp.Unix(), recordtype.LoadAvg, l.Payload()); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot add Load to database: %s",
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	} else {
+		var id int64
+
+		defer rows.Close()
+
+		if !rows.Next() {
+			// CANTHAPPEN
+			db.log.Printf("[ERROR] Query %s did not return a value\n",
+				qid)
+			return fmt.Errorf("Query %s did not return a value", qid)
+		} else if err = rows.Scan(&id); err != nil {
+			msg = fmt.Sprintf("Failed to get ID for newly added Load data: %s",
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", msg)
+			return errors.New(msg)
+		}
+
+		l.ID = krylib.ID(id)
+		status = true
+		return nil
+	}
+} // func (db *Database) LoadAdd(l *Load) error
+
+// LoadGetByHost fetches the up to <n> most recent Load values for the given
+// Host, in chronological order. If n is negative, all Load values are
+// returned.
+func (db *Database) LoadGetByHost(id krylib.ID, n int64) ([]model.Load, error) {
+	const qid query.ID = query.LoadGetByHost
+	var (
+		err  error
+		msg  string
+		stmt *sql.Stmt
+		cnt  int64
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+	if n < 0 {
+		cnt = 16
+	} else {
+		cnt = n
+	}
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(id, recordtype.LoadAvg, n); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+	var data = make([]model.Load, 0, cnt)
+
+	for rows.Next() {
+		var (
+			load  = model.Load{HostID: id}
+			stamp int64
+		)
+
+		if err = rows.Scan(&load.ID, &stamp, &load.Load[0], &load.Load[1], &load.Load[2]); err != nil {
+			msg = fmt.Sprintf("Error scanning row: %s",
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", msg)
+			return nil, errors.New(msg)
+		}
+
+		load.Timestamp = time.Unix(stamp, 0)
+
+		data = append(data, load)
+	}
+
+	slices.Reverse(data)
+
+	return data, nil
+} // func (db *Database) LoadGetByHost(id krylib.ID, n int64) ([]model.Load, error)
+
+// LoadGetByPeriod fetches the Load values of all Hosts from the given period,
+// ordered by timestamp.
+func (db *Database) LoadGetByPeriod(begin, end time.Time) ([]model.Load, error) {
+	const qid query.ID = query.LoadgetByPeriod
+	var (
+		err  error
+		msg  string
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(recordtype.LoadAvg, begin.Unix(), end.Unix()); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+	var data = make([]model.Load, 0)
+
+	for rows.Next() {
+		var (
+			load  model.Load
+			stamp int64
+		)
+
+		if err = rows.Scan(&load.ID, &load.HostID, &stamp, &load.Load[0], &load.Load[1], &load.Load[2]); err != nil {
+			msg = fmt.Sprintf("Error scanning row: %s",
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", msg)
+			return nil, errors.New(msg)
+		}
+
+		load.Timestamp = time.Unix(stamp, 0)
+
+		data = append(data, load)
+	}
+
+	return data, nil
+} // func (db *Database) LoadGetByPeriod(begin, end time.Time) ([]model.Load, error)
+
+// RecordAdd adds a Record to the database. Like, for real.
+func (db *Database) RecordAdd(rec *model.Record) error {
+	const qid query.ID = query.RecordAdd
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+		db.log.Printf("[INFO] Start ad-hoc transaction for adding Record for Host #%d\n",
+			rec.HostID)
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(rec.HostID, rec.Timestamp.Unix(), rec.Source, rec.Instance(), rec.Payload); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot add Record to database: %s",
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	var id int64
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		// CANTHAPPEN
+		db.log.Printf("[ERROR] Query %s did not return a value\n",
+			qid)
+		return fmt.Errorf("Query %s did not return a value", qid)
+	} else if err = rows.Scan(&id); err != nil {
+		msg = fmt.Sprintf("Failed to get ID for newly added Load data: %s",
+			err.Error())
+		db.log.Printf("[ERROR] %s\n", msg)
+		return errors.New(msg)
+	}
+
+	rec.ID = id
+	status = true
+	return nil
+} // func (db *Database) RecordAdd(rec *model.Record) error
+
+// RecordGetByHost retrieves all Records for a given Host, of all types.
+// Probably a bit of a blunt instrument, see RecordGetByHostRange.
+func (db *Database) RecordGetByHost(h *model.Host) ([]model.Record, error) {
+	const qid query.ID = query.RecordGetByHost
+	var (
+		err  error
+		msg  string
+		stmt *sql.Stmt
+	)
+
+	if h == nil {
+		return nil, krylib.ErrInvalidValue
+	}
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(h.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+	var data = make([]model.Record, 0)
+
+	for rows.Next() {
+		var (
+			rec        = model.Record{HostID: int64(h.ID)}
+			stamp, src int64
+		)
+
+		if err = rows.Scan(&rec.ID, &stamp, &src, &rec.Payload); err != nil {
+			msg = fmt.Sprintf("Error scanning row: %s",
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", msg)
+			return nil, errors.New(msg)
+		}
+
+		rec.Source = recordtype.ID(src)
+		rec.Timestamp = time.Unix(stamp, 0)
+
+		data = append(data, rec)
+	}
+
+	return data, nil
+} // func (db *Database) RecordGetByHost(h *model.Host) ([]model.Record, error)
+
+// RecordGetByType fetches all Records of a given type.
+// See RecordGetByTypeRange to fetch them in smaller portions.
+func (db *Database) RecordGetByType(id recordtype.ID) ([]model.Record, error) {
+	const qid query.ID = query.RecordGetByType
+	var (
+		err  error
+		msg  string
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(id); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+	var data = make([]model.Record, 0)
+
+	for rows.Next() {
+		var (
+			rec   = model.Record{Source: id}
+			stamp int64
+		)
+
+		if err = rows.Scan(&rec.ID, &rec.HostID, &stamp, &rec.Payload); err != nil {
+			msg = fmt.Sprintf("Error scanning row: %s",
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", msg)
+			return nil, errors.New(msg)
+		}
+
+		rec.Timestamp = time.Unix(stamp, 0)
+
+		data = append(data, rec)
+	}
+
+	return data, nil
+} // func (db *Database) RecordGetByType(id recordtype.ID) ([]model.Record, error)
+
+// RecordGetByHostType fetches all records for a given Host of a given type.
+// See RecordGetByHostTypeRange to fetch them in smaller portions.
+func (db *Database) RecordGetByHostType(h *model.Host, t recordtype.ID) ([]model.Record, error) {
+	const qid query.ID = query.RecordGetByHostType
+	var (
+		err  error
+		msg  string
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(h.ID, t); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+	var data = make([]model.Record, 0)
+
+	for rows.Next() {
+		var (
+			rec = model.Record{
+				HostID: int64(h.ID),
+				Source: t,
+			}
+			stamp int64
+		)
+
+		if err = rows.Scan(&rec.ID, &stamp, &rec.Payload); err != nil {
+			msg = fmt.Sprintf("Error scanning row: %s",
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", msg)
+			return nil, errors.New(msg)
+		}
+
+		rec.Timestamp = time.Unix(stamp, 0)
+
+		data = append(data, rec)
+	}
+
+	return data, nil
+} // func (db *Database) RecordGetByHostType(h *model.Host, t recordtype.ID) ([]model.Record, error)
+
+// RecordGetLatestByHost fetches the most recent Record of each type and
+// instance, e.g. of each check, for the given Host, ordered by type.
+func (db *Database) RecordGetLatestByHost(h *model.Host) ([]model.Record, error) {
+	const qid query.ID = query.RecordGetLatestByHost
+	var (
+		err  error
+		msg  string
+		stmt *sql.Stmt
+	)
+
+	if h == nil {
+		return nil, krylib.ErrInvalidValue
+	}
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(h.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+	var data = make([]model.Record, 0)
+
+	for rows.Next() {
+		var (
+			rec        = model.Record{HostID: int64(h.ID)}
+			stamp, src int64
+		)
+
+		if err = rows.Scan(&rec.ID, &stamp, &src, &rec.Payload); err != nil {
+			msg = fmt.Sprintf("Error scanning row: %s",
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", msg)
+			return nil, errors.New(msg)
+		}
+
+		rec.Source = recordtype.ID(src)
+		rec.Timestamp = time.Unix(stamp, 0)
+
+		data = append(data, rec)
+	}
+
+	return data, nil
+} // func (db *Database) RecordGetLatestByHost(h *model.Host) ([]model.Record, error)
+
+// RecordGetByHostRange fetches a page of Records of all types for the given
+// Host.
+func (db *Database) RecordGetByHostRange(h *model.Host, r RecordRange) ([]model.Record, error) {
+	if h == nil {
+		return nil, krylib.ErrInvalidValue
+	}
+
+	var args = append([]any{h.ID}, r.args()...)
+
+	return db.recordQuery(query.RecordGetByHostRange, args...)
+} // func (db *Database) RecordGetByHostRange(h *model.Host, r RecordRange) ([]model.Record, error)
+
+// RecordGetByTypeRange fetches a page of Records of the given type from all
+// Hosts.
+func (db *Database) RecordGetByTypeRange(t recordtype.ID, r RecordRange) ([]model.Record, error) {
+	var args = append([]any{t}, r.args()...)
+
+	return db.recordQuery(query.RecordGetByTypeRange, args...)
+} // func (db *Database) RecordGetByTypeRange(t recordtype.ID, r RecordRange) ([]model.Record, error)
+
+// RecordGetByHostTypeRange fetches a page of Records of the given type for
+// the given Host.
+func (db *Database) RecordGetByHostTypeRange(h *model.Host, t recordtype.ID, r RecordRange) ([]model.Record, error) {
+	if h == nil {
+		return nil, krylib.ErrInvalidValue
+	}
+
+	var args = append([]any{h.ID, t}, r.args()...)
+
+	return db.recordQuery(query.RecordGetByHostTypeRange, args...)
+} // func (db *Database) RecordGetByHostTypeRange(h *model.Host, t recordtype.ID, r RecordRange) ([]model.Record, error)
+
+// recordQuery runs a query that returns the ID, Host ID, timestamp, type
+// and payload of Records.
+func (db *Database) recordQuery(qid query.ID, args ...any) ([]model.Record, error) {
+	var (
+		err  error
+		msg  string
+		stmt *sql.Stmt
+		rows *sql.Rows
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(args...); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+	var data = make([]model.Record, 0)
+
+	for rows.Next() {
+		var (
+			rec        model.Record
+			stamp, src int64
+		)
+
+		if err = rows.Scan(&rec.ID, &rec.HostID, &stamp, &src, &rec.Payload); err != nil {
+			msg = fmt.Sprintf("Error scanning row: %s",
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", msg)
+			return nil, errors.New(msg)
+		}
+
+		rec.Source = recordtype.ID(src)
+		rec.Timestamp = time.Unix(stamp, 0)
+
+		data = append(data, rec)
+	}
+
+	return data, nil
+} // func (db *Database) recordQuery(qid query.ID, args ...any) ([]model.Record, error)
+
+// unixStamp converts a time to a Unix timestamp for storing it in the
+// database, the zero time is stored as 0.
+func unixStamp(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.Unix()
+} // func unixStamp(t time.Time) int64
+
+// fromUnixStamp is the reverse of unixStamp.
+func fromUnixStamp(stamp int64) time.Time {
+	if stamp == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(stamp, 0)
+} // func fromUnixStamp(stamp int64) time.Time
+
+// AlertAdd adds an Alert to the database.
+func (db *Database) AlertAdd(a *model.Alert) error {
+	const qid query.ID = query.AlertAdd
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+	var id int64
+
+EXEC_QUERY:
+	if err = stmt.QueryRow(
+		a.Rule,
+		a.HostID,
+		a.Instance,
+		a.State,
+		a.Value,
+		a.Threshold,
+		unixStamp(a.Since),
+		unixStamp(a.Fired),
+		unixStamp(a.Resolved),
+		unixStamp(a.Updated)).Scan(&id); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		err = fmt.Errorf("Cannot add Alert %s for Host %d to database: %s",
+			a.Rule,
+			a.HostID,
+			err.Error())
+		db.log.Printf("[ERROR] %s\n", err.Error())
+		return err
+	}
+
+	a.ID = krylib.ID(id)
+	status = true
+	return nil
+} // func (db *Database) AlertAdd(a *model.Alert) error
+
+// AlertUpdate saves the state, value and timestamps of an Alert.
+func (db *Database) AlertUpdate(a *model.Alert) error {
+	const qid query.ID = query.AlertUpdate
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(
+		a.State,
+		a.Value,
+		a.Threshold,
+		unixStamp(a.Fired),
+		unixStamp(a.Resolved),
+		unixStamp(a.Updated),
+		a.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		err = fmt.Errorf("Cannot update Alert %d (%s): %s",
+			a.ID,
+			a.Rule,
+			err.Error())
+		db.log.Printf("[ERROR] %s\n", err.Error())
+		return err
+	}
+
+	status = true
+	return nil
+} // func (db *Database) AlertUpdate(a *model.Alert) error
+
+// AlertDelete removes an Alert from the database.
+func (db *Database) AlertDelete(a *model.Alert) error {
+	const qid query.ID = query.AlertDelete
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(a.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		err = fmt.Errorf("Cannot delete Alert %d (%s): %s",
+			a.ID,
+			a.Rule,
+			err.Error())
+		db.log.Printf("[ERROR] %s\n", err.Error())
+		return err
+	}
+
+	status = true
+	return nil
+} // func (db *Database) AlertDelete(a *model.Alert) error
+
+// AlertGetActive fetches all Alerts that have not been resolved.
+func (db *Database) AlertGetActive() ([]model.Alert, error) {
+	return db.alertQuery(query.AlertGetActive)
+} // func (db *Database) AlertGetActive() ([]model.Alert, error)
+
+// AlertGetRecent fetches the up to <n> most recently updated Alerts,
+// including resolved ones.
+func (db *Database) AlertGetRecent(n int64) ([]model.Alert, error) {
+	return db.alertQuery(query.AlertGetRecent, n)
+} // func (db *Database) AlertGetRecent(n int64) ([]model.Alert, error)
+
+func (db *Database) alertQuery(qid query.ID, args ...any) ([]model.Alert, error) {
+	var (
+		err  error
+		stmt *sql.Stmt
+		rows *sql.Rows
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(args...); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+	var alerts = make([]model.Alert, 0)
+
+	for rows.Next() {
+		var (
+			a                               model.Alert
+			since, fired, resolved, updated int64
+		)
+
+		if err = rows.Scan(
+			&a.ID,
+			&a.Rule,
+			&a.HostID,
+			&a.Instance,
+			&a.State,
+			&a.Value,
+			&a.Threshold,
+			&since,
+			&fired,
+			&resolved,
+			&updated); err != nil {
+			var msg = fmt.Sprintf("Error scanning row: %s",
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", msg)
+			return nil, errors.New(msg)
+		}
+
+		a.Since = fromUnixStamp(since)
+		a.Fired = fromUnixStamp(fired)
+		a.Resolved = fromUnixStamp(resolved)
+		a.Updated = fromUnixStamp(updated)
+		alerts = append(alerts, a)
+	}
+
+	return alerts, nil
+} // func (db *Database) alertQuery(qid query.ID, args ...any) ([]model.Alert, error)
+
+// DeliveryAdd records an attempt to deliver a Notification.
+func (db *Database) DeliveryAdd(d *model.Delivery) error {
+	const qid query.ID = query.DeliveryAdd
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		buf    []byte
+		status bool
+	)
+
+	if d.AlertIDs == nil {
+		d.AlertIDs = []krylib.ID{}
+	}
+
+	if buf, err = json.Marshal(d.AlertIDs); err != nil {
+		db.log.Printf("[ERROR] Cannot serialize Alert IDs: %s\n",
+			err.Error())
+		return err
+	} else if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+	var id int64
+
+EXEC_QUERY:
+	if err = stmt.QueryRow(
+		d.Channel,
+		d.Timestamp.Unix(),
+		string(buf),
+		d.Success,
+		d.Message).Scan(&id); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		err = fmt.Errorf("Cannot add Delivery via %s to database: %s",
+			d.Channel,
+			err.Error())
+		db.log.Printf("[ERROR] %s\n", err.Error())
+		return err
+	}
+
+	d.ID = krylib.ID(id)
+	status = true
+	return nil
+} // func (db *Database) DeliveryAdd(d *model.Delivery) error
+
+// DeliveryGetRecent fetches the up to <n> most recent delivery attempts,
+// newest first.
+func (db *Database) DeliveryGetRecent(n int64) ([]model.Delivery, error) {
+	const qid query.ID = query.DeliveryGetRecent
+	var (
+		err  error
+		stmt *sql.Stmt
+		rows *sql.Rows
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(n); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
 
 		return nil, err
 	}
 
 	defer rows.Close() // nolint: errcheck,gosec
-	var data = make([]model.Record, 0)
+	var list = make([]model.Delivery, 0)
 
 	for rows.Next() {
 		var (
-			rec = model.Record{
-				HostID: int64(h.ID),
-				Source: t,
+			stamp  int64
+			alerts string
+			d      model.Delivery
+		)
+
+		if err = rows.Scan(&d.ID, &d.Channel, &stamp, &alerts, &d.Success, &d.Message); err != nil {
+			var msg = fmt.Sprintf("Error scanning row: %s",
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", msg)
+			return nil, errors.New(msg)
+		} else if err = json.Unmarshal([]byte(alerts), &d.AlertIDs); err != nil {
+			db.log.Printf("[ERROR] Cannot parse Alert IDs of Delivery %d: %s\n",
+				d.ID,
+				err.Error())
+			return nil, err
+		}
+
+		d.Timestamp = time.Unix(stamp, 0)
+		list = append(list, d)
+	}
+
+	return list, nil
+} // func (db *Database) DeliveryGetRecent(n int64) ([]model.Delivery, error)
+
+// rollupKey identifies a Rollup while aggregating Records.
+type rollupKey struct {
+	host     int64
+	src      recordtype.ID
+	metric   string
+	instance string
+	res      resolution.ID
+	bucket   int64
+}
+
+// RollupProcess adds up to <limit> Records that have not been aggregated, yet,
+// to the rollups of all resolutions. It returns the number of Records
+// processed, if that is less than limit, the rollups have caught up with
+// the raw data.
+//
+// Records are processed in the order of their IDs, not their timestamps, so
+// Records that arrive late, e.g. because an Agent was offline for a while,
+// are still added to the correct buckets.
+func (db *Database) RollupProcess(limit int64) (int, error) {
+	var (
+		err error
+		cnt int
+	)
+
+	if db.tx != nil {
+		return db.rollupProcess(limit)
+	} else if err = db.Begin(); err != nil {
+		return 0, err
+	} else if cnt, err = db.rollupProcess(limit); err != nil {
+		db.Rollback() // nolint: errcheck
+		return 0, err
+	} else if err = db.Commit(); err != nil {
+		db.log.Printf("[ERROR] Cannot commit rollups: %s\n",
+			err.Error())
+		db.Rollback() // nolint: errcheck
+		return 0, err
+	}
+
+	return cnt, nil
+} // func (db *Database) RollupProcess(limit int64) (int, error)
+
+func (db *Database) rollupProcess(limit int64) (int, error) {
+	var (
+		err     error
+		last    int64
+		recs    []model.Record
+		buckets = make(map[rollupKey]*model.Rollup)
+	)
+
+	if last, err = db.rollupWatermark(); err != nil {
+		return 0, err
+	} else if recs, err = db.recordQuery(query.RecordGetAfterID, last, limit); err != nil {
+		db.log.Printf("[ERROR] Cannot load Records after %d: %s\n",
+			last,
+			err.Error())
+		return 0, err
+	} else if len(recs) == 0 {
+		return 0, nil
+	}
+
+	for i := range recs {
+		var (
+			samples []model.Sample
+			rec     = &recs[i]
+		)
+
+		if samples, err = rec.Samples(); err != nil {
+			db.log.Printf("[INFO] Skip %s Record %d in rollup: %s\n",
+				rec.Source,
+				rec.ID,
+				err.Error())
+			continue
+		}
+
+		for _, s := range samples {
+			for _, res := range resolution.Rollups {
+				var (
+					r   *model.Rollup
+					ok  bool
+					key = rollupKey{
+						host:     rec.HostID,
+						src:      rec.Source,
+						metric:   s.Metric,
+						instance: s.Instance,
+						res:      res,
+						bucket:   res.Bucket(rec.Timestamp).Unix(),
+					}
+				)
+
+				if r, ok = buckets[key]; !ok {
+					r = &model.Rollup{
+						HostID:     krylib.ID(rec.HostID),
+						Source:     rec.Source,
+						Metric:     s.Metric,
+						Instance:   s.Instance,
+						Resolution: res,
+						Bucket:     time.Unix(key.bucket, 0),
+					}
+					buckets[key] = r
+				}
+
+				r.Add(s.Value)
 			}
-			stamp int64
+		}
+	}
+
+	for _, r := range buckets {
+		if err = db.rollupAdd(r); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = db.rollupWatermarkSet(recs[len(recs)-1].ID); err != nil {
+		return 0, err
+	}
+
+	return len(recs), nil
+} // func (db *Database) rollupProcess(limit int64) (int, error)
+
+func (db *Database) rollupWatermark() (int64, error) {
+	const qid query.ID = query.RollupWatermarkGet
+	var (
+		err  error
+		stmt *sql.Stmt
+		last int64
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return 0, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+EXEC_QUERY:
+	if err = stmt.QueryRow().Scan(&last); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		db.log.Printf("[ERROR] Cannot query rollup watermark: %s\n",
+			err.Error())
+		return 0, err
+	}
+
+	return last, nil
+} // func (db *Database) rollupWatermark() (int64, error)
+
+func (db *Database) rollupWatermarkSet(last int64) error {
+	const qid query.ID = query.RollupWatermarkSet
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(last); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		db.log.Printf("[ERROR] Cannot set rollup watermark to %d: %s\n",
+			last,
+			err.Error())
+		return err
+	}
+
+	return nil
+} // func (db *Database) rollupWatermarkSet(last int64) error
+
+// rollupAdd merges the Rollup into the matching bucket in the database.
+func (db *Database) rollupAdd(r *model.Rollup) error {
+	var (
+		err  error
+		stmt *sql.Stmt
+		qid  = rollupQueries[r.Resolution].add
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(
+		r.HostID,
+		r.Source,
+		r.Metric,
+		r.Instance,
+		r.Bucket.Unix(),
+		r.Count,
+		r.Min,
+		r.Max,
+		r.Avg); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		db.log.Printf("[ERROR] Cannot add %s rollup of %s for Host %d: %s\n",
+			r.Resolution,
+			r.Metric,
+			r.HostID,
+			err.Error())
+		return err
+	}
+
+	return nil
+} // func (db *Database) rollupAdd(r *model.Rollup) error
+
+// RollupGetByHostType fetches the Rollups at the given resolution for all
+// metrics of the given type of Record for the given Host.
+func (db *Database) RollupGetByHostType(h *model.Host, t recordtype.ID, res resolution.ID, begin, end time.Time) ([]model.Rollup, error) {
+	var (
+		err  error
+		msg  string
+		stmt *sql.Stmt
+		rows *sql.Rows
+		qs   rollupQuerySet
+		ok   bool
+	)
+
+	if h == nil {
+		return nil, krylib.ErrInvalidValue
+	} else if qs, ok = rollupQueries[res]; !ok {
+		return nil, fmt.Errorf("There are no rollups at resolution %s", res)
+	} else if stmt, err = db.getQuery(qs.get); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qs.get,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(h.ID, t, res.Bucket(begin).Unix(), end.Unix()); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+	var data = make([]model.Rollup, 0)
+
+	for rows.Next() {
+		var (
+			r = model.Rollup{
+				HostID:     h.ID,
+				Source:     t,
+				Resolution: res,
+			}
+			bucket int64
 		)
 
-		if err = rows.Scan(&rec.ID, &rec.HostID, &stamp, &rec.Payload); err != nil {
+		if err = rows.Scan(&r.Metric, &r.Instance, &bucket, &r.Count, &r.Min, &r.Max, &r.Avg); err != nil {
 			msg = fmt.Sprintf("Error scanning row: %s",
 				err.Error())
 			db.log.Printf("[ERROR] %s\n", msg)
 			return nil, errors.New(msg)
 		}
 
-		rec.Timestamp = time.Unix(stamp, 0)
+		r.Bucket = time.Unix(bucket, 0)
 
-		data = append(data, rec)
+		data = append(data, r)
 	}
 
 	return data, nil
-} // func (db *Database) RecordGetByHostType(h *model.Host, t recordtype.ID) ([]model.Record, error)
+} // func (db *Database) RollupGetByHostType(h *model.Host, t recordtype.ID, res resolution.ID, begin, end time.Time) ([]model.Rollup, error)
+
+// SeriesGetByHostType fetches the history of all metrics of the given type
+// of Record for the given Host, choosing the resolution according to the
+// length of the period: For short periods, the raw Samples are returned as
+// Rollups with a Count of 1, for longer periods, the appropriate rollups.
+func (db *Database) SeriesGetByHostType(h *model.Host, t recordtype.ID, begin, end time.Time) ([]model.Rollup, resolution.ID, error) {
+	var (
+		err  error
+		recs []model.Record
+		data []model.Rollup
+		res  = resolution.For(end.Sub(begin))
+	)
+
+	if res != resolution.Raw {
+		data, err = db.RollupGetByHostType(h, t, res, begin, end)
+		return data, res, err
+	} else if recs, err = db.RecordGetByHostTypeRange(h, t, RecordRange{Begin: begin, End: end}); err != nil {
+		return nil, res, err
+	}
+
+	data = make([]model.Rollup, 0, len(recs))
+
+	for i := range recs {
+		var samples []model.Sample
+
+		if samples, err = recs[i].Samples(); err != nil {
+			db.log.Printf("[ERROR] Cannot get Samples from %s Record %d: %s\n",
+				recs[i].Source,
+				recs[i].ID,
+				err.Error())
+			continue
+		}
+
+		for _, s := range samples {
+			var r = model.Rollup{
+				HostID:     h.ID,
+				Source:     t,
+				Metric:     s.Metric,
+				Instance:   s.Instance,
+				Resolution: res,
+				Bucket:     recs[i].Timestamp,
+			}
+
+			r.Add(s.Value)
+			data = append(data, r)
+		}
+	}
+
+	return data, res, nil
+} // func (db *Database) SeriesGetByHostType(h *model.Host, t recordtype.ID, begin, end time.Time) ([]model.Rollup, resolution.ID, error)
+
+// RecordPrune deletes up to <limit> Records of the given type that are older
+// than <before>. Records that have not been added to the rollups, yet, are
+// kept. It returns the number of Records deleted.
+func (db *Database) RecordPrune(t recordtype.ID, before time.Time, limit int64) (int64, error) {
+	return db.prune(query.RecordPrune, t, before.Unix(), limit)
+} // func (db *Database) RecordPrune(t recordtype.ID, before time.Time, limit int64) (int64, error)
+
+// RollupPrune deletes up to <limit> rollups at the given resolution whose
+// buckets start before <before>. It returns the number of rollups deleted.
+func (db *Database) RollupPrune(res resolution.ID, before time.Time, limit int64) (int64, error) {
+	var (
+		qs rollupQuerySet
+		ok bool
+	)
+
+	if qs, ok = rollupQueries[res]; !ok {
+		return 0, fmt.Errorf("There are no rollups at resolution %s", res)
+	}
+
+	return db.prune(qs.prune, before.Unix(), limit)
+} // func (db *Database) RollupPrune(res resolution.ID, before time.Time, limit int64) (int64, error)
+
+// prune executes a DELETE query in its own transaction, unless one is
+// already in progress, and returns the number of rows deleted.
+func (db *Database) prune(qid query.ID, args ...any) (int64, error) {
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		res    sql.Result
+		cnt    int64
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return 0, err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return 0, errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if res, err = stmt.Exec(args...); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		err = fmt.Errorf("Cannot prune old data (%s): %s",
+			qid,
+			err.Error())
+		db.log.Printf("[ERROR] %s\n", err.Error())
+		return 0, err
+	} else if cnt, err = res.RowsAffected(); err != nil {
+		db.log.Printf("[ERROR] Cannot get number of deleted rows: %s\n",
+			err.Error())
+		return 0, err
+	}
+
+	status = true
+	return cnt, nil
+} // func (db *Database) prune(qid query.ID, args ...any) (int64, error)
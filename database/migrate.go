@@ -0,0 +1,167 @@
+// /home/krylon/go/src/github.com/blicero/donkey/database/migrate.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 15:40:02 krylon>
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Migration describes a single step in the evolution of the database schema.
+type Migration struct {
+	Version     int
+	Description string
+	Applied     bool
+}
+
+// SchemaVersion returns the version of the database schema, i.e. the number
+// of migrations that have been applied to it.
+func (db *Database) SchemaVersion() (int, error) {
+	var (
+		err     error
+		version int
+	)
+
+	if db.tx != nil {
+		err = db.tx.QueryRow("PRAGMA user_version").Scan(&version)
+	} else {
+		err = db.db.QueryRow("PRAGMA user_version").Scan(&version)
+	}
+
+	if err != nil {
+		db.log.Printf("[ERROR] Cannot query schema version: %s\n",
+			err.Error())
+		return 0, err
+	}
+
+	return version, nil
+} // func (db *Database) SchemaVersion() (int, error)
+
+// Migrations returns a list of all known migrations, along with the
+// information whether they have been applied to the database.
+func (db *Database) Migrations() ([]Migration, error) {
+	var (
+		err     error
+		version int
+		list    = make([]Migration, len(qMigrate))
+	)
+
+	if version, err = db.SchemaVersion(); err != nil {
+		return nil, err
+	}
+
+	for i, m := range qMigrate {
+		list[i] = Migration{
+			Version:     i + 1,
+			Description: m.desc,
+			Applied:     i < version,
+		}
+	}
+
+	return list, nil
+} // func (db *Database) Migrations() ([]Migration, error)
+
+// migrate applies all pending migrations to the database. All of them are
+// applied in a single transaction, so if one fails, the schema is left
+// unchanged.
+//
+// The transactions database/sql starts only take the write lock once they
+// write something, so two processes could both read the same schema version
+// before either of them applies a migration. We begin the transaction on a
+// connection of our own with BEGIN IMMEDIATE instead, which takes the write
+// lock right away.
+func (db *Database) migrate() error {
+	var (
+		err     error
+		version int
+		conn    *sql.Conn
+		status  bool
+		ctx     = context.Background()
+	)
+
+	if conn, err = db.db.Conn(ctx); err != nil {
+		db.log.Printf("[ERROR] Cannot get database connection: %s\n",
+			err.Error())
+		return err
+	}
+
+	defer conn.Close() // nolint: errcheck
+
+BEGIN_TX:
+	if _, err = conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto BEGIN_TX
+		}
+
+		db.log.Printf("[ERROR] Cannot begin transaction: %s\n",
+			err.Error())
+		return err
+	}
+
+	defer func() {
+		if status {
+			return
+		} else if _, err2 := conn.ExecContext(ctx, "ROLLBACK"); err2 != nil {
+			db.log.Printf("[CANTHAPPEN] Cannot rollback transaction: %s\n",
+				err2.Error())
+		}
+	}()
+
+	// We check the schema version inside the transaction, so we do not
+	// race another process that is migrating the same database.
+	if err = conn.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		db.log.Printf("[ERROR] Cannot query schema version: %s\n",
+			err.Error())
+		return err
+	} else if version > len(qMigrate) {
+		err = fmt.Errorf("Database schema version %d is newer than what I know about (%d)",
+			version,
+			len(qMigrate))
+		db.log.Printf("[ERROR] %s\n", err.Error())
+		return err
+	} else if version == len(qMigrate) {
+		return nil
+	}
+
+	for i := version; i < len(qMigrate); i++ {
+		var m = qMigrate[i]
+
+		db.log.Printf("[INFO] Apply migration %d: %s\n",
+			i+1,
+			m.desc)
+
+		for _, q := range m.queries {
+			db.log.Printf("[TRACE] Execute migration query:\n%s\n",
+				q)
+			if _, err = conn.ExecContext(ctx, q); err != nil {
+				db.log.Printf("[ERROR] Migration %d (%s) failed: %s\n%s\n",
+					i+1,
+					m.desc,
+					err.Error(),
+					q)
+				return err
+			}
+		}
+	}
+
+	// PRAGMA does not accept bind parameters.
+	if _, err = conn.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", len(qMigrate))); err != nil {
+		db.log.Printf("[ERROR] Cannot set schema version to %d: %s\n",
+			len(qMigrate),
+			err.Error())
+		return err
+	} else if _, err = conn.ExecContext(ctx, "COMMIT"); err != nil {
+		db.log.Printf("[ERROR] Failed to commit migrations: %s\n",
+			err.Error())
+		return err
+	}
+
+	status = true
+	return nil
+} // func (db *Database) migrate() error
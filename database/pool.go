@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/blicero/donkey/common"
 	"github.com/blicero/donkey/logdomain"
@@ -22,11 +23,25 @@ type dblink struct {
 
 // Pool is a pool of database connections
 type Pool struct {
-	cnt   int
-	log   *log.Logger
-	link  *dblink
-	lock  sync.RWMutex
-	empty *sync.Cond
+	cnt      int
+	log      *log.Logger
+	link     *dblink
+	lock     sync.RWMutex
+	empty    *sync.Cond
+	gets     int64
+	waits    int64
+	waitTime time.Duration
+}
+
+// PoolStats is a snapshot of a Pool's usage counters.
+// Gets counts the connections handed out by Get, Waits how many of those
+// had to wait for a connection to be returned, and WaitTime the total time
+// spent waiting.
+type PoolStats struct {
+	Idle     int
+	Gets     int64
+	Waits    int64
+	WaitTime time.Duration
 }
 
 // NewPool creates a Pool of database connections.
@@ -83,7 +98,11 @@ func (pool *Pool) Close() error {
 // Get returns a DB connection from the pool.
 // If the pool is empty, it waits for a connection to be returned.
 func (pool *Pool) Get() *Database {
-	var link *dblink
+	var (
+		link   *dblink
+		begin  time.Time
+		waited bool
+	)
 
 	pool.lock.Lock()
 	defer pool.lock.Unlock()
@@ -93,11 +112,22 @@ WAIT_FOR_LINK:
 		link = pool.link
 		pool.link = link.next
 		pool.cnt--
+		pool.gets++
+
+		if waited {
+			pool.waits++
+			pool.waitTime += time.Since(begin)
+		}
 
 		link.next = nil
 		return link.db
 	}
 
+	if !waited {
+		waited = true
+		begin = time.Now()
+	}
+
 	// Wait for it!!!
 	pool.empty.Wait()
 	goto WAIT_FOR_LINK
@@ -155,3 +185,16 @@ func (pool *Pool) IsEmpty() bool {
 	pool.lock.RUnlock()
 	return empty
 } // func (pool *Pool) IsEmpty() bool
+
+// Stats returns a snapshot of the Pool's usage counters.
+func (pool *Pool) Stats() PoolStats {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	return PoolStats{
+		Idle:     pool.cnt,
+		Gets:     pool.gets,
+		Waits:    pool.waits,
+		WaitTime: pool.waitTime,
+	}
+} // func (pool *Pool) Stats() PoolStats
@@ -6,20 +6,87 @@
 
 package database
 
-import "github.com/blicero/donkey/database/query"
+import (
+	"fmt"
+
+	"github.com/blicero/donkey/database/query"
+	"github.com/blicero/donkey/model/resolution"
+)
+
+// The rollup tables for the different resolutions all look the same, so we
+// only write the queries once.
+const (
+	qRollupAdd = `
+INSERT INTO rollup_%s (host_id, recordtype, metric, instance, bucket, cnt, vmin, vmax, vavg)
+               VALUES (      ?,          ?,      ?,        ?,      ?,   ?,    ?,    ?,    ?)
+ON CONFLICT (host_id, recordtype, metric, instance, bucket) DO UPDATE
+SET vavg = (vavg * cnt + excluded.vavg * excluded.cnt) / (cnt + excluded.cnt),
+    cnt = cnt + excluded.cnt,
+    vmin = min(vmin, excluded.vmin),
+    vmax = max(vmax, excluded.vmax)
+`
+	qRollupGet = `
+SELECT
+    metric,
+    instance,
+    bucket,
+    cnt,
+    vmin,
+    vmax,
+    vavg
+FROM rollup_%s
+WHERE host_id = ? AND recordtype = ? AND bucket BETWEEN ? AND ?
+ORDER BY bucket, metric, instance
+`
+	qRollupPrune = `
+DELETE FROM rollup_%[1]s
+WHERE id IN (SELECT id FROM rollup_%[1]s WHERE bucket < ? LIMIT ?)
+`
+)
 
 var qDB = map[query.ID]string{
 	query.HostAdd:               "INSERT INTO host (name, addr, os) VALUES (?, ?, ?) RETURNING id",
-	query.HostGetByID:           "SELECT name, addr, os, last_contact FROM host WHERE id = ?",
-	query.HostGetByAddr:         "SELECT id, name, os, last_contact FROM host WHERE addr = ?",
-	query.HostGetByName:         "SELECT id, addr, os, last_contact FROM host WHERE name = ?",
-	query.HostGetAll:            "SELECT id, name, addr, os, last_contact FROM host",
+	query.HostGetByID:           "SELECT name, addr, os, last_contact, state, contact_interval FROM host WHERE id = ?",
+	query.HostGetByAddr:         "SELECT id, name, os, last_contact, state, contact_interval FROM host WHERE addr = ?",
+	query.HostGetByName:         "SELECT id, addr, os, last_contact, state, contact_interval FROM host WHERE name = ?",
+	query.HostGetAll:            "SELECT id, name, addr, os, last_contact, state, contact_interval FROM host",
 	query.HostDelete:            "DELETE FROM host WHERE id = ?",
 	query.HostUpdateName:        "UPDATE host SET name = ? WHERE id = ?",
 	query.HostUpdateAddr:        "UPDATE host SET addr = ? WHERE id = ?",
 	query.HostUpdateOS:          "UPDATE host SET os = ? WHERE id = ?",
 	query.HostUpdateLastContact: "UPDATE host SET last_contact = ? WHERE id = ?",
-	query.LoadAdd:               "INSERT INTO record (host_id, timestamp, recordtype, payload) VALUES (?, ?, ?, ?)",
+	query.HostUpdateState:       "UPDATE host SET state = ? WHERE id = ?",
+	query.HostUpdateInterval:    "UPDATE host SET contact_interval = ? WHERE id = ?",
+	query.HostGetToken:          "SELECT token FROM host WHERE id = ?",
+	query.HostUpdateToken:       "UPDATE host SET token = ? WHERE id = ?",
+	query.HostEventAdd: `
+INSERT INTO host_event (host_id, timestamp, old_state, new_state)
+                VALUES (      ?,         ?,         ?,         ?)
+RETURNING id
+`,
+	query.HostEventGetByHost: `
+SELECT
+    id,
+    timestamp,
+    old_state,
+    new_state
+FROM host_event
+WHERE host_id = ?
+ORDER BY timestamp DESC, id DESC
+LIMIT ?
+`,
+	query.HostEventGetRecent: `
+SELECT
+    id,
+    host_id,
+    timestamp,
+    old_state,
+    new_state
+FROM host_event
+ORDER BY timestamp DESC, id DESC
+LIMIT ?
+`,
+	query.LoadAdd: "INSERT INTO record (host_id, timestamp, recordtype, payload) VALUES (?, ?, ?, ?)",
 	query.LoadGetByHost: `
 SELECT
     id,
@@ -29,7 +96,8 @@ SELECT
     payload ->> '$[2]' AS load15
 FROM record
 WHERE host_id = ? AND recordtype = ?
-ORDER BY timestamp
+ORDER BY timestamp DESC
+LIMIT ?
 `,
 	query.LoadgetByPeriod: `
 SELECT
@@ -44,8 +112,8 @@ WHERE recordtype = ? AND timestamp BETWEEN ? AND ?
 ORDER BY timestamp, host_id
 `,
 	query.RecordAdd: `
-INSERT INTO record (host_id, timestamp, recordtype, payload)
-            VALUES (      ?,         ?,          ?,       ?)
+INSERT INTO record (host_id, timestamp, recordtype, instance, payload)
+            VALUES (      ?,         ?,          ?,        ?,       ?)
 RETURNING id
 `,
 	query.RecordGetByHost: `
@@ -77,4 +145,185 @@ FROM record
 WHERE host_id = ? AND recordtype = ?
 ORDER BY timestamp
 `,
+	query.RecordGetLatestByHost: `
+SELECT
+    r.id,
+    r.timestamp,
+    r.recordtype,
+    r.payload
+FROM record r
+WHERE r.host_id = ?
+  AND r.timestamp = (SELECT MAX(timestamp)
+                     FROM record
+                     WHERE host_id = r.host_id
+                       AND recordtype = r.recordtype
+                       AND instance = r.instance)
+ORDER BY r.recordtype, r.instance
+`,
+	query.RecordGetByHostRange: `
+SELECT
+    id,
+    host_id,
+    timestamp,
+    recordtype,
+    payload
+FROM record
+WHERE host_id = ?
+  AND timestamp BETWEEN ? AND ?
+  AND (timestamp, id) > (?, ?)
+ORDER BY timestamp, id
+LIMIT ?
+`,
+	query.RecordGetByTypeRange: `
+SELECT
+    id,
+    host_id,
+    timestamp,
+    recordtype,
+    payload
+FROM record
+WHERE recordtype = ?
+  AND timestamp BETWEEN ? AND ?
+  AND (timestamp, id) > (?, ?)
+ORDER BY timestamp, id
+LIMIT ?
+`,
+	query.RecordGetByHostTypeRange: `
+SELECT
+    id,
+    host_id,
+    timestamp,
+    recordtype,
+    payload
+FROM record
+WHERE host_id = ? AND recordtype = ?
+  AND timestamp BETWEEN ? AND ?
+  AND (timestamp, id) > (?, ?)
+ORDER BY timestamp, id
+LIMIT ?
+`,
+	query.RecordGetAfterID: `
+SELECT
+    id,
+    host_id,
+    timestamp,
+    recordtype,
+    payload
+FROM record
+WHERE id > ?
+ORDER BY id
+LIMIT ?
+`,
+	// Records that have not been added to the rollups are never deleted.
+	query.RecordPrune: `
+DELETE FROM record
+WHERE id IN (SELECT id
+             FROM record
+             WHERE recordtype = ?
+               AND timestamp < ?
+               AND id <= (SELECT last_record FROM rollup_watermark WHERE id = 1)
+             LIMIT ?)
+`,
+	query.AlertAdd: `
+INSERT INTO alert (rule, host_id, instance, state, value, threshold, since, fired, resolved, updated)
+           VALUES (   ?,       ?,        ?,     ?,     ?,         ?,     ?,     ?,        ?,       ?)
+RETURNING id
+`,
+	query.AlertUpdate: `
+UPDATE alert
+SET state = ?,
+    value = ?,
+    threshold = ?,
+    fired = ?,
+    resolved = ?,
+    updated = ?
+WHERE id = ?
+`,
+	query.AlertDelete: "DELETE FROM alert WHERE id = ?",
+	query.AlertGetActive: `
+SELECT
+    id,
+    rule,
+    host_id,
+    instance,
+    state,
+    value,
+    threshold,
+    since,
+    fired,
+    resolved,
+    updated
+FROM alert
+WHERE state <> 2
+ORDER BY since, id
+`,
+	query.AlertGetRecent: `
+SELECT
+    id,
+    rule,
+    host_id,
+    instance,
+    state,
+    value,
+    threshold,
+    since,
+    fired,
+    resolved,
+    updated
+FROM alert
+ORDER BY updated DESC, id DESC
+LIMIT ?
+`,
+	query.DeliveryAdd: `
+INSERT INTO delivery (channel, timestamp, alerts, success, message)
+              VALUES (      ?,         ?,      ?,       ?,       ?)
+RETURNING id
+`,
+	query.DeliveryGetRecent: `
+SELECT
+    id,
+    channel,
+    timestamp,
+    alerts,
+    success,
+    message
+FROM delivery
+ORDER BY timestamp DESC, id DESC
+LIMIT ?
+`,
+	query.RollupWatermarkGet: "SELECT last_record FROM rollup_watermark WHERE id = 1",
+	query.RollupWatermarkSet: "UPDATE rollup_watermark SET last_record = ? WHERE id = 1",
+	query.RollupMinuteAdd:    fmt.Sprintf(qRollupAdd, "minute"),
+	query.RollupHourAdd:      fmt.Sprintf(qRollupAdd, "hour"),
+	query.RollupDayAdd:       fmt.Sprintf(qRollupAdd, "day"),
+	query.RollupMinuteGet:    fmt.Sprintf(qRollupGet, "minute"),
+	query.RollupHourGet:      fmt.Sprintf(qRollupGet, "hour"),
+	query.RollupDayGet:       fmt.Sprintf(qRollupGet, "day"),
+	query.RollupMinutePrune:  fmt.Sprintf(qRollupPrune, "minute"),
+	query.RollupHourPrune:    fmt.Sprintf(qRollupPrune, "hour"),
+	query.RollupDayPrune:     fmt.Sprintf(qRollupPrune, "day"),
+}
+
+type rollupQuerySet struct {
+	add, get, prune query.ID
+}
+
+// rollupQueries maps each rollup resolution to the queries that add to,
+// read from and prune its table.
+var rollupQueries = map[resolution.ID]rollupQuerySet{
+	resolution.Minute: {
+		add:   query.RollupMinuteAdd,
+		get:   query.RollupMinuteGet,
+		prune: query.RollupMinutePrune,
+	},
+	resolution.Hour: {
+		add:   query.RollupHourAdd,
+		get:   query.RollupHourGet,
+		prune: query.RollupHourPrune,
+	},
+	resolution.Day: {
+		add:   query.RollupDayAdd,
+		get:   query.RollupDayGet,
+		prune: query.RollupDayPrune,
+	},
 }
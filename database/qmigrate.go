@@ -0,0 +1,256 @@
+// /home/krylon/go/src/github.com/blicero/donkey/database/qmigrate.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 15:12:40 krylon>
+
+package database
+
+// qInit is the baseline schema, i.e. version 0. All changes to the schema
+// after that go into qMigrate, so existing databases can be upgraded in
+// place.
+//
+// The version of a migration is its index in qMigrate plus one. Once a
+// migration has been released, it must never be changed or removed, only
+// new ones appended.
+
+type migration struct {
+	desc    string
+	queries []string
+}
+
+var qMigrate = []migration{
+	{
+		desc: "Index records by host, type and time",
+		queries: []string{
+			"CREATE INDEX record_host_type_time_idx ON record (host_id, recordtype, timestamp)",
+		},
+	},
+	{
+		desc: "Track the liveness of hosts",
+		queries: []string{
+			"ALTER TABLE host ADD COLUMN state INTEGER NOT NULL DEFAULT 0",
+			`
+CREATE TABLE host_event (
+    id INTEGER PRIMARY KEY,
+    host_id INTEGER NOT NULL,
+    timestamp INTEGER NOT NULL,
+    old_state INTEGER NOT NULL,
+    new_state INTEGER NOT NULL,
+    FOREIGN KEY (host_id) REFERENCES host (id)
+        ON UPDATE RESTRICT
+        ON DELETE CASCADE
+) STRICT
+`,
+			"CREATE INDEX host_event_host_time_idx ON host_event (host_id, timestamp)",
+			"CREATE INDEX host_event_time_idx ON host_event (timestamp)",
+		},
+	},
+	{
+		desc: "Persist the state of alerts",
+		queries: []string{
+			`
+CREATE TABLE alert (
+    id INTEGER PRIMARY KEY,
+    rule TEXT NOT NULL,
+    host_id INTEGER NOT NULL,
+    instance TEXT NOT NULL DEFAULT '',
+    state INTEGER NOT NULL,
+    value REAL NOT NULL,
+    threshold REAL NOT NULL,
+    since INTEGER NOT NULL,
+    fired INTEGER NOT NULL DEFAULT 0,
+    resolved INTEGER NOT NULL DEFAULT 0,
+    updated INTEGER NOT NULL,
+    FOREIGN KEY (host_id) REFERENCES host (id)
+        ON UPDATE RESTRICT
+        ON DELETE CASCADE,
+    CHECK (rule <> '')
+) STRICT
+`,
+			// There can be only one unresolved Alert per rule, host and
+			// instance.
+			"CREATE UNIQUE INDEX alert_active_idx ON alert (rule, host_id, instance) WHERE state <> 2",
+			"CREATE INDEX alert_updated_idx ON alert (updated)",
+		},
+	},
+	{
+		desc: "Record deliveries of alert notifications",
+		queries: []string{
+			`
+CREATE TABLE delivery (
+    id INTEGER PRIMARY KEY,
+    channel TEXT NOT NULL,
+    timestamp INTEGER NOT NULL,
+    alerts TEXT NOT NULL DEFAULT '[]',
+    success INTEGER NOT NULL,
+    message TEXT NOT NULL DEFAULT '',
+    CHECK (channel <> ''),
+    CHECK (json_valid(alerts))
+) STRICT
+`,
+			"CREATE INDEX delivery_time_idx ON delivery (timestamp)",
+			"CREATE INDEX delivery_channel_idx ON delivery (channel, timestamp)",
+		},
+	},
+	{
+		desc: "Aggregate records into rollups by minute, hour and day",
+		queries: []string{
+			`
+CREATE TABLE rollup_minute (
+    id INTEGER PRIMARY KEY,
+    host_id INTEGER NOT NULL,
+    recordtype INTEGER NOT NULL,
+    metric TEXT NOT NULL,
+    instance TEXT NOT NULL DEFAULT '',
+    bucket INTEGER NOT NULL,
+    cnt INTEGER NOT NULL,
+    vmin REAL NOT NULL,
+    vmax REAL NOT NULL,
+    vavg REAL NOT NULL,
+    FOREIGN KEY (host_id) REFERENCES host (id)
+        ON UPDATE RESTRICT
+        ON DELETE CASCADE,
+    UNIQUE (host_id, recordtype, metric, instance, bucket),
+    CHECK (cnt > 0)
+) STRICT
+`,
+			"CREATE INDEX rollup_minute_host_type_bucket_idx ON rollup_minute (host_id, recordtype, bucket)",
+			"CREATE INDEX rollup_minute_bucket_idx ON rollup_minute (bucket)",
+			`
+CREATE TABLE rollup_hour (
+    id INTEGER PRIMARY KEY,
+    host_id INTEGER NOT NULL,
+    recordtype INTEGER NOT NULL,
+    metric TEXT NOT NULL,
+    instance TEXT NOT NULL DEFAULT '',
+    bucket INTEGER NOT NULL,
+    cnt INTEGER NOT NULL,
+    vmin REAL NOT NULL,
+    vmax REAL NOT NULL,
+    vavg REAL NOT NULL,
+    FOREIGN KEY (host_id) REFERENCES host (id)
+        ON UPDATE RESTRICT
+        ON DELETE CASCADE,
+    UNIQUE (host_id, recordtype, metric, instance, bucket),
+    CHECK (cnt > 0)
+) STRICT
+`,
+			"CREATE INDEX rollup_hour_host_type_bucket_idx ON rollup_hour (host_id, recordtype, bucket)",
+			"CREATE INDEX rollup_hour_bucket_idx ON rollup_hour (bucket)",
+			`
+CREATE TABLE rollup_day (
+    id INTEGER PRIMARY KEY,
+    host_id INTEGER NOT NULL,
+    recordtype INTEGER NOT NULL,
+    metric TEXT NOT NULL,
+    instance TEXT NOT NULL DEFAULT '',
+    bucket INTEGER NOT NULL,
+    cnt INTEGER NOT NULL,
+    vmin REAL NOT NULL,
+    vmax REAL NOT NULL,
+    vavg REAL NOT NULL,
+    FOREIGN KEY (host_id) REFERENCES host (id)
+        ON UPDATE RESTRICT
+        ON DELETE CASCADE,
+    UNIQUE (host_id, recordtype, metric, instance, bucket),
+    CHECK (cnt > 0)
+) STRICT
+`,
+			"CREATE INDEX rollup_day_host_type_bucket_idx ON rollup_day (host_id, recordtype, bucket)",
+			"CREATE INDEX rollup_day_bucket_idx ON rollup_day (bucket)",
+			// The ID of the last Record that has been added to the
+			// rollups.
+			`
+CREATE TABLE rollup_watermark (
+    id INTEGER PRIMARY KEY,
+    last_record INTEGER NOT NULL,
+    CHECK (id = 1)
+) STRICT
+`,
+			"INSERT INTO rollup_watermark (id, last_record) VALUES (1, 0)",
+		},
+	},
+	{
+		desc: "Authenticate agents with per-host tokens",
+		queries: []string{
+			// The SHA-256 hash of the token issued to the Host's
+			// Agent, hex-encoded. Hosts registered before tokens
+			// were introduced have an empty token.
+			"ALTER TABLE host ADD COLUMN token TEXT NOT NULL DEFAULT ''",
+		},
+	},
+	{
+		desc: "Remember how often hosts contact the server",
+		queries: []string{
+			// The longest time in seconds the Host's Agent goes
+			// without contacting the Server, as reported by the
+			// Agent. 0 means the Agent has not told us, yet.
+			"ALTER TABLE host ADD COLUMN contact_interval INTEGER NOT NULL DEFAULT 0",
+		},
+	},
+	{
+		desc: "Tell records of the same type and time apart by instance",
+		queries: []string{
+			// Several checks of one Host may finish in the same
+			// second, the instance is the name of the check. SQLite
+			// cannot change a UNIQUE constraint, so we have to
+			// rebuild the table.
+			//
+			// The rollups and pruning track their progress by ID,
+			// so IDs of deleted Records must never be handed out
+			// again, hence AUTOINCREMENT.
+			`
+CREATE TABLE record_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    host_id INTEGER NOT NULL,
+    timestamp INTEGER NOT NULL,
+    recordtype INTEGER NOT NULL,
+    instance TEXT NOT NULL DEFAULT '',
+    payload TEXT NOT NULL,
+    UNIQUE (host_id, timestamp, recordtype, instance),
+    FOREIGN KEY (host_id) REFERENCES host (id)
+        ON UPDATE RESTRICT
+        ON DELETE CASCADE
+) STRICT
+`,
+			// Records of type 7 (recordtype.Check) are named after
+			// their check.
+			`
+INSERT INTO record_new (id, host_id, timestamp, recordtype, instance, payload)
+SELECT id,
+       host_id,
+       timestamp,
+       recordtype,
+       CASE WHEN recordtype = 7 AND json_valid(payload)
+            THEN COALESCE(payload ->> '$.Name', '')
+            ELSE ''
+       END,
+       payload
+FROM record
+`,
+			"DROP TABLE record",
+			"ALTER TABLE record_new RENAME TO record",
+			"CREATE INDEX record_host_idx ON record (host_id)",
+			"CREATE INDEX record_time_idx ON record (timestamp)",
+			"CREATE INDEX record_type_idx ON record (recordtype)",
+			"CREATE INDEX record_host_type_time_idx ON record (host_id, recordtype, timestamp)",
+			// The newest Records may have been pruned already, in
+			// which case their IDs have been handed out again. New
+			// IDs have to start above the rollup watermark.
+			`
+UPDATE sqlite_sequence
+SET seq = (SELECT last_record FROM rollup_watermark WHERE id = 1)
+WHERE name = 'record'
+  AND seq < (SELECT last_record FROM rollup_watermark WHERE id = 1)
+`,
+			`
+INSERT INTO sqlite_sequence (name, seq)
+SELECT 'record', last_record
+FROM rollup_watermark
+WHERE id = 1
+  AND NOT EXISTS (SELECT 1 FROM sqlite_sequence WHERE name = 'record')
+`,
+		},
+	},
+}
@@ -23,6 +23,13 @@ const (
 	HostUpdateAddr
 	HostUpdateOS
 	HostUpdateLastContact
+	HostUpdateState
+	HostUpdateInterval
+	HostGetToken
+	HostUpdateToken
+	HostEventAdd
+	HostEventGetByHost
+	HostEventGetRecent
 	LoadAdd
 	LoadGetByHost
 	LoadgetByPeriod
@@ -30,4 +37,28 @@ const (
 	RecordGetByHost
 	RecordGetByType
 	RecordGetByHostType
+	RecordGetLatestByHost
+	RecordGetByHostRange
+	RecordGetByTypeRange
+	RecordGetByHostTypeRange
+	RecordGetAfterID
+	RecordPrune
+	AlertAdd
+	AlertUpdate
+	AlertDelete
+	AlertGetActive
+	AlertGetRecent
+	DeliveryAdd
+	DeliveryGetRecent
+	RollupWatermarkGet
+	RollupWatermarkSet
+	RollupMinuteAdd
+	RollupHourAdd
+	RollupDayAdd
+	RollupMinuteGet
+	RollupHourGet
+	RollupDayGet
+	RollupMinutePrune
+	RollupHourPrune
+	RollupDayPrune
 )
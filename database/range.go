@@ -0,0 +1,79 @@
+// /home/krylon/go/src/github.com/blicero/donkey/database/range.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 23:41:26 krylon>
+
+package database
+
+import (
+	"math"
+	"time"
+
+	"github.com/blicero/donkey/model"
+)
+
+// Cursor marks a position in a list of Records ordered by timestamp and ID.
+// The zero Cursor is before the first Record.
+type Cursor struct {
+	Timestamp time.Time
+	ID        int64
+}
+
+// RecordRange selects a page of Records from a period of time. Records are
+// returned in chronological order, starting after the Cursor. A zero Begin
+// or End leaves the period open on that side, a Limit of zero or less
+// returns all matching Records.
+type RecordRange struct {
+	Begin time.Time
+	End   time.Time
+	After Cursor
+	Limit int64
+}
+
+// Next returns the RecordRange for the page following recs, which must be
+// the Records returned for r. The second return value is false if recs was
+// the last page.
+func (r RecordRange) Next(recs []model.Record) (RecordRange, bool) {
+	if r.Limit <= 0 || int64(len(recs)) < r.Limit {
+		return r, false
+	}
+
+	var last = &recs[len(recs)-1]
+
+	r.After = Cursor{
+		Timestamp: last.Timestamp,
+		ID:        last.ID,
+	}
+
+	return r, true
+} // func (r RecordRange) Next(recs []model.Record) (RecordRange, bool)
+
+// args returns the query parameters for the period, the Cursor and the
+// Limit, in that order.
+func (r *RecordRange) args() []any {
+	var (
+		begin, end   int64 = 0, math.MaxInt64
+		after, curID int64 = math.MinInt64, 0
+		limit        int64 = -1
+	)
+
+	if !r.Begin.IsZero() {
+		begin = r.Begin.Unix()
+	}
+
+	if !r.End.IsZero() {
+		end = r.End.Unix()
+	}
+
+	if !r.After.Timestamp.IsZero() {
+		after = r.After.Timestamp.Unix()
+		curID = r.After.ID
+	}
+
+	if r.Limit > 0 {
+		limit = r.Limit
+	}
+
+	return []any{begin, end, after, curID, limit}
+} // func (r *RecordRange) args() []any
@@ -7,14 +7,178 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"os"
+	"strings"
 
+	"github.com/blicero/donkey/agent"
 	"github.com/blicero/donkey/common"
+	"github.com/blicero/donkey/server"
 )
 
+// errUsage indicates that a command was invoked incorrectly. The usage of
+// the command has been printed already.
+var errUsage = errors.New("Invalid usage")
+
+// command is a subcommand of the donkey executable. Commands that take
+// subcommands of their own, like "hosts", dispatch on the first argument
+// themselves.
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"server", "Run the Server", cmdServer},
+		{"agent", "Run the Agent", cmdAgent},
+		{"hosts", "Manage Hosts (list, delete, revoke)", cmdHosts},
+		{"records", "Query Records (query)", cmdRecords},
+		{"db", "Database administration (maintain)", cmdDB},
+		{"version", "Print version information", cmdVersion},
+	}
+} // func init()
+
 func main() {
-	fmt.Printf("%s %s, built on %s",
+	var err error
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != os.Args[1] {
+			continue
+		} else if err = cmd.run(os.Args[2:]); err != nil {
+			if !errors.Is(err, errUsage) && !errors.Is(err, flag.ErrHelp) {
+				fmt.Fprintf(os.Stderr, "%s %s: %s\n",
+					strings.ToLower(common.AppName),
+					cmd.name,
+					err.Error())
+			}
+			os.Exit(1)
+		}
+		return
+	}
+
+	if os.Args[1] != "help" && os.Args[1] != "-h" && os.Args[1] != "--help" {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", os.Args[1])
+	}
+
+	usage()
+	os.Exit(1)
+} // func main()
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [options] [arguments]\n\nCommands:\n",
+		strings.ToLower(common.AppName))
+
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.usage)
+	}
+
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for the options of a command.\n",
+		strings.ToLower(common.AppName))
+} // func usage()
+
+// newFlagSet creates a FlagSet for a command, including the flags common to
+// all commands. The returned pointer receives the value of -basedir.
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	var (
+		fs      = flag.NewFlagSet(name, flag.ContinueOnError)
+		baseDir = fs.String("basedir", common.BaseDir, "The folder to keep the database, configuration and logs in")
+	)
+
+	return fs, baseDir
+} // func newFlagSet(name string) (*flag.FlagSet, *string)
+
+// setBaseDir switches to the given base directory, unless it is the one in
+// use already.
+func setBaseDir(dir string) error {
+	if dir == "" || dir == common.BaseDir {
+		return common.InitApp()
+	}
+
+	return common.SetBaseDir(dir)
+} // func setBaseDir(dir string) error
+
+func cmdVersion(args []string) error {
+	fmt.Printf("%s %s, built on %s\n",
 		common.AppName,
 		common.Version,
 		common.BuildStamp.Format(common.TimestampFormat))
-}
+	return nil
+} // func cmdVersion(args []string) error
+
+func cmdServer(args []string) error {
+	var (
+		err         error
+		srv         *server.Server
+		cfg         *server.Config
+		fs, baseDir = newFlagSet("server")
+		addr        = fs.String("addr", "", "The address to listen on, overrides the configuration file")
+		cfgPath     = fs.String("config", "", "The configuration file (default server.json in the base directory)")
+	)
+
+	if err = fs.Parse(args); err != nil {
+		return err
+	} else if fs.NArg() != 0 {
+		fs.Usage()
+		return errUsage
+	} else if err = setBaseDir(*baseDir); err != nil {
+		return err
+	}
+
+	if *cfgPath == "" {
+		*cfgPath = common.ServerConfPath
+	}
+
+	if cfg, err = server.ReadConfig(*cfgPath); err != nil {
+		return err
+	} else if *addr != "" {
+		cfg.Addr = *addr
+	}
+
+	if srv, err = server.CreateWithConfig(cfg); err != nil {
+		return err
+	}
+
+	srv.Run()
+	return nil
+} // func cmdServer(args []string) error
+
+func cmdAgent(args []string) error {
+	var (
+		err         error
+		ag          *agent.Agent
+		fs, baseDir = newFlagSet("agent")
+		srvAddr     = fs.String("server", "", "The address of the Server, overrides the configuration file")
+		cfgPath     = fs.String("config", "", "The configuration file (default agent.json in the base directory)")
+	)
+
+	if err = fs.Parse(args); err != nil {
+		return err
+	} else if fs.NArg() != 0 {
+		fs.Usage()
+		return errUsage
+	} else if err = setBaseDir(*baseDir); err != nil {
+		return err
+	}
+
+	if *cfgPath != "" {
+		common.AgentConfPath = *cfgPath
+	}
+
+	if ag, err = agent.Create(*srvAddr); err != nil {
+		return err
+	}
+
+	ag.Run()
+	return nil
+} // func cmdAgent(args []string) error
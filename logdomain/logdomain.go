@@ -6,6 +6,11 @@
 
 package logdomain
 
+import (
+	"fmt"
+	"strings"
+)
+
 //go:generate stringer -type=ID
 
 // ID is an id...
@@ -20,6 +25,7 @@ const (
 	Server
 	Agent
 	Probe
+	Alert
 )
 
 // AllDomains returns a slice of all the valid values for ID.
@@ -32,5 +38,17 @@ func AllDomains() []ID {
 		Server,
 		Agent,
 		Probe,
+		Alert,
 	}
 } // func AllDomains() []ID
+
+// Parse returns the log domain with the given name, ignoring case.
+func Parse(s string) (ID, error) {
+	for _, id := range AllDomains() {
+		if strings.EqualFold(s, id.String()) {
+			return id, nil
+		}
+	}
+
+	return 0, fmt.Errorf("Unknown log domain %q", s)
+} // func Parse(s string) (ID, error)
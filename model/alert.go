@@ -0,0 +1,72 @@
+// /home/krylon/go/src/github.com/blicero/donkey/model/alert.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 22:14:02 krylon>
+
+package model
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/blicero/donkey/model/alertstate"
+	"github.com/blicero/krylib"
+)
+
+// Alert is raised when a Host's Records meet the condition of an alerting
+// rule. An Alert is identified by the name of the rule, the Host and the
+// instance of the metric (which may be empty).
+//
+// Since is the time the condition was first met, Fired the time the Alert
+// started firing, and Resolved the time it was resolved. The latter two are
+// zero until the Alert reaches the respective state.
+type Alert struct {
+	ID        krylib.ID
+	Rule      string
+	HostID    krylib.ID
+	Instance  string
+	State     alertstate.State
+	Value     float64
+	Threshold float64
+	Since     time.Time
+	Fired     time.Time
+	Resolved  time.Time
+	Updated   time.Time
+}
+
+// Key returns a string that identifies the Alert by rule, Host and instance.
+func (a *Alert) Key() string {
+	return AlertKey(a.Rule, a.HostID, a.Instance)
+} // func (a *Alert) Key() string
+
+// AlertKey returns a string that identifies an Alert by rule, Host and
+// instance.
+func AlertKey(rule string, host krylib.ID, instance string) string {
+	return rule + "\x00" + strconv.FormatInt(int64(host), 10) + "\x00" + instance
+} // func AlertKey(rule string, host krylib.ID, instance string) string
+
+// AlertNotice is an Alert along with the name of its Host, as it is passed
+// on to notification channels.
+type AlertNotice struct {
+	Alert
+	HostName string
+}
+
+// Notification is delivered to a notification channel. It contains one or
+// more Alerts that started firing or were resolved at about the same time.
+type Notification struct {
+	Channel   string
+	Timestamp time.Time
+	Alerts    []AlertNotice
+}
+
+// Delivery records an attempt to deliver a Notification.
+type Delivery struct {
+	ID        krylib.ID
+	Channel   string
+	Timestamp time.Time
+	AlertIDs  []krylib.ID
+	Success   bool
+	Message   string
+}
@@ -0,0 +1,23 @@
+// /home/krylon/go/src/github.com/blicero/donkey/model/alertstate/alertstate.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 22:11:37 krylon>
+
+// Package alertstate provides symbolic constants for the life cycle of an
+// Alert.
+package alertstate
+
+//go:generate stringer -type=State
+
+// State is the state of an Alert.
+type State uint8
+
+// Pending means the condition of the rule is met, but has not been met for
+// long enough. Firing means the condition has been met for long enough,
+// Resolved means the condition is no longer met after the Alert had fired.
+const (
+	Pending State = iota
+	Firing
+	Resolved
+)
@@ -0,0 +1,332 @@
+// /home/krylon/go/src/github.com/blicero/donkey/model/check.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 11:48:03 krylon>
+
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/blicero/donkey/model/recordtype"
+)
+
+func init() {
+	RegisterPayload(recordtype.Check, PayloadType{
+		Name:     "Check",
+		Decode:   decodeCheck,
+		Encode:   encodeJSON,
+		Validate: validateCheck,
+		Instance: func(v any) string { return v.(*Check).Name },
+	})
+} // func init()
+
+// The status codes of a check, as defined by the Nagios plugin API. A check
+// that exits with any other code is considered UNKNOWN.
+const (
+	CheckOK       = 0
+	CheckWarning  = 1
+	CheckCritical = 2
+	CheckUnknown  = 3
+)
+
+var checkStatusNames = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// CheckStatusName returns the name of a check's status code.
+func CheckStatusName(status int) string {
+	if status < CheckOK || status > CheckUnknown {
+		return checkStatusNames[CheckUnknown]
+	}
+
+	return checkStatusNames[status]
+} // func CheckStatusName(status int) string
+
+// PerfData is a single value from the performance data of a check, written
+// as label=value[UOM];warn;crit;min;max by the check. Warn and Crit are
+// threshold ranges in the format of the Nagios plugin guidelines, e.g.
+// "10", "10:" or "@5:10". Min and Max are nil if the check does not report
+// them.
+type PerfData struct {
+	Label string
+	Value float64
+	UOM   string   `json:",omitempty"`
+	Warn  string   `json:",omitempty"`
+	Crit  string   `json:",omitempty"`
+	Min   *float64 `json:",omitempty"`
+	Max   *float64 `json:",omitempty"`
+}
+
+// Check is the result of running an external check command. Status is the
+// command's exit status, see CheckOK and friends, Output the first line of
+// its output, LongOutput any further lines. Duration is the time the
+// command took in seconds.
+type Check struct {
+	Name       string
+	Status     int
+	Output     string
+	LongOutput string     `json:",omitempty"`
+	Perf       []PerfData `json:",omitempty"`
+	Duration   float64
+}
+
+// Samples returns the metric check.status, the exit status of the check,
+// with the name of the check as instance, and each value of the performance
+// data as check.perf, with the instance made up of the name of the check and
+// the label. If the warning or critical threshold is a plain upper limit, it
+// is reported as the Sample's High or Crit limit.
+func (c *Check) Samples() []Sample {
+	var samples = make([]Sample, 0, len(c.Perf)+1)
+
+	samples = append(samples, Sample{
+		Metric:   "check.status",
+		Instance: c.Name,
+		Value:    float64(c.Status),
+	})
+
+	for _, p := range c.Perf {
+		samples = append(samples, Sample{
+			Metric:   "check.perf",
+			Instance: c.Name + "/" + p.Label,
+			Value:    p.Value,
+			High:     rangeLimit(p.Warn),
+			Crit:     rangeLimit(p.Crit),
+		})
+	}
+
+	return samples
+} // func (c *Check) Samples() []Sample
+
+// rangeLimit returns the upper end of a threshold range, if the range means
+// "alert if the value is above the limit", i.e. it is of the form "10",
+// "0:10" or "~:10". For all other ranges, it returns nil.
+func rangeLimit(r string) *float64 {
+	var (
+		err   error
+		limit float64
+		start string
+		end   = r
+	)
+
+	if r == "" || strings.HasPrefix(r, "@") {
+		return nil
+	} else if i := strings.IndexByte(r, ':'); i >= 0 {
+		start, end = r[:i], r[i+1:]
+	}
+
+	switch start {
+	case "", "~", "0":
+	default:
+		return nil
+	}
+
+	if limit, err = strconv.ParseFloat(end, 64); err != nil {
+		return nil
+	}
+
+	return &limit
+} // func rangeLimit(r string) *float64
+
+// perfValuePat matches the value of a performance data item, along with its
+// unit of measurement.
+var perfValuePat = regexp.MustCompile(`^([-+]?(?:\d+(?:\.\d*)?|\.\d+)(?:[eE][-+]?\d+)?)(\S*)$`)
+
+// ParseCheckOutput parses the output of a check that follows the Nagios
+// plugin conventions:
+//
+//	TEXT OUTPUT | OPTIONAL PERFDATA
+//	LONG TEXT LINE 1
+//	LONG TEXT LINE 2 | PERFDATA LINE 2
+//	PERFDATA LINE 3
+//
+// It returns the first line of text, the remaining lines of text, and the
+// performance data. Items of performance data that cannot be parsed, or
+// whose value is "U" (undetermined), are skipped.
+func ParseCheckOutput(buf []byte) (string, string, []PerfData) {
+	var (
+		output, perf string
+		long         []string
+		inPerf       bool
+		lines        = strings.Split(strings.TrimRight(string(buf), "\r\n"), "\n")
+	)
+
+	output, perf, _ = strings.Cut(lines[0], "|")
+	output = strings.TrimSpace(output)
+
+	for _, line := range lines[1:] {
+		line = strings.TrimRight(line, "\r")
+
+		if inPerf {
+			perf += " " + line
+		} else if text, data, found := strings.Cut(line, "|"); found {
+			long = append(long, strings.TrimRight(text, " \t"))
+			perf += " " + data
+			inPerf = true
+		} else {
+			long = append(long, line)
+		}
+	}
+
+	return output,
+		strings.TrimSpace(strings.Join(long, "\n")),
+		parsePerfData(perf)
+} // func ParseCheckOutput(buf []byte) (string, string, []PerfData)
+
+// parsePerfData parses a list of performance data items separated by
+// whitespace. Labels that contain spaces or equals signs are quoted with
+// single quotes, a single quote within a label is written as two.
+func parsePerfData(s string) []PerfData {
+	var perf = make([]PerfData, 0)
+
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		var (
+			label, value string
+			item         PerfData
+			ok           bool
+		)
+
+		if label, s, ok = cutPerfLabel(s); !ok {
+			return perf
+		} else if label == "" {
+			continue
+		}
+
+		if i := strings.IndexAny(s, " \t"); i >= 0 {
+			value, s = s[:i], s[i+1:]
+		} else {
+			value, s = s, ""
+		}
+
+		if item, ok = parsePerfValue(value); ok {
+			item.Label = label
+			perf = append(perf, item)
+		}
+	}
+
+	return perf
+} // func parsePerfData(s string) []PerfData
+
+// cutPerfLabel cuts the label of a performance data item and the following
+// equals sign from the beginning of s.
+func cutPerfLabel(s string) (string, string, bool) {
+	if !strings.HasPrefix(s, "'") {
+		var label, rest, found = strings.Cut(s, "=")
+
+		if !found || strings.ContainsAny(label, " \t") {
+			// No label here, skip to the next item.
+			if i := strings.IndexAny(s, " \t"); i >= 0 {
+				return "", s[i+1:], true
+			}
+			return "", "", false
+		}
+
+		return label, rest, true
+	}
+
+	var label strings.Builder
+
+	for i := 1; i < len(s); i++ {
+		if s[i] != '\'' {
+			label.WriteByte(s[i])
+		} else if i+1 < len(s) && s[i+1] == '\'' {
+			label.WriteByte('\'')
+			i++
+		} else if i+1 < len(s) && s[i+1] == '=' {
+			return label.String(), s[i+2:], true
+		} else {
+			break
+		}
+	}
+
+	return "", "", false
+} // func cutPerfLabel(s string) (string, string, bool)
+
+// parsePerfValue parses value[UOM];warn;crit;min;max.
+func parsePerfValue(s string) (PerfData, bool) {
+	var (
+		err    error
+		item   PerfData
+		fields = strings.Split(s, ";")
+		m      = perfValuePat.FindStringSubmatch(fields[0])
+	)
+
+	if m == nil {
+		return item, false
+	} else if item.Value, err = strconv.ParseFloat(m[1], 64); err != nil {
+		return item, false
+	}
+
+	item.UOM = m[2]
+
+	if len(fields) > 1 {
+		item.Warn = fields[1]
+	}
+	if len(fields) > 2 {
+		item.Crit = fields[2]
+	}
+	if len(fields) > 3 {
+		item.Min = parseOptFloat(fields[3])
+	}
+	if len(fields) > 4 {
+		item.Max = parseOptFloat(fields[4])
+	}
+
+	return item, true
+} // func parsePerfValue(s string) (PerfData, bool)
+
+func parseOptFloat(s string) *float64 {
+	var f, err = strconv.ParseFloat(s, 64)
+
+	if err != nil {
+		return nil
+	}
+
+	return &f
+} // func parseOptFloat(s string) *float64
+
+func decodeCheck(rec *Record) (any, error) {
+	var (
+		err error
+		c   = new(Check)
+	)
+
+	if err = json.Unmarshal([]byte(rec.Payload), c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+} // func decodeCheck(rec *Record) (any, error)
+
+func validateCheck(v any) error {
+	var (
+		c  *Check
+		ok bool
+	)
+
+	if c, ok = v.(*Check); !ok {
+		return fmt.Errorf("expected *Check, got %T", v)
+	} else if c.Name == "" {
+		return errors.New("Check has no name")
+	} else if c.Status < CheckOK || c.Status > CheckUnknown {
+		return fmt.Errorf("invalid status %d of check %s", c.Status, c.Name)
+	} else if err := finite(c.Duration); err != nil {
+		return err
+	} else if c.Duration < 0 {
+		return fmt.Errorf("negative duration of check %s", c.Name)
+	}
+
+	for _, p := range c.Perf {
+		if p.Label == "" {
+			return fmt.Errorf("performance data of check %s has no label", c.Name)
+		} else if err := finite(p.Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+} // func validateCheck(v any) error
@@ -0,0 +1,98 @@
+// /home/krylon/go/src/github.com/blicero/donkey/model/cpufreq.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 17:05:31 krylon>
+
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/blicero/donkey/model/recordtype"
+)
+
+func init() {
+	RegisterPayload(recordtype.CPUFreq, PayloadType{
+		Name:     "CPUFreq",
+		Decode:   decodeCPUFreq,
+		Encode:   encodeJSON,
+		Validate: validateCPUFreq,
+	})
+} // func init()
+
+// CoreFreq is the clock frequency of a single CPU core, in kHz.
+// Min and Max are the limits the hardware supports.
+type CoreFreq struct {
+	CPU int
+	Cur int64
+	Min int64
+	Max int64
+}
+
+// CPUFreq holds the clock frequencies of all CPU cores of a Host.
+type CPUFreq struct {
+	Cores []CoreFreq
+}
+
+// Samples returns the current frequency of each core, in kHz, as the metric
+// cpufreq, the instance is the number of the core.
+func (c *CPUFreq) Samples() []Sample {
+	var samples = make([]Sample, len(c.Cores))
+
+	for i, core := range c.Cores {
+		var high = float64(core.Max)
+
+		samples[i] = Sample{
+			Metric:   "cpufreq",
+			Instance: fmt.Sprintf("cpu%d", core.CPU),
+			Value:    float64(core.Cur),
+			High:     &high,
+		}
+	}
+
+	return samples
+} // func (c *CPUFreq) Samples() []Sample
+
+func decodeCPUFreq(rec *Record) (any, error) {
+	var (
+		err  error
+		freq = new(CPUFreq)
+	)
+
+	if err = json.Unmarshal([]byte(rec.Payload), freq); err != nil {
+		return nil, err
+	}
+
+	return freq, nil
+} // func decodeCPUFreq(rec *Record) (any, error)
+
+func validateCPUFreq(v any) error {
+	var (
+		freq *CPUFreq
+		ok   bool
+	)
+
+	if freq, ok = v.(*CPUFreq); !ok {
+		return fmt.Errorf("expected *CPUFreq, got %T", v)
+	} else if len(freq.Cores) == 0 {
+		return errors.New("no CPU cores in payload")
+	}
+
+	for _, c := range freq.Cores {
+		if c.Cur <= 0 {
+			return fmt.Errorf("CPU %d has invalid frequency %d",
+				c.CPU,
+				c.Cur)
+		} else if c.Min < 0 || c.Max < 0 || (c.Max > 0 && c.Min > c.Max) {
+			return fmt.Errorf("CPU %d has invalid frequency range %d - %d",
+				c.CPU,
+				c.Min,
+				c.Max)
+		}
+	}
+
+	return nil
+} // func validateCPUFreq(v any) error
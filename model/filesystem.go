@@ -0,0 +1,110 @@
+// /home/krylon/go/src/github.com/blicero/donkey/model/filesystem.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 18:20:44 krylon>
+
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/blicero/donkey/model/recordtype"
+)
+
+func init() {
+	RegisterPayload(recordtype.Filesystem, PayloadType{
+		Name:     "Filesystem",
+		Decode:   decodeFilesystem,
+		Encode:   encodeJSON,
+		Validate: validateFilesystem,
+	})
+} // func init()
+
+// MountUsage describes the usage of a single mounted file system.
+// Sizes are in bytes. Avail is the space available to unprivileged users,
+// which may be less than Free.
+type MountUsage struct {
+	Device     string
+	Mountpoint string
+	FSType     string
+	Total      uint64
+	Used       uint64
+	Free       uint64
+	Avail      uint64
+	Inodes     uint64
+	InodesUsed uint64
+	InodesFree uint64
+}
+
+// UsedPercent returns the percentage of space in use, from the point of view
+// of an unprivileged user, like df(1) does.
+func (m *MountUsage) UsedPercent() float64 {
+	if m.Used+m.Avail == 0 {
+		return 0
+	}
+
+	return float64(m.Used) * 100 / float64(m.Used+m.Avail)
+} // func (m *MountUsage) UsedPercent() float64
+
+// Filesystem holds the usage of all mounted file systems of a Host.
+type Filesystem struct {
+	Mounts []MountUsage
+}
+
+// Samples returns the metrics fs.used_pct, fs.inodes_used_pct and fs.avail
+// (in bytes) for each file system, the instance is the mount point.
+func (f *Filesystem) Samples() []Sample {
+	var samples = make([]Sample, 0, len(f.Mounts)*3)
+
+	for i := range f.Mounts {
+		var m = &f.Mounts[i]
+
+		samples = append(samples,
+			Sample{Metric: "fs.used_pct", Instance: m.Mountpoint, Value: m.UsedPercent()},
+			Sample{Metric: "fs.inodes_used_pct", Instance: m.Mountpoint, Value: percent(m.InodesUsed, m.Inodes)},
+			Sample{Metric: "fs.avail", Instance: m.Mountpoint, Value: float64(m.Avail)})
+	}
+
+	return samples
+} // func (f *Filesystem) Samples() []Sample
+
+func decodeFilesystem(rec *Record) (any, error) {
+	var (
+		err error
+		fs  = new(Filesystem)
+	)
+
+	if err = json.Unmarshal([]byte(rec.Payload), fs); err != nil {
+		return nil, err
+	}
+
+	return fs, nil
+} // func decodeFilesystem(rec *Record) (any, error)
+
+func validateFilesystem(v any) error {
+	var (
+		fs *Filesystem
+		ok bool
+	)
+
+	if fs, ok = v.(*Filesystem); !ok {
+		return fmt.Errorf("expected *Filesystem, got %T", v)
+	} else if len(fs.Mounts) == 0 {
+		return errors.New("no file systems in payload")
+	}
+
+	for _, m := range fs.Mounts {
+		if m.Mountpoint == "" {
+			return errors.New("file system has no mount point")
+		} else if m.Used > m.Total || m.Free > m.Total {
+			return fmt.Errorf("usage of %s exceeds its size", m.Mountpoint)
+		} else if m.InodesUsed > m.Inodes || m.InodesFree > m.Inodes {
+			return fmt.Errorf("inode usage of %s exceeds the number of inodes", m.Mountpoint)
+		}
+	}
+
+	return nil
+} // func validateFilesystem(v any) error
@@ -9,14 +9,28 @@ package model
 import (
 	"time"
 
+	"github.com/blicero/donkey/model/hoststate"
 	"github.com/blicero/krylib"
 )
 
-// Host is a machine out there, on the network.
+// Host is a machine out there, on the network. Interval is the longest time
+// the Host's Agent goes without contacting the Server, as reported by the
+// Agent, or 0 if it has not told us.
 type Host struct {
 	ID          krylib.ID
 	Name        string
 	Addr        string
 	OS          string
 	LastContact time.Time
+	State       hoststate.State
+	Interval    time.Duration
+}
+
+// HostEvent records a change in a Host's liveness state.
+type HostEvent struct {
+	ID        krylib.ID
+	HostID    krylib.ID
+	Timestamp time.Time
+	Previous  hoststate.State
+	State     hoststate.State
 }
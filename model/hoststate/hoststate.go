@@ -0,0 +1,24 @@
+// /home/krylon/go/src/github.com/blicero/donkey/model/hoststate/hoststate.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 21:02:14 krylon>
+
+// Package hoststate provides symbolic constants for the liveness of a Host,
+// as far as the Server can tell from the Agent's reports.
+package hoststate
+
+//go:generate stringer -type=State
+
+// State describes whether a Host is reporting in regularly.
+type State uint8
+
+// Unknown means we have not heard from the Host, yet. Up means it reports
+// regularly, Stale means it has been silent for longer than expected, and
+// Down means it has been silent for so long we assume it is offline.
+const (
+	Unknown State = iota
+	Up
+	Stale
+	Down
+)
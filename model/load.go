@@ -8,11 +8,23 @@ package model
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/blicero/donkey/model/recordtype"
 	"github.com/blicero/krylib"
 )
 
+func init() {
+	RegisterPayload(recordtype.LoadAvg, PayloadType{
+		Name:     "Load",
+		Decode:   decodeLoad,
+		Encode:   encodeLoad,
+		Validate: validateLoad,
+	})
+} // func init()
+
 // Load is a record of the system load average that is available on most
 // Unix-like systems (all that I have seen so far).
 type Load struct {
@@ -22,6 +34,16 @@ type Load struct {
 	Load      [3]float64
 }
 
+// Samples returns the three load averages as the metrics load1, load5 and
+// load15.
+func (l *Load) Samples() []Sample {
+	return []Sample{
+		{Metric: "load1", Value: l.Load[0]},
+		{Metric: "load5", Value: l.Load[1]},
+		{Metric: "load15", Value: l.Load[2]},
+	}
+} // func (l *Load) Samples() []Sample
+
 // Payload returns the Load record's payload as a JSON string.
 func (l *Load) Payload() string {
 	var (
@@ -35,3 +57,49 @@ func (l *Load) Payload() string {
 
 	return string(buf)
 }
+
+func decodeLoad(rec *Record) (any, error) {
+	var (
+		err  error
+		load = &Load{
+			ID:        krylib.ID(rec.ID),
+			HostID:    krylib.ID(rec.HostID),
+			Timestamp: rec.Timestamp,
+		}
+	)
+
+	if err = json.Unmarshal([]byte(rec.Payload), &load.Load); err != nil {
+		return nil, err
+	}
+
+	return load, nil
+} // func decodeLoad(rec *Record) (any, error)
+
+func encodeLoad(v any) (string, error) {
+	if l, ok := v.(*Load); ok {
+		return l.Payload(), nil
+	}
+
+	return "", fmt.Errorf("expected *Load, got %T", v)
+} // func encodeLoad(v any) (string, error)
+
+func validateLoad(v any) error {
+	var (
+		l  *Load
+		ok bool
+	)
+
+	if l, ok = v.(*Load); !ok {
+		return fmt.Errorf("expected *Load, got %T", v)
+	} else if err := finite(l.Load[:]...); err != nil {
+		return err
+	}
+
+	for _, f := range l.Load {
+		if f < 0 {
+			return errors.New("load average cannot be negative")
+		}
+	}
+
+	return nil
+} // func validateLoad(v any) error
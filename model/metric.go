@@ -0,0 +1,58 @@
+// /home/krylon/go/src/github.com/blicero/donkey/model/metric.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 22:03:51 krylon>
+
+package model
+
+import "fmt"
+
+// Sample is a single numeric value extracted from a Record's payload, so
+// different types of Records can be handled uniformly, e.g. by alerting
+// rules.
+//
+// Metric names the kind of value, e.g. "load1" or "sensor.temp". Instance
+// tells apart several values of the same Metric in one Record, e.g. the
+// mount point of a file system. High and Crit are limits reported along
+// with the value, if any.
+type Sample struct {
+	Metric   string
+	Instance string
+	Value    float64
+	High     *float64 `json:",omitempty"`
+	Crit     *float64 `json:",omitempty"`
+}
+
+// Measurable is implemented by payload types that can be broken down into
+// Samples.
+type Measurable interface {
+	Samples() []Sample
+}
+
+// Samples decodes the Record's payload and breaks it down into Samples.
+func (r *Record) Samples() ([]Sample, error) {
+	var (
+		err error
+		v   any
+		m   Measurable
+		ok  bool
+	)
+
+	if v, err = r.Decode(); err != nil {
+		return nil, err
+	} else if m, ok = v.(Measurable); !ok {
+		return nil, fmt.Errorf("Payload of type %T does not provide Samples", v)
+	}
+
+	return m.Samples(), nil
+} // func (r *Record) Samples() ([]Sample, error)
+
+// percent returns part as a percentage of total, or 0 if total is 0.
+func percent(part, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(part) * 100 / float64(total)
+} // func percent(part, total uint64) float64
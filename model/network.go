@@ -0,0 +1,125 @@
+// /home/krylon/go/src/github.com/blicero/donkey/model/network.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 19:40:18 krylon>
+
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/blicero/donkey/model/recordtype"
+)
+
+func init() {
+	RegisterPayload(recordtype.Network, PayloadType{
+		Name:     "Network",
+		Decode:   decodeNetwork,
+		Encode:   encodeJSON,
+		Validate: validateNetwork,
+	})
+} // func init()
+
+// IfaceRates holds the throughput of a single network interface. All values
+// are per second.
+type IfaceRates struct {
+	Name      string
+	RxBytes   float64
+	TxBytes   float64
+	RxPackets float64
+	TxPackets float64
+	RxErrors  float64
+	TxErrors  float64
+	RxDrops   float64
+	TxDrops   float64
+}
+
+func (r *IfaceRates) values() []float64 {
+	return []float64{
+		r.RxBytes,
+		r.TxBytes,
+		r.RxPackets,
+		r.TxPackets,
+		r.RxErrors,
+		r.TxErrors,
+		r.RxDrops,
+		r.TxDrops,
+	}
+} // func (r *IfaceRates) values() []float64
+
+// Network holds the throughput of a Host's network interfaces, averaged over
+// the Interval (in seconds) since the previous sample.
+type Network struct {
+	Interval   float64
+	Interfaces []IfaceRates
+}
+
+// Samples returns the rates of each interface as the metrics net.rx_bytes,
+// net.tx_bytes, net.rx_packets, net.tx_packets, net.rx_errors,
+// net.tx_errors, net.rx_drops and net.tx_drops, the instance is the name of
+// the interface.
+func (n *Network) Samples() []Sample {
+	var (
+		names   = []string{"rx_bytes", "tx_bytes", "rx_packets", "tx_packets", "rx_errors", "tx_errors", "rx_drops", "tx_drops"}
+		samples = make([]Sample, 0, len(n.Interfaces)*len(names))
+	)
+
+	for i := range n.Interfaces {
+		for j, v := range n.Interfaces[i].values() {
+			samples = append(samples, Sample{
+				Metric:   "net." + names[j],
+				Instance: n.Interfaces[i].Name,
+				Value:    v,
+			})
+		}
+	}
+
+	return samples
+} // func (n *Network) Samples() []Sample
+
+func decodeNetwork(rec *Record) (any, error) {
+	var (
+		err error
+		net = new(Network)
+	)
+
+	if err = json.Unmarshal([]byte(rec.Payload), net); err != nil {
+		return nil, err
+	}
+
+	return net, nil
+} // func decodeNetwork(rec *Record) (any, error)
+
+func validateNetwork(v any) error {
+	var (
+		net *Network
+		ok  bool
+	)
+
+	if net, ok = v.(*Network); !ok {
+		return fmt.Errorf("expected *Network, got %T", v)
+	} else if net.Interval <= 0 {
+		return errors.New("sampling interval must be positive")
+	}
+
+	for _, iface := range net.Interfaces {
+		var vals = iface.values()
+
+		if iface.Name == "" {
+			return errors.New("network interface has no name")
+		} else if err := finite(vals...); err != nil {
+			return err
+		}
+
+		for _, f := range vals {
+			if f < 0 {
+				return fmt.Errorf("negative rate for interface %s", iface.Name)
+			}
+		}
+	}
+
+	return nil
+} // func validateNetwork(v any) error
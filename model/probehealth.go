@@ -0,0 +1,101 @@
+// /home/krylon/go/src/github.com/blicero/donkey/model/probehealth.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 08:44:12 krylon>
+
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/blicero/donkey/model/recordtype"
+)
+
+func init() {
+	RegisterPayload(recordtype.ProbeHealth, PayloadType{
+		Name:     "ProbeHealth",
+		Decode:   decodeProbeHealth,
+		Encode:   encodeJSON,
+		Validate: validateProbeHealth,
+	})
+} // func init()
+
+// ProbeStatus describes the state of one of an Agent's Probes. Interval is
+// the time between two samples in seconds. Errors counts the failed
+// attempts to collect a sample, Restarts how often the Agent had to
+// restart the Probe after it crashed. LastError is the most recent error,
+// if any.
+type ProbeStatus struct {
+	Name      string
+	Running   bool
+	Interval  float64
+	Errors    uint64
+	Restarts  uint64
+	LastError string `json:",omitempty"`
+}
+
+// ProbeHealth is the state of all Probes an Agent runs.
+type ProbeHealth struct {
+	Probes []ProbeStatus
+}
+
+// Samples returns the metrics probe.up, 1 if the Probe is running and 0 if
+// not, probe.errors and probe.restarts for each Probe, the instance is the
+// name of the Probe.
+func (h *ProbeHealth) Samples() []Sample {
+	var samples = make([]Sample, 0, len(h.Probes)*3)
+
+	for _, p := range h.Probes {
+		var up float64
+
+		if p.Running {
+			up = 1
+		}
+
+		samples = append(samples,
+			Sample{Metric: "probe.up", Instance: p.Name, Value: up},
+			Sample{Metric: "probe.errors", Instance: p.Name, Value: float64(p.Errors)},
+			Sample{Metric: "probe.restarts", Instance: p.Name, Value: float64(p.Restarts)})
+	}
+
+	return samples
+} // func (h *ProbeHealth) Samples() []Sample
+
+func decodeProbeHealth(rec *Record) (any, error) {
+	var (
+		err error
+		h   = new(ProbeHealth)
+	)
+
+	if err = json.Unmarshal([]byte(rec.Payload), h); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+} // func decodeProbeHealth(rec *Record) (any, error)
+
+func validateProbeHealth(v any) error {
+	var (
+		h  *ProbeHealth
+		ok bool
+	)
+
+	if h, ok = v.(*ProbeHealth); !ok {
+		return fmt.Errorf("expected *ProbeHealth, got %T", v)
+	}
+
+	for _, p := range h.Probes {
+		if p.Name == "" {
+			return errors.New("Probe has no name")
+		} else if err := finite(p.Interval); err != nil {
+			return err
+		} else if p.Interval <= 0 {
+			return fmt.Errorf("interval of Probe %s must be positive", p.Name)
+		}
+	}
+
+	return nil
+} // func validateProbeHealth(v any) error
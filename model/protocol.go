@@ -14,3 +14,51 @@ type Response struct {
 	Message   string
 	Timestamp time.Time
 }
+
+// RecordStatus tells the Agent what became of a single Record it submitted
+// as part of a batch.
+type RecordStatus struct {
+	ID      int64
+	Status  bool
+	Message string
+}
+
+// BatchResponse is what the Server sends to the Agent after handling a batch
+// of Records. Results contains one entry per submitted Record, in the same
+// order.
+type BatchResponse struct {
+	Response
+	Results []RecordStatus
+}
+
+// MaxBatchSize is the maximum number of Records an Agent may submit in a
+// single batch.
+const MaxBatchSize = 1024
+
+// Registration is what the Server sends to an Agent that registered
+// successfully. The Agent has to present Token with every report it sends
+// for HostID.
+type Registration struct {
+	Response
+	HostID int64
+	Token  string
+}
+
+// AgentConfig holds the settings of an Agent that can be managed from the
+// Server. Probes maps the types of Probes to run to the interval between two
+// samples in seconds. BatchSize and BatchInterval control how the Agent
+// sends Records to the Server. Settings that are zero or nil are left to
+// the Agent's own configuration.
+type AgentConfig struct {
+	Probes        map[string]int `json:",omitempty"`
+	BatchSize     int            `json:",omitempty"`
+	BatchInterval int            `json:",omitempty"`
+}
+
+// ConfigResponse is what the Server sends to an Agent asking for its
+// configuration. If Config is nil, the Server does not manage the Agent's
+// configuration, and the Agent uses its own.
+type ConfigResponse struct {
+	Response
+	Config *AgentConfig `json:",omitempty"`
+}
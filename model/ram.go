@@ -0,0 +1,93 @@
+// /home/krylon/go/src/github.com/blicero/donkey/model/ram.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 17:11:48 krylon>
+
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/blicero/donkey/model/recordtype"
+)
+
+func init() {
+	RegisterPayload(recordtype.RAM, PayloadType{
+		Name:     "RAM",
+		Decode:   decodeRAM,
+		Encode:   encodeJSON,
+		Validate: validateRAM,
+	})
+} // func init()
+
+// RAM describes the memory and swap usage of a Host. All values are in bytes.
+type RAM struct {
+	Total     uint64
+	Free      uint64
+	Available uint64
+	Buffers   uint64
+	Cached    uint64
+	SwapTotal uint64
+	SwapFree  uint64
+}
+
+// Used returns the amount of memory in use, not counting buffers and caches.
+func (r *RAM) Used() uint64 {
+	if r.Available > 0 && r.Available <= r.Total {
+		return r.Total - r.Available
+	} else if used := r.Free + r.Buffers + r.Cached; used <= r.Total {
+		return r.Total - used
+	}
+
+	return 0
+} // func (r *RAM) Used() uint64
+
+// SwapUsed returns the amount of swap space in use.
+func (r *RAM) SwapUsed() uint64 {
+	return r.SwapTotal - r.SwapFree
+} // func (r *RAM) SwapUsed() uint64
+
+// Samples returns the metrics ram.used_pct and swap.used_pct, the share of
+// memory and swap space in use, as well as ram.available in bytes.
+func (r *RAM) Samples() []Sample {
+	return []Sample{
+		{Metric: "ram.used_pct", Value: percent(r.Used(), r.Total)},
+		{Metric: "ram.available", Value: float64(r.Available)},
+		{Metric: "swap.used_pct", Value: percent(r.SwapUsed(), r.SwapTotal)},
+	}
+} // func (r *RAM) Samples() []Sample
+
+func decodeRAM(rec *Record) (any, error) {
+	var (
+		err error
+		ram = new(RAM)
+	)
+
+	if err = json.Unmarshal([]byte(rec.Payload), ram); err != nil {
+		return nil, err
+	}
+
+	return ram, nil
+} // func decodeRAM(rec *Record) (any, error)
+
+func validateRAM(v any) error {
+	var (
+		ram *RAM
+		ok  bool
+	)
+
+	if ram, ok = v.(*RAM); !ok {
+		return fmt.Errorf("expected *RAM, got %T", v)
+	} else if ram.Total == 0 {
+		return errors.New("total memory cannot be zero")
+	} else if ram.Free > ram.Total || ram.Available > ram.Total {
+		return errors.New("free memory exceeds total memory")
+	} else if ram.SwapFree > ram.SwapTotal {
+		return errors.New("free swap space exceeds total swap space")
+	}
+
+	return nil
+} // func validateRAM(v any) error
@@ -10,6 +10,12 @@ package recordtype
 
 //go:generate stringer -type=ID
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 type ID uint8
 
 const (
@@ -17,4 +23,43 @@ const (
 	Sensors
 	CPUFreq
 	RAM
+	Filesystem
+	Network
+	ProbeHealth
+	Check
 )
+
+// All returns all types of Record, in order. When adding a type above, add
+// it here, too.
+func All() []ID {
+	return []ID{
+		LoadAvg,
+		Sensors,
+		CPUFreq,
+		RAM,
+		Filesystem,
+		Network,
+		ProbeHealth,
+		Check,
+	}
+} // func All() []ID
+
+// Parse returns the type of Record with the given name, ignoring case, or
+// number.
+func Parse(s string) (ID, error) {
+	for _, id := range All() {
+		if strings.EqualFold(s, id.String()) {
+			return id, nil
+		}
+	}
+
+	if n, err := strconv.ParseUint(s, 10, 8); err == nil {
+		for _, id := range All() {
+			if uint64(id) == n {
+				return id, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("Unknown record type %q", s)
+} // func Parse(s string) (ID, error)
@@ -0,0 +1,149 @@
+// /home/krylon/go/src/github.com/blicero/donkey/model/registry.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 16:21:09 krylon>
+
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/blicero/donkey/model/recordtype"
+)
+
+// ErrUnknownType indicates that no PayloadType has been registered for a
+// Record's type.
+var ErrUnknownType = errors.New("unknown record type")
+
+// ErrInvalidPayload indicates that a Record's payload could not be decoded or
+// contains nonsensical values.
+var ErrInvalidPayload = errors.New("invalid payload")
+
+// PayloadType describes the Go type that corresponds to the payload of a
+// particular type of Record.
+//
+// Decode turns a Record into a pointer to the Go type, Encode does the
+// reverse for the payload, and Validate checks a decoded value for
+// plausibility. Instance is only needed for types of which an Agent may send
+// several Records at the same time, it returns what tells them apart, e.g.
+// the name of a check.
+type PayloadType struct {
+	Name     string
+	Decode   func(rec *Record) (any, error)
+	Encode   func(v any) (string, error)
+	Validate func(v any) error
+	Instance func(v any) string
+}
+
+var (
+	regLock  sync.RWMutex
+	registry = make(map[recordtype.ID]PayloadType)
+)
+
+// RegisterPayload registers the PayloadType for the given type of Record,
+// replacing any previous registration.
+func RegisterPayload(id recordtype.ID, pt PayloadType) {
+	regLock.Lock()
+	registry[id] = pt
+	regLock.Unlock()
+} // func RegisterPayload(id recordtype.ID, pt PayloadType)
+
+// LookupPayload returns the PayloadType registered for the given type of Record.
+func LookupPayload(id recordtype.ID) (PayloadType, bool) {
+	regLock.RLock()
+	pt, ok := registry[id]
+	regLock.RUnlock()
+	return pt, ok
+} // func LookupPayload(id recordtype.ID) (PayloadType, bool)
+
+// EncodePayload serializes a value into a payload for the given type of Record.
+func EncodePayload(id recordtype.ID, v any) (string, error) {
+	var (
+		pt PayloadType
+		ok bool
+	)
+
+	if pt, ok = LookupPayload(id); !ok {
+		return "", fmt.Errorf("%w: %d", ErrUnknownType, id)
+	}
+
+	return pt.Encode(v)
+} // func EncodePayload(id recordtype.ID, v any) (string, error)
+
+// Decode parses the Record's payload into the Go type registered for its
+// type and validates the result.
+func (r *Record) Decode() (any, error) {
+	var (
+		err error
+		pt  PayloadType
+		ok  bool
+		v   any
+	)
+
+	if pt, ok = LookupPayload(r.Source); !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownType, r.Source)
+	} else if v, err = pt.Decode(r); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPayload, err.Error())
+	} else if err = pt.Validate(v); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPayload, err.Error())
+	}
+
+	return v, nil
+} // func (r *Record) Decode() (any, error)
+
+// Instance returns what distinguishes the Record from others of the same type
+// and Host with the same timestamp, or an empty string if there can be only
+// one such Record.
+func (r *Record) Instance() string {
+	var (
+		err error
+		pt  PayloadType
+		ok  bool
+		v   any
+	)
+
+	if pt, ok = LookupPayload(r.Source); !ok || pt.Instance == nil {
+		return ""
+	} else if v, err = pt.Decode(r); err != nil {
+		return ""
+	}
+
+	return pt.Instance(v)
+} // func (r *Record) Instance() string
+
+// Validate checks if the Record's payload can be decoded and makes sense.
+func (r *Record) Validate() error {
+	var _, err = r.Decode()
+	return err
+} // func (r *Record) Validate() error
+
+// encodeJSON is the Encode function for payload types that are serialized
+// as plain JSON.
+func encodeJSON(v any) (string, error) {
+	var (
+		err error
+		buf []byte
+	)
+
+	if buf, err = json.Marshal(v); err != nil {
+		return "", err
+	}
+
+	return string(buf), nil
+} // func encodeJSON(v any) (string, error)
+
+// finite returns an error if any of the given values is NaN or infinite.
+func finite(vals ...float64) error {
+	for _, f := range vals {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("value %f is not a finite number", f)
+		}
+	}
+
+	return nil
+} // func finite(vals ...float64) error
@@ -0,0 +1,76 @@
+// /home/krylon/go/src/github.com/blicero/donkey/model/resolution/resolution.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 00:02:31 krylon>
+
+// Package resolution provides symbolic constants for the granularity at
+// which the history of a metric is stored.
+package resolution
+
+//go:generate stringer -type=ID
+
+import "time"
+
+// ID identifies a resolution.
+type ID uint8
+
+// Raw is the data as reported by the Agents, the others are aggregates over
+// buckets of one minute, one hour and one day, respectively.
+const (
+	Raw ID = iota
+	Minute
+	Hour
+	Day
+)
+
+// Rollups lists the resolutions raw data is aggregated into, finest first.
+var Rollups = []ID{Minute, Hour, Day}
+
+// Duration returns the length of a bucket, it is 0 for Raw.
+func (id ID) Duration() time.Duration {
+	switch id {
+	case Minute:
+		return time.Minute
+	case Hour:
+		return time.Hour
+	case Day:
+		return time.Hour * 24
+	default:
+		return 0
+	}
+} // func (id ID) Duration() time.Duration
+
+// Bucket returns the start of the bucket t falls into. Buckets are aligned
+// to UTC, so days start at midnight UTC.
+func (id ID) Bucket(t time.Time) time.Time {
+	if id == Raw {
+		return t
+	}
+
+	return t.Truncate(id.Duration())
+} // func (id ID) Bucket(t time.Time) time.Time
+
+// MaxRawSpan is the longest time range for which raw data is used.
+// MaxPoints is the most buckets we want to return for a time range.
+const (
+	MaxRawSpan = time.Hour * 6
+	MaxPoints  = 1500
+)
+
+// For returns the resolution to use for a time range of the given length:
+// Raw data for short ranges, otherwise the finest resolution that yields no
+// more than MaxPoints buckets.
+func For(span time.Duration) ID {
+	if span <= MaxRawSpan {
+		return Raw
+	}
+
+	for _, id := range Rollups {
+		if span/id.Duration() <= MaxPoints {
+			return id
+		}
+	}
+
+	return Day
+} // func For(span time.Duration) ID
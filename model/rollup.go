@@ -0,0 +1,44 @@
+// /home/krylon/go/src/github.com/blicero/donkey/model/rollup.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 00:08:17 krylon>
+
+package model
+
+import (
+	"time"
+
+	"github.com/blicero/donkey/model/recordtype"
+	"github.com/blicero/donkey/model/resolution"
+	"github.com/blicero/krylib"
+)
+
+// Rollup aggregates the values of one metric (and instance) of a Host over
+// a bucket of time, starting at Bucket. A single raw Sample can be
+// represented as a Rollup at resolution.Raw with a Count of 1.
+type Rollup struct {
+	HostID     krylib.ID
+	Source     recordtype.ID
+	Metric     string
+	Instance   string
+	Resolution resolution.ID
+	Bucket     time.Time
+	Count      int64
+	Min        float64
+	Max        float64
+	Avg        float64
+}
+
+// Add adds a single value to the Rollup.
+func (r *Rollup) Add(v float64) {
+	if r.Count == 0 {
+		r.Min, r.Max, r.Avg = v, v, v
+	} else {
+		r.Min = min(r.Min, v)
+		r.Max = max(r.Max, v)
+		r.Avg += (v - r.Avg) / float64(r.Count+1)
+	}
+
+	r.Count++
+} // func (r *Rollup) Add(v float64)
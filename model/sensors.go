@@ -0,0 +1,314 @@
+// /home/krylon/go/src/github.com/blicero/donkey/model/sensors.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 20:31:44 krylon>
+
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/blicero/donkey/model/recordtype"
+)
+
+func init() {
+	RegisterPayload(recordtype.Sensors, PayloadType{
+		Name:     "Sensors",
+		Decode:   decodeSensors,
+		Encode:   encodeJSON,
+		Validate: validateSensors,
+	})
+} // func init()
+
+// The kinds of sensor readings we know about.
+const (
+	SensorTemp    = "temp"
+	SensorFan     = "fan"
+	SensorVoltage = "voltage"
+)
+
+// sensorKinds maps the prefixes lm-sensors and the hwmon subsystem use for
+// their features to the kind of reading.
+var sensorKinds = map[string]string{
+	"temp": SensorTemp,
+	"fan":  SensorFan,
+	"in":   SensorVoltage,
+}
+
+// SensorReading is a single value reported by a hardware sensor.
+// Temperatures are in degrees Celsius, fan speeds in RPM, voltages in Volt.
+// High and Crit are nil if the chip does not report a limit.
+type SensorReading struct {
+	Chip    string
+	Adapter string
+	Label   string
+	Kind    string
+	Value   float64
+	High    *float64 `json:",omitempty"`
+	Crit    *float64 `json:",omitempty"`
+}
+
+// Sensors is the normalized list of readings from a Host's hardware sensors.
+type Sensors struct {
+	Readings []SensorReading
+}
+
+// Find returns the first reading of the given kind whose label matches,
+// ignoring case, or nil if there is none.
+func (s *Sensors) Find(kind, label string) *SensorReading {
+	for i := range s.Readings {
+		if s.Readings[i].Kind == kind && strings.EqualFold(s.Readings[i].Label, label) {
+			return &s.Readings[i]
+		}
+	}
+
+	return nil
+} // func (s *Sensors) Find(kind, label string) *SensorReading
+
+// Max returns the reading of the given kind with the highest value, or nil
+// if there is none.
+func (s *Sensors) Max(kind string) *SensorReading {
+	var max *SensorReading
+
+	for i := range s.Readings {
+		if s.Readings[i].Kind != kind {
+			continue
+		} else if max == nil || s.Readings[i].Value > max.Value {
+			max = &s.Readings[i]
+		}
+	}
+
+	return max
+} // func (s *Sensors) Max(kind string) *SensorReading
+
+// Sort orders the readings by chip, kind and label.
+func (s *Sensors) Sort() {
+	sort.SliceStable(s.Readings, func(i, j int) bool {
+		var a, b = &s.Readings[i], &s.Readings[j]
+
+		if a.Chip != b.Chip {
+			return a.Chip < b.Chip
+		} else if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+
+		return a.Label < b.Label
+	})
+} // func (s *Sensors) Sort()
+
+// Samples returns each reading as a Sample of the metric sensor.<kind>, the
+// instance is made up of the chip and the label.
+func (s *Sensors) Samples() []Sample {
+	var samples = make([]Sample, len(s.Readings))
+
+	for i, r := range s.Readings {
+		samples[i] = Sample{
+			Metric:   "sensor." + r.Kind,
+			Instance: r.Chip + "/" + r.Label,
+			Value:    r.Value,
+			High:     r.High,
+			Crit:     r.Crit,
+		}
+	}
+
+	return samples
+} // func (s *Sensors) Samples() []Sample
+
+// Older versions of lm-sensors emit each feature as a flat object, the keys
+// carry the type and number of the feature:
+//
+//	"Core 0": { "temp2_input": 49.000, "temp2_crit": 100.000 }
+//
+// Newer versions use the raw feature name as the key and nest the values:
+//
+//	"temp1": { "label": "Tctl", "input": { "quantity": "temperature", "value": 61.75 } }
+var (
+	sensorsFlatPat    = regexp.MustCompile(`^(temp|fan|in)\d+_(input|max|crit)$`)
+	sensorsFeaturePat = regexp.MustCompile(`^(temp|fan|in)\d+$`)
+)
+
+// ParseSensorsJSON parses the output of `sensors -j` into a normalized list
+// of readings. Features that are neither temperatures, fans or voltages, as
+// well as features without a current value, are skipped.
+func ParseSensorsJSON(buf []byte) (*Sensors, error) {
+	var (
+		err   error
+		chips map[string]map[string]json.RawMessage
+		s     = &Sensors{Readings: make([]SensorReading, 0)}
+	)
+
+	if err = json.Unmarshal(buf, &chips); err != nil {
+		return nil, err
+	}
+
+	for chip, features := range chips {
+		var adapter string
+
+		if raw, ok := features["Adapter"]; ok {
+			json.Unmarshal(raw, &adapter) // nolint: errcheck
+		}
+
+		for name, raw := range features {
+			var (
+				r     SensorReading
+				ok    bool
+				entry map[string]any
+			)
+
+			if name == "Adapter" {
+				continue
+			} else if json.Unmarshal(raw, &entry) != nil {
+				continue
+			}
+
+			r.Chip = chip
+			r.Adapter = adapter
+			r.Label = strings.TrimSpace(name)
+
+			// Older versions name features like "temp1", too, if the chip
+			// provides no label, so we cannot tell the formats apart by
+			// the name alone.
+			if ok = parseSensorFlat(&r, entry); !ok {
+				if m := sensorsFeaturePat.FindStringSubmatch(name); m != nil {
+					ok = parseSensorFeature(&r, m[1], entry)
+				}
+			}
+
+			if ok {
+				s.Readings = append(s.Readings, r)
+			}
+		}
+	}
+
+	s.Sort()
+
+	return s, nil
+} // func ParseSensorsJSON(buf []byte) (*Sensors, error)
+
+// parseSensorFlat handles a feature in the format of older lm-sensors
+// versions.
+func parseSensorFlat(r *SensorReading, entry map[string]any) bool {
+	var hasInput bool
+
+	for key, v := range entry {
+		var (
+			f  float64
+			ok bool
+			m  = sensorsFlatPat.FindStringSubmatch(key)
+		)
+
+		if m == nil {
+			continue
+		} else if f, ok = v.(float64); !ok {
+			continue
+		}
+
+		r.Kind = sensorKinds[m[1]]
+
+		switch m[2] {
+		case "input":
+			r.Value = f
+			hasInput = true
+		case "max":
+			r.High = &f
+		case "crit":
+			r.Crit = &f
+		}
+	}
+
+	return hasInput
+} // func parseSensorFlat(r *SensorReading, entry map[string]any) bool
+
+// parseSensorFeature handles a feature in the format of newer lm-sensors
+// versions.
+func parseSensorFeature(r *SensorReading, prefix string, entry map[string]any) bool {
+	var hasInput bool
+
+	r.Kind = sensorKinds[prefix]
+
+	if label, ok := entry["label"].(string); ok && label != "" {
+		r.Label = label
+	}
+
+	for key, v := range entry {
+		var (
+			f   float64
+			ok  bool
+			sub map[string]any
+		)
+
+		if sub, ok = v.(map[string]any); !ok {
+			continue
+		} else if f, ok = sub["value"].(float64); !ok {
+			continue
+		}
+
+		switch key {
+		case "input":
+			r.Value = f
+			hasInput = true
+		case "max":
+			r.High = &f
+		case "crit":
+			r.Crit = &f
+		}
+	}
+
+	return hasInput
+} // func parseSensorFeature(r *SensorReading, prefix string, entry map[string]any) bool
+
+// decodeSensors accepts both the normalized format and the raw output of
+// `sensors -j` that older Agents sent, so Records stored before the
+// normalization was introduced can still be read.
+func decodeSensors(rec *Record) (any, error) {
+	var (
+		err   error
+		probe map[string]json.RawMessage
+		s     = new(Sensors)
+	)
+
+	if err = json.Unmarshal([]byte(rec.Payload), &probe); err != nil {
+		return nil, err
+	} else if _, ok := probe["Readings"]; !ok {
+		return ParseSensorsJSON([]byte(rec.Payload))
+	} else if err = json.Unmarshal([]byte(rec.Payload), s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+} // func decodeSensors(rec *Record) (any, error)
+
+func validateSensors(v any) error {
+	var (
+		s  *Sensors
+		ok bool
+	)
+
+	if s, ok = v.(*Sensors); !ok {
+		return fmt.Errorf("expected *Sensors, got %T", v)
+	} else if len(s.Readings) == 0 {
+		return errors.New("no sensor readings found in payload")
+	}
+
+	for _, r := range s.Readings {
+		switch r.Kind {
+		case SensorTemp, SensorFan, SensorVoltage:
+		default:
+			return fmt.Errorf("unknown kind of sensor reading %q", r.Kind)
+		}
+
+		if r.Chip == "" {
+			return errors.New("sensor reading has no chip")
+		} else if err := finite(r.Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+} // func validateSensors(v any) error
@@ -35,6 +35,9 @@ var testHosts = []model.Host{
 	},
 }
 
+// testTokens holds the tokens issued to testHosts, in the same order.
+var testTokens = make([]string, len(testHosts))
+
 func TestMain(m *testing.M) {
 	var (
 		err     error
@@ -80,9 +83,17 @@ func prepareDB() error {
 	}
 
 	for i, h := range testHosts {
+		var hash string
+
 		if err = db.HostAdd(&h); err != nil {
 			db.Rollback() // nolint: errcheck
 			return err
+		} else if testTokens[i], hash, err = newToken(); err != nil {
+			db.Rollback() // nolint: errcheck
+			return err
+		} else if err = db.HostUpdateToken(&h, hash); err != nil {
+			db.Rollback() // nolint: errcheck
+			return err
 		} else if h.ID == 0 {
 			err = fmt.Errorf("Host did not receive an ID: %s / %s",
 				h.Name,
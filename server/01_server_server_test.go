@@ -12,9 +12,12 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"reflect"
+	"sort"
 	"testing"
 	"time"
 
+	"github.com/blicero/donkey/database"
 	"github.com/blicero/donkey/model"
 	"github.com/blicero/donkey/model/recordtype"
 )
@@ -51,7 +54,7 @@ func TestReportData(t *testing.T) {
 			path)
 	)
 
-	for _, h := range testHosts {
+	for i, h := range testHosts {
 		var (
 			req        *http.Request
 			res        *http.Response
@@ -80,7 +83,11 @@ func TestReportData(t *testing.T) {
 				h.ID,
 				err.Error())
 			continue
-		} else if res, err = client.Do(req); err != nil {
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testTokens[i])
+
+		if res, err = client.Do(req); err != nil {
 			t.Errorf("Failed to perform HTTP request for %s: %s",
 				addr,
 				err.Error())
@@ -102,3 +109,270 @@ func TestReportData(t *testing.T) {
 		}
 	}
 } // func TestReportData(t *testing.T)
+
+func TestReportBatch(t *testing.T) {
+	const path = "/ws/report/batch"
+
+	if srv == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err    error
+		client http.Client
+		stamp  = time.Now().Add(time.Hour)
+		addr   = fmt.Sprintf("http://%s%s",
+			testAddr,
+			path)
+	)
+
+	// A batch may only contain Records of the Host the token was issued
+	// to, so we send one batch per Host.
+	for idx, h := range testHosts {
+		var (
+			req        *http.Request
+			res        *http.Response
+			reply      model.BatchResponse
+			serialized []byte
+			buf        *bytes.Buffer
+			rec        = model.Record{
+				HostID:    int64(h.ID),
+				Timestamp: stamp,
+				Source:    recordtype.LoadAvg,
+				Payload:   "[0.15, 0.17, 0.2]",
+			}
+			// The last Record duplicates the first one, so the
+			// Server should reject it while accepting the rest.
+			batch = []model.Record{rec, rec}
+		)
+
+		if serialized, err = json.Marshal(batch); err != nil {
+			t.Fatalf("Failed to serialize batch: %s", err.Error())
+		}
+
+		buf = bytes.NewBuffer(serialized)
+
+		if req, err = http.NewRequest("POST", addr, buf); err != nil {
+			t.Fatalf("Failed to create HTTP request: %s", err.Error())
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testTokens[idx])
+
+		if res, err = client.Do(req); err != nil {
+			t.Fatalf("Failed to perform HTTP request for %s: %s",
+				addr,
+				err.Error())
+		}
+
+		buf.Reset()
+		_, err = io.Copy(buf, res.Body)
+		res.Body.Close()
+
+		if err != nil {
+			t.Fatalf("Failed to read response body from Server: %s",
+				err.Error())
+		} else if err = json.Unmarshal(buf.Bytes(), &reply); err != nil {
+			t.Fatalf("Failed to unmarshal response body: %s\n\n%s\n",
+				err.Error(),
+				buf.String())
+		} else if !reply.Status {
+			t.Fatalf("Server did not accept batch: %s", reply.Message)
+		} else if len(reply.Results) != len(batch) {
+			t.Fatalf("Unexpected number of results: %d (expected %d)",
+				len(reply.Results),
+				len(batch))
+		}
+
+		for i, r := range reply.Results {
+			var expect = i < len(batch)-1
+
+			if r.Status != expect {
+				t.Errorf("Unexpected status for Record #%d of %s: %t (expected %t) - %s",
+					i,
+					h.Name,
+					r.Status,
+					expect,
+					r.Message)
+			} else if r.Status && r.ID == 0 {
+				t.Errorf("Record #%d of %s was accepted, but has no ID",
+					i,
+					h.Name)
+			}
+		}
+	}
+} // func TestReportBatch(t *testing.T)
+
+// TestReportChecks sends the results of several checks that finished in the
+// same second, the Server has to store all of them.
+func TestReportChecks(t *testing.T) {
+	if srv == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err    error
+		status int
+		db     *database.Database
+		recs   []model.Record
+		reply  model.BatchResponse
+		res    model.Response
+		names  []string
+		h      = &testHosts[0]
+		stamp  = time.Now().Add(time.Hour * 2).Truncate(time.Second)
+		batch  []model.Record
+		expect = []string{"disk", "http", "ping", "swap"}
+	)
+
+	var check = func(name string) model.Record {
+		var (
+			err     error
+			payload string
+		)
+
+		if payload, err = model.EncodePayload(recordtype.Check, &model.Check{Name: name, Output: "OK"}); err != nil {
+			t.Fatalf("Cannot encode check %s: %s", name, err.Error())
+		}
+
+		return model.Record{
+			HostID:    int64(h.ID),
+			Timestamp: stamp,
+			Source:    recordtype.Check,
+			Payload:   payload,
+		}
+	}
+
+	// The last Record duplicates the first one and must be rejected.
+	for _, name := range []string{"ping", "disk", "http", "ping"} {
+		batch = append(batch, check(name))
+	}
+
+	if status, err = agentPost("/ws/report/batch", testTokens[0], batch, &reply); err != nil {
+		t.Fatalf("Cannot send batch of checks: %s", err.Error())
+	} else if status != http.StatusOK || !reply.Status {
+		t.Fatalf("Server did not accept batch of checks: %d - %s", status, reply.Message)
+	} else if len(reply.Results) != len(batch) {
+		t.Fatalf("Expected %d results, got %d", len(batch), len(reply.Results))
+	}
+
+	for i, r := range reply.Results {
+		if r.Status != (i < len(batch)-1) {
+			t.Errorf("Unexpected status for check #%d: %t - %s",
+				i,
+				r.Status,
+				r.Message)
+		}
+	}
+
+	if status, err = agentPost("/ws/report", testTokens[0], check("swap"), &res); err != nil {
+		t.Fatalf("Cannot send check: %s", err.Error())
+	} else if status != http.StatusOK || !res.Status {
+		t.Fatalf("Server did not accept check: %d - %s", status, res.Message)
+	}
+
+	db = srv.pool.Get()
+	defer srv.pool.Put(db)
+
+	if recs, err = db.RecordGetByHostType(h, recordtype.Check); err != nil {
+		t.Fatalf("Cannot load checks of Host %s: %s", h.Name, err.Error())
+	}
+
+	for _, r := range recs {
+		var v any
+
+		if !r.Timestamp.Equal(stamp) {
+			continue
+		} else if v, err = r.Decode(); err != nil {
+			t.Fatalf("Cannot decode Record %d: %s", r.ID, err.Error())
+		}
+
+		names = append(names, v.(*model.Check).Name)
+	}
+
+	sort.Strings(names)
+
+	if !reflect.DeepEqual(names, expect) {
+		t.Errorf("Expected checks %v to be stored, got %v", expect, names)
+	}
+} // func TestReportChecks(t *testing.T)
+
+func TestReportInvalid(t *testing.T) {
+	const path = "/ws/report"
+
+	if srv == nil {
+		t.SkipNow()
+	}
+
+	type testCase struct {
+		src     recordtype.ID
+		payload string
+	}
+
+	var (
+		err    error
+		client http.Client
+		addr   = fmt.Sprintf("http://%s%s",
+			testAddr,
+			path)
+		tests = []testCase{
+			{src: recordtype.LoadAvg, payload: "garbage"},
+			{src: recordtype.LoadAvg, payload: `{"load": 1}`},
+			{src: recordtype.LoadAvg, payload: "[-1, 0.5, 0.25]"},
+			{src: recordtype.Sensors, payload: "{}"},
+			{src: recordtype.ID(250), payload: "[]"},
+		}
+	)
+
+	for i, c := range tests {
+		var (
+			req        *http.Request
+			res        *http.Response
+			reply      model.Response
+			serialized []byte
+			buf        *bytes.Buffer
+			rec        = model.Record{
+				HostID:    int64(testHosts[0].ID),
+				Timestamp: time.Now().Add(time.Minute * time.Duration(i+1)),
+				Source:    c.src,
+				Payload:   c.payload,
+			}
+		)
+
+		if serialized, err = json.Marshal(&rec); err != nil {
+			t.Errorf("Failed to serialize Record: %s", err.Error())
+			continue
+		}
+
+		buf = bytes.NewBuffer(serialized)
+
+		if req, err = http.NewRequest("POST", addr, buf); err != nil {
+			t.Errorf("Failed to create HTTP request: %s", err.Error())
+			continue
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testTokens[0])
+
+		if res, err = client.Do(req); err != nil {
+			t.Errorf("Failed to perform HTTP request for %s: %s",
+				addr,
+				err.Error())
+			continue
+		}
+
+		buf.Reset()
+		_, err = io.Copy(buf, res.Body)
+		res.Body.Close()
+
+		if err != nil {
+			t.Errorf("Failed to read response body from Server: %s",
+				err.Error())
+		} else if err = json.Unmarshal(buf.Bytes(), &reply); err != nil {
+			t.Errorf("Failed to unmarshal response body: %s\n\n%s\n",
+				err.Error(),
+				buf.String())
+		} else if reply.Status {
+			t.Errorf("Server accepted invalid payload %q for type %d",
+				c.payload,
+				c.src)
+		}
+	}
+} // func TestReportInvalid(t *testing.T)
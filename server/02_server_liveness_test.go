@@ -0,0 +1,168 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/02_server_liveness_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 21:41:07 krylon>
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/blicero/donkey/database"
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/hoststate"
+)
+
+// TestHostLiveness relies on TestReportData having submitted a Record for
+// each test Host.
+func TestHostLiveness(t *testing.T) {
+	if srv == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err    error
+		db     *database.Database
+		host   *model.Host
+		events []model.HostEvent
+		live   = srv.getLiveness()
+		id     = testHosts[0].ID
+	)
+
+	db = srv.pool.Get()
+	defer srv.pool.Put(db)
+
+	if host, err = db.HostGetByID(id); err != nil {
+		t.Fatalf("Cannot look up Host %d: %s", id, err.Error())
+	} else if host == nil {
+		t.Fatalf("Host %d was not found in database", id)
+	} else if time.Since(host.LastContact) > time.Minute {
+		t.Fatalf("Last contact of Host %s was not updated: %s",
+			host.Name,
+			host.LastContact)
+	} else if host.State != hoststate.Up {
+		t.Fatalf("Host %s should be %s, but is %s",
+			host.Name,
+			hoststate.Up,
+			host.State)
+	}
+
+	var steps = []struct {
+		silence time.Duration
+		expect  hoststate.State
+	}{
+		{silence: live.interval, expect: hoststate.Up},
+		{silence: live.interval * time.Duration(live.staleAfter), expect: hoststate.Stale},
+		{silence: live.interval * time.Duration(live.downAfter), expect: hoststate.Down},
+	}
+
+	for _, s := range steps {
+		srv.checkHosts(host.LastContact.Add(s.silence))
+
+		if host, err = db.HostGetByID(id); err != nil {
+			t.Fatalf("Cannot look up Host %d: %s", id, err.Error())
+		} else if host.State != s.expect {
+			t.Errorf("After %s of silence, Host %s should be %s, but is %s",
+				s.silence,
+				host.Name,
+				s.expect,
+				host.State)
+		}
+	}
+
+	if events, err = db.HostEventGetByHost(id, 10); err != nil {
+		t.Fatalf("Cannot load events for Host %d: %s", id, err.Error())
+	} else if len(events) != 3 {
+		t.Fatalf("Expected 3 events for Host %d, got %d: %v",
+			id,
+			len(events),
+			events)
+	}
+
+	var expect = [][2]hoststate.State{
+		{hoststate.Stale, hoststate.Down},
+		{hoststate.Up, hoststate.Stale},
+		{hoststate.Unknown, hoststate.Up},
+	}
+
+	for i, ev := range events {
+		if ev.Previous != expect[i][0] || ev.State != expect[i][1] {
+			t.Errorf("Event #%d should be %s -> %s, but is %s -> %s",
+				i,
+				expect[i][0],
+				expect[i][1],
+				ev.Previous,
+				ev.State)
+		}
+	}
+} // func TestHostLiveness(t *testing.T)
+
+// TestHostLivenessInterval checks that a Host whose Agent reported a long
+// contact interval is given more time before it is considered stale.
+func TestHostLivenessInterval(t *testing.T) {
+	if srv == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err      error
+		db       *database.Database
+		host     *model.Host
+		status   int
+		reply    model.ConfigResponse
+		live     = srv.getLiveness()
+		id       = testHosts[1].ID
+		interval = live.interval * 10
+	)
+
+	if status, err = agentGet(fmt.Sprintf("/ws/config/%d?interval=%d", id, int64(interval.Seconds())),
+		testTokens[1],
+		&reply); err != nil {
+		t.Fatalf("Cannot get configuration: %s", err.Error())
+	} else if status != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, status, reply.Message)
+	}
+
+	db = srv.pool.Get()
+	defer srv.pool.Put(db)
+
+	if host, err = db.HostGetByID(id); err != nil {
+		t.Fatalf("Cannot look up Host %d: %s", id, err.Error())
+	} else if host.Interval != interval {
+		t.Fatalf("Contact interval of Host %s should be %s, but is %s",
+			host.Name,
+			interval,
+			host.Interval)
+	} else if host.State != hoststate.Up {
+		t.Fatalf("Host %s should be %s, but is %s",
+			host.Name,
+			hoststate.Up,
+			host.State)
+	}
+
+	var steps = []struct {
+		silence time.Duration
+		expect  hoststate.State
+	}{
+		{silence: live.interval * time.Duration(live.staleAfter), expect: hoststate.Up},
+		{silence: interval * time.Duration(live.staleAfter), expect: hoststate.Stale},
+	}
+
+	for _, s := range steps {
+		srv.checkHosts(host.LastContact.Add(s.silence))
+
+		if host, err = db.HostGetByID(id); err != nil {
+			t.Fatalf("Cannot look up Host %d: %s", id, err.Error())
+		} else if host.State != s.expect {
+			t.Errorf("After %s of silence, Host %s should be %s, but is %s",
+				s.silence,
+				host.Name,
+				s.expect,
+				host.State)
+		}
+	}
+} // func TestHostLivenessInterval(t *testing.T)
@@ -0,0 +1,224 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/03_server_alert_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 23:02:55 krylon>
+
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blicero/donkey/common"
+	"github.com/blicero/donkey/database"
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/alertstate"
+	"github.com/blicero/donkey/model/recordtype"
+)
+
+func TestAlertConfig(t *testing.T) {
+	type testCase struct {
+		name        string
+		content     string
+		rules       int
+		expectError bool
+	}
+
+	var tests = []testCase{
+		{
+			name: "valid",
+			content: `{ "Rules": [
+  { "Name": "load", "Metric": "load1", "Op": ">", "Threshold": 8, "For": "5m", "Hosts": "^web", "Hysteresis": 1 },
+  { "Name": "hot", "Metric": "sensor.temp", "Op": ">=", "Limit": "crit", "For": 30 }
+] }`,
+			rules: 2,
+		},
+		{
+			name:        "operator",
+			content:     `{ "Rules": [ { "Name": "load", "Metric": "load1", "Op": "=", "Threshold": 8 } ] }`,
+			expectError: true,
+		},
+		{
+			name:        "duplicate",
+			content:     `{ "Rules": [ { "Name": "a", "Metric": "load1", "Op": ">" }, { "Name": "a", "Metric": "load5", "Op": ">" } ] }`,
+			expectError: true,
+		},
+		{
+			name:        "pattern",
+			content:     `{ "Rules": [ { "Name": "a", "Metric": "load1", "Op": ">", "Hosts": "(" } ] }`,
+			expectError: true,
+		},
+	}
+
+	for _, c := range tests {
+		var (
+			err  error
+			cfg  *alertConfig
+			path = filepath.Join(common.BaseDir, fmt.Sprintf("alerts_%s.json", c.name))
+		)
+
+		if err = os.WriteFile(path, []byte(c.content), 0600); err != nil {
+			t.Fatalf("Cannot write %s: %s", path, err.Error())
+		} else if cfg, err = readAlertConfig(path); err != nil {
+			if !c.expectError {
+				t.Errorf("[%s] Unexpected error: %s", c.name, err.Error())
+			}
+		} else if c.expectError {
+			t.Errorf("[%s] Expected an error, but got none", c.name)
+		} else if len(cfg.Rules) != c.rules {
+			t.Errorf("[%s] Expected %d rules, got %d", c.name, c.rules, len(cfg.Rules))
+		}
+	}
+
+	var (
+		err error
+		cfg *alertConfig
+	)
+
+	if cfg, err = readAlertConfig(filepath.Join(common.BaseDir, "does_not_exist.json")); err != nil {
+		t.Errorf("Missing configuration file should not be an error: %s", err.Error())
+	} else if len(cfg.Rules) != 0 {
+		t.Errorf("Missing configuration file should yield no rules, got %d", len(cfg.Rules))
+	}
+} // func TestAlertConfig(t *testing.T)
+
+func TestAlertEngine(t *testing.T) {
+	var (
+		err   error
+		db    *database.Database
+		eng   *alertEngine
+		host  = &testHosts[1]
+		stamp = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+		rules = []*alertRule{
+			{
+				Name:       "load",
+				Metric:     "load1",
+				Hosts:      "^" + host.Name + "$",
+				Op:         ">",
+				Threshold:  8,
+				For:        duration(time.Minute * 5),
+				Hysteresis: 1,
+			},
+			{
+				Name:   "hot",
+				Metric: "sensor.temp",
+				Op:     ">=",
+				Limit:  "crit",
+			},
+		}
+	)
+
+	for _, r := range rules {
+		if err = r.compile(); err != nil {
+			t.Fatalf("Cannot compile rule %s: %s", r.Name, err.Error())
+		}
+	}
+
+	if eng, err = newAlertEngine(rules); err != nil {
+		t.Fatalf("Cannot create alerting engine: %s", err.Error())
+	} else if db, err = database.Open(common.DbPath); err != nil {
+		t.Fatalf("Cannot open database: %s", err.Error())
+	}
+
+	defer db.Close() // nolint: errcheck
+
+	var steps = []struct {
+		offset  time.Duration
+		source  recordtype.ID
+		payload string
+		changed int
+		state   alertstate.State
+		active  int
+	}{
+		// A pending Alert that clears before it fires is dropped.
+		{offset: 0, source: recordtype.LoadAvg, payload: "[9, 1, 1]", active: 1, state: alertstate.Pending},
+		{offset: time.Minute, source: recordtype.LoadAvg, payload: "[7, 1, 1]", active: 0},
+		{offset: time.Minute * 2, source: recordtype.LoadAvg, payload: "[9, 1, 1]", active: 1, state: alertstate.Pending},
+		{offset: time.Minute * 5, source: recordtype.LoadAvg, payload: "[9, 1, 1]", active: 1, state: alertstate.Pending},
+		{offset: time.Minute * 7, source: recordtype.LoadAvg, payload: "[9, 1, 1]", active: 1, changed: 1, state: alertstate.Firing},
+		// Within the hysteresis, the Alert keeps firing.
+		{offset: time.Minute * 8, source: recordtype.LoadAvg, payload: "[7.5, 1, 1]", active: 1, state: alertstate.Firing},
+		{offset: time.Minute * 9, source: recordtype.LoadAvg, payload: "[6.5, 1, 1]", active: 0, changed: 1, state: alertstate.Resolved},
+		// Rules without a duration fire immediately.
+		{
+			offset:  time.Minute * 10,
+			source:  recordtype.Sensors,
+			payload: `{"Readings": [{"Chip": "coretemp", "Label": "Core 0", "Kind": "temp", "Value": 101, "Crit": 100}, {"Chip": "coretemp", "Label": "Core 1", "Kind": "temp", "Value": 60}]}`,
+			active:  1,
+			changed: 1,
+			state:   alertstate.Firing,
+		},
+	}
+
+	for i, s := range steps {
+		var (
+			changed []model.Alert
+			active  []model.Alert
+			rec     = &model.Record{
+				HostID:    int64(host.ID),
+				Timestamp: stamp.Add(s.offset),
+				Source:    s.source,
+				Payload:   s.payload,
+			}
+		)
+
+		if changed, err = eng.Process(db, host, rec); err != nil {
+			t.Fatalf("Step %d: Failed to process Record: %s", i, err.Error())
+		} else if len(changed) != s.changed {
+			t.Errorf("Step %d: Expected %d changed Alerts, got %d: %v",
+				i,
+				s.changed,
+				len(changed),
+				changed)
+		} else if s.changed > 0 && changed[0].State != s.state {
+			t.Errorf("Step %d: Alert should be %s, but is %s",
+				i,
+				s.state,
+				changed[0].State)
+		}
+
+		if active = eng.Active(); len(active) != s.active {
+			t.Errorf("Step %d: Expected %d active Alerts, got %d: %v",
+				i,
+				s.active,
+				len(active),
+				active)
+		} else if s.active > 0 && active[0].State != s.state {
+			t.Errorf("Step %d: Active Alert should be %s, but is %s",
+				i,
+				s.state,
+				active[0].State)
+		}
+	}
+
+	// A new engine must pick up the firing Alert from the database.
+	var restored *alertEngine
+
+	if restored, err = newAlertEngine(rules); err != nil {
+		t.Fatalf("Cannot create alerting engine: %s", err.Error())
+	} else if err = restored.restore(db); err != nil {
+		t.Fatalf("Cannot restore Alerts: %s", err.Error())
+	}
+
+	var active = restored.Active()
+
+	if len(active) != 1 {
+		t.Fatalf("Expected 1 restored Alert, got %d: %v", len(active), active)
+	} else if active[0].Rule != "hot" || active[0].Instance != "coretemp/Core 0" || active[0].State != alertstate.Firing {
+		t.Errorf("Unexpected restored Alert: %+v", active[0])
+	}
+
+	var recent []model.Alert
+
+	if recent, err = db.AlertGetRecent(10); err != nil {
+		t.Fatalf("Cannot load recent Alerts: %s", err.Error())
+	} else if len(recent) != 2 {
+		t.Errorf("Expected 2 Alerts in database, got %d: %v", len(recent), recent)
+	} else if recent[1].State != alertstate.Resolved || recent[1].Fired.IsZero() || recent[1].Resolved.IsZero() {
+		t.Errorf("Unexpected resolved Alert: %+v", recent[1])
+	}
+} // func TestAlertEngine(t *testing.T)
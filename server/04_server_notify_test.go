@@ -0,0 +1,465 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/04_server_notify_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 00:12:40 krylon>
+
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blicero/donkey/common"
+	"github.com/blicero/donkey/database"
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/alertstate"
+)
+
+func makeTestNotification(channel string) *model.Notification {
+	return &model.Notification{
+		Channel:   channel,
+		Timestamp: time.Now(),
+		Alerts: []model.AlertNotice{
+			{
+				Alert: model.Alert{
+					ID:        42,
+					Rule:      "load",
+					HostID:    testHosts[0].ID,
+					State:     alertstate.Firing,
+					Value:     9.5,
+					Threshold: 8,
+				},
+				HostName: testHosts[0].Name,
+			},
+		},
+	}
+} // func makeTestNotification(channel string) *model.Notification
+
+func TestNotifyWebhook(t *testing.T) {
+	var (
+		err      error
+		ch       notifyChannel
+		received model.Notification
+		header   string
+		hook     = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header.Get("X-Token")
+			if e := json.NewDecoder(r.Body).Decode(&received); e != nil {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		}))
+	)
+
+	defer hook.Close()
+
+	var cfg = &channelConfig{
+		Name:    "hook",
+		Type:    "webhook",
+		URL:     hook.URL,
+		Headers: map[string]string{"X-Token": "geheim"},
+	}
+
+	if ch, err = createChannel(cfg); err != nil {
+		t.Fatalf("Cannot create webhook channel: %s", err.Error())
+	} else if err = ch.Send(context.Background(), makeTestNotification(cfg.Name)); err != nil {
+		t.Fatalf("Failed to send Notification: %s", err.Error())
+	} else if header != "geheim" {
+		t.Errorf("Webhook did not receive custom header, got %q", header)
+	} else if len(received.Alerts) != 1 || received.Alerts[0].ID != 42 {
+		t.Errorf("Webhook received unexpected Notification: %+v", received)
+	}
+} // func TestNotifyWebhook(t *testing.T)
+
+func TestNotifyExec(t *testing.T) {
+	var (
+		err      error
+		ch       notifyChannel
+		buf      []byte
+		received model.Notification
+		path     = filepath.Join(common.BaseDir, "notify_exec.json")
+		cfg      = &channelConfig{
+			Name:    "script",
+			Type:    "exec",
+			Command: "/bin/sh",
+			Args:    []string{"-c", "cat > " + path},
+		}
+	)
+
+	if ch, err = createChannel(cfg); err != nil {
+		t.Fatalf("Cannot create exec channel: %s", err.Error())
+	} else if err = ch.Send(context.Background(), makeTestNotification(cfg.Name)); err != nil {
+		t.Fatalf("Failed to send Notification: %s", err.Error())
+	} else if buf, err = os.ReadFile(path); err != nil {
+		t.Fatalf("Command did not write %s: %s", path, err.Error())
+	} else if err = json.Unmarshal(buf, &received); err != nil {
+		t.Fatalf("Cannot parse Notification written by command: %s\n%s", err.Error(), buf)
+	} else if len(received.Alerts) != 1 || received.Alerts[0].HostName != testHosts[0].Name {
+		t.Errorf("Command received unexpected Notification: %+v", received)
+	}
+
+	cfg = &channelConfig{
+		Name:    "fail",
+		Type:    "exec",
+		Command: "/bin/false",
+	}
+
+	if ch, err = createChannel(cfg); err != nil {
+		t.Fatalf("Cannot create exec channel: %s", err.Error())
+	} else if err = ch.Send(context.Background(), makeTestNotification(cfg.Name)); err == nil {
+		t.Error("A failing command should result in an error")
+	}
+} // func TestNotifyExec(t *testing.T)
+
+// fakeSMTP is a minimal SMTP server that accepts a single message.
+type fakeSMTP struct {
+	l    net.Listener
+	lock sync.Mutex
+	rcpt []string
+	data string
+	done chan struct{}
+}
+
+func startFakeSMTP(t *testing.T) *fakeSMTP {
+	var (
+		err error
+		s   = &fakeSMTP{done: make(chan struct{})}
+	)
+
+	if s.l, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+		t.Fatalf("Cannot listen for SMTP: %s", err.Error())
+	}
+
+	go s.serve()
+
+	return s
+} // func startFakeSMTP(t *testing.T) *fakeSMTP
+
+func (s *fakeSMTP) serve() {
+	var (
+		err  error
+		conn net.Conn
+	)
+
+	defer close(s.done)
+
+	if conn, err = s.l.Accept(); err != nil {
+		return
+	}
+
+	defer conn.Close() // nolint: errcheck
+
+	var (
+		r      = bufio.NewReader(conn)
+		inData bool
+		data   strings.Builder
+	)
+
+	io.WriteString(conn, "220 localhost fake ESMTP\r\n") // nolint: errcheck
+
+	for {
+		var line string
+
+		if line, err = r.ReadString('\n'); err != nil {
+			return
+		}
+
+		if inData {
+			if line == ".\r\n" {
+				inData = false
+				s.lock.Lock()
+				s.data = data.String()
+				s.lock.Unlock()
+				io.WriteString(conn, "250 OK\r\n") // nolint: errcheck
+			} else {
+				data.WriteString(line)
+			}
+			continue
+		}
+
+		var cmd = strings.ToUpper(strings.TrimSpace(line))
+
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			io.WriteString(conn, "250 localhost\r\n") // nolint: errcheck
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			s.lock.Lock()
+			s.rcpt = append(s.rcpt, strings.TrimSpace(line[8:]))
+			s.lock.Unlock()
+			io.WriteString(conn, "250 OK\r\n") // nolint: errcheck
+		case cmd == "DATA":
+			inData = true
+			io.WriteString(conn, "354 Go ahead\r\n") // nolint: errcheck
+		case cmd == "QUIT":
+			io.WriteString(conn, "221 Bye\r\n") // nolint: errcheck
+			return
+		default:
+			io.WriteString(conn, "250 OK\r\n") // nolint: errcheck
+		}
+	}
+} // func (s *fakeSMTP) serve()
+
+func TestNotifySMTP(t *testing.T) {
+	var (
+		err  error
+		ch   notifyChannel
+		smtp = startFakeSMTP(t)
+		cfg  = &channelConfig{
+			Name:   "mail",
+			Type:   "smtp",
+			Server: smtp.l.Addr().String(),
+			From:   "donkey@example.com",
+			To:     []string{"ops@example.com", "admin@example.com"},
+		}
+	)
+
+	defer smtp.l.Close() // nolint: errcheck
+
+	if ch, err = createChannel(cfg); err != nil {
+		t.Fatalf("Cannot create smtp channel: %s", err.Error())
+	} else if err = ch.Send(context.Background(), makeTestNotification(cfg.Name)); err != nil {
+		t.Fatalf("Failed to send Notification: %s", err.Error())
+	}
+
+	<-smtp.done
+
+	smtp.lock.Lock()
+	defer smtp.lock.Unlock()
+
+	if len(smtp.rcpt) != 2 {
+		t.Errorf("Expected 2 recipients, got %v", smtp.rcpt)
+	} else if !strings.Contains(smtp.data, "Subject: [Donkey] load on "+testHosts[0].Name+" is Firing") {
+		t.Errorf("Unexpected message:\n%s", smtp.data)
+	}
+} // func TestNotifySMTP(t *testing.T)
+
+// recordingChannel remembers the Notifications it was asked to send.
+type recordingChannel struct {
+	lock  sync.Mutex
+	sent  []*model.Notification
+	times []time.Time
+}
+
+func (c *recordingChannel) Send(_ context.Context, n *model.Notification) error {
+	c.lock.Lock()
+	c.sent = append(c.sent, n)
+	c.times = append(c.times, time.Now())
+	c.lock.Unlock()
+	return nil
+} // func (c *recordingChannel) Send(_ context.Context, n *model.Notification) error
+
+func TestNotifyGrouping(t *testing.T) {
+	const (
+		groupWait = time.Millisecond * 100
+		rateLimit = time.Millisecond * 500
+	)
+
+	var (
+		err      error
+		n        *notifier
+		pool     *database.Pool
+		db       *database.Database
+		list     []model.Delivery
+		rec      = new(recordingChannel)
+		channels = []*channelConfig{
+			{
+				Name:      "grouped",
+				Type:      "exec",
+				Command:   "/bin/true",
+				GroupWait: duration(groupWait),
+				RateLimit: duration(rateLimit),
+			},
+		}
+		notice = makeTestNotification("grouped").Alerts[0]
+	)
+
+	if pool, err = database.NewPool(1); err != nil {
+		t.Fatalf("Cannot create database pool: %s", err.Error())
+	} else if n, err = newNotifier(pool, channels, map[string][]string{"load": {"grouped"}}); err != nil {
+		t.Fatalf("Cannot create notifier: %s", err.Error())
+	}
+
+	n.queues["grouped"].ch = rec
+
+	var t0 = time.Now()
+
+	// Two Alerts that arrive in quick succession are delivered together.
+	n.Enqueue(notice)
+	notice.ID++
+	n.Enqueue(notice)
+	time.Sleep(groupWait * 2)
+
+	// The next one has to wait for the rate limit.
+	notice.ID++
+	n.Enqueue(notice)
+	time.Sleep(rateLimit + groupWait)
+
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+
+	if len(rec.sent) != 2 {
+		t.Fatalf("Expected 2 Notifications, got %d", len(rec.sent))
+	} else if len(rec.sent[0].Alerts) != 2 || len(rec.sent[1].Alerts) != 1 {
+		t.Errorf("Unexpected grouping: %d, %d Alerts",
+			len(rec.sent[0].Alerts),
+			len(rec.sent[1].Alerts))
+	} else if d := rec.times[0].Sub(t0); d < groupWait {
+		t.Errorf("First Notification was sent after %s, before the group wait of %s", d, groupWait)
+	} else if d = rec.times[1].Sub(rec.times[0]); d < rateLimit {
+		t.Errorf("Second Notification was sent %s after the first, rate limit is %s", d, rateLimit)
+	}
+
+	db = pool.Get()
+	defer pool.Put(db)
+
+	if list, err = db.DeliveryGetRecent(10); err != nil {
+		t.Fatalf("Cannot load deliveries: %s", err.Error())
+	} else if len(list) < 2 {
+		t.Fatalf("Expected at least 2 deliveries in database, got %d", len(list))
+	} else if !list[0].Success || len(list[0].AlertIDs) != 1 || len(list[1].AlertIDs) != 2 {
+		t.Errorf("Unexpected deliveries: %+v", list[:2])
+	}
+} // func TestNotifyGrouping(t *testing.T)
+
+// flakyChannel fails the first failures attempts to send a Notification.
+type flakyChannel struct {
+	recordingChannel
+	failures int
+	attempts int
+}
+
+func (c *flakyChannel) Send(ctx context.Context, n *model.Notification) error {
+	c.lock.Lock()
+	c.attempts++
+	var fail = c.attempts <= c.failures
+	c.lock.Unlock()
+
+	if fail {
+		return errors.New("Channel is flaky")
+	}
+
+	return c.recordingChannel.Send(ctx, n)
+} // func (c *flakyChannel) Send(ctx context.Context, n *model.Notification) error
+
+func TestNotifyRetry(t *testing.T) {
+	const (
+		groupWait = time.Millisecond * 20
+		retry     = time.Millisecond * 20
+	)
+
+	type testCase struct {
+		failures int
+		attempts int
+		sent     int
+	}
+
+	var cases = []testCase{
+		{failures: 2, attempts: 3, sent: 1},
+		{failures: maxNotifyAttempts * 2, attempts: maxNotifyAttempts, sent: 0},
+	}
+
+	for i, c := range cases {
+		var (
+			err      error
+			n        *notifier
+			pool     *database.Pool
+			ch       = &flakyChannel{failures: c.failures}
+			channels = []*channelConfig{
+				{
+					Name:      "flaky",
+					Type:      "exec",
+					Command:   "/bin/true",
+					GroupWait: duration(groupWait),
+					RateLimit: duration(time.Hour),
+				},
+			}
+			notice = makeTestNotification("flaky").Alerts[0]
+		)
+
+		if pool, err = database.NewPool(1); err != nil {
+			t.Fatalf("Cannot create database pool: %s", err.Error())
+		} else if n, err = newNotifier(pool, channels, nil); err != nil {
+			t.Fatalf("Cannot create notifier: %s", err.Error())
+		}
+
+		n.retry = retry
+		n.queues["flaky"].ch = ch
+
+		n.Enqueue(notice)
+
+		// The retries are 20, 40, 80 and 160ms apart.
+		time.Sleep(groupWait + retry*(1<<maxNotifyAttempts))
+		n.stop()
+
+		ch.lock.Lock()
+		if ch.attempts != c.attempts {
+			t.Errorf("Test case #%d: Expected %d attempts, got %d",
+				i,
+				c.attempts,
+				ch.attempts)
+		} else if len(ch.sent) != c.sent {
+			t.Errorf("Test case #%d: Expected %d Notifications, got %d",
+				i,
+				c.sent,
+				len(ch.sent))
+		} else if c.sent > 0 && len(ch.sent[0].Alerts) != 1 {
+			t.Errorf("Test case #%d: Expected 1 Alert, got %d",
+				i,
+				len(ch.sent[0].Alerts))
+		}
+		ch.lock.Unlock()
+	}
+} // func TestNotifyRetry(t *testing.T)
+
+func TestNotifyStop(t *testing.T) {
+	const groupWait = time.Millisecond * 50
+
+	var (
+		err      error
+		n        *notifier
+		pool     *database.Pool
+		rec      = new(recordingChannel)
+		channels = []*channelConfig{
+			{
+				Name:      "stopped",
+				Type:      "exec",
+				Command:   "/bin/true",
+				GroupWait: duration(groupWait),
+			},
+		}
+		notice = makeTestNotification("stopped").Alerts[0]
+	)
+
+	if pool, err = database.NewPool(1); err != nil {
+		t.Fatalf("Cannot create database pool: %s", err.Error())
+	} else if n, err = newNotifier(pool, channels, nil); err != nil {
+		t.Fatalf("Cannot create notifier: %s", err.Error())
+	}
+
+	n.queues["stopped"].ch = rec
+
+	n.Enqueue(notice)
+	n.stop()
+	n.Enqueue(notice)
+	time.Sleep(groupWait * 3)
+
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+
+	if len(rec.sent) != 0 {
+		t.Errorf("Stopped notifier delivered %d Notifications", len(rec.sent))
+	} else if q := n.queues["stopped"]; q.timer != nil || len(q.pending) != 0 {
+		t.Errorf("Stopped notifier still has %d Alerts pending", len(q.pending))
+	}
+} // func TestNotifyStop(t *testing.T)
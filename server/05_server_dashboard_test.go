@@ -0,0 +1,80 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/05_server_dashboard_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 22:31:12 krylon>
+
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/blicero/donkey/database"
+)
+
+// TestDashboard relies on TestReportData having submitted a load average
+// for each test Host.
+func TestDashboard(t *testing.T) {
+	if srv == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err  error
+		res  *http.Response
+		body []byte
+		addr = fmt.Sprintf("http://%s/index", testAddr)
+	)
+
+	if res, err = http.Get(addr); err != nil {
+		t.Fatalf("Failed to GET %s: %s", addr, err.Error())
+	}
+
+	defer res.Body.Close() // nolint: errcheck
+
+	if res.StatusCode != 200 {
+		t.Fatalf("Unexpected HTTP status from %s: %s",
+			addr,
+			res.Status)
+	} else if body, err = io.ReadAll(res.Body); err != nil {
+		t.Fatalf("Failed to read response body: %s", err.Error())
+	}
+
+	var page = string(body)
+
+	for _, h := range testHosts {
+		if !strings.Contains(page, h.Name) {
+			t.Errorf("Host %s is missing from the dashboard", h.Name)
+		} else if !strings.Contains(page, h.OS) {
+			t.Errorf("OS of Host %s (%s) is missing from the dashboard",
+				h.Name,
+				h.OS)
+		}
+	}
+
+	var (
+		db      *database.Database
+		summary []hostSummary
+	)
+
+	db = srv.pool.Get()
+	defer srv.pool.Put(db)
+
+	if summary, err = srv.summarizeHosts(db); err != nil {
+		t.Fatalf("Cannot summarize Hosts: %s", err.Error())
+	}
+
+	for _, s := range summary {
+		if s.Load == nil {
+			t.Errorf("Host %s should have a load average", s.Name)
+		} else if l := formatFloat(s.Load.Load[2]); !strings.Contains(page, l) {
+			t.Errorf("Load average %s of Host %s is missing from the dashboard",
+				l,
+				s.Name)
+		}
+	}
+} // func TestDashboard(t *testing.T)
@@ -0,0 +1,228 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/06_server_host_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 23:24:18 krylon>
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blicero/donkey/model/recordtype"
+	"github.com/blicero/donkey/model/resolution"
+)
+
+func TestParseRange(t *testing.T) {
+	type testCase struct {
+		s           string
+		d           time.Duration
+		expectError bool
+	}
+
+	var tests = []testCase{
+		{s: "", d: defaultChartRange},
+		{s: "90m", d: time.Minute * 90},
+		{s: "7d", d: time.Hour * 24 * 7},
+		{s: "0s", expectError: true},
+		{s: "-1h", expectError: true},
+		{s: "xd", expectError: true},
+		{s: "yesterday", expectError: true},
+	}
+
+	for _, c := range tests {
+		var (
+			err error
+			d   time.Duration
+		)
+
+		if d, err = parseRange(c.s); err != nil {
+			if !c.expectError {
+				t.Errorf("Unexpected error parsing range %q: %s",
+					c.s,
+					err.Error())
+			}
+		} else if c.expectError {
+			t.Errorf("Parsing range %q should have failed, got %s",
+				c.s,
+				d)
+		} else if d != c.d {
+			t.Errorf("Range %q should be %s, got %s",
+				c.s,
+				c.d,
+				d)
+		}
+	}
+} // func TestParseRange(t *testing.T)
+
+// TestHostDetails relies on TestReportData having submitted a load average
+// for each test Host, and TestReportChecks having submitted several checks
+// for the first one.
+func TestHostDetails(t *testing.T) {
+	if srv == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err  error
+		res  *http.Response
+		body []byte
+		h    = testHosts[0]
+		addr = fmt.Sprintf("http://%s/host/%d", testAddr, h.ID)
+	)
+
+	if res, err = http.Get(addr); err != nil {
+		t.Fatalf("Failed to GET %s: %s", addr, err.Error())
+	}
+
+	defer res.Body.Close() // nolint: errcheck
+
+	if res.StatusCode != 200 {
+		t.Fatalf("Unexpected HTTP status from %s: %s",
+			addr,
+			res.Status)
+	} else if body, err = io.ReadAll(res.Body); err != nil {
+		t.Fatalf("Failed to read response body: %s", err.Error())
+	}
+
+	var (
+		page   = string(body)
+		chart  = fmt.Sprintf("chart_%d", recordtype.LoadAvg)
+		checks = fmt.Sprintf(`id="chart_%d"`, recordtype.Check)
+	)
+
+	if !strings.Contains(page, h.Name) {
+		t.Errorf("Host name %s is missing from the page", h.Name)
+	} else if !strings.Contains(page, chart) {
+		t.Errorf("Chart %s is missing from the page", chart)
+	} else if n := strings.Count(page, checks); n != 1 {
+		t.Errorf("Expected one chart for all checks, got %d", n)
+	}
+} // func TestHostDetails(t *testing.T)
+
+func TestHostChart(t *testing.T) {
+	if srv == nil {
+		t.SkipNow()
+	}
+
+	type testCase struct {
+		path        string
+		series      []string
+		expectError bool
+	}
+
+	var (
+		id    = testHosts[0].ID
+		tests = []testCase{
+			{
+				path:   fmt.Sprintf("/ajax/host/%d/chart/%d?range=1h", id, recordtype.LoadAvg),
+				series: []string{"load1", "load5", "load15"},
+			},
+			{
+				path:   fmt.Sprintf("/ajax/host/%d/chart/%d", id, recordtype.LoadAvg),
+				series: []string{"load1", "load5", "load15"},
+			},
+			{
+				path:        fmt.Sprintf("/ajax/host/%d/chart/%d?range=never", id, recordtype.LoadAvg),
+				expectError: true,
+			},
+			{
+				path:        fmt.Sprintf("/ajax/host/%d/chart/%d", 4711, recordtype.LoadAvg),
+				expectError: true,
+			},
+			{
+				path:        fmt.Sprintf("/ajax/host/%d/chart/%d", id, 200),
+				expectError: true,
+			},
+		}
+	)
+
+	for _, c := range tests {
+		var (
+			err   error
+			res   *http.Response
+			reply chartData
+			addr  = fmt.Sprintf("http://%s%s", testAddr, c.path)
+		)
+
+		if res, err = http.Get(addr); err != nil {
+			t.Errorf("Failed to GET %s: %s", addr, err.Error())
+			continue
+		}
+
+		err = json.NewDecoder(res.Body).Decode(&reply)
+		res.Body.Close() // nolint: errcheck,gosec
+
+		if err != nil {
+			t.Errorf("Cannot decode reply from %s: %s", c.path, err.Error())
+		} else if reply.Status == c.expectError {
+			t.Errorf("Unexpected Status from %s: %t (%s)",
+				c.path,
+				reply.Status,
+				reply.Message)
+		} else if c.expectError {
+			continue
+		} else if len(reply.Series) != len(c.series) {
+			t.Errorf("Expected %d series from %s, got %d",
+				len(c.series),
+				c.path,
+				len(reply.Series))
+		} else {
+			for i, s := range reply.Series {
+				if s.Name != c.series[i] {
+					t.Errorf("Series #%d from %s should be %s, not %s",
+						i,
+						c.path,
+						c.series[i],
+						s.Name)
+				} else if len(s.Points) == 0 {
+					t.Errorf("Series %s from %s has no data", s.Name, c.path)
+				}
+			}
+		}
+	}
+} // func TestHostChart(t *testing.T)
+
+// TestHostChartRollup checks that charts for long time ranges are drawn from
+// rollups.
+func TestHostChartRollup(t *testing.T) {
+	if srv == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err   error
+		res   *http.Response
+		reply chartData
+		addr  = fmt.Sprintf("http://%s/ajax/host/%d/chart/%d?range=30d",
+			testAddr,
+			testHosts[0].ID,
+			recordtype.LoadAvg)
+	)
+
+	if cnt := srv.rollup(); cnt == 0 {
+		t.Fatalf("No Records were aggregated into rollups")
+	} else if res, err = http.Get(addr); err != nil {
+		t.Fatalf("Failed to GET %s: %s", addr, err.Error())
+	}
+
+	defer res.Body.Close() // nolint: errcheck
+
+	if err = json.NewDecoder(res.Body).Decode(&reply); err != nil {
+		t.Fatalf("Cannot decode reply: %s", err.Error())
+	} else if !reply.Status {
+		t.Fatalf("Server reported an error: %s", reply.Message)
+	} else if reply.Resolution != resolution.Hour {
+		t.Errorf("Chart over 30 days should use resolution %s, not %s",
+			resolution.Hour,
+			reply.Resolution)
+	} else if len(reply.Series) != 3 {
+		t.Errorf("Expected 3 series, got %d", len(reply.Series))
+	}
+} // func TestHostChartRollup(t *testing.T)
@@ -0,0 +1,197 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/07_server_retention_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 01:37:02 krylon>
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blicero/donkey/database"
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/recordtype"
+	"github.com/blicero/donkey/model/resolution"
+)
+
+func TestRetentionPolicy(t *testing.T) {
+	if srv == nil {
+		t.SkipNow()
+	}
+
+	type testCase struct {
+		name        string
+		policy      RetentionPolicy
+		expectError bool
+	}
+
+	var tests = []testCase{
+		{name: "default", policy: DefaultRetention()},
+		{name: "forever", policy: RetentionPolicy{}},
+		{
+			name:        "negative",
+			policy:      RetentionPolicy{Raw: -time.Hour},
+			expectError: true,
+		},
+		{
+			name: "negative by type",
+			policy: RetentionPolicy{
+				RawByType: map[recordtype.ID]time.Duration{recordtype.RAM: -time.Hour},
+			},
+			expectError: true,
+		},
+		{
+			name: "raw rollups",
+			policy: RetentionPolicy{
+				Rollups: map[resolution.ID]time.Duration{resolution.Raw: time.Hour},
+			},
+			expectError: true,
+		},
+	}
+
+	defer srv.SetRetention(DefaultRetention()) // nolint: errcheck
+
+	for _, c := range tests {
+		var err = srv.SetRetention(c.policy)
+
+		if err != nil && !c.expectError {
+			t.Errorf("Unexpected error setting retention policy %s: %s",
+				c.name,
+				err.Error())
+		} else if err == nil && c.expectError {
+			t.Errorf("Setting retention policy %s should have failed",
+				c.name)
+		}
+	}
+} // func TestRetentionPolicy(t *testing.T)
+
+// TestPruneDefault checks that the default RetentionPolicy does not delete
+// Records, however old they are.
+func TestPruneDefault(t *testing.T) {
+	if srv == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err  error
+		db   *database.Database
+		recs []model.Record
+		h    = testHosts[0]
+		rec  = model.Record{
+			HostID:    int64(h.ID),
+			Timestamp: time.Now().Add(-day * 60),
+			Source:    recordtype.LoadAvg,
+			Payload:   "[1, 1, 1]",
+		}
+	)
+
+	db = srv.pool.Get()
+	defer srv.pool.Put(db)
+
+	if err = srv.SetRetention(DefaultRetention()); err != nil {
+		t.Fatalf("Cannot set retention policy: %s", err.Error())
+	} else if err = db.RecordAdd(&rec); err != nil {
+		t.Fatalf("Cannot add Record: %s", err.Error())
+	}
+
+	srv.rollup()
+	srv.prune(time.Now())
+
+	if recs, err = db.RecordGetByHostType(&h, recordtype.LoadAvg); err != nil {
+		t.Fatalf("Cannot load Records of Host %s: %s",
+			h.Name,
+			err.Error())
+	}
+
+	for _, r := range recs {
+		if r.ID == rec.ID {
+			return
+		}
+	}
+
+	t.Errorf("Record %d was deleted by the default retention policy", rec.ID)
+} // func TestPruneDefault(t *testing.T)
+
+// TestLoopStop checks that the Server's background loops return when the
+// Server is stopped rather than waiting for their next tick.
+func TestLoopStop(t *testing.T) {
+	var (
+		s = &Server{
+			done: make(chan struct{}),
+			live: liveness{interval: time.Hour},
+		}
+		finished = make(chan string, 2)
+	)
+
+	s.active.Store(true)
+
+	go func() {
+		s.pruneLoop()
+		finished <- "pruneLoop"
+	}()
+
+	go func() {
+		s.watchHosts()
+		finished <- "watchHosts"
+	}()
+
+	s.Stop()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case name := <-finished:
+			t.Logf("%s has returned", name)
+		case <-time.After(time.Second * 2):
+			t.Fatalf("Background loops did not return after the Server was stopped")
+		}
+	}
+} // func TestLoopStop(t *testing.T)
+
+// TestPrune deletes all load averages the previous tests have submitted.
+// TestReportBatch submits Records from an hour in the future, so we prune as
+// if it were two hours from now.
+func TestPrune(t *testing.T) {
+	if srv == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err    error
+		db     *database.Database
+		policy = DefaultRetention()
+		now    = time.Now().Add(time.Hour * 2)
+	)
+
+	policy.RawByType[recordtype.LoadAvg] = time.Minute
+
+	if err = srv.SetRetention(policy); err != nil {
+		t.Fatalf("Cannot set retention policy: %s", err.Error())
+	}
+
+	defer srv.SetRetention(DefaultRetention()) // nolint: errcheck
+
+	srv.rollup()
+
+	if cnt := srv.prune(now); cnt == 0 {
+		t.Fatalf("No data was pruned")
+	}
+
+	db = srv.pool.Get()
+	defer srv.pool.Put(db)
+
+	for _, h := range testHosts {
+		var recs []model.Record
+
+		if recs, err = db.RecordGetByHostType(&h, recordtype.LoadAvg); err != nil {
+			t.Errorf("Cannot load Records of Host %s: %s",
+				h.Name,
+				err.Error())
+		} else if len(recs) != 0 {
+			t.Errorf("%d Records of Host %s were not pruned",
+				len(recs),
+				h.Name)
+		}
+	}
+} // func TestPrune(t *testing.T)
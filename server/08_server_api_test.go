@@ -0,0 +1,197 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/08_server_api_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 02:40:17 krylon>
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/blicero/donkey/database"
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/recordtype"
+)
+
+// apiGet fetches the given path from the API and decodes the response into
+// v, unless the status is not 200.
+func apiGet(path string, v any) (int, error) {
+	var (
+		err  error
+		res  *http.Response
+		addr = fmt.Sprintf("http://%s/api/v1%s", testAddr, path)
+	)
+
+	if res, err = http.Get(addr); err != nil {
+		return 0, err
+	}
+
+	defer res.Body.Close() // nolint: errcheck
+
+	if res.StatusCode != http.StatusOK {
+		return res.StatusCode, nil
+	}
+
+	return res.StatusCode, json.NewDecoder(res.Body).Decode(v)
+} // func apiGet(path string, v any) (int, error)
+
+func TestAPIHosts(t *testing.T) {
+	if srv == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err    error
+		status int
+		hosts  []apiHost
+		host   apiHost
+		types  []apiRecordType
+	)
+
+	if status, err = apiGet("/hosts", &hosts); err != nil {
+		t.Fatalf("Cannot list Hosts: %s", err.Error())
+	} else if status != http.StatusOK {
+		t.Fatalf("Unexpected HTTP status listing Hosts: %d", status)
+	} else if len(hosts) != len(testHosts) {
+		t.Errorf("Expected %d Hosts, got %d", len(testHosts), len(hosts))
+	}
+
+	if status, err = apiGet(fmt.Sprintf("/hosts/%d", testHosts[1].ID), &host); err != nil {
+		t.Fatalf("Cannot get Host: %s", err.Error())
+	} else if status != http.StatusOK {
+		t.Fatalf("Unexpected HTTP status getting Host: %d", status)
+	} else if host.Name != testHosts[1].Name {
+		t.Errorf("Expected Host %s, got %s", testHosts[1].Name, host.Name)
+	}
+
+	if status, err = apiGet("/hosts/4711", &host); err != nil {
+		t.Fatalf("Cannot get Host: %s", err.Error())
+	} else if status != http.StatusNotFound {
+		t.Errorf("Getting a non-existent Host should yield status %d, not %d",
+			http.StatusNotFound,
+			status)
+	}
+
+	if status, err = apiGet("/types", &types); err != nil {
+		t.Fatalf("Cannot list record types: %s", err.Error())
+	} else if status != http.StatusOK {
+		t.Fatalf("Unexpected HTTP status listing record types: %d", status)
+	} else if len(types) != len(recordtype.All()) {
+		t.Errorf("Expected %d record types, got %d",
+			len(recordtype.All()),
+			len(types))
+	}
+} // func TestAPIHosts(t *testing.T)
+
+func TestAPIRecords(t *testing.T) {
+	if srv == nil {
+		t.SkipNow()
+	}
+
+	const recCnt = 10
+	var (
+		err   error
+		db    *database.Database
+		h     = testHosts[2]
+		begin = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	)
+
+	db = srv.pool.Get()
+	for i := 0; i < recCnt; i++ {
+		var rec = model.Record{
+			HostID:    int64(h.ID),
+			Timestamp: begin.Add(time.Minute * time.Duration(i)),
+			Source:    recordtype.RAM,
+			Payload:   `{"Total": 1024, "Free": 512}`,
+		}
+
+		if err = db.RecordAdd(&rec); err != nil {
+			srv.pool.Put(db)
+			t.Fatalf("Cannot add Record: %s", err.Error())
+		}
+	}
+	srv.pool.Put(db)
+
+	var (
+		params = url.Values{
+			"host":  {fmt.Sprintf("%d", h.ID)},
+			"type":  {"ram"},
+			"begin": {begin.Format(time.RFC3339)},
+			"end":   {begin.Add(time.Hour).Format(time.RFC3339)},
+			"limit": {"4"},
+		}
+		pages, total int
+	)
+
+	for {
+		var (
+			status int
+			page   apiRecordPage
+		)
+
+		if status, err = apiGet("/records?"+params.Encode(), &page); err != nil {
+			t.Fatalf("Cannot query Records: %s", err.Error())
+		} else if status != http.StatusOK {
+			t.Fatalf("Unexpected HTTP status querying Records: %d", status)
+		}
+
+		for _, r := range page.Records {
+			if r.Type != recordtype.RAM.String() {
+				t.Errorf("Unexpected record type %s", r.Type)
+			} else if r.HostID != int64(h.ID) {
+				t.Errorf("Record %d belongs to Host %d, not %d",
+					r.ID,
+					r.HostID,
+					h.ID)
+			} else if !json.Valid(r.Payload) {
+				t.Errorf("Payload of Record %d is not valid JSON", r.ID)
+			}
+		}
+
+		total += len(page.Records)
+		pages++
+
+		if page.Next == "" {
+			break
+		}
+
+		params.Set("after", page.Next)
+	}
+
+	if total != recCnt {
+		t.Errorf("Expected %d Records, got %d", recCnt, total)
+	} else if pages != 3 {
+		t.Errorf("Expected 3 pages, got %d", pages)
+	}
+
+	var invalid = []string{
+		"/records",
+		"/records?type=nosuchtype",
+		"/records?host=1&begin=yesterday",
+		"/records?host=1&limit=0",
+		"/records?host=1&after=abc",
+		"/records?host=1&begin=2025-03-02T00:00:00Z&end=2025-03-01T00:00:00Z",
+	}
+
+	for _, path := range invalid {
+		var (
+			status int
+			page   apiRecordPage
+		)
+
+		if status, err = apiGet(path, &page); err != nil {
+			t.Errorf("Cannot query %s: %s", path, err.Error())
+		} else if status != http.StatusBadRequest {
+			t.Errorf("Query %s should yield status %d, not %d",
+				path,
+				http.StatusBadRequest,
+				status)
+		}
+	}
+} // func TestAPIRecords(t *testing.T)
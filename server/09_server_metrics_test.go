@@ -0,0 +1,175 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/09_server_metrics_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 03:27:09 krylon>
+
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blicero/donkey/database"
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/recordtype"
+)
+
+func TestMetricName(t *testing.T) {
+	type testCase struct {
+		metric   string
+		expected string
+	}
+
+	var cases = []testCase{
+		{"load1", "donkey_load1"},
+		{"fs.used_pct", "donkey_fs_used_pct"},
+		{"sensor.temp", "donkey_sensor_temp"},
+		{"foo-bar baz", "donkey_foo_bar_baz"},
+	}
+
+	for _, c := range cases {
+		if name := metricName(c.metric); name != c.expected {
+			t.Errorf("metricName(%q) = %q, expected %q",
+				c.metric,
+				name,
+				c.expected)
+		}
+	}
+} // func TestMetricName(t *testing.T)
+
+func TestMetrics(t *testing.T) {
+	if srv == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err     error
+		db      *database.Database
+		res     *http.Response
+		scanner *bufio.Scanner
+		series  = make(map[string]string)
+		types   = make(map[string]bool)
+		h       = testHosts[0]
+		silent  = model.Host{Name: "silent", Addr: "silent.example.com", OS: "Linux"}
+		addr    = fmt.Sprintf("http://%s/metrics", testAddr)
+		now     = time.Now().Truncate(time.Second)
+		recs    = []model.Record{
+			{
+				HostID:    int64(h.ID),
+				Timestamp: now.Add(time.Hour * 3),
+				Source:    recordtype.LoadAvg,
+				Payload:   "[0.25, 0.5, 1]",
+			},
+			// The newest check is not the first one by name.
+			{
+				HostID:    int64(h.ID),
+				Timestamp: now.Add(time.Hour * 3),
+				Source:    recordtype.Check,
+				Payload:   `{"Name":"aaa","Status":0,"Output":"OK"}`,
+			},
+			{
+				HostID:    int64(h.ID),
+				Timestamp: now.Add(time.Hour * 4),
+				Source:    recordtype.Check,
+				Payload:   `{"Name":"zzz","Status":2,"Output":"CRITICAL"}`,
+			},
+		}
+	)
+
+	db = srv.pool.Get()
+	defer srv.pool.Put(db)
+
+	for i := range recs {
+		if err = db.RecordAdd(&recs[i]); err != nil {
+			t.Fatalf("Cannot add Record: %s", err.Error())
+		}
+	}
+
+	// A Host that has never been in touch must not show up with a last
+	// contact in 1970.
+	if err = db.HostAdd(&silent); err != nil {
+		t.Fatalf("Cannot add Host %s: %s", silent.Name, err.Error())
+	}
+
+	defer db.HostDelete(silent.ID) // nolint: errcheck
+
+	if res, err = http.Get(addr); err != nil {
+		t.Fatalf("Cannot GET %s: %s", addr, err.Error())
+	}
+
+	defer res.Body.Close() // nolint: errcheck
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("Unexpected HTTP status: %d", res.StatusCode)
+	} else if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Unexpected Content-Type: %s", ct)
+	}
+
+	scanner = bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		var line = scanner.Text()
+
+		if strings.HasPrefix(line, "# TYPE ") {
+			var name = strings.Fields(line)[2]
+			if types[name] {
+				t.Errorf("Metric %s is declared more than once", name)
+			}
+			types[name] = true
+			continue
+		} else if strings.HasPrefix(line, "#") || line == "" {
+			continue
+		}
+
+		var idx = strings.LastIndexByte(line, ' ')
+		if idx < 0 {
+			t.Errorf("Malformed line: %s", line)
+			continue
+		}
+		series[line[:idx]] = line[idx+1:]
+	}
+
+	if err = scanner.Err(); err != nil {
+		t.Fatalf("Cannot read response: %s", err.Error())
+	}
+
+	var expected = map[string]string{
+		fmt.Sprintf(`donkey_load1{host="%s"}`, h.Name):                                                "0.25",
+		fmt.Sprintf(`donkey_load15{host="%s"}`, h.Name):                                               "1",
+		fmt.Sprintf(`donkey_check_status{host="%s",name="zzz"}`, h.Name):                              "2",
+		fmt.Sprintf(`donkey_record_timestamp_seconds{host="%s",type="%s"}`, h.Name, recordtype.Check): strconv.FormatFloat(float64(now.Add(time.Hour*4).Unix()), 'g', -1, 64),
+	}
+
+	for k, v := range expected {
+		if series[k] != v {
+			t.Errorf("Expected %s to be %s, got %q", k, v, series[k])
+		}
+	}
+
+	var contact = fmt.Sprintf(`donkey_host_last_contact_seconds{host="%s"}`, silent.Name)
+	if v, ok := series[contact]; ok {
+		t.Errorf("Host %s has never been in touch, but %s is %s",
+			silent.Name,
+			contact,
+			v)
+	}
+
+	for _, name := range []string{
+		"donkey_records_ingested_total",
+		"donkey_ingest_errors_total",
+		"donkey_db_pool_wait_seconds_total",
+	} {
+		if _, ok := series[name]; !ok {
+			t.Errorf("Server metric %s is missing", name)
+		}
+	}
+
+	if series["donkey_records_ingested_total"] == "0" {
+		t.Error("No Records were counted as ingested")
+	}
+} // func TestMetrics(t *testing.T)
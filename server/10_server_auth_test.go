@@ -0,0 +1,206 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/10_server_auth_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 04:31:52 krylon>
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/recordtype"
+	"github.com/blicero/krylib"
+)
+
+// agentPost sends v to one of the Agent endpoints of the test Server,
+// presenting token if it is not empty, and decodes the response into reply.
+func agentPost(path, token string, v, reply any) (int, error) {
+	return agentPostTo(http.DefaultClient, "http://"+testAddr, path, token, v, reply)
+} // func agentPost(path, token string, v, reply any) (int, error)
+
+// agentPostTo is like agentPost, but talks to the Server at base using the
+// given client.
+func agentPostTo(client *http.Client, base, path, token string, v, reply any) (int, error) {
+	var (
+		err        error
+		req        *http.Request
+		res        *http.Response
+		serialized []byte
+		addr       = base + path
+	)
+
+	if serialized, err = json.Marshal(v); err != nil {
+		return 0, err
+	} else if req, err = http.NewRequest("POST", addr, bytes.NewReader(serialized)); err != nil {
+		return 0, err
+	} else if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	if res, err = client.Do(req); err != nil {
+		return 0, err
+	}
+
+	defer res.Body.Close() // nolint: errcheck
+
+	return res.StatusCode, json.NewDecoder(res.Body).Decode(reply)
+} // func agentPostTo(client *http.Client, base, path, token string, v, reply any) (int, error)
+
+func TestAgentAuth(t *testing.T) {
+	if srv == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err    error
+		status int
+		reg    model.Registration
+		res    model.Response
+		batch  model.BatchResponse
+		token  string
+		host   = model.Host{Name: "dbobo", OS: "NetBSD"}
+		rec    = model.Record{
+			Timestamp: time.Now(),
+			Source:    recordtype.LoadAvg,
+			Payload:   "[0.5, 0.5, 0.5]",
+		}
+	)
+
+	if status, err = agentPost("/ws/register", "", &host, &reg); err != nil {
+		t.Fatalf("Cannot register Host %s: %s", host.Name, err.Error())
+	} else if status != http.StatusOK || !reg.Status {
+		t.Fatalf("Registering Host %s failed with status %d: %s",
+			host.Name,
+			status,
+			reg.Message)
+	} else if reg.HostID == 0 || reg.Token == "" {
+		t.Fatalf("Registration did not yield Host ID and token: %#v", reg)
+	}
+
+	token = reg.Token
+	rec.HostID = reg.HostID
+
+	type testCase struct {
+		token  string
+		status int
+	}
+
+	var cases = []testCase{
+		{token: token, status: http.StatusOK},
+		{token: "", status: http.StatusUnauthorized},
+		{token: testTokens[0], status: http.StatusForbidden},
+	}
+
+	for i, c := range cases {
+		rec.Timestamp = rec.Timestamp.Add(time.Second)
+
+		if status, err = agentPost("/ws/report", c.token, &rec, &res); err != nil {
+			t.Errorf("Test case #%d: Cannot report Record: %s", i, err.Error())
+		} else if status != c.status {
+			t.Errorf("Test case #%d: Unexpected status %d (expected %d): %s",
+				i,
+				status,
+				c.status,
+				res.Message)
+		} else if res.Status != (c.status == http.StatusOK) {
+			t.Errorf("Test case #%d: Unexpected response status %t: %s",
+				i,
+				res.Status,
+				res.Message)
+		}
+	}
+
+	// A batch must not contain Records of other Hosts.
+	var other = rec
+	other.HostID = int64(testHosts[0].ID)
+
+	if status, err = agentPost("/ws/report/batch", token, []model.Record{rec, other}, &batch); err != nil {
+		t.Errorf("Cannot report batch: %s", err.Error())
+	} else if status != http.StatusForbidden {
+		t.Errorf("Batch with foreign Records yielded status %d (expected %d): %s",
+			status,
+			http.StatusForbidden,
+			batch.Message)
+	}
+
+	// Registering a known Host again requires its current token.
+	if status, err = agentPost("/ws/register", "", &host, &reg); err != nil {
+		t.Fatalf("Cannot register Host %s: %s", host.Name, err.Error())
+	} else if status != http.StatusConflict {
+		t.Errorf("Registering Host %s again without a token yielded status %d (expected %d)",
+			host.Name,
+			status,
+			http.StatusConflict)
+	}
+
+	if status, err = agentPost("/ws/register", token, &host, &reg); err != nil {
+		t.Fatalf("Cannot register Host %s: %s", host.Name, err.Error())
+	} else if status != http.StatusOK || !reg.Status {
+		t.Fatalf("Registering Host %s again failed with status %d: %s",
+			host.Name,
+			status,
+			reg.Message)
+	} else if reg.HostID != rec.HostID {
+		t.Errorf("Host %s was assigned a new ID: %d (expected %d)",
+			host.Name,
+			reg.HostID,
+			rec.HostID)
+	} else if reg.Token == token {
+		t.Errorf("Host %s was not issued a new token", host.Name)
+	}
+
+	// The old token is no longer valid.
+	rec.Timestamp = rec.Timestamp.Add(time.Second)
+	if status, err = agentPost("/ws/report", token, &rec, &res); err != nil {
+		t.Errorf("Cannot report Record: %s", err.Error())
+	} else if status != http.StatusForbidden {
+		t.Errorf("Old token yielded status %d (expected %d)",
+			status,
+			http.StatusForbidden)
+	}
+
+	// Once the token is revoked, the Agent that lost its token may
+	// claim the Host again.
+	var db = srv.pool.Get()
+	defer srv.pool.Put(db)
+
+	if err = db.HostUpdateToken(&model.Host{ID: krylib.ID(reg.HostID)}, ""); err != nil {
+		t.Fatalf("Cannot revoke token of Host %s: %s", host.Name, err.Error())
+	} else if status, err = agentPost("/ws/report", reg.Token, &rec, &res); err != nil {
+		t.Errorf("Cannot report Record: %s", err.Error())
+	} else if status != http.StatusForbidden {
+		t.Errorf("Revoked token yielded status %d (expected %d)",
+			status,
+			http.StatusForbidden)
+	} else if status, err = agentPost("/ws/register", token, &host, &reg); err != nil {
+		t.Fatalf("Cannot register Host %s: %s", host.Name, err.Error())
+	} else if status != http.StatusOK || !reg.Status {
+		t.Errorf("Registering Host %s after revoking its token failed with status %d: %s",
+			host.Name,
+			status,
+			reg.Message)
+	}
+
+	// Records of Hosts we do not know are refused, too, so the Agent
+	// registers again.
+	rec.HostID = 1 << 40
+	if status, err = agentPost("/ws/report", reg.Token, &rec, &res); err != nil {
+		t.Errorf("Cannot report Record: %s", err.Error())
+	} else if status != http.StatusForbidden {
+		t.Errorf("Unknown Host yielded status %d (expected %d)",
+			status,
+			http.StatusForbidden)
+	} else if status, err = agentPost("/ws/report/batch", reg.Token, []model.Record{rec}, &batch); err != nil {
+		t.Errorf("Cannot report batch: %s", err.Error())
+	} else if status != http.StatusForbidden {
+		t.Errorf("Batch of unknown Host yielded status %d (expected %d)",
+			status,
+			http.StatusForbidden)
+	}
+} // func TestAgentAuth(t *testing.T)
@@ -0,0 +1,155 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/11_server_tls_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 06:02:14 krylon>
+
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/blicero/donkey/common/testcert"
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/recordtype"
+)
+
+// tlsClient returns an HTTP client that trusts the test CA and presents the
+// client certificate issued for name, unless name is empty.
+func tlsClient(t *testing.T, b *testcert.Bundle, name string) *http.Client {
+	var (
+		err  error
+		pem  []byte
+		cert tls.Certificate
+		cfg  = &tls.Config{RootCAs: x509.NewCertPool()}
+	)
+
+	if pem, err = os.ReadFile(b.CA); err != nil {
+		t.Fatalf("Cannot read CA certificate: %s", err.Error())
+	}
+
+	cfg.RootCAs.AppendCertsFromPEM(pem)
+
+	if name != "" {
+		var paths = b.Clients[name]
+
+		if cert, err = tls.LoadX509KeyPair(paths[0], paths[1]); err != nil {
+			t.Fatalf("Cannot load client certificate for %s: %s",
+				name,
+				err.Error())
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: cfg},
+		Timeout:   time.Second * 5,
+	}
+} // func tlsClient(t *testing.T, b *testcert.Bundle, name string) *http.Client
+
+func TestTLSConfig(t *testing.T) {
+	var (
+		err error
+		b   *testcert.Bundle
+	)
+
+	if b, err = testcert.Generate(t.TempDir()); err != nil {
+		t.Fatalf("Cannot generate certificates: %s", err.Error())
+	}
+
+	type testCase struct {
+		cfg         TLSConfig
+		expectError bool
+	}
+
+	var cases = []testCase{
+		{cfg: TLSConfig{CertFile: b.Cert, KeyFile: b.Key}},
+		{cfg: TLSConfig{CertFile: b.Cert, KeyFile: b.Key, ClientCAFile: b.CA, RequireClientCert: true}},
+		{cfg: TLSConfig{CertFile: b.Cert}, expectError: true},
+		{cfg: TLSConfig{CertFile: b.Cert, KeyFile: b.CA}, expectError: true},
+		{cfg: TLSConfig{CertFile: b.Cert, KeyFile: b.Key, RequireClientCert: true}, expectError: true},
+		{cfg: TLSConfig{CertFile: b.Cert, KeyFile: b.Key, ClientCAFile: b.Key}, expectError: true},
+	}
+
+	for i, c := range cases {
+		if _, err = c.cfg.config(); err != nil && !c.expectError {
+			t.Errorf("Test case #%d: Unexpected error: %s", i, err.Error())
+		} else if err == nil && c.expectError {
+			t.Errorf("Test case #%d: Expected an error", i)
+		}
+	}
+
+	if srv != nil && srv.SetTLS(&cases[0].cfg) == nil {
+		t.Error("Changing the TLS settings of a running Server should fail")
+	}
+} // func TestTLSConfig(t *testing.T)
+
+func TestTLSReport(t *testing.T) {
+	if srv == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err    error
+		b      *testcert.Bundle
+		ts     *httptest.Server
+		status int
+		res    model.Response
+		h      = testHosts[0]
+		rec    = model.Record{
+			HostID:    int64(h.ID),
+			Timestamp: time.Now().Add(time.Hour * 4),
+			Source:    recordtype.LoadAvg,
+			Payload:   "[0.1, 0.1, 0.1]",
+		}
+	)
+
+	if b, err = testcert.Generate(t.TempDir(), h.Name, testHosts[1].Name); err != nil {
+		t.Fatalf("Cannot generate certificates: %s", err.Error())
+	}
+
+	var cfg = &TLSConfig{
+		CertFile:          b.Cert,
+		KeyFile:           b.Key,
+		ClientCAFile:      b.CA,
+		RequireClientCert: true,
+	}
+
+	ts = httptest.NewUnstartedServer(srv.router)
+	if ts.TLS, err = cfg.config(); err != nil {
+		t.Fatalf("Cannot create TLS configuration: %s", err.Error())
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	// The certificate matches the Host.
+	if status, err = agentPostTo(tlsClient(t, b, h.Name), ts.URL, "/ws/report", testTokens[0], &rec, &res); err != nil {
+		t.Errorf("Cannot report Record via HTTPS: %s", err.Error())
+	} else if status != http.StatusOK || !res.Status {
+		t.Errorf("Reporting via HTTPS failed with status %d: %s",
+			status,
+			res.Message)
+	}
+
+	// The certificate was issued to a different Host.
+	rec.Timestamp = rec.Timestamp.Add(time.Second)
+	if status, err = agentPostTo(tlsClient(t, b, testHosts[1].Name), ts.URL, "/ws/report", testTokens[0], &rec, &res); err != nil {
+		t.Errorf("Cannot report Record via HTTPS: %s", err.Error())
+	} else if status != http.StatusForbidden {
+		t.Errorf("Reporting with a foreign certificate yielded status %d (expected %d): %s",
+			status,
+			http.StatusForbidden,
+			res.Message)
+	}
+
+	// No certificate at all does not get past the handshake.
+	if _, err = agentPostTo(tlsClient(t, b, ""), ts.URL, "/ws/report", testTokens[0], &rec, &res); err == nil {
+		t.Error("Server accepted a connection without a client certificate")
+	}
+} // func TestTLSReport(t *testing.T)
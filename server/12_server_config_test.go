@@ -0,0 +1,148 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/12_server_config_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 08:21:07 krylon>
+
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blicero/donkey/common"
+	"github.com/blicero/donkey/logdomain"
+	"github.com/blicero/donkey/model/recordtype"
+	"github.com/blicero/donkey/model/resolution"
+)
+
+func TestReadConfig(t *testing.T) {
+	var (
+		err  error
+		cfg  *Config
+		dir  = t.TempDir()
+		path = filepath.Join(dir, "server.json")
+	)
+
+	if cfg, err = ReadConfig(filepath.Join(dir, "does-not-exist.json")); err != nil {
+		t.Fatalf("Missing configuration file should yield defaults: %s", err.Error())
+	} else if cfg.PoolSize != defaultPoolSize || cfg.DBPath != common.DbPath {
+		t.Errorf("Unexpected defaults: %#v", cfg)
+	}
+
+	const valid = `{
+    "Addr": "[::1]:4711",
+    "PoolSize": 8,
+    "LogLevels": {"database": "info", "Server": "WARN"},
+    "Retention": {
+        "Raw": "14d",
+        "RawByType": {"Sensors": "2d"},
+        "Rollups": {"Minute": "12h", "Day": "0"}
+    }
+}`
+
+	if err = os.WriteFile(path, []byte(valid), 0600); err != nil {
+		t.Fatalf("Cannot write %s: %s", path, err.Error())
+	} else if cfg, err = ReadConfig(path); err != nil {
+		t.Fatalf("Cannot read configuration: %s", err.Error())
+	}
+
+	if cfg.Addr != "[::1]:4711" || cfg.PoolSize != 8 {
+		t.Errorf("Unexpected address or pool size: %s / %d", cfg.Addr, cfg.PoolSize)
+	} else if cfg.DBPath != common.DbPath {
+		t.Errorf("DBPath should have kept its default, not %s", cfg.DBPath)
+	}
+
+	if cfg.levels[logdomain.Database] != "INFO" || cfg.levels[logdomain.Server] != "WARN" {
+		t.Errorf("Unexpected log levels: %v", cfg.levels)
+	}
+
+	if cfg.retention.Raw != day*14 {
+		t.Errorf("Unexpected retention for Records: %s", cfg.retention.Raw)
+	} else if cfg.retention.RawByType[recordtype.Sensors] != day*2 {
+		t.Errorf("Unexpected retention for Sensors: %s",
+			cfg.retention.RawByType[recordtype.Sensors])
+	} else if cfg.retention.Rollups[resolution.Minute] != time.Hour*12 {
+		t.Errorf("Unexpected retention for rollups by minute: %s",
+			cfg.retention.Rollups[resolution.Minute])
+	} else if cfg.retention.Rollups[resolution.Hour] != day*365 {
+		t.Errorf("Retention for rollups by hour should have kept its default, not %s",
+			cfg.retention.Rollups[resolution.Hour])
+	}
+
+	var invalid = []string{
+		`{"Addr": "localhost"}`,
+		`{"PoolSize": 0}`,
+		`{"DBPath": ""}`,
+		`{"Port": 4711}`,
+		`{"LogLevels": {"Server": "CHATTY"}}`,
+		`{"LogLevels": {"Frontend": "INFO"}}`,
+		`{"Retention": {"Raw": "a week"}}`,
+		`{"Retention": {"RawByType": {"Weather": "1d"}}}`,
+		`{"Retention": {"Rollups": {"Fortnight": "1d"}}}`,
+		`{"Retention": {"Rollups": {"Minute": "-1h"}}}`,
+		`{"TLS": {"CertFile": "/does/not/exist.pem", "KeyFile": "/does/not/exist.key"}}`,
+	}
+
+	for i, s := range invalid {
+		if err = os.WriteFile(path, []byte(s), 0600); err != nil {
+			t.Fatalf("Cannot write %s: %s", path, err.Error())
+		} else if _, err = ReadConfig(path); err == nil {
+			t.Errorf("Invalid configuration #%d was accepted: %s", i, s)
+		}
+	}
+} // func TestReadConfig(t *testing.T)
+
+func TestReloadConfig(t *testing.T) {
+	if srv == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err  error
+		path = filepath.Join(t.TempDir(), "server.json")
+		conf = `{
+    "Addr": "[::1]:4711",
+    "LogLevels": {"Database": "WARN"},
+    "Retention": {"Raw": "3d"}
+}`
+	)
+
+	srv.cfg.path = path
+	defer func() {
+		// Restore the defaults for the tests that follow.
+		os.WriteFile(path, []byte("{}"), 0600) // nolint: errcheck
+		srv.reloadConfig()                     // nolint: errcheck
+		srv.cfg.path = ""
+	}()
+
+	if err = os.WriteFile(path, []byte(conf), 0600); err != nil {
+		t.Fatalf("Cannot write %s: %s", path, err.Error())
+	} else if err = srv.reloadConfig(); err != nil {
+		t.Fatalf("Cannot reload configuration: %s", err.Error())
+	}
+
+	if r := srv.getRetention(); r.Raw != day*3 {
+		t.Errorf("Retention for Records was not reloaded: %s", r.Raw)
+	}
+
+	if lvl := common.PackageLevels[logdomain.Database]; lvl != "WARN" {
+		t.Errorf("Log level for Database was not reloaded: %s", lvl)
+	}
+
+	// The address cannot be changed while running.
+	if srv.web.Addr != testAddr {
+		t.Errorf("Server address changed to %s", srv.web.Addr)
+	}
+
+	// An invalid file leaves the settings alone.
+	if err = os.WriteFile(path, []byte(`{"Retention": {"Raw": "nope"}}`), 0600); err != nil {
+		t.Fatalf("Cannot write %s: %s", path, err.Error())
+	} else if err = srv.reloadConfig(); err == nil {
+		t.Error("Reloading an invalid configuration should fail")
+	} else if r := srv.getRetention(); r.Raw != day*3 {
+		t.Errorf("Failed reload changed retention for Records to %s", r.Raw)
+	}
+} // func TestReloadConfig(t *testing.T)
@@ -0,0 +1,169 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/13_server_fleet_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 11:16:40 krylon>
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/blicero/donkey/model"
+)
+
+const testFleet = `{
+    "Defaults": {"Probes": {"load": 10}, "BatchSize": 5},
+    "Groups": [
+        {"Name": "bobos", "Hosts": "^[ab]bobo$", "Config": {"Probes": {"load": 10, "ram": 60}}},
+        {"Name": "abobo", "Hosts": "^abobo$", "Config": {"BatchInterval": 30}}
+    ],
+    "Hosts": {
+        "bbobo": {"BatchSize": 50}
+    }
+}`
+
+func TestFleetConfig(t *testing.T) {
+	var (
+		err  error
+		cfg  *fleetConfig
+		dir  = t.TempDir()
+		path = filepath.Join(dir, "fleet.json")
+	)
+
+	if cfg, err = readFleetConfig(filepath.Join(dir, "does-not-exist.json")); err != nil {
+		t.Fatalf("Missing fleet configuration should be empty: %s", err.Error())
+	} else if c := cfg.configFor("abobo"); c != nil {
+		t.Errorf("Empty fleet configuration returned %#v", c)
+	}
+
+	if err = os.WriteFile(path, []byte(testFleet), 0600); err != nil {
+		t.Fatalf("Cannot write %s: %s", path, err.Error())
+	} else if cfg, err = readFleetConfig(path); err != nil {
+		t.Fatalf("Cannot read fleet configuration: %s", err.Error())
+	}
+
+	var expected = map[string]model.AgentConfig{
+		"abobo": {Probes: map[string]int{"load": 10, "ram": 60}, BatchSize: 5, BatchInterval: 30},
+		"bbobo": {Probes: map[string]int{"load": 10, "ram": 60}, BatchSize: 50},
+		"cbobo": {Probes: map[string]int{"load": 10}, BatchSize: 5},
+	}
+
+	for name, e := range expected {
+		if c := cfg.configFor(name); c == nil {
+			t.Errorf("No configuration for Host %s", name)
+		} else if !reflect.DeepEqual(*c, e) {
+			t.Errorf("Unexpected configuration for Host %s: %#v", name, *c)
+		}
+	}
+
+	var invalid = []string{
+		`{"Groups": [{"Hosts": ".*"}]}`,
+		`{"Groups": [{"Name": "all"}]}`,
+		`{"Groups": [{"Name": "all", "Hosts": "("}]}`,
+		`{"Groups": [{"Name": "all", "Hosts": ".*"}, {"Name": "all", "Hosts": "x"}]}`,
+		`{"Defaults": {"Probes": {"load": -1}}}`,
+		`{"Hosts": {"abobo": {"BatchSize": -5}}}`,
+		`{"Hosts": {"abobo": {"BatchSize": 5000}}}`,
+		`{"Hosts": {"abobo": null}}`,
+	}
+
+	for i, s := range invalid {
+		if err = os.WriteFile(path, []byte(s), 0600); err != nil {
+			t.Fatalf("Cannot write %s: %s", path, err.Error())
+		} else if _, err = readFleetConfig(path); err == nil {
+			t.Errorf("Invalid fleet configuration #%d was accepted: %s", i, s)
+		}
+	}
+} // func TestFleetConfig(t *testing.T)
+
+// agentGet fetches one of the Agent endpoints of the test Server, presenting
+// token if it is not empty, and decodes the response into reply.
+func agentGet(path, token string, reply any) (int, error) {
+	var (
+		err error
+		req *http.Request
+		res *http.Response
+	)
+
+	if req, err = http.NewRequest("GET", "http://"+testAddr+path, nil); err != nil {
+		return 0, err
+	} else if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	if res, err = http.DefaultClient.Do(req); err != nil {
+		return 0, err
+	}
+
+	defer res.Body.Close() // nolint: errcheck
+
+	return res.StatusCode, json.NewDecoder(res.Body).Decode(reply)
+} // func agentGet(path, token string, reply any) (int, error)
+
+func TestClientConfig(t *testing.T) {
+	if srv == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err    error
+		cfg    *fleetConfig
+		status int
+		path   = filepath.Join(t.TempDir(), "fleet.json")
+		old    = srv.getFleet()
+	)
+
+	if err = os.WriteFile(path, []byte(`{"Hosts": {"abobo": {"Probes": {"ram": 60}}}}`), 0600); err != nil {
+		t.Fatalf("Cannot write %s: %s", path, err.Error())
+	} else if cfg, err = readFleetConfig(path); err != nil {
+		t.Fatalf("Cannot read fleet configuration: %s", err.Error())
+	}
+
+	srv.setFleet(cfg)
+	defer srv.setFleet(old)
+
+	type testCase struct {
+		host     int
+		token    string
+		status   int
+		expected *model.AgentConfig
+	}
+
+	var cases = []testCase{
+		{host: 0, token: testTokens[0], status: http.StatusOK, expected: &model.AgentConfig{Probes: map[string]int{"ram": 60}}},
+		{host: 1, token: testTokens[1], status: http.StatusOK},
+		{host: 0, status: http.StatusUnauthorized},
+		{host: 0, token: testTokens[1], status: http.StatusForbidden},
+	}
+
+	for i, c := range cases {
+		var (
+			reply model.ConfigResponse
+			url   = fmt.Sprintf("/ws/config/%d", testHosts[c.host].ID)
+		)
+
+		if status, err = agentGet(url, c.token, &reply); err != nil {
+			t.Errorf("Test case #%d: Cannot get configuration: %s", i, err.Error())
+		} else if status != c.status {
+			t.Errorf("Test case #%d: Expected status %d, got %d: %s",
+				i,
+				c.status,
+				status,
+				reply.Message)
+		} else if status == http.StatusOK && !reply.Status {
+			t.Errorf("Test case #%d: Request failed: %s", i, reply.Message)
+		} else if !reflect.DeepEqual(reply.Config, c.expected) {
+			t.Errorf("Test case #%d: Expected configuration %#v, got %#v",
+				i,
+				c.expected,
+				reply.Config)
+		}
+	}
+} // func TestClientConfig(t *testing.T)
@@ -2,6 +2,40 @@
 // -*- mode: go; coding: utf-8; -*-
 // Created on 10. 06. 2024 by Benjamin Walkenhorst
 // (c) 2024 Benjamin Walkenhorst
-// Time-stamp: <2024-06-12 19:28:06 krylon>
+// Time-stamp: <2026-10-18 22:52:40 krylon>
+//
+// This file contains data structures to be sent to the client in response to
+// AJAX requests.
 
 package server
+
+import (
+	"time"
+
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/recordtype"
+	"github.com/blicero/donkey/model/resolution"
+	"github.com/blicero/krylib"
+)
+
+// chartSeries is a single line in a chart. Each point is a pair of a Unix
+// timestamp (in seconds) and a value.
+type chartSeries struct {
+	Name   string
+	Points [][2]float64
+}
+
+// chartData is what the Server sends in reply to a request for the data to
+// draw a chart for one type of Record of a Host. Depending on the time
+// range, the values are either raw or the averages of rollups at the given
+// Resolution.
+type chartData struct {
+	model.Response
+	HostID     krylib.ID
+	Type       recordtype.ID
+	Name       string
+	Begin      time.Time
+	End        time.Time
+	Resolution resolution.ID
+	Series     []chartSeries
+}
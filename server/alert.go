@@ -0,0 +1,488 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/alert.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 22:46:19 krylon>
+//
+// Alerting: Rules are evaluated against incoming Records, the resulting
+// Alerts are kept in memory and persisted in the database.
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/blicero/donkey/common"
+	"github.com/blicero/donkey/database"
+	"github.com/blicero/donkey/logdomain"
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/alertstate"
+)
+
+// duration is a time.Duration that can be read from JSON either as a string
+// like "5m" or as a number of seconds.
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(buf []byte) error {
+	var (
+		err  error
+		str  string
+		secs float64
+		val  time.Duration
+	)
+
+	if err = json.Unmarshal(buf, &secs); err == nil {
+		*d = duration(secs * float64(time.Second))
+		return nil
+	} else if err = json.Unmarshal(buf, &str); err != nil {
+		return fmt.Errorf("Invalid duration %s", buf)
+	} else if val, err = time.ParseDuration(str); err != nil {
+		return err
+	}
+
+	*d = duration(val)
+	return nil
+} // func (d *duration) UnmarshalJSON(buf []byte) error
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+} // func (d duration) MarshalJSON() ([]byte, error)
+
+// alertRule describes a condition on the Samples of incoming Records that
+// should raise an Alert, e.g. "load1 > 8 for 5m on hosts matching ^web".
+//
+// Metric selects the Samples the rule applies to, Hosts and Instance are
+// optional regular expressions matched against the name of the Host and
+// the instance of the Sample, respectively.
+// The value is compared to Threshold using Op, unless Limit is "high" or
+// "crit", in which case it is compared to the limit reported with the
+// Sample, and Samples without such a limit are ignored.
+// The condition must hold for at least For before the Alert fires. Once it
+// has fired, the value must fall below (or rise above, for "<" and "<=")
+// the threshold by at least Hysteresis for the Alert to be resolved, so it
+// does not flap when the value hovers around the threshold.
+// Channels lists the notification channels the rule's Alerts are delivered
+// to, if it is empty, they are delivered to all channels.
+type alertRule struct {
+	Name       string
+	Metric     string
+	Hosts      string `json:",omitempty"`
+	Instance   string `json:",omitempty"`
+	Op         string
+	Threshold  float64  `json:",omitempty"`
+	Limit      string   `json:",omitempty"`
+	For        duration `json:",omitempty"`
+	Hysteresis float64  `json:",omitempty"`
+	Channels   []string `json:",omitempty"`
+	hostPat    *regexp.Regexp
+	instPat    *regexp.Regexp
+}
+
+// compile checks the rule for validity and compiles its regular
+// expressions.
+func (r *alertRule) compile() error {
+	var err error
+
+	switch r.Op {
+	case ">", ">=", "<", "<=":
+	default:
+		return fmt.Errorf("Rule %q: invalid operator %q", r.Name, r.Op)
+	}
+
+	switch r.Limit {
+	case "", "high", "crit":
+	default:
+		return fmt.Errorf("Rule %q: invalid limit %q", r.Name, r.Limit)
+	}
+
+	if r.Name == "" {
+		return errors.New("Rule has no name")
+	} else if r.Metric == "" {
+		return fmt.Errorf("Rule %q has no metric", r.Name)
+	} else if r.For < 0 || r.Hysteresis < 0 {
+		return fmt.Errorf("Rule %q: duration and hysteresis must not be negative", r.Name)
+	}
+
+	if r.Hosts != "" {
+		if r.hostPat, err = regexp.Compile(r.Hosts); err != nil {
+			return fmt.Errorf("Rule %q: invalid host pattern: %s", r.Name, err.Error())
+		}
+	}
+
+	if r.Instance != "" {
+		if r.instPat, err = regexp.Compile(r.Instance); err != nil {
+			return fmt.Errorf("Rule %q: invalid instance pattern: %s", r.Name, err.Error())
+		}
+	}
+
+	return nil
+} // func (r *alertRule) compile() error
+
+func (r *alertRule) matchHost(h *model.Host) bool {
+	return r.hostPat == nil || r.hostPat.MatchString(h.Name)
+} // func (r *alertRule) matchHost(h *model.Host) bool
+
+func (r *alertRule) matchSample(s *model.Sample) bool {
+	return s.Metric == r.Metric && (r.instPat == nil || r.instPat.MatchString(s.Instance))
+} // func (r *alertRule) matchSample(s *model.Sample) bool
+
+// threshold returns the threshold to compare the Sample's value to. If the
+// rule refers to a limit the Sample does not have, it returns false.
+func (r *alertRule) threshold(s *model.Sample) (float64, bool) {
+	switch r.Limit {
+	case "high":
+		if s.High == nil {
+			return 0, false
+		}
+		return *s.High, true
+	case "crit":
+		if s.Crit == nil {
+			return 0, false
+		}
+		return *s.Crit, true
+	default:
+		return r.Threshold, true
+	}
+} // func (r *alertRule) threshold(s *model.Sample) (float64, bool)
+
+// breached returns true if the value meets the condition of the rule.
+func (r *alertRule) breached(val, threshold float64) bool {
+	switch r.Op {
+	case ">":
+		return val > threshold
+	case ">=":
+		return val >= threshold
+	case "<":
+		return val < threshold
+	case "<=":
+		return val <= threshold
+	default:
+		return false
+	}
+} // func (r *alertRule) breached(val, threshold float64) bool
+
+// cleared returns true if a firing Alert should be resolved.
+func (r *alertRule) cleared(val, threshold float64) bool {
+	switch r.Op {
+	case ">", ">=":
+		return !r.breached(val, threshold-r.Hysteresis)
+	default:
+		return !r.breached(val, threshold+r.Hysteresis)
+	}
+} // func (r *alertRule) cleared(val, threshold float64) bool
+
+// alertConfig is the content of the alerting configuration file.
+type alertConfig struct {
+	Rules    []*alertRule
+	Channels []*channelConfig
+}
+
+// routes maps the names of the rules to the notification channels their
+// Alerts go to.
+func (c *alertConfig) routes() map[string][]string {
+	var routes = make(map[string][]string, len(c.Rules))
+
+	for _, r := range c.Rules {
+		routes[r.Name] = r.Channels
+	}
+
+	return routes
+} // func (c *alertConfig) routes() map[string][]string
+
+// readAlertConfig reads the alerting configuration from the given file. If
+// the file does not exist, an empty configuration is returned.
+func readAlertConfig(path string) (*alertConfig, error) {
+	var (
+		err   error
+		buf   []byte
+		cfg   = new(alertConfig)
+		names = make(map[string]bool)
+	)
+
+	if buf, err = os.ReadFile(path); err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, err
+	} else if err = json.Unmarshal(buf, cfg); err != nil {
+		return nil, fmt.Errorf("Cannot parse %s: %s", path, err.Error())
+	}
+
+	for _, r := range cfg.Rules {
+		if err = r.compile(); err != nil {
+			return nil, err
+		} else if names[r.Name] {
+			return nil, fmt.Errorf("Duplicate rule %q", r.Name)
+		}
+
+		names[r.Name] = true
+	}
+
+	return cfg, nil
+} // func readAlertConfig(path string) (*alertConfig, error)
+
+// alertEngine evaluates the alerting rules against incoming Records and
+// keeps track of the Alerts that have not been resolved, yet.
+type alertEngine struct {
+	log    *log.Logger
+	lock   sync.Mutex
+	rules  []*alertRule
+	alerts map[string]*model.Alert
+}
+
+// newAlertEngine creates an alertEngine for the given rules. The rules must
+// have been compiled already.
+func newAlertEngine(rules []*alertRule) (*alertEngine, error) {
+	var (
+		err error
+		e   = &alertEngine{
+			rules:  rules,
+			alerts: make(map[string]*model.Alert),
+		}
+	)
+
+	if e.log, err = common.GetLogger(logdomain.Alert); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+} // func newAlertEngine(rules []*alertRule) (*alertEngine, error)
+
+// restore loads the unresolved Alerts from the database. Alerts for rules
+// that no longer exist are removed.
+func (e *alertEngine) restore(db *database.Database) error {
+	var (
+		err    error
+		alerts []model.Alert
+		rules  = make(map[string]bool, len(e.rules))
+	)
+
+	for _, r := range e.rules {
+		rules[r.Name] = true
+	}
+
+	if alerts, err = db.AlertGetActive(); err != nil {
+		e.log.Printf("[ERROR] Cannot load active Alerts: %s\n",
+			err.Error())
+		return err
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	for i := range alerts {
+		var a = &alerts[i]
+
+		if !rules[a.Rule] {
+			e.log.Printf("[INFO] Removing Alert %d, rule %q no longer exists\n",
+				a.ID,
+				a.Rule)
+			if err = db.AlertDelete(a); err != nil {
+				return err
+			}
+			continue
+		}
+
+		e.alerts[a.Key()] = a
+	}
+
+	e.log.Printf("[DEBUG] Restored %d active Alerts\n", len(e.alerts))
+
+	return nil
+} // func (e *alertEngine) restore(db *database.Database) error
+
+// Active returns a copy of all Alerts that have not been resolved.
+func (e *alertEngine) Active() []model.Alert {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	var alerts = make([]model.Alert, 0, len(e.alerts))
+
+	for _, a := range e.alerts {
+		alerts = append(alerts, *a)
+	}
+
+	return alerts
+} // func (e *alertEngine) Active() []model.Alert
+
+// Process evaluates the rules against a Record from the given Host. It
+// returns the Alerts that started firing or were resolved.
+func (e *alertEngine) Process(db *database.Database, h *model.Host, rec *model.Record) ([]model.Alert, error) {
+	var (
+		err     error
+		samples []model.Sample
+		changed []model.Alert
+	)
+
+	if len(e.rules) == 0 {
+		return nil, nil
+	} else if samples, err = rec.Samples(); err != nil {
+		e.log.Printf("[DEBUG] Cannot get Samples from Record %d: %s\n",
+			rec.ID,
+			err.Error())
+		return nil, nil
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	for _, r := range e.rules {
+		if !r.matchHost(h) {
+			continue
+		}
+
+		for i := range samples {
+			var (
+				a           *model.Alert
+				thr         float64
+				ok, changes bool
+				s           = &samples[i]
+			)
+
+			if !r.matchSample(s) {
+				continue
+			} else if thr, ok = r.threshold(s); !ok {
+				continue
+			}
+
+			if a, changes, err = e.evaluate(db, r, h, s, thr, rec.Timestamp); err != nil {
+				return changed, err
+			} else if changes {
+				changed = append(changed, *a)
+			}
+		}
+	}
+
+	return changed, nil
+} // func (e *alertEngine) Process(db *database.Database, h *model.Host, rec *model.Record) ([]model.Alert, error)
+
+// evaluate advances the Alert for a single Sample through its life cycle.
+// It returns true if the Alert started firing or was resolved.
+// The caller must hold the lock.
+func (e *alertEngine) evaluate(db *database.Database, r *alertRule, h *model.Host, s *model.Sample, thr float64, stamp time.Time) (*model.Alert, bool, error) {
+	var (
+		err error
+		key = model.AlertKey(r.Name, h.ID, s.Instance)
+		a   = e.alerts[key]
+	)
+
+	if a == nil {
+		if !r.breached(s.Value, thr) {
+			return nil, false, nil
+		}
+
+		a = &model.Alert{
+			Rule:      r.Name,
+			HostID:    h.ID,
+			Instance:  s.Instance,
+			State:     alertstate.Pending,
+			Value:     s.Value,
+			Threshold: thr,
+			Since:     stamp,
+			Updated:   stamp,
+		}
+
+		if r.For == 0 {
+			a.State = alertstate.Firing
+			a.Fired = stamp
+		}
+
+		if err = db.AlertAdd(a); err != nil {
+			return nil, false, err
+		}
+
+		e.alerts[key] = a
+		e.log.Printf("[INFO] Alert %s on Host %s %s is %s: %f %s %f\n",
+			r.Name,
+			h.Name,
+			s.Instance,
+			a.State,
+			s.Value,
+			r.Op,
+			thr)
+
+		return a, a.State == alertstate.Firing, nil
+	}
+
+	a.Value = s.Value
+	a.Threshold = thr
+
+	switch a.State {
+	case alertstate.Pending:
+		if !r.breached(s.Value, thr) {
+			e.log.Printf("[DEBUG] Pending Alert %s on Host %s %s has cleared\n",
+				r.Name,
+				h.Name,
+				s.Instance)
+			delete(e.alerts, key)
+			return a, false, db.AlertDelete(a)
+		} else if stamp.Sub(a.Since) < time.Duration(r.For) {
+			return a, false, nil
+		}
+
+		a.State = alertstate.Firing
+		a.Fired = stamp
+	case alertstate.Firing:
+		if !r.cleared(s.Value, thr) {
+			return a, false, nil
+		}
+
+		a.State = alertstate.Resolved
+		a.Resolved = stamp
+		delete(e.alerts, key)
+	default:
+		return a, false, nil
+	}
+
+	a.Updated = stamp
+
+	e.log.Printf("[INFO] Alert %s on Host %s %s is %s: %f %s %f\n",
+		r.Name,
+		h.Name,
+		s.Instance,
+		a.State,
+		s.Value,
+		r.Op,
+		thr)
+
+	if err = db.AlertUpdate(a); err != nil {
+		return a, false, err
+	}
+
+	return a, true, nil
+} // func (e *alertEngine) evaluate(...) (*model.Alert, bool, error)
+
+// processAlerts evaluates the alerting rules against a Record that has just
+// been added to the database.
+func (srv *Server) processAlerts(db *database.Database, h *model.Host, rec *model.Record) {
+	var (
+		err     error
+		changed []model.Alert
+	)
+
+	if changed, err = srv.alerts.Process(db, h, rec); err != nil {
+		srv.log.Printf("[ERROR] Failed to evaluate alerting rules for Record %d from Host %s: %s\n",
+			rec.ID,
+			h.Name,
+			err.Error())
+	}
+
+	if len(changed) == 0 {
+		return
+	}
+
+	var notices = make([]model.AlertNotice, len(changed))
+
+	for i, a := range changed {
+		notices[i] = model.AlertNotice{Alert: a, HostName: h.Name}
+	}
+
+	srv.notify.Enqueue(notices...)
+} // func (srv *Server) processAlerts(db *database.Database, h *model.Host, rec *model.Record)
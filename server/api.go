@@ -0,0 +1,345 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/api.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 02:21:13 krylon>
+//
+// The read-only REST API for scripts and other tools. Unlike the web service
+// for the Agents, it reports errors using the HTTP status.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blicero/donkey/database"
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/recordtype"
+	"github.com/blicero/krylib"
+	"github.com/gorilla/mux"
+)
+
+//   URLs for the API:
+//   /api/v1/hosts       -> handleAPIHostList
+//   /api/v1/hosts/{id}  -> handleAPIHostGet
+//   /api/v1/types       -> handleAPITypeList
+//   /api/v1/records     -> handleAPIRecords
+//
+//   /api/v1/records accepts the query parameters
+//   host   - ID of the Host
+//   type   - name or number of the record type
+//   begin  - start of the period, RFC 3339 or Unix time (default: unlimited)
+//   end    - end of the period, RFC 3339 or Unix time (default: now)
+//   range  - length of the period, e.g. 6h or 7d, instead of begin
+//   limit  - maximum number of Records to return
+//   after  - the value of Next from the previous page
+//   At least one of host and type is required.
+
+// Unless the client asks for a different limit, the API returns pages of
+// apiDefaultLimit Records, and never more than apiMaxLimit.
+const (
+	apiDefaultLimit = 1000
+	apiMaxLimit     = 10000
+)
+
+func (srv *Server) apiRoutes() {
+	var api = srv.router.PathPrefix("/api/v1").Subrouter()
+
+	api.HandleFunc("/hosts", srv.handleAPIHostList).Methods("GET")
+	api.HandleFunc("/hosts/{id:(?:\\d+$)}", srv.handleAPIHostGet).Methods("GET")
+	api.HandleFunc("/types", srv.handleAPITypeList).Methods("GET")
+	api.HandleFunc("/records", srv.handleAPIRecords).Methods("GET")
+} // func (srv *Server) apiRoutes()
+
+func (srv *Server) handleAPIHostList(w http.ResponseWriter, r *http.Request) {
+	srv.log.Printf("[TRACE] Handle %s from %s\n",
+		r.URL,
+		r.RemoteAddr)
+
+	var (
+		err   error
+		db    *database.Database
+		hosts []model.Host
+		list  []apiHost
+	)
+
+	db = srv.pool.Get()
+	defer srv.pool.Put(db)
+
+	if hosts, err = db.HostGetAll(); err != nil {
+		srv.sendAPIError(w, http.StatusInternalServerError,
+			fmt.Sprintf("Cannot load Hosts: %s", err.Error()))
+		return
+	}
+
+	list = make([]apiHost, len(hosts))
+	for i := range hosts {
+		list[i] = newAPIHost(&hosts[i])
+	}
+
+	srv.sendAPIResponse(w, list)
+} // func (srv *Server) handleAPIHostList(w http.ResponseWriter, r *http.Request)
+
+func (srv *Server) handleAPIHostGet(w http.ResponseWriter, r *http.Request) {
+	srv.log.Printf("[TRACE] Handle %s from %s\n",
+		r.URL,
+		r.RemoteAddr)
+
+	var (
+		err  error
+		id   int64
+		db   *database.Database
+		host *model.Host
+	)
+
+	if id, err = strconv.ParseInt(mux.Vars(r)["id"], 10, 64); err != nil {
+		srv.sendAPIError(w, http.StatusBadRequest,
+			fmt.Sprintf("Invalid Host ID %q", mux.Vars(r)["id"]))
+		return
+	}
+
+	db = srv.pool.Get()
+	defer srv.pool.Put(db)
+
+	if host, err = db.HostGetByID(krylib.ID(id)); err != nil {
+		srv.sendAPIError(w, http.StatusInternalServerError,
+			fmt.Sprintf("Cannot look up Host %d: %s", id, err.Error()))
+		return
+	} else if host == nil {
+		srv.sendAPIError(w, http.StatusNotFound,
+			fmt.Sprintf("Host %d does not exist", id))
+		return
+	}
+
+	srv.sendAPIResponse(w, newAPIHost(host))
+} // func (srv *Server) handleAPIHostGet(w http.ResponseWriter, r *http.Request)
+
+func (srv *Server) handleAPITypeList(w http.ResponseWriter, r *http.Request) {
+	srv.log.Printf("[TRACE] Handle %s from %s\n",
+		r.URL,
+		r.RemoteAddr)
+
+	var (
+		types = recordtype.All()
+		list  = make([]apiRecordType, len(types))
+	)
+
+	for i, t := range types {
+		list[i] = apiRecordType{ID: t, Name: t.String()}
+	}
+
+	srv.sendAPIResponse(w, list)
+} // func (srv *Server) handleAPITypeList(w http.ResponseWriter, r *http.Request)
+
+func (srv *Server) handleAPIRecords(w http.ResponseWriter, r *http.Request) {
+	srv.log.Printf("[TRACE] Handle %s from %s\n",
+		r.URL,
+		r.RemoteAddr)
+
+	var (
+		err    error
+		id     int64
+		typ    recordtype.ID
+		db     *database.Database
+		host   *model.Host
+		recs   []model.Record
+		rng    database.RecordRange
+		more   bool
+		page   apiRecordPage
+		params = r.URL.Query()
+	)
+
+	if rng, err = parseRecordRange(params); err != nil {
+		srv.sendAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if params.Get("host") == "" && params.Get("type") == "" {
+		srv.sendAPIError(w, http.StatusBadRequest,
+			"At least one of the parameters host and type is required")
+		return
+	} else if s := params.Get("type"); s != "" {
+		if typ, err = recordtype.Parse(s); err != nil {
+			srv.sendAPIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	db = srv.pool.Get()
+	defer srv.pool.Put(db)
+
+	if s := params.Get("host"); s != "" {
+		if id, err = strconv.ParseInt(s, 10, 64); err != nil {
+			srv.sendAPIError(w, http.StatusBadRequest,
+				fmt.Sprintf("Invalid Host ID %q", s))
+			return
+		} else if host, err = db.HostGetByID(krylib.ID(id)); err != nil {
+			srv.sendAPIError(w, http.StatusInternalServerError,
+				fmt.Sprintf("Cannot look up Host %d: %s", id, err.Error()))
+			return
+		} else if host == nil {
+			srv.sendAPIError(w, http.StatusNotFound,
+				fmt.Sprintf("Host %d does not exist", id))
+			return
+		}
+	}
+
+	switch {
+	case host != nil && params.Get("type") != "":
+		recs, err = db.RecordGetByHostTypeRange(host, typ, rng)
+	case host != nil:
+		recs, err = db.RecordGetByHostRange(host, rng)
+	default:
+		recs, err = db.RecordGetByTypeRange(typ, rng)
+	}
+
+	if err != nil {
+		srv.sendAPIError(w, http.StatusInternalServerError,
+			fmt.Sprintf("Cannot load Records: %s", err.Error()))
+		return
+	}
+
+	page.Records = make([]apiRecord, len(recs))
+	for i := range recs {
+		page.Records[i] = newAPIRecord(&recs[i])
+	}
+
+	if rng, more = rng.Next(recs); more {
+		page.Next = formatCursor(rng.After)
+	}
+
+	srv.sendAPIResponse(w, &page)
+} // func (srv *Server) handleAPIRecords(w http.ResponseWriter, r *http.Request)
+
+// parseRecordRange builds a RecordRange from the query parameters begin,
+// end, range, limit and after.
+func parseRecordRange(params map[string][]string) (database.RecordRange, error) {
+	var (
+		err    error
+		period time.Duration
+		rng    = database.RecordRange{
+			End:   time.Now(),
+			Limit: apiDefaultLimit,
+		}
+		get = func(key string) string {
+			if v := params[key]; len(v) > 0 {
+				return v[0]
+			}
+			return ""
+		}
+	)
+
+	if s := get("end"); s != "" {
+		if rng.End, err = parseTime(s); err != nil {
+			return rng, err
+		}
+	}
+
+	if s := get("begin"); s != "" {
+		if rng.Begin, err = parseTime(s); err != nil {
+			return rng, err
+		}
+	} else if s = get("range"); s != "" {
+		if period, err = parseRange(s); err != nil {
+			return rng, err
+		}
+		rng.Begin = rng.End.Add(-period)
+	}
+
+	if s := get("limit"); s != "" {
+		if rng.Limit, err = strconv.ParseInt(s, 10, 64); err != nil || rng.Limit < 1 {
+			return rng, fmt.Errorf("Invalid limit %q", s)
+		} else if rng.Limit > apiMaxLimit {
+			rng.Limit = apiMaxLimit
+		}
+	}
+
+	if s := get("after"); s != "" {
+		if rng.After, err = parseCursor(s); err != nil {
+			return rng, err
+		}
+	}
+
+	if !rng.Begin.IsZero() && rng.Begin.After(rng.End) {
+		return rng, fmt.Errorf("Begin of period (%s) is after its end (%s)",
+			rng.Begin.Format(time.RFC3339),
+			rng.End.Format(time.RFC3339))
+	}
+
+	return rng, nil
+} // func parseRecordRange(params map[string][]string) (database.RecordRange, error)
+
+// parseTime parses a timestamp in RFC 3339 format or as Unix time.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	} else if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+
+	return time.Time{}, fmt.Errorf("Invalid timestamp %q", s)
+} // func parseTime(s string) (time.Time, error)
+
+// formatCursor and parseCursor convert a Cursor to and from the string the
+// API passes to clients.
+func formatCursor(c database.Cursor) string {
+	return fmt.Sprintf("%d_%d", c.Timestamp.Unix(), c.ID)
+} // func formatCursor(c database.Cursor) string
+
+func parseCursor(s string) (database.Cursor, error) {
+	var (
+		err       error
+		c         database.Cursor
+		stamp, id int64
+		parts     = strings.Split(s, "_")
+	)
+
+	if len(parts) != 2 {
+		return c, fmt.Errorf("Invalid cursor %q", s)
+	} else if stamp, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
+		return c, fmt.Errorf("Invalid cursor %q", s)
+	} else if id, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+		return c, fmt.Errorf("Invalid cursor %q", s)
+	}
+
+	c.Timestamp = time.Unix(stamp, 0)
+	c.ID = id
+
+	return c, nil
+} // func parseCursor(s string) (database.Cursor, error)
+
+func (srv *Server) sendAPIResponse(w http.ResponseWriter, v any) {
+	var (
+		err  error
+		rbuf []byte
+	)
+
+	if rbuf, err = json.Marshal(v); err != nil {
+		srv.sendAPIError(w, http.StatusInternalServerError,
+			fmt.Sprintf("Error serializing response: %s", err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store, max-age=0")
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(rbuf); err != nil {
+		srv.log.Printf("[ERROR] Failed to send API response: %s\n",
+			err.Error())
+	}
+} // func (srv *Server) sendAPIResponse(w http.ResponseWriter, v any)
+
+func (srv *Server) sendAPIError(w http.ResponseWriter, status int, msg string) {
+	srv.log.Printf("[ERROR] API request failed: %s\n", msg)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store, max-age=0")
+	w.WriteHeader(status)
+	if _, err := w.Write(errJSON(msg)); err != nil {
+		srv.log.Printf("[ERROR] Failed to send API error: %s\n",
+			err.Error())
+	}
+} // func (srv *Server) sendAPIError(w http.ResponseWriter, status int, msg string)
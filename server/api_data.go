@@ -0,0 +1,79 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/api_data.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 01:58:40 krylon>
+//
+// This file contains the data structures the REST API sends to clients.
+// They are separate from the types in model, so the API stays stable when
+// those change.
+
+package server
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/recordtype"
+	"github.com/blicero/krylib"
+)
+
+type apiHost struct {
+	ID          krylib.ID
+	Name        string
+	Addr        string
+	OS          string
+	LastContact time.Time
+	State       string
+}
+
+func newAPIHost(h *model.Host) apiHost {
+	return apiHost{
+		ID:          h.ID,
+		Name:        h.Name,
+		Addr:        h.Addr,
+		OS:          h.OS,
+		LastContact: h.LastContact,
+		State:       h.State.String(),
+	}
+} // func newAPIHost(h *model.Host) apiHost
+
+type apiRecordType struct {
+	ID   recordtype.ID
+	Name string
+}
+
+// apiRecord is a Record, the payload is passed on as the JSON object the
+// Agent reported. Should a payload not be valid JSON, it is passed on as a
+// string.
+type apiRecord struct {
+	ID        int64
+	HostID    int64
+	Timestamp time.Time
+	Type      string
+	Payload   json.RawMessage
+}
+
+func newAPIRecord(r *model.Record) apiRecord {
+	var rec = apiRecord{
+		ID:        r.ID,
+		HostID:    r.HostID,
+		Timestamp: r.Timestamp,
+		Type:      r.Source.String(),
+		Payload:   json.RawMessage(r.Payload),
+	}
+
+	if !json.Valid(rec.Payload) {
+		rec.Payload, _ = json.Marshal(r.Payload)
+	}
+
+	return rec
+} // func newAPIRecord(r *model.Record) apiRecord
+
+// apiRecordPage is a page of Records. If there are more Records, Next is
+// the value to pass in the parameter after to get the next page.
+type apiRecordPage struct {
+	Records []apiRecord
+	Next    string `json:",omitempty"`
+}
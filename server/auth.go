@@ -0,0 +1,155 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/auth.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 04:02:15 krylon>
+//
+// Authentication of Agents. When a Host registers, the Server issues a
+// random token to its Agent, which the Agent has to present in the
+// Authorization header of every report. The Server only stores a hash of
+// the token.
+
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/blicero/donkey/database"
+	"github.com/blicero/donkey/model"
+)
+
+const (
+	tokenSize    = 32
+	bearerPrefix = "Bearer "
+)
+
+var (
+	errNoToken  = errors.New("Request does not carry a token")
+	errBadToken = errors.New("Token does not match")
+)
+
+// newToken creates a random token and returns it along with its hash.
+func newToken() (string, string, error) {
+	var buf = make([]byte, tokenSize)
+
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+
+	var token = hex.EncodeToString(buf)
+
+	return token, hashToken(token), nil
+} // func newToken() (string, string, error)
+
+// hashToken returns the hex-encoded SHA-256 hash of a token.
+func hashToken(token string) string {
+	var sum = sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+} // func hashToken(token string) string
+
+// bearerToken extracts the token from a request's Authorization header.
+func bearerToken(r *http.Request) string {
+	var hdr = r.Header.Get("Authorization")
+
+	if !strings.HasPrefix(hdr, bearerPrefix) {
+		return ""
+	}
+
+	return strings.TrimSpace(hdr[len(bearerPrefix):])
+} // func bearerToken(r *http.Request) string
+
+// authenticate checks the token carried by a request against the one issued
+// to the given Host. If the check fails, it returns the HTTP status to reply
+// with along with an error.
+func (srv *Server) authenticate(db *database.Database, r *http.Request, h *model.Host) (int, error) {
+	var (
+		err           error
+		token, stored string
+	)
+
+	if err = checkClientCert(r, h); err != nil {
+		srv.log.Printf("[INFO] Rejecting request from %s: %s\n",
+			r.RemoteAddr,
+			err.Error())
+		return http.StatusForbidden, err
+	} else if token = bearerToken(r); token == "" {
+		srv.log.Printf("[INFO] Request from %s for Host %s (%d) does not carry a token\n",
+			r.RemoteAddr,
+			h.Name,
+			h.ID)
+		return http.StatusUnauthorized, errNoToken
+	} else if stored, err = db.HostGetToken(h.ID); err != nil {
+		srv.log.Printf("[ERROR] Cannot get token of Host %s (%d): %s\n",
+			h.Name,
+			h.ID,
+			err.Error())
+		return http.StatusInternalServerError, err
+	} else if stored == "" ||
+		subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(stored)) != 1 {
+		srv.log.Printf("[INFO] Request from %s presented an invalid token for Host %s (%d)\n",
+			r.RemoteAddr,
+			h.Name,
+			h.ID)
+		return http.StatusForbidden, errBadToken
+	}
+
+	return http.StatusOK, nil
+} // func (srv *Server) authenticate(db *database.Database, r *http.Request, h *model.Host) (int, error)
+
+// issueToken creates a new token for a Host, replacing the old one, if any.
+func (srv *Server) issueToken(db *database.Database, h *model.Host) (string, error) {
+	var (
+		err         error
+		token, hash string
+	)
+
+	if token, hash, err = newToken(); err != nil {
+		srv.log.Printf("[ERROR] Cannot create token for Host %s: %s\n",
+			h.Name,
+			err.Error())
+		return "", err
+	} else if err = db.HostUpdateToken(h, hash); err != nil {
+		return "", fmt.Errorf("Cannot store token of Host %s: %w",
+			h.Name,
+			err)
+	}
+
+	return token, nil
+} // func (srv *Server) issueToken(db *database.Database, h *model.Host) (string, error)
+
+// mayRegisterAgain decides if an Agent may register a Host that is already
+// known, which gets it a new token. The Agent has to present the current
+// token, unless the Host was registered before the Server started issuing
+// tokens, in which case the first Agent to register claims it.
+func (srv *Server) mayRegisterAgain(db *database.Database, r *http.Request, h *model.Host) (int, error) {
+	var (
+		err    error
+		stored string
+	)
+
+	if stored, err = db.HostGetToken(h.ID); err != nil {
+		srv.log.Printf("[ERROR] Cannot get token of Host %s (%d): %s\n",
+			h.Name,
+			h.ID,
+			err.Error())
+		return http.StatusInternalServerError, err
+	} else if stored == "" {
+		srv.log.Printf("[INFO] Host %s (%d) has no token, yet, %s claims it\n",
+			h.Name,
+			h.ID,
+			r.RemoteAddr)
+		return http.StatusOK, nil
+	} else if bearerToken(r) == "" {
+		return http.StatusConflict, errNoToken
+	}
+
+	return srv.authenticate(db, r, h)
+} // func (srv *Server) mayRegisterAgain(db *database.Database, r *http.Request, h *model.Host) (int, error)
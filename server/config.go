@@ -0,0 +1,294 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/config.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 07:58:40 krylon>
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/blicero/donkey/common"
+	"github.com/blicero/donkey/logdomain"
+	"github.com/blicero/donkey/model/recordtype"
+	"github.com/blicero/donkey/model/resolution"
+	"github.com/hashicorp/logutils"
+)
+
+// Config holds the Server's settings, as read from common.ServerConfPath.
+//
+// Addr is the address to listen on, DBPath the database file, and PoolSize
+// the number of database connections. LogLevels sets the minimum log level
+// per log domain, e.g. {"Database": "INFO"}, domains not listed log
+// everything. Retention overrides parts of the default RetentionPolicy. If
+// TLS is set, the Server uses HTTPS.
+//
+// When the Server receives SIGHUP, it reads the file again and applies
+// LogLevels and Retention. Changing any of the other settings requires a
+// restart. The configuration handed out to Agents is reloaded, too.
+type Config struct {
+	Addr      string
+	DBPath    string
+	PoolSize  int
+	Debug     bool
+	LogLevels map[string]string `json:",omitempty"`
+	Retention *RetentionConfig  `json:",omitempty"`
+	TLS       *TLSConfig        `json:",omitempty"`
+
+	path      string
+	levels    map[logdomain.ID]logutils.LogLevel
+	retention RetentionPolicy
+}
+
+// RetentionConfig is how a RetentionPolicy is written in the configuration
+// file. Durations are given in the format understood by time.ParseDuration
+// or as a number of days, e.g. "7d", a duration of "0" keeps data forever.
+// Types of Records and resolutions are given by name, e.g.
+// {"RawByType": {"Sensors": "30d"}, "Rollups": {"Minute": "14d"}}.
+// Anything left out keeps its default.
+type RetentionConfig struct {
+	Raw       string            `json:",omitempty"`
+	RawByType map[string]string `json:",omitempty"`
+	Rollups   map[string]string `json:",omitempty"`
+}
+
+// DefaultConfig returns the settings the Server uses if there is no
+// configuration file.
+func DefaultConfig() *Config {
+	return &Config{
+		Addr:     fmt.Sprintf(":%d", common.Port),
+		DBPath:   common.DbPath,
+		PoolSize: defaultPoolSize,
+		Debug:    common.Debug,
+	}
+} // func DefaultConfig() *Config
+
+// ReadConfig reads the Server's configuration from the given file. Settings
+// missing from the file keep their defaults, if the file does not exist at
+// all, the defaults are used for everything.
+func ReadConfig(path string) (*Config, error) {
+	var (
+		err error
+		buf []byte
+		dec *json.Decoder
+		cfg = DefaultConfig()
+	)
+
+	cfg.path = path
+
+	if buf, err = os.ReadFile(path); err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	} else {
+		dec = json.NewDecoder(bytes.NewReader(buf))
+		dec.DisallowUnknownFields()
+
+		if err = dec.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("Cannot parse %s: %s", path, err.Error())
+		}
+	}
+
+	if err = cfg.validate(); err != nil {
+		return nil, fmt.Errorf("Invalid configuration in %s: %w", path, err)
+	}
+
+	return cfg, nil
+} // func ReadConfig(path string) (*Config, error)
+
+// validate checks the settings for nonsensical values.
+func (c *Config) validate() error {
+	var err error
+
+	if _, _, err = net.SplitHostPort(c.Addr); err != nil {
+		return fmt.Errorf("Invalid address %q: %s", c.Addr, err.Error())
+	} else if c.DBPath == "" {
+		return errors.New("No database path given")
+	} else if c.PoolSize < 1 {
+		return fmt.Errorf("Invalid pool size %d", c.PoolSize)
+	}
+
+	c.levels = make(map[logdomain.ID]logutils.LogLevel, len(c.LogLevels))
+
+	for name, level := range c.LogLevels {
+		var (
+			dom logdomain.ID
+			lvl logutils.LogLevel
+		)
+
+		if dom, err = logdomain.Parse(name); err != nil {
+			return err
+		} else if lvl, err = common.ParseLogLevel(level); err != nil {
+			return fmt.Errorf("Log domain %s: %w", name, err)
+		}
+
+		c.levels[dom] = lvl
+	}
+
+	if c.Retention == nil {
+		c.retention = DefaultRetention()
+	} else if c.retention, err = c.Retention.policy(); err != nil {
+		return err
+	}
+
+	if c.TLS != nil {
+		if _, err = c.TLS.config(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+} // func (c *Config) validate() error
+
+// applyLogLevels sets the log levels of all log domains. Domains that are
+// not mentioned in the configuration log everything.
+func (c *Config) applyLogLevels() error {
+	for _, dom := range logdomain.AllDomains() {
+		var (
+			found bool
+			lvl   logutils.LogLevel
+		)
+
+		if lvl, found = c.levels[dom]; !found {
+			lvl = common.MinLogLevel
+		}
+
+		if err := common.SetLogLevel(dom, lvl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+} // func (c *Config) applyLogLevels() error
+
+// parseRetention parses a retention period. "0" means forever.
+func parseRetention(s string) (time.Duration, error) {
+	if s == "0" {
+		return 0, nil
+	}
+
+	return parseRange(s)
+} // func parseRetention(s string) (time.Duration, error)
+
+// policy turns the RetentionConfig into a RetentionPolicy, starting from
+// the default one.
+func (rc *RetentionConfig) policy() (RetentionPolicy, error) {
+	var (
+		err error
+		p   = DefaultRetention()
+	)
+
+	if rc.Raw != "" {
+		if p.Raw, err = parseRetention(rc.Raw); err != nil {
+			return p, fmt.Errorf("Retention for Records: %w", err)
+		}
+	}
+
+	for name, s := range rc.RawByType {
+		var (
+			t recordtype.ID
+			d time.Duration
+		)
+
+		if t, err = recordtype.Parse(name); err != nil {
+			return p, err
+		} else if d, err = parseRetention(s); err != nil {
+			return p, fmt.Errorf("Retention for %s Records: %w", name, err)
+		}
+
+		p.RawByType[t] = d
+	}
+
+RESOLUTION:
+	for name, s := range rc.Rollups {
+		for _, res := range resolution.Rollups {
+			if strings.EqualFold(name, res.String()) {
+				if p.Rollups[res], err = parseRetention(s); err != nil {
+					return p, fmt.Errorf("Retention for rollups by %s: %w", name, err)
+				}
+				continue RESOLUTION
+			}
+		}
+
+		return p, fmt.Errorf("Unknown resolution %q", name)
+	}
+
+	return p, p.validate()
+} // func (rc *RetentionConfig) policy() (RetentionPolicy, error)
+
+// reloadConfig reads the configuration file again and applies the settings
+// that can be changed while the Server is running. Changes to the others
+// are logged and ignored.
+func (srv *Server) reloadConfig() error {
+	var (
+		err error
+		cfg *Config
+		old = srv.cfg
+	)
+
+	if old.path == "" {
+		srv.log.Println("[INFO] Server was not started from a configuration file, nothing to reload")
+		return nil
+	} else if cfg, err = ReadConfig(old.path); err != nil {
+		srv.log.Printf("[ERROR] Cannot reload configuration, keeping the current one: %s\n",
+			err.Error())
+		return err
+	}
+
+	if cfg.Addr != old.Addr ||
+		cfg.DBPath != old.DBPath ||
+		cfg.PoolSize != old.PoolSize ||
+		cfg.Debug != old.Debug ||
+		!reflect.DeepEqual(cfg.TLS, old.TLS) {
+		srv.log.Printf("[WARN] Changes to Addr, DBPath, PoolSize, Debug and TLS in %s take effect after a restart\n",
+			old.path)
+	}
+
+	if err = cfg.applyLogLevels(); err != nil {
+		srv.log.Printf("[ERROR] Cannot set log levels: %s\n",
+			err.Error())
+		return err
+	} else if err = srv.SetRetention(cfg.retention); err != nil {
+		srv.log.Printf("[ERROR] Cannot set retention policy: %s\n",
+			err.Error())
+		return err
+	}
+
+	srv.log.Printf("[INFO] Reloaded configuration from %s\n", old.path)
+	return nil
+} // func (srv *Server) reloadConfig() error
+
+// watchConfig reloads the configuration and the configuration for the
+// Agents whenever the Server receives SIGHUP.
+func (srv *Server) watchConfig() {
+	var (
+		sigq   = make(chan os.Signal, 1)
+		ticker = time.NewTicker(common.HeartBeat)
+	)
+
+	signal.Notify(sigq, syscall.SIGHUP)
+	defer signal.Stop(sigq)
+	defer ticker.Stop()
+
+	for srv.IsActive() {
+		select {
+		case <-srv.done:
+			return
+		case <-sigq:
+			srv.reloadConfig() // nolint: errcheck
+			srv.loadFleet()    // nolint: errcheck
+		case <-ticker.C:
+		}
+	}
+} // func (srv *Server) watchConfig()
@@ -0,0 +1,194 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/fleet.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 10:12:45 krylon>
+//
+// Central management of Agent configuration: The Server hands out the
+// settings from the fleet configuration file to Agents that ask for them.
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/blicero/donkey/common"
+	"github.com/blicero/donkey/model"
+)
+
+// fleetGroup applies an Agent configuration to all Hosts whose name matches
+// the regular expression Hosts.
+type fleetGroup struct {
+	Name    string
+	Hosts   string
+	Config  model.AgentConfig
+	hostPat *regexp.Regexp
+}
+
+// fleetConfig is the content of the fleet configuration file.
+//
+// The configuration an Agent receives is put together from Defaults, then
+// the Groups its Host belongs to, in the order they are listed, then the
+// entry for its Host in Hosts, if any. Each of them overrides the settings
+// it contains, Probes are replaced as a whole rather than merged.
+type fleetConfig struct {
+	Defaults *model.AgentConfig            `json:",omitempty"`
+	Groups   []*fleetGroup                 `json:",omitempty"`
+	Hosts    map[string]*model.AgentConfig `json:",omitempty"`
+}
+
+// readFleetConfig reads the fleet configuration from the given file. If the
+// file does not exist, an empty configuration is returned.
+func readFleetConfig(path string) (*fleetConfig, error) {
+	var (
+		err   error
+		buf   []byte
+		cfg   = new(fleetConfig)
+		names = make(map[string]bool)
+	)
+
+	if buf, err = os.ReadFile(path); err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, err
+	} else if err = json.Unmarshal(buf, cfg); err != nil {
+		return nil, fmt.Errorf("Cannot parse %s: %s", path, err.Error())
+	}
+
+	if cfg.Defaults != nil {
+		if err = validateAgentConfig(cfg.Defaults); err != nil {
+			return nil, fmt.Errorf("Defaults: %w", err)
+		}
+	}
+
+	for _, g := range cfg.Groups {
+		if g.Name == "" {
+			return nil, errors.New("Group has no name")
+		} else if names[g.Name] {
+			return nil, fmt.Errorf("Duplicate group %q", g.Name)
+		} else if g.Hosts == "" {
+			return nil, fmt.Errorf("Group %q has no host pattern", g.Name)
+		} else if g.hostPat, err = regexp.Compile(g.Hosts); err != nil {
+			return nil, fmt.Errorf("Group %q: invalid host pattern: %s", g.Name, err.Error())
+		} else if err = validateAgentConfig(&g.Config); err != nil {
+			return nil, fmt.Errorf("Group %q: %w", g.Name, err)
+		}
+
+		names[g.Name] = true
+	}
+
+	for name, c := range cfg.Hosts {
+		if c == nil {
+			return nil, fmt.Errorf("Host %q has no configuration", name)
+		} else if err = validateAgentConfig(c); err != nil {
+			return nil, fmt.Errorf("Host %q: %w", name, err)
+		}
+	}
+
+	return cfg, nil
+} // func readFleetConfig(path string) (*fleetConfig, error)
+
+// validateAgentConfig checks an Agent configuration for nonsensical values.
+// Which Probes exist is up to the Agent.
+func validateAgentConfig(c *model.AgentConfig) error {
+	for name, interval := range c.Probes {
+		if name == "" {
+			return errors.New("Probe has no name")
+		} else if interval < 0 {
+			return fmt.Errorf("Interval of Probe %s must not be negative", name)
+		}
+	}
+
+	if c.BatchSize < 0 || c.BatchSize > model.MaxBatchSize {
+		return fmt.Errorf("Invalid batch size %d", c.BatchSize)
+	} else if c.BatchInterval < 0 {
+		return fmt.Errorf("Invalid batch interval %d", c.BatchInterval)
+	}
+
+	return nil
+} // func validateAgentConfig(c *model.AgentConfig) error
+
+// configFor returns the configuration for the Host with the given name, or
+// nil if the fleet configuration has nothing for it.
+func (f *fleetConfig) configFor(name string) *model.AgentConfig {
+	var (
+		cfg   model.AgentConfig
+		found bool
+		apply = func(c *model.AgentConfig) {
+			found = true
+			if c.Probes != nil {
+				cfg.Probes = make(map[string]int, len(c.Probes))
+				for k, v := range c.Probes {
+					cfg.Probes[k] = v
+				}
+			}
+			if c.BatchSize != 0 {
+				cfg.BatchSize = c.BatchSize
+			}
+			if c.BatchInterval != 0 {
+				cfg.BatchInterval = c.BatchInterval
+			}
+		}
+	)
+
+	if f.Defaults != nil {
+		apply(f.Defaults)
+	}
+
+	for _, g := range f.Groups {
+		if g.hostPat.MatchString(name) {
+			apply(&g.Config)
+		}
+	}
+
+	if c, ok := f.Hosts[name]; ok {
+		apply(c)
+	}
+
+	if !found {
+		return nil
+	}
+
+	return &cfg
+} // func (f *fleetConfig) configFor(name string) *model.AgentConfig
+
+// loadFleet reads the fleet configuration file and replaces the current
+// one. If the file cannot be read, the current one is kept.
+func (srv *Server) loadFleet() error {
+	var (
+		err error
+		cfg *fleetConfig
+	)
+
+	if cfg, err = readFleetConfig(common.FleetConfPath); err != nil {
+		srv.log.Printf("[ERROR] Cannot read Agent configuration from %s: %s\n",
+			common.FleetConfPath,
+			err.Error())
+		return err
+	}
+
+	srv.log.Printf("[INFO] Loaded Agent configuration for %d groups and %d Hosts from %s\n",
+		len(cfg.Groups),
+		len(cfg.Hosts),
+		common.FleetConfPath)
+
+	srv.setFleet(cfg)
+	return nil
+} // func (srv *Server) loadFleet() error
+
+func (srv *Server) setFleet(cfg *fleetConfig) {
+	srv.lock.Lock()
+	srv.fleet = cfg
+	srv.lock.Unlock()
+} // func (srv *Server) setFleet(cfg *fleetConfig)
+
+func (srv *Server) getFleet() *fleetConfig {
+	srv.lock.RLock()
+	defer srv.lock.RUnlock()
+	return srv.fleet
+} // func (srv *Server) getFleet() *fleetConfig
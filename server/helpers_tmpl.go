@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"github.com/blicero/donkey/common"
+	"github.com/blicero/donkey/model/hoststate"
 
 	"github.com/mborgerson/GoTruncateHtml/truncatehtml"
 )
@@ -37,6 +38,7 @@ var funcmap = template.FuncMap{
 	"fmt_bytes":        formatBytes,
 	"fmt_time":         formatTime,
 	"fmt_time_minute":  formatTimeMinute,
+	"fmt_age":          formatAge,
 	"fmt_float":        formatFloat,
 	"current_year":     currentYear,
 	"minutes":          minutes,
@@ -55,6 +57,7 @@ var funcmap = template.FuncMap{
 	"truncate":         truncateHTML,
 	"intRange":         intRange,
 	"inc":              inc,
+	"state_badge":      stateBadge,
 }
 
 type generator struct {
@@ -167,6 +170,14 @@ func formatTimeMinute(t time.Time) string {
 	return t.Format(common.TimestampFormatMinute)
 } // func formatTimeMinute(t time.Time) string
 
+func formatAge(t time.Time) string {
+	if t.Unix() <= 0 {
+		return "never"
+	}
+
+	return time.Since(t).Round(time.Second).String() + " ago"
+} // func formatAge(t time.Time) string
+
 func formatFloat(f float64) string {
 	return fmt.Sprintf("%.1f", f)
 } // func formatFloat(f float64) string
@@ -310,3 +321,16 @@ func intRange(n int64) []int64 {
 func inc(n int64) int64 {
 	return n + 1
 } // func inc(n int64) int64
+
+func stateBadge(s hoststate.State) string {
+	switch s {
+	case hoststate.Up:
+		return "bg-success"
+	case hoststate.Stale:
+		return "bg-warning text-dark"
+	case hoststate.Down:
+		return "bg-danger"
+	default:
+		return "bg-secondary"
+	}
+} // func stateBadge(s hoststate.State) string
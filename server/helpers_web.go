@@ -16,14 +16,14 @@ import (
 	"github.com/blicero/donkey/common"
 )
 
-func errJSON(msg string) []byte { // nolint: unused,deadcode
-	var res = fmt.Sprintf(`{ "Status": false, "Message": %q }`,
+func errJSON(msg string) []byte {
+	var res = fmt.Sprintf(`{ "Status": false, "Message": "%s" }`,
 		jsonEscape(msg))
 
 	return []byte(res)
 } // func errJSON(msg string) []byte
 
-func jsonEscape(i string) string { // nolint: unused
+func jsonEscape(i string) string {
 	b, err := json.Marshal(i)
 	if err != nil {
 		panic(err)
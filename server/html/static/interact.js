@@ -1170,3 +1170,130 @@ function page_frame_resize () {
     console.log(msg)
     alert(msg)
 } // function page_frame_resize ()
+
+const chartColors = [
+    '#1f77b4', '#ff7f0e', '#2ca02c', '#d62728', '#9467bd',
+    '#8c564b', '#e377c2', '#7f7f7f', '#bcbd22', '#17becf',
+]
+
+function loadHostCharts (host_id) {
+    const range = $('#chart_range')[0].value
+
+    $('.host_chart').each((idx, div) => {
+        const type = div.dataset.type
+        const url = `/ajax/host/${host_id}/chart/${type}`
+        const body = $(div).find('.chart_body')[0]
+
+        const req = $.get(url,
+                          { 'range': range },
+                          (reply) => {
+                              if (reply.Status) {
+                                  renderChart(body, reply)
+                              } else {
+                                  const msg = `Error loading chart ${type} of Host ${host_id}: ${reply.Message}`
+                                  console.error(msg)
+                                  body.innerHTML = msg
+                              }
+                          },
+                          'json')
+
+        req.fail((rep, stat, xhr) => {
+            console.error(`Error requesting chart ${type} of Host ${host_id}: ${rep} / ${stat} / ${xhr}`)
+        })
+    })
+} // function loadHostCharts (host_id)
+
+function svgElement (tag, attrs) {
+    const elt = document.createElementNS('http://www.w3.org/2000/svg', tag)
+
+    for (const [key, val] of Object.entries(attrs)) {
+        elt.setAttribute(key, val)
+    }
+
+    return elt
+} // function svgElement (tag, attrs)
+
+function renderChart (container, data) {
+    const width = 800
+    const height = 240
+    const margin = { top: 10, right: 10, bottom: 25, left: 60 }
+    const series = data.Series || []
+
+    container.innerHTML = ''
+
+    if (series.length === 0) {
+        container.innerHTML = 'No data in this time range'
+        return
+    }
+
+    const t0 = new Date(data.Begin).getTime() / 1000
+    const t1 = new Date(data.End).getTime() / 1000
+    let vmin = Infinity
+    let vmax = -Infinity
+
+    for (const s of series) {
+        for (const [, v] of s.Points) {
+            vmin = Math.min(vmin, v)
+            vmax = Math.max(vmax, v)
+        }
+    }
+
+    if (vmin > 0) {
+        vmin = 0
+    }
+    if (vmax === vmin) {
+        vmax = vmin + 1
+    }
+
+    const x = (t) => margin.left + (t - t0) / (t1 - t0) * (width - margin.left - margin.right)
+    const y = (v) => height - margin.bottom - (v - vmin) / (vmax - vmin) * (height - margin.top - margin.bottom)
+
+    const svg = svgElement('svg', {
+        'viewBox': `0 0 ${width} ${height}`,
+        'width': '100%',
+        'class': 'chart',
+    })
+
+    svg.appendChild(svgElement('line', {
+        'x1': margin.left, 'y1': y(vmin), 'x2': width - margin.right, 'y2': y(vmin), 'class': 'axis',
+    }))
+    svg.appendChild(svgElement('line', {
+        'x1': margin.left, 'y1': margin.top, 'x2': margin.left, 'y2': y(vmin), 'class': 'axis',
+    }))
+
+    const labels = [
+        [margin.left - 5, y(vmax) + 4, 'end', vmax.toFixed(1)],
+        [margin.left - 5, y(vmin), 'end', vmin.toFixed(1)],
+        [margin.left, height - 5, 'start', timeStampString(new Date(t0 * 1000))],
+        [width - margin.right, height - 5, 'end', timeStampString(new Date(t1 * 1000))],
+    ]
+
+    for (const [lx, ly, anchor, text] of labels) {
+        const label = svgElement('text', { 'x': lx, 'y': ly, 'text-anchor': anchor, 'class': 'label' })
+        label.textContent = text
+        svg.appendChild(label)
+    }
+
+    const legend = document.createElement('div')
+    legend.className = 'chart_legend'
+
+    series.forEach((s, idx) => {
+        const color = chartColors[idx % chartColors.length]
+        const points = s.Points.map(([t, v]) => `${x(t).toFixed(1)},${y(v).toFixed(1)}`)
+
+        svg.appendChild(svgElement('polyline', {
+            'points': points.join(' '),
+            'fill': 'none',
+            'stroke': color,
+            'stroke-width': 1.5,
+        }))
+
+        const item = document.createElement('span')
+        item.style.color = color
+        item.textContent = s.Name
+        legend.appendChild(item)
+    })
+
+    container.appendChild(svg)
+    container.appendChild(legend)
+} // function renderChart (container, data)
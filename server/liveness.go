@@ -0,0 +1,214 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/liveness.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 21:24:50 krylon>
+
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/blicero/donkey/common"
+	"github.com/blicero/donkey/database"
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/hoststate"
+)
+
+// Unless configured otherwise, we expect to hear from every Host at least
+// every defaultContactInterval, or the interval its Agent reported, if that
+// is longer. A Host that has been silent for defaultStaleAfter intervals is
+// considered stale, after defaultDownAfter intervals it is considered down.
+const (
+	defaultContactInterval = time.Second * 30
+	defaultStaleAfter      = 4
+	defaultDownAfter       = 10
+)
+
+type liveness struct {
+	interval   time.Duration
+	staleAfter int
+	downAfter  int
+}
+
+// forHost returns the liveness settings for the given Host: If its Agent
+// told us it may be silent for longer than the interval we expect to hear
+// from Hosts, its interval is used instead.
+func (l *liveness) forHost(h *model.Host) liveness {
+	var hl = *l
+
+	if h.Interval > hl.interval {
+		hl.interval = h.Interval
+	}
+
+	return hl
+} // func (l *liveness) forHost(h *model.Host) liveness
+
+// state returns the state a Host should be in if we last heard from it
+// at the given time.
+func (l *liveness) state(last, now time.Time) hoststate.State {
+	var silence = now.Sub(last)
+
+	if last.Unix() <= 0 {
+		return hoststate.Unknown
+	} else if silence >= l.interval*time.Duration(l.downAfter) {
+		return hoststate.Down
+	} else if silence >= l.interval*time.Duration(l.staleAfter) {
+		return hoststate.Stale
+	}
+
+	return hoststate.Up
+} // func (l *liveness) state(last, now time.Time) hoststate.State
+
+// SetLiveness configures how the Server decides if a Host is alive. interval
+// is how often we expect to hear from a Host, if a Host has been silent for
+// staleAfter intervals, it is considered stale, after downAfter intervals,
+// it is considered down.
+func (srv *Server) SetLiveness(interval time.Duration, staleAfter, downAfter int) error {
+	if interval <= 0 {
+		return fmt.Errorf("Invalid contact interval %s", interval)
+	} else if staleAfter < 1 || downAfter <= staleAfter {
+		return fmt.Errorf("Invalid thresholds: stale after %d, down after %d intervals",
+			staleAfter,
+			downAfter)
+	}
+
+	srv.lock.Lock()
+	srv.live = liveness{
+		interval:   interval,
+		staleAfter: staleAfter,
+		downAfter:  downAfter,
+	}
+	srv.lock.Unlock()
+
+	return nil
+} // func (srv *Server) SetLiveness(interval time.Duration, staleAfter, downAfter int) error
+
+func (srv *Server) getLiveness() liveness {
+	srv.lock.RLock()
+	defer srv.lock.RUnlock()
+	return srv.live
+} // func (srv *Server) getLiveness() liveness
+
+// touchHost records that we just heard from the given Host.
+func (srv *Server) touchHost(db *database.Database, h *model.Host) error {
+	var (
+		err error
+		now = time.Now()
+	)
+
+	if err = db.HostUpdateLastContact(h, now); err != nil {
+		srv.log.Printf("[ERROR] Cannot update last contact of Host %s (%d): %s\n",
+			h.Name,
+			h.ID,
+			err.Error())
+		return err
+	} else if h.State == hoststate.Up {
+		return nil
+	}
+
+	srv.log.Printf("[INFO] Host %s (%d) is up (was %s)\n",
+		h.Name,
+		h.ID,
+		h.State)
+
+	if err = db.HostUpdateState(h, hoststate.Up, now); err != nil {
+		srv.log.Printf("[ERROR] Cannot update state of Host %s (%d): %s\n",
+			h.Name,
+			h.ID,
+			err.Error())
+		return err
+	}
+
+	return nil
+} // func (srv *Server) touchHost(db *database.Database, h *model.Host) error
+
+// updateInterval records how long the Agent of the given Host says it may go
+// without contacting us, in seconds. Agents that do not tell us send an
+// empty string.
+func (srv *Server) updateInterval(db *database.Database, h *model.Host, val string) {
+	var (
+		err     error
+		seconds int64
+	)
+
+	if val == "" {
+		return
+	} else if seconds, err = strconv.ParseInt(val, 10, 64); err != nil || seconds < 0 {
+		srv.log.Printf("[ERROR] Host %s (%d) sent invalid contact interval %q\n",
+			h.Name,
+			h.ID,
+			val)
+		return
+	} else if interval := time.Second * time.Duration(seconds); interval != h.Interval {
+		srv.log.Printf("[INFO] Host %s (%d) contacts us at least every %s\n",
+			h.Name,
+			h.ID,
+			interval)
+		db.HostUpdateInterval(h, interval) // nolint: errcheck
+	}
+} // func (srv *Server) updateInterval(db *database.Database, h *model.Host, val string)
+
+// watchHosts periodically checks when we last heard from each Host and marks
+// Hosts that have gone silent as stale or down.
+func (srv *Server) watchHosts() {
+	var ticker = time.NewTicker(srv.getLiveness().interval)
+	defer ticker.Stop()
+
+	for srv.active.Load() {
+		select {
+		case <-srv.done:
+			return
+		case <-ticker.C:
+			srv.checkHosts(time.Now())
+		}
+	}
+} // func (srv *Server) watchHosts()
+
+// checkHosts updates the state of all Hosts according to the time we last
+// heard from them.
+func (srv *Server) checkHosts(now time.Time) {
+	var (
+		err   error
+		db    *database.Database
+		hosts []model.Host
+		live  = srv.getLiveness()
+	)
+
+	db = srv.pool.Get()
+	defer srv.pool.Put(db)
+
+	if hosts, err = db.HostGetAll(); err != nil {
+		srv.log.Printf("[ERROR] Cannot load Hosts from database: %s\n",
+			err.Error())
+		return
+	}
+
+	for i := range hosts {
+		var (
+			h     = &hosts[i]
+			hl    = live.forHost(h)
+			state = hl.state(h.LastContact, now)
+		)
+
+		if state == h.State || state == hoststate.Unknown {
+			continue
+		}
+
+		srv.log.Printf("[INFO] Host %s (%d) is %s (was %s), last contact %s\n",
+			h.Name,
+			h.ID,
+			state,
+			h.State,
+			h.LastContact.Format(common.TimestampFormat))
+
+		if err = db.HostUpdateState(h, state, now); err != nil {
+			srv.log.Printf("[ERROR] Cannot update state of Host %s (%d): %s\n",
+				h.Name,
+				h.ID,
+				err.Error())
+		}
+	}
+} // func (srv *Server) checkHosts(now time.Time)
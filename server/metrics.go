@@ -0,0 +1,315 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/metrics.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 03:12:48 krylon>
+
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/blicero/donkey/database"
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/recordtype"
+)
+
+const (
+	metricPrefix      = "donkey_"
+	metricContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// serverStats holds the counters the Server reports about itself on
+// /metrics.
+type serverStats struct {
+	ingested     atomic.Int64
+	ingestErrors atomic.Int64
+}
+
+// count records the outcome of an attempt to store Records received from
+// an Agent.
+func (st *serverStats) count(ok, failed int) {
+	st.ingested.Add(int64(ok))
+	st.ingestErrors.Add(int64(failed))
+} // func (st *serverStats) count(ok, failed int)
+
+// metricFamily is a set of series sharing the same metric name.
+type metricFamily struct {
+	name   string
+	kind   string
+	help   string
+	series []string
+	seen   map[string]bool
+}
+
+// metricSet collects metrics for the Prometheus text exposition format.
+// The format requires all series of a metric to be written in one block,
+// so they are grouped by name and written out sorted by name.
+type metricSet struct {
+	families map[string]*metricFamily
+}
+
+func newMetricSet() *metricSet {
+	return &metricSet{families: make(map[string]*metricFamily)}
+} // func newMetricSet() *metricSet
+
+// add adds a series to the set. labels is a list of alternating label names
+// and values, labels with an empty value are left out. Prometheus rejects
+// a scrape containing the same series twice, so only the first value for
+// a given set of labels is kept.
+func (m *metricSet) add(name, kind, help string, value float64, labels ...string) {
+	var (
+		fam    *metricFamily
+		found  bool
+		series strings.Builder
+	)
+
+	name = metricName(name)
+
+	if fam, found = m.families[name]; !found {
+		fam = &metricFamily{
+			name: name,
+			kind: kind,
+			help: help,
+			seen: make(map[string]bool),
+		}
+		m.families[name] = fam
+	}
+
+	series.WriteString(name)
+
+	var sep = "{"
+	for i := 0; i+1 < len(labels); i += 2 {
+		if labels[i+1] == "" {
+			continue
+		}
+
+		fmt.Fprintf(&series, "%s%s=\"%s\"",
+			sep,
+			labels[i],
+			escapeLabel(labels[i+1]))
+		sep = ","
+	}
+
+	if sep == "," {
+		series.WriteString("}")
+	}
+
+	var key = series.String()
+
+	if fam.seen[key] {
+		return
+	}
+
+	fam.seen[key] = true
+	fam.series = append(fam.series,
+		key+" "+strconv.FormatFloat(value, 'g', -1, 64))
+} // func (m *metricSet) add(name, kind, help string, value float64, labels ...string)
+
+// WriteTo writes the metrics in the Prometheus text format.
+func (m *metricSet) WriteTo(buf *bytes.Buffer) {
+	var names = make([]string, 0, len(m.families))
+
+	for name := range m.families {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		var fam = m.families[name]
+
+		if fam.help != "" {
+			fmt.Fprintf(buf, "# HELP %s %s\n", fam.name, fam.help)
+		}
+		fmt.Fprintf(buf, "# TYPE %s %s\n", fam.name, fam.kind)
+		for _, s := range fam.series {
+			buf.WriteString(s)
+			buf.WriteByte('\n')
+		}
+	}
+} // func (m *metricSet) WriteTo(buf *bytes.Buffer)
+
+// metricName turns the name of a Sample's metric into a valid Prometheus
+// metric name, e.g. "fs.used_pct" becomes "donkey_fs_used_pct".
+func metricName(s string) string {
+	var name = []byte(metricPrefix + s)
+
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z':
+		case c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9':
+		case c == '_' || c == ':':
+		default:
+			name[i] = '_'
+		}
+	}
+
+	return string(name)
+} // func metricName(s string) string
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+} // func escapeLabel(s string) string
+
+// collectHostMetrics adds the most recent value of each metric reported by
+// the Hosts to the set.
+func (srv *Server) collectHostMetrics(db *database.Database, m *metricSet) error {
+	var (
+		err   error
+		hosts []model.Host
+	)
+
+	if hosts, err = db.HostGetAll(); err != nil {
+		srv.log.Printf("[ERROR] Cannot load Hosts: %s\n",
+			err.Error())
+		return err
+	}
+
+	for i := range hosts {
+		var (
+			recs []model.Record
+			h    = &hosts[i]
+		)
+
+		// Hosts that have never been in touch have a LastContact of
+		// 1970-01-01, not the zero time.
+		if h.LastContact.Unix() > 0 {
+			m.add("host_last_contact_seconds",
+				"gauge",
+				"Time of the last contact with the Host as a Unix timestamp.",
+				float64(h.LastContact.Unix()),
+				"host", h.Name)
+		}
+
+		if recs, err = db.RecordGetLatestByHost(h); err != nil {
+			srv.log.Printf("[ERROR] Cannot load latest Records for Host %s (%d): %s\n",
+				h.Name,
+				h.ID,
+				err.Error())
+			return err
+		}
+
+		// There may be several Records of a type, one per check, the
+		// newest of them goes into record_timestamp_seconds.
+		var latest = make(map[recordtype.ID]time.Time, len(recs))
+
+		for j := range recs {
+			var samples []model.Sample
+
+			if recs[j].Timestamp.After(latest[recs[j].Source]) {
+				latest[recs[j].Source] = recs[j].Timestamp
+			}
+
+			if samples, err = recs[j].Samples(); err != nil {
+				srv.log.Printf("[DEBUG] Cannot get Samples from %s Record %d of Host %s: %s\n",
+					recs[j].Source,
+					recs[j].ID,
+					h.Name,
+					err.Error())
+				continue
+			}
+
+			for _, s := range samples {
+				m.add(s.Metric,
+					"gauge",
+					"",
+					s.Value,
+					"host", h.Name,
+					"name", s.Instance)
+			}
+		}
+
+		for _, id := range recordtype.All() {
+			if stamp, ok := latest[id]; ok {
+				m.add("record_timestamp_seconds",
+					"gauge",
+					"Time of the most recent Record of a type as a Unix timestamp.",
+					float64(stamp.Unix()),
+					"host", h.Name,
+					"type", id.String())
+			}
+		}
+	}
+
+	return nil
+} // func (srv *Server) collectHostMetrics(db *database.Database, m *metricSet) error
+
+// collectServerMetrics adds the Server's own counters to the set.
+func (srv *Server) collectServerMetrics(m *metricSet) {
+	var pool = srv.pool.Stats()
+
+	m.add("records_ingested_total",
+		"counter",
+		"Number of Records received from Agents and stored in the database.",
+		float64(srv.stats.ingested.Load()))
+	m.add("ingest_errors_total",
+		"counter",
+		"Number of Records received from Agents that could not be stored.",
+		float64(srv.stats.ingestErrors.Load()))
+	m.add("db_pool_idle_connections",
+		"gauge",
+		"Number of database connections currently idle in the pool.",
+		float64(pool.Idle))
+	m.add("db_pool_gets_total",
+		"counter",
+		"Number of database connections taken from the pool.",
+		float64(pool.Gets))
+	m.add("db_pool_waits_total",
+		"counter",
+		"Number of times a connection had to wait for the pool.",
+		float64(pool.Waits))
+	m.add("db_pool_wait_seconds_total",
+		"counter",
+		"Total time spent waiting for a database connection.",
+		pool.WaitTime.Seconds())
+} // func (srv *Server) collectServerMetrics(m *metricSet)
+
+// handleMetrics exposes the latest metrics of all Hosts and the Server's own
+// counters in the Prometheus text format.
+func (srv *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	srv.log.Printf("[TRACE] Handle %s from %s\n",
+		r.URL,
+		r.RemoteAddr)
+
+	var (
+		err error
+		db  *database.Database
+		buf bytes.Buffer
+		m   = newMetricSet()
+	)
+
+	// Read the pool's counters before taking a connection, so scraping
+	// does not show up as a connection in use.
+	srv.collectServerMetrics(m)
+
+	db = srv.pool.Get()
+	defer srv.pool.Put(db)
+
+	if err = srv.collectHostMetrics(db, m); err != nil {
+		http.Error(w,
+			fmt.Sprintf("Cannot collect metrics: %s", err.Error()),
+			http.StatusInternalServerError)
+		return
+	}
+
+	m.WriteTo(&buf)
+
+	w.Header().Set("Content-Type", metricContentType)
+	w.Header().Set("Cache-Control", "no-store, max-age=0")
+	w.WriteHeader(200)
+	if _, err = w.Write(buf.Bytes()); err != nil {
+		srv.log.Printf("[ERROR] Failed to send metrics: %s\n",
+			err.Error())
+	}
+} // func (srv *Server) handleMetrics(w http.ResponseWriter, r *http.Request)
@@ -0,0 +1,521 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/notify.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-18 23:48:12 krylon>
+//
+// Notifications: Alerts that start firing or get resolved are delivered to
+// the channels configured in the alerting configuration.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/smtp"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blicero/donkey/common"
+	"github.com/blicero/donkey/database"
+	"github.com/blicero/donkey/logdomain"
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/krylib"
+)
+
+// Unless a channel is configured otherwise, Alerts are collected for
+// defaultGroupWait before they are delivered together, deliveries on a
+// channel are at least defaultRateLimit apart, and a delivery attempt is
+// aborted after defaultNotifyTimeout.
+const (
+	defaultGroupWait     = time.Second * 10
+	defaultRateLimit     = time.Minute
+	defaultNotifyTimeout = time.Second * 30
+)
+
+// A failed delivery is retried up to maxNotifyAttempts times in total, the
+// delay before each retry starts at notifyRetryDelay and doubles with every
+// failed attempt.
+const (
+	maxNotifyAttempts = 5
+	notifyRetryDelay  = time.Second * 5
+)
+
+// channelConfig describes a notification channel. Which of the fields are
+// used depends on the Type:
+//
+//   - "webhook" POSTs the Notification as JSON to URL, adding Headers.
+//   - "smtp" sends an email via the mail server at Server (host:port) from
+//     From to the addresses in To, authenticating with Username and
+//     Password if given.
+//   - "exec" runs Command with Args and passes the Notification as JSON on
+//     its standard input.
+type channelConfig struct {
+	Name      string
+	Type      string
+	URL       string            `json:",omitempty"`
+	Headers   map[string]string `json:",omitempty"`
+	Server    string            `json:",omitempty"`
+	From      string            `json:",omitempty"`
+	To        []string          `json:",omitempty"`
+	Username  string            `json:",omitempty"`
+	Password  string            `json:",omitempty"`
+	Command   string            `json:",omitempty"`
+	Args      []string          `json:",omitempty"`
+	Timeout   duration          `json:",omitempty"`
+	GroupWait duration          `json:",omitempty"`
+	RateLimit duration          `json:",omitempty"`
+}
+
+// notifyChannel is implemented by the different kinds of notification
+// channels.
+type notifyChannel interface {
+	Send(ctx context.Context, n *model.Notification) error
+}
+
+// createChannel checks the configuration of a channel, fills in defaults
+// and creates the channel.
+func createChannel(c *channelConfig) (notifyChannel, error) {
+	if c.Name == "" {
+		return nil, errors.New("Notification channel has no name")
+	}
+
+	if c.Timeout <= 0 {
+		c.Timeout = duration(defaultNotifyTimeout)
+	}
+	if c.GroupWait <= 0 {
+		c.GroupWait = duration(defaultGroupWait)
+	}
+	if c.RateLimit < 0 {
+		return nil, fmt.Errorf("Channel %q: rate limit must not be negative", c.Name)
+	} else if c.RateLimit == 0 {
+		c.RateLimit = duration(defaultRateLimit)
+	}
+
+	switch c.Type {
+	case "webhook":
+		if c.URL == "" {
+			return nil, fmt.Errorf("Channel %q: webhook needs a URL", c.Name)
+		}
+		return &webhookChannel{cfg: c}, nil
+	case "smtp":
+		if c.Server == "" || c.From == "" || len(c.To) == 0 {
+			return nil, fmt.Errorf("Channel %q: smtp needs Server, From and To", c.Name)
+		}
+		return &smtpChannel{cfg: c}, nil
+	case "exec":
+		if c.Command == "" {
+			return nil, fmt.Errorf("Channel %q: exec needs a Command", c.Name)
+		}
+		return &execChannel{cfg: c}, nil
+	default:
+		return nil, fmt.Errorf("Channel %q: unknown type %q", c.Name, c.Type)
+	}
+} // func createChannel(c *channelConfig) (notifyChannel, error)
+
+// webhookChannel POSTs Notifications as JSON to a URL.
+type webhookChannel struct {
+	cfg *channelConfig
+}
+
+func (c *webhookChannel) Send(ctx context.Context, n *model.Notification) error {
+	var (
+		err  error
+		buf  []byte
+		req  *http.Request
+		res  *http.Response
+		body bytes.Buffer
+	)
+
+	if buf, err = json.Marshal(n); err != nil {
+		return err
+	} else if req, err = http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.URL, bytes.NewReader(buf)); err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range c.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	if res, err = http.DefaultClient.Do(req); err != nil {
+		return err
+	}
+
+	defer res.Body.Close()  // nolint: errcheck
+	body.ReadFrom(res.Body) // nolint: errcheck
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("Webhook %s replied %s: %s",
+			c.cfg.URL,
+			res.Status,
+			strings.TrimSpace(body.String()))
+	}
+
+	return nil
+} // func (c *webhookChannel) Send(ctx context.Context, n *model.Notification) error
+
+// smtpChannel sends Notifications by email.
+type smtpChannel struct {
+	cfg *channelConfig
+}
+
+func (c *smtpChannel) Send(ctx context.Context, n *model.Notification) error {
+	var (
+		auth smtp.Auth
+		msg  bytes.Buffer
+		done = make(chan error, 1)
+	)
+
+	if c.cfg.Username != "" {
+		var host = c.cfg.Server
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", c.cfg.Username, c.cfg.Password, host)
+	}
+
+	fmt.Fprintf(&msg, "From: %s\r\n", c.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(c.cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: [%s] %s\r\n", common.AppName, notificationSubject(n))
+	fmt.Fprintf(&msg, "Date: %s\r\n", n.Timestamp.Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	for _, a := range n.Alerts {
+		fmt.Fprintf(&msg, "%s\r\n", describeAlert(&a))
+	}
+
+	// net/smtp does not support contexts, so we wait for it in a
+	// separate goroutine.
+	go func() {
+		done <- smtp.SendMail(c.cfg.Server, auth, c.cfg.From, c.cfg.To, msg.Bytes())
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+} // func (c *smtpChannel) Send(ctx context.Context, n *model.Notification) error
+
+// execChannel runs a command and passes the Notification as JSON on its
+// standard input.
+type execChannel struct {
+	cfg *channelConfig
+}
+
+func (c *execChannel) Send(ctx context.Context, n *model.Notification) error {
+	var (
+		err    error
+		buf    []byte
+		cmd    *exec.Cmd
+		output bytes.Buffer
+	)
+
+	if buf, err = json.Marshal(n); err != nil {
+		return err
+	}
+
+	cmd = exec.CommandContext(ctx, c.cfg.Command, c.cfg.Args...)
+	cmd.Stdin = bytes.NewReader(buf)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %s - %s",
+			c.cfg.Command,
+			err.Error(),
+			strings.TrimSpace(output.String()))
+	}
+
+	return nil
+} // func (c *execChannel) Send(ctx context.Context, n *model.Notification) error
+
+// notificationSubject returns a one-line summary of a Notification.
+func notificationSubject(n *model.Notification) string {
+	if len(n.Alerts) == 1 {
+		return describeAlert(&n.Alerts[0])
+	}
+
+	return fmt.Sprintf("%d Alerts changed state", len(n.Alerts))
+} // func notificationSubject(n *model.Notification) string
+
+// describeAlert returns a one-line description of an Alert.
+func describeAlert(a *model.AlertNotice) string {
+	var what = a.Rule
+	if a.Instance != "" {
+		what += " (" + a.Instance + ")"
+	}
+
+	return fmt.Sprintf("%s on %s is %s: value %g, threshold %g",
+		what,
+		a.HostName,
+		a.State,
+		a.Value,
+		a.Threshold)
+} // func describeAlert(a *model.AlertNotice) string
+
+// channelQueue collects the Alerts for a single channel until they are
+// delivered. attempts counts the failed deliveries of the Alerts at the
+// head of pending.
+type channelQueue struct {
+	name     string
+	cfg      *channelConfig
+	ch       notifyChannel
+	pending  []model.AlertNotice
+	timer    *time.Timer
+	lastSent time.Time
+	attempts int
+}
+
+// notifier delivers Alerts to notification channels. Alerts for the same
+// channel that arrive within the channel's GroupWait are delivered as a
+// single Notification, and deliveries on a channel are at least RateLimit
+// apart; Alerts that arrive in the meantime are held back and delivered
+// together afterwards. Failed deliveries are retried with a growing delay.
+type notifier struct {
+	log    *log.Logger
+	pool   *database.Pool
+	lock   sync.Mutex
+	queues map[string]*channelQueue
+	routes map[string][]string
+	retry  time.Duration
+	closed bool
+}
+
+// newNotifier creates a notifier for the given channels. routes maps the
+// names of alerting rules to the names of the channels their Alerts are
+// delivered to, rules that are not listed go to all channels.
+func newNotifier(pool *database.Pool, channels []*channelConfig, routes map[string][]string) (*notifier, error) {
+	var (
+		err error
+		n   = &notifier{
+			pool:   pool,
+			queues: make(map[string]*channelQueue, len(channels)),
+			routes: routes,
+			retry:  notifyRetryDelay,
+		}
+	)
+
+	if n.log, err = common.GetLogger(logdomain.Alert); err != nil {
+		return nil, err
+	}
+
+	for _, c := range channels {
+		var ch notifyChannel
+
+		if ch, err = createChannel(c); err != nil {
+			return nil, err
+		} else if _, dup := n.queues[c.Name]; dup {
+			return nil, fmt.Errorf("Duplicate notification channel %q", c.Name)
+		}
+
+		n.queues[c.Name] = &channelQueue{
+			name: c.Name,
+			cfg:  c,
+			ch:   ch,
+		}
+	}
+
+	for rule, names := range routes {
+		for _, name := range names {
+			if _, ok := n.queues[name]; !ok {
+				return nil, fmt.Errorf("Rule %q refers to unknown channel %q",
+					rule,
+					name)
+			}
+		}
+	}
+
+	return n, nil
+} // func newNotifier(...) (*notifier, error)
+
+// Enqueue schedules the given Alerts for delivery.
+func (n *notifier) Enqueue(alerts ...model.AlertNotice) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.closed {
+		n.log.Printf("[WARN] Notifier has been stopped, dropping %d Alerts\n",
+			len(alerts))
+		return
+	}
+
+	for _, a := range alerts {
+		var names, ok = n.routes[a.Rule]
+
+		if !ok || len(names) == 0 {
+			names = make([]string, 0, len(n.queues))
+			for name := range n.queues {
+				names = append(names, name)
+			}
+		}
+
+		for _, name := range names {
+			var q = n.queues[name]
+
+			q.pending = append(q.pending, a)
+
+			if q.timer == nil {
+				q.timer = time.AfterFunc(time.Duration(q.cfg.GroupWait), func() { n.flush(q) })
+			}
+		}
+	}
+} // func (n *notifier) Enqueue(alerts ...model.AlertNotice)
+
+// stop cancels all scheduled deliveries. Alerts that are still pending are
+// discarded, and Alerts enqueued afterwards are dropped.
+func (n *notifier) stop() {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.closed {
+		return
+	}
+
+	n.closed = true
+
+	for _, q := range n.queues {
+		if q.timer != nil {
+			q.timer.Stop()
+			q.timer = nil
+		}
+
+		if len(q.pending) > 0 {
+			n.log.Printf("[WARN] Discarding %d undelivered Alerts for channel %s\n",
+				len(q.pending),
+				q.name)
+			q.pending = nil
+		}
+	}
+} // func (n *notifier) stop()
+
+// flush delivers the pending Alerts of a channel, unless the rate limit
+// forbids it, in which case it reschedules itself. If the delivery fails,
+// the Alerts are put back in front of the queue and retried later, until
+// maxNotifyAttempts is reached.
+func (n *notifier) flush(q *channelQueue) {
+	var (
+		now   = time.Now()
+		prev  time.Time
+		notif = &model.Notification{Channel: q.name, Timestamp: now}
+	)
+
+	n.lock.Lock()
+
+	if n.closed {
+		n.lock.Unlock()
+		return
+	} else if wait := q.lastSent.Add(time.Duration(q.cfg.RateLimit)).Sub(now); !q.lastSent.IsZero() && wait > 0 {
+		n.log.Printf("[DEBUG] Rate limit on channel %s, delivering %d Alerts in %s\n",
+			q.name,
+			len(q.pending),
+			wait)
+		q.timer = time.AfterFunc(wait, func() { n.flush(q) })
+		n.lock.Unlock()
+		return
+	}
+
+	notif.Alerts = q.pending
+	prev = q.lastSent
+	q.pending = nil
+	q.timer = nil
+	q.lastSent = now
+	n.lock.Unlock()
+
+	if len(notif.Alerts) == 0 || n.deliver(q, notif) {
+		n.lock.Lock()
+		q.attempts = 0
+		n.lock.Unlock()
+		return
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	q.attempts++
+
+	if n.closed {
+		return
+	} else if q.attempts >= maxNotifyAttempts {
+		n.log.Printf("[ERROR] Giving up on delivering %d Alerts via %s after %d attempts\n",
+			len(notif.Alerts),
+			q.name,
+			q.attempts)
+		q.attempts = 0
+		return
+	}
+
+	// A failed attempt did not reach anyone, so it does not count against
+	// the rate limit.
+	var delay = n.retry << (q.attempts - 1)
+
+	n.log.Printf("[INFO] Retrying delivery of %d Alerts via %s in %s (attempt %d of %d)\n",
+		len(notif.Alerts),
+		q.name,
+		delay,
+		q.attempts+1,
+		maxNotifyAttempts)
+
+	q.lastSent = prev
+	q.pending = append(notif.Alerts, q.pending...)
+
+	if q.timer != nil {
+		q.timer.Stop()
+	}
+
+	q.timer = time.AfterFunc(delay, func() { n.flush(q) })
+} // func (n *notifier) flush(q *channelQueue)
+
+// deliver sends a Notification and records the attempt in the database. It
+// returns true if the Notification was sent successfully.
+func (n *notifier) deliver(q *channelQueue, notif *model.Notification) bool {
+	var (
+		err error
+		db  *database.Database
+		d   = &model.Delivery{
+			Channel:   q.name,
+			Timestamp: notif.Timestamp,
+			AlertIDs:  make([]krylib.ID, len(notif.Alerts)),
+		}
+		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(q.cfg.Timeout))
+	)
+
+	defer cancel()
+
+	for i, a := range notif.Alerts {
+		d.AlertIDs[i] = a.ID
+	}
+
+	if err = q.ch.Send(ctx, notif); err != nil {
+		d.Message = err.Error()
+		n.log.Printf("[ERROR] Failed to deliver %d Alerts via %s: %s\n",
+			len(notif.Alerts),
+			q.name,
+			err.Error())
+	} else {
+		d.Success = true
+		n.log.Printf("[INFO] Delivered %d Alerts via %s\n",
+			len(notif.Alerts),
+			q.name)
+	}
+
+	db = n.pool.Get()
+	defer n.pool.Put(db)
+
+	if err = db.DeliveryAdd(d); err != nil {
+		n.log.Printf("[ERROR] Cannot record delivery via %s: %s\n",
+			q.name,
+			err.Error())
+	}
+
+	return d.Success
+} // func (n *notifier) deliver(q *channelQueue, notif *model.Notification) bool
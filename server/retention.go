@@ -0,0 +1,210 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/retention.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 01:12:26 krylon>
+
+package server
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/blicero/donkey/database"
+	"github.com/blicero/donkey/model/recordtype"
+	"github.com/blicero/donkey/model/resolution"
+)
+
+// The Server deletes old data every pruneInterval. Rows are deleted in
+// chunks of pruneChunkSize, each in its own transaction, with a short pause
+// in between, so Agents reporting data are not locked out for long. If more
+// than pruneMaintenanceThreshold rows have been deleted, the database is
+// vacuumed afterwards.
+const (
+	pruneInterval             = time.Hour
+	pruneChunkSize            = 1000
+	pruneChunkPause           = time.Millisecond * 50
+	pruneMaintenanceThreshold = 100000
+)
+
+const day = time.Hour * 24
+
+// RetentionPolicy determines how long the Server keeps data. Raw is how long
+// Records are kept, RawByType overrides it for individual types of Records.
+// Rollups is how long rollups at each resolution are kept. A duration of
+// zero means the data is kept forever.
+//
+// Records are only deleted after they have been added to the rollups.
+type RetentionPolicy struct {
+	Raw       time.Duration
+	RawByType map[recordtype.ID]time.Duration
+	Rollups   map[resolution.ID]time.Duration
+}
+
+// DefaultRetention returns the RetentionPolicy the Server uses unless told
+// otherwise: Records are kept forever, rollups by minute for a month,
+// rollups by hour for a year, and rollups by day forever. Deleting Records
+// cannot be undone, so operators who want it have to ask for it in the
+// configuration file.
+func DefaultRetention() RetentionPolicy {
+	return RetentionPolicy{
+		Raw:       0,
+		RawByType: make(map[recordtype.ID]time.Duration),
+		Rollups: map[resolution.ID]time.Duration{
+			resolution.Minute: day * 30,
+			resolution.Hour:   day * 365,
+			resolution.Day:    0,
+		},
+	}
+} // func DefaultRetention() RetentionPolicy
+
+// validate checks the RetentionPolicy for nonsensical values.
+func (p *RetentionPolicy) validate() error {
+	if p.Raw < 0 {
+		return fmt.Errorf("Invalid retention for Records: %s", p.Raw)
+	}
+
+	for t, d := range p.RawByType {
+		if d < 0 {
+			return fmt.Errorf("Invalid retention for %s Records: %s", t, d)
+		}
+	}
+
+	for res, d := range p.Rollups {
+		if !slices.Contains(resolution.Rollups, res) {
+			return fmt.Errorf("There are no rollups at resolution %s", res)
+		} else if d < 0 {
+			return fmt.Errorf("Invalid retention for rollups by %s: %s", res, d)
+		}
+	}
+
+	return nil
+} // func (p *RetentionPolicy) validate() error
+
+// raw returns how long Records of the given type are kept.
+func (p *RetentionPolicy) raw(t recordtype.ID) time.Duration {
+	if d, ok := p.RawByType[t]; ok {
+		return d
+	}
+
+	return p.Raw
+} // func (p *RetentionPolicy) raw(t recordtype.ID) time.Duration
+
+// SetRetention sets the Server's RetentionPolicy.
+func (srv *Server) SetRetention(p RetentionPolicy) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+
+	srv.lock.Lock()
+	srv.retention = p
+	srv.lock.Unlock()
+
+	return nil
+} // func (srv *Server) SetRetention(p RetentionPolicy) error
+
+func (srv *Server) getRetention() RetentionPolicy {
+	srv.lock.RLock()
+	defer srv.lock.RUnlock()
+	return srv.retention
+} // func (srv *Server) getRetention() RetentionPolicy
+
+// pruneLoop periodically deletes data that has outlived the
+// RetentionPolicy.
+func (srv *Server) pruneLoop() {
+	var ticker = time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for srv.active.Load() {
+		select {
+		case <-srv.done:
+			return
+		case <-ticker.C:
+			srv.prune(time.Now())
+		}
+	}
+} // func (srv *Server) pruneLoop()
+
+// prune deletes all data that is older than the RetentionPolicy allows as of
+// <now>. It returns the number of rows deleted.
+func (srv *Server) prune(now time.Time) int64 {
+	var (
+		db     *database.Database
+		total  int64
+		policy = srv.getRetention()
+	)
+
+	db = srv.pool.Get()
+	defer srv.pool.Put(db)
+
+	for _, t := range recordtype.All() {
+		var keep = policy.raw(t)
+
+		if keep == 0 {
+			continue
+		}
+
+		total += srv.pruneChunks(fmt.Sprintf("%s Records", t), func() (int64, error) {
+			return db.RecordPrune(t, now.Add(-keep), pruneChunkSize)
+		})
+	}
+
+	for _, res := range resolution.Rollups {
+		var keep = policy.Rollups[res]
+
+		if keep == 0 {
+			continue
+		}
+
+		total += srv.pruneChunks(fmt.Sprintf("rollups by %s", res), func() (int64, error) {
+			return db.RollupPrune(res, now.Add(-keep), pruneChunkSize)
+		})
+	}
+
+	if total > 0 {
+		srv.log.Printf("[INFO] Deleted %d rows of old data\n", total)
+	}
+
+	if total >= pruneMaintenanceThreshold {
+		srv.log.Printf("[INFO] Performing database maintenance\n")
+		if err := db.PerformMaintenance(); err != nil {
+			srv.log.Printf("[ERROR] Database maintenance failed: %s\n",
+				err.Error())
+		}
+	}
+
+	return total
+} // func (srv *Server) prune(now time.Time) int64
+
+// pruneChunks calls del until it deletes less than a full chunk or fails,
+// and returns the total number of rows deleted.
+func (srv *Server) pruneChunks(what string, del func() (int64, error)) int64 {
+	var (
+		err        error
+		cnt, total int64
+	)
+
+	for {
+		if cnt, err = del(); err != nil {
+			srv.log.Printf("[ERROR] Failed to delete old %s: %s\n",
+				what,
+				err.Error())
+			break
+		}
+
+		total += cnt
+
+		if cnt < pruneChunkSize {
+			break
+		}
+
+		time.Sleep(pruneChunkPause)
+	}
+
+	if total > 0 {
+		srv.log.Printf("[DEBUG] Deleted %d old %s\n", total, what)
+	}
+
+	return total
+} // func (srv *Server) pruneChunks(what string, del func() (int64, error)) int64
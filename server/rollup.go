@@ -0,0 +1,69 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/rollup.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 18. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 00:31:44 krylon>
+
+package server
+
+import (
+	"time"
+
+	"github.com/blicero/donkey/database"
+)
+
+// The Server aggregates new Records into rollups every rollupInterval, in
+// batches of up to rollupBatchSize Records, so each transaction is short.
+const (
+	rollupInterval  = time.Minute
+	rollupBatchSize = 5000
+)
+
+// rollupLoop periodically aggregates new Records into rollups.
+func (srv *Server) rollupLoop() {
+	var ticker = time.NewTicker(rollupInterval)
+	defer ticker.Stop()
+
+	for srv.active.Load() {
+		srv.rollup()
+
+		select {
+		case <-srv.done:
+			return
+		case <-ticker.C:
+		}
+	}
+} // func (srv *Server) rollupLoop()
+
+// rollup aggregates all Records that have not been aggregated, yet. It
+// returns the number of Records processed.
+func (srv *Server) rollup() int {
+	var (
+		err      error
+		db       *database.Database
+		cnt, sum int
+	)
+
+	db = srv.pool.Get()
+	defer srv.pool.Put(db)
+
+	for {
+		if cnt, err = db.RollupProcess(rollupBatchSize); err != nil {
+			srv.log.Printf("[ERROR] Failed to aggregate Records into rollups: %s\n",
+				err.Error())
+			break
+		}
+
+		sum += cnt
+
+		if cnt < rollupBatchSize {
+			break
+		}
+	}
+
+	if sum > 0 {
+		srv.log.Printf("[DEBUG] Added %d Records to rollups\n", sum)
+	}
+
+	return sum
+} // func (srv *Server) rollup() int
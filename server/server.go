@@ -8,6 +8,7 @@ package server
 
 import (
 	"embed"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"html/template"
@@ -18,6 +19,8 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -25,14 +28,23 @@ import (
 	"github.com/blicero/donkey/common"
 	"github.com/blicero/donkey/database"
 	"github.com/blicero/donkey/logdomain"
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/recordtype"
+	"github.com/blicero/krylib"
 	"github.com/gorilla/mux"
 )
 
 const ( // nolint: deadcode
-	poolSize = 4
-	bufSize  = 4096
+	defaultPoolSize = 4
+	bufSize         = 4096
 )
 
+// defaultChartRange is the time range the charts on a Host's page cover
+// unless the user picks a different one from chartRanges.
+const defaultChartRange = time.Hour * 6
+
+var chartRanges = []string{"1h", "6h", "24h", "7d", "30d"}
+
 //go:embed html
 var assets embed.FS
 
@@ -43,19 +55,56 @@ type Server struct {
 	pool      *database.Pool
 	lock      sync.RWMutex // nolint: unused,structcheck
 	active    atomic.Bool
+	done      chan struct{}
+	stopOnce  sync.Once
 	router    *mux.Router
 	tmpl      *template.Template
 	web       http.Server
 	mimeTypes map[string]string
+	live      liveness
+	retention RetentionPolicy
+	alerts    *alertEngine
+	notify    *notifier
+	stats     serverStats
+	cfg       *Config
+	fleet     *fleetConfig
 }
 
-// Create creates and returns a new Server.
+// Create creates and returns a new Server listening on the given address,
+// with default settings otherwise.
 func Create(addr string) (*Server, error) {
+	var cfg = DefaultConfig()
+
+	cfg.Addr = addr
+	return CreateWithConfig(cfg)
+} // func Create(addr string) (*Server, error)
+
+// CreateWithConfig creates and returns a new Server using the given settings.
+func CreateWithConfig(cfg *Config) (*Server, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	common.Debug = cfg.Debug
+	common.DbPath = cfg.DBPath
+
+	if err := cfg.applyLogLevels(); err != nil {
+		return nil, err
+	}
+
 	var (
 		err error
 		msg string
 		srv = &Server{
-			addr: addr,
+			cfg:  cfg,
+			addr: cfg.Addr,
+			done: make(chan struct{}),
+			live: liveness{
+				interval:   defaultContactInterval,
+				staleAfter: defaultStaleAfter,
+				downAfter:  defaultDownAfter,
+			},
+			retention: cfg.retention,
 			mimeTypes: map[string]string{
 				".css":  "text/css",
 				".map":  "application/json",
@@ -77,7 +126,7 @@ func Create(addr string) (*Server, error) {
 			"Error creating Logger: %s\n",
 			err.Error())
 		return nil, err
-	} else if srv.pool, err = database.NewPool(poolSize); err != nil {
+	} else if srv.pool, err = database.NewPool(cfg.PoolSize); err != nil {
 		srv.log.Printf("[ERROR] Cannot allocate database connection pool: %s\n",
 			err.Error())
 		return nil, err
@@ -86,6 +135,12 @@ func Create(addr string) (*Server, error) {
 		return nil, errors.New("Database pool is nil")
 	}
 
+	if err = srv.initAlerts(); err != nil {
+		return nil, err
+	} else if err = srv.loadFleet(); err != nil {
+		return nil, err
+	}
+
 	const tmplFolder = "html/templates"
 	var templates []fs.DirEntry
 	var tmplRe = regexp.MustCompile("[.]tmpl$")
@@ -124,7 +179,7 @@ func Create(addr string) (*Server, error) {
 	}
 
 	srv.router = mux.NewRouter()
-	srv.web.Addr = addr
+	srv.web.Addr = cfg.Addr
 	srv.web.ErrorLog = srv.log
 	srv.web.Handler = srv.router
 
@@ -132,26 +187,46 @@ func Create(addr string) (*Server, error) {
 	srv.router.HandleFunc("/favicon.ico", srv.handleFavIco)
 	srv.router.HandleFunc("/static/{file}", srv.handleStaticFile)
 	srv.router.HandleFunc("/{page:(?:index|main|start)?$}", srv.handleMain)
+	srv.router.HandleFunc("/host/{id:(?:\\d+$)}", srv.handleHostDetails)
 
 	// Agent handlers
 	srv.router.HandleFunc("/ws/register", srv.handleClientRegister)
 	srv.router.HandleFunc("/ws/report/load/{name:(?:\\w+$)}", srv.handleClientReportLoad)
 	srv.router.HandleFunc("/ws/report", srv.handleClientReportData)
+	srv.router.HandleFunc("/ws/report/batch", srv.handleClientReportBatch)
+	srv.router.HandleFunc("/ws/config/{id:(?:\\d+$)}", srv.handleClientConfig).Methods("GET")
+
+	// REST API
+	srv.apiRoutes()
+
+	// Prometheus
+	srv.router.HandleFunc("/metrics", srv.handleMetrics).Methods("GET")
 
 	// AJAX Handlers
 	srv.router.HandleFunc("/ajax/beacon", srv.handleBeacon)
+	srv.router.HandleFunc("/ajax/host/{id:(?:\\d+)}/chart/{type:(?:\\d+$)}", srv.handleHostChart)
+
+	if err = srv.SetTLS(cfg.TLS); err != nil {
+		return nil, err
+	}
 
 	return srv, nil
-} // func Create(addr string) (*Server, error)
+} // func CreateWithConfig(cfg *Config) (*Server, error)
 
 // IsActive returns the Server's active flag.
 func (srv *Server) IsActive() bool {
 	return srv.active.Load()
 } // func (srv *Server) IsActive() bool
 
-// Stop clears the Server's active flag.
+// Stop clears the Server's active flag, stops the Server's background
+// goroutines and cancels pending notifications.
 func (srv *Server) Stop() {
 	srv.active.Store(false)
+	srv.stopOnce.Do(func() { close(srv.done) })
+
+	if srv.notify != nil {
+		srv.notify.stop()
+	}
 } // func (srv *Server) Stop()
 
 // Run executes the Server's loop, waiting for new connections and starting
@@ -160,11 +235,27 @@ func (srv *Server) Run() {
 	var err error
 
 	defer srv.log.Println("[INFO] Web server is shutting down")
+	defer srv.Stop()
 
-	srv.log.Printf("[INFO] Web frontend is going online at %s\n", srv.addr)
+	srv.log.Printf("[INFO] Web frontend is going online at %s (TLS: %t)\n",
+		srv.addr,
+		srv.web.TLSConfig != nil)
 	http.Handle("/", srv.router)
 
-	if err = srv.web.ListenAndServe(); err != nil {
+	srv.active.Store(true)
+	go srv.watchHosts()
+	go srv.rollupLoop()
+	go srv.pruneLoop()
+	go srv.watchConfig()
+
+	// The certificates are part of the TLSConfig already.
+	if srv.web.TLSConfig != nil {
+		err = srv.web.ListenAndServeTLS("", "")
+	} else {
+		err = srv.web.ListenAndServe()
+	}
+
+	if err != nil {
 		if err.Error() != "http: Server closed" {
 			srv.log.Printf("[ERROR] ListenAndServe returned an error: %s\n",
 				err.Error())
@@ -174,12 +265,233 @@ func (srv *Server) Run() {
 	}
 } // func (srv *Server) Run()
 
+// initAlerts reads the alerting rules and notification channels, and
+// restores the Alerts that were active when the Server was last stopped.
+func (srv *Server) initAlerts() error {
+	var (
+		err error
+		cfg *alertConfig
+		db  *database.Database
+	)
+
+	if cfg, err = readAlertConfig(common.AlertConfPath); err != nil {
+		srv.log.Printf("[ERROR] Cannot read alerting rules from %s: %s\n",
+			common.AlertConfPath,
+			err.Error())
+		return err
+	} else if srv.alerts, err = newAlertEngine(cfg.Rules); err != nil {
+		srv.log.Printf("[ERROR] Cannot create alerting engine: %s\n",
+			err.Error())
+		return err
+	} else if srv.notify, err = newNotifier(srv.pool, cfg.Channels, cfg.routes()); err != nil {
+		srv.log.Printf("[ERROR] Cannot set up notification channels: %s\n",
+			err.Error())
+		return err
+	}
+
+	srv.log.Printf("[INFO] Loaded %d alerting rules and %d notification channels from %s\n",
+		len(cfg.Rules),
+		len(cfg.Channels),
+		common.AlertConfPath)
+
+	db = srv.pool.Get()
+	defer srv.pool.Put(db)
+
+	return srv.alerts.restore(db)
+} // func (srv *Server) initAlerts() error
+
 func (srv *Server) handleMain(w http.ResponseWriter, r *http.Request) {
 	srv.log.Printf("[TRACE] Handle %s from %s\n",
 		r.URL,
 		r.RemoteAddr)
+
+	const tmplName = "main"
+
+	var (
+		err  error
+		msg  string
+		db   *database.Database
+		tmpl *template.Template
+		data = tmplDataIndex{
+			tmplDataBase: srv.baseData("Main", r),
+		}
+	)
+
+	if tmpl = srv.tmpl.Lookup(tmplName); tmpl == nil {
+		msg = fmt.Sprintf("Could not find template %q", tmplName)
+		srv.log.Println("[CRITICAL] " + msg)
+		srv.sendErrorMessage(w, msg)
+		return
+	}
+
+	db = srv.pool.Get()
+	defer srv.pool.Put(db)
+
+	if data.Hosts, err = srv.summarizeHosts(db); err != nil {
+		msg = fmt.Sprintf("Cannot load Hosts from database: %s",
+			err.Error())
+		srv.sendErrorMessage(w, msg)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	w.Header().Set("Cache-Control", "no-store, max-age=0")
+	w.WriteHeader(200)
+	if err = tmpl.Execute(w, &data); err != nil {
+		msg = fmt.Sprintf("Error rendering template %q: %s",
+			tmplName,
+			err.Error())
+		srv.log.Printf("[ERROR] %s\n", msg)
+	}
 } // func (srv *Server) handleMain(w http.ResponseWriter, r *http.Request)
 
+// summarizeHosts loads all Hosts along with their most recent load average
+// and temperature readings.
+func (srv *Server) summarizeHosts(db *database.Database) ([]hostSummary, error) {
+	var (
+		err     error
+		hosts   []model.Host
+		summary []hostSummary
+	)
+
+	if hosts, err = db.HostGetAll(); err != nil {
+		return nil, err
+	}
+
+	summary = make([]hostSummary, len(hosts))
+
+	for i := range hosts {
+		var recs []model.Record
+
+		summary[i].Host = hosts[i]
+
+		if recs, err = db.RecordGetLatestByHost(&hosts[i]); err != nil {
+			srv.log.Printf("[ERROR] Cannot load latest Records for Host %s (%d): %s\n",
+				hosts[i].Name,
+				hosts[i].ID,
+				err.Error())
+			return nil, err
+		}
+
+		for j := range recs {
+			var payload any
+
+			if payload, err = recs[j].Decode(); err != nil {
+				srv.log.Printf("[ERROR] Cannot decode %s Record %d of Host %s: %s\n",
+					recs[j].Source,
+					recs[j].ID,
+					hosts[i].Name,
+					err.Error())
+				continue
+			}
+
+			switch p := payload.(type) {
+			case *model.Load:
+				summary[i].Load = p
+			case *model.Sensors:
+				summary[i].Temp = p.Max(model.SensorTemp)
+			}
+		}
+	}
+
+	return summary, nil
+} // func (srv *Server) summarizeHosts(db *database.Database) ([]hostSummary, error)
+
+func (srv *Server) handleHostDetails(w http.ResponseWriter, r *http.Request) {
+	srv.log.Printf("[TRACE] Handle %s from %s\n",
+		r.URL,
+		r.RemoteAddr)
+
+	const (
+		tmplName = "host"
+		eventCnt = 10
+	)
+
+	var (
+		err   error
+		msg   string
+		id    int64
+		db    *database.Database
+		tmpl  *template.Template
+		recs  []model.Record
+		loads []model.Load
+		data  = tmplDataHost{
+			tmplDataBase: srv.baseData("Host", r),
+			Ranges:       chartRanges,
+		}
+	)
+
+	if id, err = strconv.ParseInt(mux.Vars(r)["id"], 10, 64); err != nil {
+		msg = fmt.Sprintf("Invalid Host ID %q: %s",
+			mux.Vars(r)["id"],
+			err.Error())
+		srv.sendErrorMessage(w, msg)
+		return
+	} else if tmpl = srv.tmpl.Lookup(tmplName); tmpl == nil {
+		msg = fmt.Sprintf("Could not find template %q", tmplName)
+		srv.log.Println("[CRITICAL] " + msg)
+		srv.sendErrorMessage(w, msg)
+		return
+	}
+
+	db = srv.pool.Get()
+	defer srv.pool.Put(db)
+
+	if data.Host, err = db.HostGetByID(krylib.ID(id)); err != nil {
+		msg = fmt.Sprintf("Cannot look up Host %d: %s",
+			id,
+			err.Error())
+		srv.sendErrorMessage(w, msg)
+		return
+	} else if data.Host == nil {
+		msg = fmt.Sprintf("Host %d does not exist", id)
+		srv.sendErrorMessage(w, msg)
+		return
+	} else if recs, err = db.RecordGetLatestByHost(data.Host); err != nil {
+		msg = fmt.Sprintf("Cannot load latest Records of Host %s: %s",
+			data.Host.Name,
+			err.Error())
+		srv.sendErrorMessage(w, msg)
+		return
+	} else if loads, err = db.LoadGetByHost(data.Host.ID, 1); err != nil {
+		msg = fmt.Sprintf("Cannot load load average of Host %s: %s",
+			data.Host.Name,
+			err.Error())
+		srv.sendErrorMessage(w, msg)
+		return
+	} else if data.Events, err = db.HostEventGetByHost(data.Host.ID, eventCnt); err != nil {
+		msg = fmt.Sprintf("Cannot load events of Host %s: %s",
+			data.Host.Name,
+			err.Error())
+		srv.sendErrorMessage(w, msg)
+		return
+	}
+
+	data.Title = data.Host.Name
+	data.Types = make([]recordtype.ID, 0, len(recs))
+	for _, rec := range recs {
+		// There is one Record per check, but only one chart for all
+		// of them. The Records are ordered by type.
+		if n := len(data.Types); n == 0 || data.Types[n-1] != rec.Source {
+			data.Types = append(data.Types, rec.Source)
+		}
+	}
+
+	if len(loads) > 0 {
+		data.Load = &loads[0]
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	w.Header().Set("Cache-Control", "no-store, max-age=0")
+	w.WriteHeader(200)
+	if err = tmpl.Execute(w, &data); err != nil {
+		msg = fmt.Sprintf("Error rendering template %q: %s",
+			tmplName,
+			err.Error())
+		srv.log.Printf("[ERROR] %s\n", msg)
+	}
+} // func (srv *Server) handleHostDetails(w http.ResponseWriter, r *http.Request)
+
 func (srv *Server) handleFavIco(w http.ResponseWriter, request *http.Request) {
 	srv.log.Printf("[TRACE] Handle request for %s\n",
 		request.URL.EscapedPath())
@@ -310,3 +622,157 @@ func (srv *Server) handleBeacon(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(200)
 	w.Write(response) // nolint: errcheck,gosec
 } // func (srv *Web) handleBeacon(w http.ResponseWriter, r *http.Request)
+
+// handleHostChart delivers the data to draw a chart of one type of Record
+// of a Host. The time range is passed in the query parameter range, e.g.
+// "6h" or "7d".
+func (srv *Server) handleHostChart(w http.ResponseWriter, r *http.Request) {
+	srv.log.Printf("[TRACE] Handle %s from %s\n",
+		r.URL,
+		r.RemoteAddr)
+
+	var (
+		err      error
+		msg      string
+		id, rtyp int64
+		period   time.Duration
+		db       *database.Database
+		host     *model.Host
+		rollups  []model.Rollup
+		pt       model.PayloadType
+		ok       bool
+		rbuf     []byte
+		res      chartData
+		vars     = mux.Vars(r)
+	)
+
+	if id, err = strconv.ParseInt(vars["id"], 10, 64); err != nil {
+		res.Message = fmt.Sprintf("Invalid Host ID %q: %s",
+			vars["id"],
+			err.Error())
+		srv.log.Printf("[ERROR] %s\n", res.Message)
+		goto SEND_RESPONSE
+	} else if rtyp, err = strconv.ParseInt(vars["type"], 10, 8); err != nil {
+		res.Message = fmt.Sprintf("Invalid record type %q: %s",
+			vars["type"],
+			err.Error())
+		srv.log.Printf("[ERROR] %s\n", res.Message)
+		goto SEND_RESPONSE
+	} else if pt, ok = model.LookupPayload(recordtype.ID(rtyp)); !ok {
+		res.Message = fmt.Sprintf("Unknown record type %d", rtyp)
+		srv.log.Printf("[ERROR] %s\n", res.Message)
+		goto SEND_RESPONSE
+	} else if period, err = parseRange(r.URL.Query().Get("range")); err != nil {
+		res.Message = err.Error()
+		srv.log.Printf("[ERROR] %s\n", res.Message)
+		goto SEND_RESPONSE
+	}
+
+	res.HostID = krylib.ID(id)
+	res.Type = recordtype.ID(rtyp)
+	res.Name = pt.Name
+	res.End = time.Now()
+	res.Begin = res.End.Add(-period)
+
+	db = srv.pool.Get()
+	defer srv.pool.Put(db)
+
+	if host, err = db.HostGetByID(res.HostID); err != nil {
+		res.Message = fmt.Sprintf("Cannot look up Host %d: %s",
+			id,
+			err.Error())
+		srv.log.Printf("[ERROR] %s\n", res.Message)
+		goto SEND_RESPONSE
+	} else if host == nil {
+		res.Message = fmt.Sprintf("Host %d does not exist", id)
+		srv.log.Printf("[ERROR] %s\n", res.Message)
+		goto SEND_RESPONSE
+	} else if rollups, res.Resolution, err = db.SeriesGetByHostType(host, res.Type, res.Begin, res.End); err != nil {
+		res.Message = fmt.Sprintf("Cannot load %s Records of Host %s: %s",
+			res.Type,
+			host.Name,
+			err.Error())
+		srv.log.Printf("[ERROR] %s\n", res.Message)
+		goto SEND_RESPONSE
+	}
+
+	res.Series = buildSeries(rollups)
+	res.Status = true
+
+SEND_RESPONSE:
+	res.Timestamp = time.Now()
+	if rbuf, err = json.Marshal(&res); err != nil {
+		srv.log.Printf("[ERROR] Error serializing response: %s\n",
+			err.Error())
+		rbuf = errJSON(err.Error())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store, max-age=0")
+	w.WriteHeader(200)
+	if _, err = w.Write(rbuf); err != nil {
+		msg = fmt.Sprintf("Failed to send result: %s",
+			err.Error())
+		srv.log.Println("[ERROR] " + msg)
+	}
+} // func (srv *Server) handleHostChart(w http.ResponseWriter, r *http.Request)
+
+// buildSeries sorts the values into one series per metric and instance,
+// using the average of each Rollup.
+func buildSeries(data []model.Rollup) []chartSeries {
+	var (
+		series = make([]chartSeries, 0)
+		index  = make(map[string]int)
+	)
+
+	for _, r := range data {
+		var (
+			idx  int
+			ok   bool
+			name = r.Metric
+		)
+
+		if r.Instance != "" {
+			name += " " + r.Instance
+		}
+
+		if idx, ok = index[name]; !ok {
+			idx = len(series)
+			index[name] = idx
+			series = append(series, chartSeries{Name: name})
+		}
+
+		series[idx].Points = append(series[idx].Points,
+			[2]float64{float64(r.Bucket.Unix()), r.Avg})
+	}
+
+	return series
+} // func buildSeries(data []model.Rollup) []chartSeries
+
+// parseRange parses the time range for a chart. In addition to the format
+// understood by time.ParseDuration, it accepts a number of days, e.g. "7d".
+// An empty string yields the default range.
+func parseRange(s string) (time.Duration, error) {
+	var (
+		err  error
+		d    time.Duration
+		days int
+	)
+
+	if s == "" {
+		return defaultChartRange, nil
+	} else if strings.HasSuffix(s, "d") {
+		if days, err = strconv.Atoi(strings.TrimSuffix(s, "d")); err != nil {
+			return 0, fmt.Errorf("Invalid time range %q: %s", s, err.Error())
+		}
+		d = time.Hour * 24 * time.Duration(days)
+	} else if d, err = time.ParseDuration(s); err != nil {
+		return 0, fmt.Errorf("Invalid time range %q: %s", s, err.Error())
+	}
+
+	if d <= 0 {
+		return 0, fmt.Errorf("Invalid time range %q: must be positive", s)
+	}
+
+	return d, nil
+} // func parseRange(s string) (time.Duration, error)
@@ -0,0 +1,121 @@
+// /home/krylon/go/src/github.com/blicero/donkey/server/tls.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 19. 10. 2026 by Benjamin Walkenhorst
+// (c) 2026 Benjamin Walkenhorst
+// Time-stamp: <2026-10-19 05:24:37 krylon>
+
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+
+	"github.com/blicero/donkey/model"
+)
+
+// TLSConfig tells the Server to use HTTPS.
+// CertFile and KeyFile contain the Server's certificate and private key in
+// PEM format.
+// If ClientCAFile is set, Agents may present a client certificate issued by
+// one of the CAs it contains, and if RequireClientCert is set, they have to.
+// A client certificate has to be issued for the name of the Host the Agent
+// reports for, either as its CommonName or as one of its DNS names.
+type TLSConfig struct {
+	CertFile          string
+	KeyFile           string
+	ClientCAFile      string `json:",omitempty"`
+	RequireClientCert bool   `json:",omitempty"`
+}
+
+// config turns the TLSConfig into a tls.Config, loading the certificates
+// along the way.
+func (c *TLSConfig) config() (*tls.Config, error) {
+	var (
+		err  error
+		cert tls.Certificate
+		pem  []byte
+		cfg  = &tls.Config{MinVersion: tls.VersionTLS12}
+	)
+
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("TLS needs both a certificate and a key")
+	} else if c.RequireClientCert && c.ClientCAFile == "" {
+		return nil, errors.New("Requiring client certificates needs a CA to verify them")
+	} else if cert, err = tls.LoadX509KeyPair(c.CertFile, c.KeyFile); err != nil {
+		return nil, fmt.Errorf("Cannot load certificate %s and key %s: %w",
+			c.CertFile,
+			c.KeyFile,
+			err)
+	}
+
+	cfg.Certificates = []tls.Certificate{cert}
+
+	if c.ClientCAFile == "" {
+		return cfg, nil
+	} else if pem, err = os.ReadFile(c.ClientCAFile); err != nil {
+		return nil, fmt.Errorf("Cannot read client CAs from %s: %w",
+			c.ClientCAFile,
+			err)
+	}
+
+	cfg.ClientCAs = x509.NewCertPool()
+	if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s does not contain any certificates",
+			c.ClientCAFile)
+	}
+
+	if c.RequireClientCert {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	} else {
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return cfg, nil
+} // func (c *TLSConfig) config() (*tls.Config, error)
+
+// SetTLS makes the Server use HTTPS. It has to be called before Run, a nil
+// TLSConfig makes the Server use plain HTTP.
+func (srv *Server) SetTLS(c *TLSConfig) error {
+	var (
+		err error
+		cfg *tls.Config
+	)
+
+	if srv.IsActive() {
+		return errors.New("Cannot change TLS settings of a running Server")
+	} else if c == nil {
+		srv.web.TLSConfig = nil
+		return nil
+	} else if cfg, err = c.config(); err != nil {
+		srv.log.Printf("[ERROR] Invalid TLS settings: %s\n",
+			err.Error())
+		return err
+	}
+
+	srv.web.TLSConfig = cfg
+	return nil
+} // func (srv *Server) SetTLS(c *TLSConfig) error
+
+// checkClientCert makes sure that a client certificate presented with a
+// request was issued for the given Host. Requests without a verified client
+// certificate pass, whether they need one is decided during the handshake.
+func checkClientCert(r *http.Request, h *model.Host) error {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return nil
+	}
+
+	var cert = r.TLS.VerifiedChains[0][0]
+
+	if cert.Subject.CommonName == h.Name || slices.Contains(cert.DNSNames, h.Name) {
+		return nil
+	}
+
+	return fmt.Errorf("Client certificate for %q does not match Host %s",
+		cert.Subject.CommonName,
+		h.Name)
+} // func checkClientCert(r *http.Request, h *model.Host) error
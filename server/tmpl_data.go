@@ -14,6 +14,8 @@ import (
 	"time"
 
 	"github.com/blicero/donkey/common"
+	"github.com/blicero/donkey/model"
+	"github.com/blicero/donkey/model/recordtype"
 
 	"github.com/hashicorp/logutils"
 )
@@ -50,8 +52,27 @@ type tmplDataBase struct { // nolint: unused
 	URL        string
 }
 
+// hostSummary is what the dashboard shows about a Host: the Host itself plus
+// the most recent load average and the hottest temperature sensor, either
+// of which may be nil if the Host has not reported them.
+type hostSummary struct {
+	model.Host
+	Load *model.Load
+	Temp *model.SensorReading
+}
+
 type tmplDataIndex struct { // nolint: unused,deadcode
 	tmplDataBase
+	Hosts []hostSummary
+}
+
+type tmplDataHost struct {
+	tmplDataBase
+	Host   *model.Host
+	Load   *model.Load
+	Events []model.HostEvent
+	Types  []recordtype.ID
+	Ranges []string
 }
 
 // Local Variables:  //
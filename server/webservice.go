@@ -13,6 +13,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"strconv"
 	"time"
@@ -25,7 +26,13 @@ import (
 
 //   URLs für Agent:
 //   /ws/register                    -> handleClientRegister
+//   /ws/report                      -> handleClientReportData
+//   /ws/report/batch                -> handleClientReportBatch
 //   /ws/report/load/{name:(?:\w+$)} -> handleClientReportLoad
+//   /ws/config/{id:(?:\d+$)}        -> handleClientConfig
+//
+//   All but /ws/register require the token issued to the Agent when it
+//   registered, see auth.go.
 
 func (srv *Server) handleClientRegister(w http.ResponseWriter, r *http.Request) {
 	srv.log.Printf("[TRACE] Handle request for %s from %s\n",
@@ -39,7 +46,8 @@ func (srv *Server) handleClientRegister(w http.ResponseWriter, r *http.Request)
 		body         []byte
 		host, dbhost *model.Host
 		msg          string
-		res          model.Response
+		res          model.Registration
+		status       = http.StatusOK
 	)
 
 	if _, err = io.Copy(&buf, r.Body); err != nil {
@@ -61,18 +69,37 @@ func (srv *Server) handleClientRegister(w http.ResponseWriter, r *http.Request)
 		srv.log.Printf("[ERROR] %s\n", msg)
 		res.Message = msg
 		goto SEND_RESPONSE
-	} else if dbhost, err = db.HostGetByName(host.Name); err != nil {
-		res.Message = fmt.Sprintf("Failed to look up host %s in database: %s",
+	} else if host.Addr == "" {
+		// The Agent does not know which of its addresses the Server
+		// sees, so we use the one the request came from.
+		if host.Addr, _, err = net.SplitHostPort(r.RemoteAddr); err != nil {
+			host.Addr = r.RemoteAddr
+		}
+	}
+
+	if err = checkClientCert(r, host); err != nil {
+		res.Message = fmt.Sprintf("Cannot register host %s: %s",
 			host.Name,
 			err.Error())
 		srv.log.Printf("[ERROR] %s\n", res.Message)
+		status = http.StatusForbidden
 		goto SEND_RESPONSE
-	} else if dbhost != nil {
-		res.Message = fmt.Sprintf("Cannot register host %s: Already exists in database (%d)",
+	} else if dbhost, err = db.HostGetByName(host.Name); err != nil {
+		res.Message = fmt.Sprintf("Failed to look up host %s in database: %s",
 			host.Name,
-			dbhost.ID)
+			err.Error())
 		srv.log.Printf("[ERROR] %s\n", res.Message)
 		goto SEND_RESPONSE
+	} else if dbhost != nil {
+		if status, err = srv.mayRegisterAgain(db, r, dbhost); err != nil {
+			res.Message = fmt.Sprintf("Cannot register host %s: Already exists in database (%d): %s",
+				host.Name,
+				dbhost.ID,
+				err.Error())
+			srv.log.Printf("[ERROR] %s\n", res.Message)
+			goto SEND_RESPONSE
+		}
+		host = dbhost
 	} else if err = db.HostAdd(host); err != nil {
 		res.Message = fmt.Sprintf("Error adding host %s to database: %s",
 			host.Name,
@@ -82,7 +109,20 @@ func (srv *Server) handleClientRegister(w http.ResponseWriter, r *http.Request)
 		goto SEND_RESPONSE
 	}
 
+	if err = srv.touchHost(db, host); err != nil {
+		res.Message = fmt.Sprintf("Error updating last contact of host %s: %s",
+			host.Name,
+			err.Error())
+		goto SEND_RESPONSE
+	} else if res.Token, err = srv.issueToken(db, host); err != nil {
+		res.Message = err.Error()
+		srv.log.Printf("[ERROR] %s\n", res.Message)
+		status = http.StatusInternalServerError
+		goto SEND_RESPONSE
+	}
+
 	res.Status = true
+	res.HostID = int64(host.ID)
 	res.Message = strconv.Itoa(int(host.ID))
 
 SEND_RESPONSE:
@@ -96,7 +136,7 @@ SEND_RESPONSE:
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Cache-Control", "no-store, max-age=0")
-	w.WriteHeader(200)
+	w.WriteHeader(status)
 	if _, err = w.Write(rbuf); err != nil {
 		msg = fmt.Sprintf("Failed to send result: %s",
 			err.Error())
@@ -118,6 +158,7 @@ func (srv *Server) handleClientReportData(w http.ResponseWriter, r *http.Request
 		payload model.Record
 		host    *model.Host
 		body    []byte
+		status  = http.StatusOK
 	)
 
 	if _, err = io.Copy(&buf, r.Body); err != nil {
@@ -135,6 +176,13 @@ func (srv *Server) handleClientReportData(w http.ResponseWriter, r *http.Request
 		srv.log.Printf("[ERROR] %s\n", msg)
 		res.Message = msg
 		goto SEND_RESPONSE
+	} else if err = payload.Validate(); err != nil {
+		msg = fmt.Sprintf("Rejecting Record from Host %d: %s",
+			payload.HostID,
+			err.Error())
+		srv.log.Printf("[ERROR] %s\n", msg)
+		res.Message = msg
+		goto SEND_RESPONSE
 	}
 
 	db = srv.pool.Get()
@@ -148,11 +196,19 @@ func (srv *Server) handleClientReportData(w http.ResponseWriter, r *http.Request
 		res.Message = msg
 		goto SEND_RESPONSE
 	} else if host == nil {
+		// The Host may have been deleted. Refusing the request makes
+		// the Agent register again instead of discarding its Records.
 		msg = fmt.Sprintf("Host ID %d was not found in database",
 			payload.HostID)
 		srv.log.Printf("[ERROR] %s\n",
 			msg)
 		res.Message = msg
+		status = http.StatusForbidden
+		goto SEND_RESPONSE
+	} else if status, err = srv.authenticate(db, r, host); err != nil {
+		res.Message = fmt.Sprintf("Rejecting Record from Host %d: %s",
+			payload.HostID,
+			err.Error())
 		goto SEND_RESPONSE
 	} else if err = db.RecordAdd(&payload); err != nil {
 		msg = fmt.Sprintf("Failed to add Record to Database: %s",
@@ -162,11 +218,23 @@ func (srv *Server) handleClientReportData(w http.ResponseWriter, r *http.Request
 		goto SEND_RESPONSE
 	}
 
+	// The Record is already stored, so failing to update the Host's
+	// last contact or to evaluate the alerting rules is not worth
+	// rejecting it over.
+	srv.touchHost(db, host) // nolint: errcheck
+	srv.processAlerts(db, host, &payload)
+
 	res.Message = fmt.Sprintf("Record added to database, ID = %d",
 		payload.ID)
 	res.Status = true
 
 SEND_RESPONSE:
+	if res.Status {
+		srv.stats.count(1, 0)
+	} else {
+		srv.stats.count(0, 1)
+	}
+
 	res.Timestamp = time.Now()
 	var rbuf []byte
 	if rbuf, err = json.Marshal(&res); err != nil {
@@ -177,7 +245,7 @@ SEND_RESPONSE:
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Cache-Control", "no-store, max-age=0")
-	w.WriteHeader(200)
+	w.WriteHeader(status)
 	if _, err = w.Write(rbuf); err != nil {
 		msg = fmt.Sprintf("Failed to send result: %s",
 			err.Error())
@@ -185,6 +253,179 @@ SEND_RESPONSE:
 	}
 } // func (srv *Server) handleClientReportData(w http.ResponseWriter, r *http.Request)
 
+// handleClientReportBatch accepts an array of Records and adds them to the
+// database in a single transaction. The response contains the status of each
+// Record, so the Agent can tell which ones were rejected.
+func (srv *Server) handleClientReportBatch(w http.ResponseWriter, r *http.Request) {
+	srv.log.Printf("[TRACE] Handle request for %s from %s\n",
+		r.URL.EscapedPath(),
+		r.RemoteAddr)
+
+	var (
+		err     error
+		db      *database.Database
+		msg     string
+		buf     bytes.Buffer
+		res     model.BatchResponse
+		payload []model.Record
+		hosts   map[int64]*model.Host
+		stored  []int
+		status  bool
+		code    = http.StatusOK
+		body    []byte
+	)
+
+	if _, err = io.Copy(&buf, r.Body); err != nil {
+		res.Message = fmt.Sprintf("Failed to read HTTP request body: %s",
+			err.Error())
+		srv.log.Printf("[ERROR] %s\n",
+			res.Message)
+		goto SEND_RESPONSE
+	}
+
+	body = buf.Bytes()
+
+	if err = json.Unmarshal(body, &payload); err != nil {
+		msg = fmt.Sprintf("Failed to decode payload: %s", err.Error())
+		srv.log.Printf("[ERROR] %s\n", msg)
+		res.Message = msg
+		goto SEND_RESPONSE
+	} else if len(payload) > model.MaxBatchSize {
+		res.Message = fmt.Sprintf("Batch contains %d Records, the maximum is %d",
+			len(payload),
+			model.MaxBatchSize)
+		srv.log.Printf("[ERROR] %s\n", res.Message)
+		goto SEND_RESPONSE
+	}
+
+	db = srv.pool.Get()
+	defer srv.pool.Put(db)
+
+	if err = db.Begin(); err != nil {
+		res.Message = fmt.Sprintf("Failed to start transaction: %s",
+			err.Error())
+		srv.log.Printf("[ERROR] %s\n", res.Message)
+		goto SEND_RESPONSE
+	}
+
+	defer func() {
+		if status {
+			return
+		} else if err := db.Rollback(); err != nil {
+			srv.log.Printf("[ERROR] Failed to roll back transaction: %s\n",
+				err.Error())
+		}
+	}()
+
+	hosts = make(map[int64]*model.Host)
+	res.Results = make([]model.RecordStatus, len(payload))
+
+	for i := range payload {
+		var (
+			found bool
+			host  *model.Host
+			rec   = &payload[i]
+		)
+
+		if host, found = hosts[rec.HostID]; !found {
+			if host, err = db.HostGetByID(krylib.ID(rec.HostID)); err != nil {
+				res.Results[i].Message = fmt.Sprintf("Failed to look up host by ID %d in database: %s",
+					rec.HostID,
+					err.Error())
+				srv.log.Printf("[ERROR] %s\n", res.Results[i].Message)
+				continue
+			} else if host == nil {
+				res.Message = fmt.Sprintf("Rejecting batch: Host ID %d was not found in database",
+					rec.HostID)
+				srv.log.Printf("[ERROR] %s\n", res.Message)
+				code = http.StatusForbidden
+				res.Results = nil
+				goto SEND_RESPONSE
+			} else if code, err = srv.authenticate(db, r, host); err != nil {
+				// All Records of a batch have to belong to the
+				// Host the token was issued to.
+				res.Message = fmt.Sprintf("Rejecting batch for Host %d: %s",
+					rec.HostID,
+					err.Error())
+				res.Results = nil
+				goto SEND_RESPONSE
+			}
+			hosts[rec.HostID] = host
+		}
+
+		if err = rec.Validate(); err != nil {
+			res.Results[i].Message = fmt.Sprintf("Rejecting Record from Host %d: %s",
+				rec.HostID,
+				err.Error())
+			srv.log.Printf("[ERROR] %s\n", res.Results[i].Message)
+		} else if err = db.RecordAdd(rec); err != nil {
+			res.Results[i].Message = fmt.Sprintf("Failed to add Record to Database: %s",
+				err.Error())
+			srv.log.Printf("[ERROR] %s\n", res.Results[i].Message)
+		} else {
+			res.Results[i].ID = rec.ID
+			res.Results[i].Status = true
+			stored = append(stored, i)
+		}
+	}
+
+	for _, host := range hosts {
+		srv.touchHost(db, host) // nolint: errcheck
+	}
+
+	if err = db.Commit(); err != nil {
+		res.Message = fmt.Sprintf("Failed to commit transaction: %s",
+			err.Error())
+		srv.log.Printf("[ERROR] %s\n", res.Message)
+		res.Results = nil
+		goto SEND_RESPONSE
+	}
+
+	status = true
+	res.Status = true
+	res.Message = fmt.Sprintf("Processed batch of %d Records",
+		len(payload))
+
+	// The alerting rules only get to see the Records once they are
+	// stored. If the commit failed, the Agent would send them again, and
+	// the alert engine would have counted them twice.
+	for _, i := range stored {
+		var rec = &payload[i]
+
+		srv.processAlerts(db, hosts[rec.HostID], rec)
+	}
+
+SEND_RESPONSE:
+	if res.Status {
+		var ok int
+		for _, rs := range res.Results {
+			if rs.Status {
+				ok++
+			}
+		}
+		srv.stats.count(ok, len(res.Results)-ok)
+	} else {
+		srv.stats.count(0, max(len(payload), 1))
+	}
+
+	res.Timestamp = time.Now()
+	var rbuf []byte
+	if rbuf, err = json.Marshal(&res); err != nil {
+		srv.log.Printf("[ERROR] Error serializing response: %s\n",
+			err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store, max-age=0")
+	w.WriteHeader(code)
+	if _, err = w.Write(rbuf); err != nil {
+		msg = fmt.Sprintf("Failed to send result: %s",
+			err.Error())
+		srv.log.Println("[ERROR] " + msg)
+	}
+} // func (srv *Server) handleClientReportBatch(w http.ResponseWriter, r *http.Request)
+
 func (srv *Server) handleClientReportLoad(w http.ResponseWriter, r *http.Request) {
 	srv.log.Printf("[TRACE] Handle request for %s from %s\n",
 		r.URL.EscapedPath(),
@@ -200,6 +441,7 @@ func (srv *Server) handleClientReportLoad(w http.ResponseWriter, r *http.Request
 		host    *model.Host
 		name    string
 		body    []byte
+		status  = http.StatusOK
 	)
 
 	vars := mux.Vars(r)
@@ -236,6 +478,7 @@ func (srv *Server) handleClientReportLoad(w http.ResponseWriter, r *http.Request
 	} else if host == nil {
 		res.Message = fmt.Sprintf("Did not find Host %s in database", name)
 		srv.log.Printf("[ERROR] %s\n", res.Message)
+		status = http.StatusForbidden
 		goto SEND_RESPONSE
 	} else if host.ID != payload.HostID {
 		res.Message = fmt.Sprintf("Mismatched Host ID: Payload says %d, database says %d",
@@ -243,6 +486,11 @@ func (srv *Server) handleClientReportLoad(w http.ResponseWriter, r *http.Request
 			host.ID)
 		srv.log.Printf("[ERROR] %s\n", res.Message)
 		goto SEND_RESPONSE
+	} else if status, err = srv.authenticate(db, r, host); err != nil {
+		res.Message = fmt.Sprintf("Rejecting Load for Host %s: %s",
+			name,
+			err.Error())
+		goto SEND_RESPONSE
 	} else if err = db.LoadAdd(&payload); err != nil {
 		res.Message = fmt.Sprintf("Error adding Load for Host %d (%s) to database: %s",
 			host.ID,
@@ -253,9 +501,16 @@ func (srv *Server) handleClientReportLoad(w http.ResponseWriter, r *http.Request
 		goto SEND_RESPONSE
 	}
 
+	srv.touchHost(db, host) // nolint: errcheck
 	res.Status = true
 
 SEND_RESPONSE:
+	if res.Status {
+		srv.stats.count(1, 0)
+	} else {
+		srv.stats.count(0, 1)
+	}
+
 	res.Timestamp = time.Now()
 	var rbuf []byte
 	if rbuf, err = json.Marshal(&res); err != nil {
@@ -266,10 +521,86 @@ SEND_RESPONSE:
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Cache-Control", "no-store, max-age=0")
-	w.WriteHeader(200)
+	w.WriteHeader(status)
 	if _, err = w.Write(rbuf); err != nil {
 		msg = fmt.Sprintf("Failed to send result: %s",
 			err.Error())
 		srv.log.Println("[ERROR] " + msg)
 	}
 } // func (srv *Server) handleClientReportLoad(w http.ResponseWriter, r *http.Request)
+
+// handleClientConfig sends an Agent the configuration the Server manages for
+// its Host, see fleet.go.
+func (srv *Server) handleClientConfig(w http.ResponseWriter, r *http.Request) {
+	srv.log.Printf("[TRACE] Handle request for %s from %s\n",
+		r.URL.EscapedPath(),
+		r.RemoteAddr)
+
+	var (
+		err    error
+		db     *database.Database
+		msg    string
+		res    model.ConfigResponse
+		host   *model.Host
+		id     int64
+		status = http.StatusOK
+	)
+
+	if id, err = strconv.ParseInt(mux.Vars(r)["id"], 10, 64); err != nil {
+		res.Message = fmt.Sprintf("Invalid Host ID %q: %s",
+			mux.Vars(r)["id"],
+			err.Error())
+		srv.log.Printf("[ERROR] %s\n", res.Message)
+		goto SEND_RESPONSE
+	}
+
+	db = srv.pool.Get()
+	defer srv.pool.Put(db)
+
+	if host, err = db.HostGetByID(krylib.ID(id)); err != nil {
+		res.Message = fmt.Sprintf("Failed to look up host by ID %d in database: %s",
+			id,
+			err.Error())
+		srv.log.Printf("[ERROR] %s\n", res.Message)
+		goto SEND_RESPONSE
+	} else if host == nil {
+		res.Message = fmt.Sprintf("Host ID %d was not found in database",
+			id)
+		srv.log.Printf("[ERROR] %s\n", res.Message)
+		status = http.StatusForbidden
+		goto SEND_RESPONSE
+	} else if status, err = srv.authenticate(db, r, host); err != nil {
+		res.Message = fmt.Sprintf("Refusing configuration to Host %d: %s",
+			id,
+			err.Error())
+		goto SEND_RESPONSE
+	}
+
+	srv.touchHost(db, host) // nolint: errcheck
+	srv.updateInterval(db, host, r.URL.Query().Get("interval"))
+
+	if res.Config = srv.getFleet().configFor(host.Name); res.Config == nil {
+		res.Message = fmt.Sprintf("No configuration for Host %s", host.Name)
+	} else {
+		res.Message = fmt.Sprintf("Configuration for Host %s", host.Name)
+	}
+	res.Status = true
+
+SEND_RESPONSE:
+	res.Timestamp = time.Now()
+	var rbuf []byte
+	if rbuf, err = json.Marshal(&res); err != nil {
+		srv.log.Printf("[ERROR] Error serializing response: %s\n",
+			err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store, max-age=0")
+	w.WriteHeader(status)
+	if _, err = w.Write(rbuf); err != nil {
+		msg = fmt.Sprintf("Failed to send result: %s",
+			err.Error())
+		srv.log.Println("[ERROR] " + msg)
+	}
+} // func (srv *Server) handleClientConfig(w http.ResponseWriter, r *http.Request)